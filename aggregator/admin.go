@@ -0,0 +1,75 @@
+package aggregator
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// adminHandler returns the handler of the admin endpoints, which change the
+// behavior of the aggregator or expose the effective config and the
+// verifications sent to L1, requiring the AdminToken if set.
+func (a *Aggregator) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/config", a.handleEffectiveConfig)
+	mux.HandleFunc("/admin/verifications", a.handleVerificationByTxHash)
+	mux.HandleFunc("/admin/verification/timer/reset", a.handleResetVerificationTimer)
+	mux.HandleFunc("/admin/verification/gasprice", a.handleVerificationGasPrice)
+	mux.HandleFunc("/admin/txs/cancel", a.handleCancelTx)
+	if a.cfg.FailureCircuit.Enabled {
+		mux.HandleFunc("/admin/circuit/resume", a.handleResume)
+	}
+	if a.cfg.ProofRegeneration.Enabled {
+		mux.HandleFunc("/admin/regenerations", a.handleProofRegenerations)
+	}
+	return requireBearerToken(a.cfg.AdminToken, mux)
+}
+
+// requireBearerToken returns a handler rejecting the requests without the
+// token as their bearer token. An empty token lets every request through.
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startAdminServer serves the admin endpoints on AdminHost, the loopback
+// interface by default, apart from the status server so they are not
+// reachable wherever the status is.
+func (a *Aggregator) startAdminServer() {
+	a.adminSrv = &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", a.cfg.AdminHost, a.cfg.AdminPort),
+		Handler: a.adminHandler(),
+	}
+	if a.cfg.AdminToken == "" && !isLoopbackHost(a.cfg.AdminHost) {
+		log.Warnf("Admin server listening on %s without token, it must not be reachable by untrusted clients", a.adminSrv.Addr)
+	}
+	go func() {
+		log.Infof("Admin server listening on port %d", a.cfg.AdminPort)
+		if err := a.adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Failed to serve admin, err: %v", err)
+		}
+	}()
+}
+
+// isLoopbackHost returns whether the host only listens on the loopback
+// interface.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
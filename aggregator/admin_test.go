@@ -0,0 +1,69 @@
+package aggregator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireBearerToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "no token required", expectedStatus: http.StatusOK},
+		{name: "token", token: "s3cr3t", authorization: "Bearer s3cr3t", expectedStatus: http.StatusOK},
+		{name: "missing token", token: "s3cr3t", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "s3cr3t", authorization: "Bearer other", expectedStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", token: "s3cr3t", authorization: "s3cr3t", expectedStatus: http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/verification/gasprice", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			requireBearerToken(tc.token, ok).ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	a := Aggregator{cfg: Config{AdminToken: "s3cr3t"}}
+
+	// the admin endpoints require the token
+	for _, path := range []string{"/admin/verification/gasprice", "/admin/config", "/admin/verifications"} {
+		rec := httptest.NewRecorder()
+		a.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, path)
+	}
+
+	// the regenerations are only served when enabled
+	req := httptest.NewRequest(http.MethodGet, "/admin/regenerations", nil)
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	rec := httptest.NewRecorder()
+	a.adminHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	a.cfg.ProofRegeneration.Enabled = true
+	rec = httptest.NewRecorder()
+	a.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/regenerations", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	assert.True(t, isLoopbackHost("127.0.0.1"))
+	assert.True(t, isLoopbackHost("::1"))
+	assert.True(t, isLoopbackHost("localhost"))
+	assert.False(t, isLoopbackHost("0.0.0.0"))
+	assert.False(t, isLoopbackHost(""))
+	assert.False(t, isLoopbackHost("10.0.0.1"))
+}
@@ -0,0 +1,100 @@
+package aggregator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+type affinityRecord struct {
+	batchNumber uint64
+	provedAt    time.Time
+}
+
+// batchAffinity tracks the last batch proved by each connected prover, so the
+// following batch can be preferentially assigned to it while its caches are
+// warm.
+type batchAffinity struct {
+	mu   sync.RWMutex
+	last map[string]affinityRecord
+}
+
+func newBatchAffinity() *batchAffinity {
+	return &batchAffinity{
+		last: make(map[string]affinityRecord),
+	}
+}
+
+// record stores the batch proved by the prover.
+func (b *batchAffinity) record(proverID string, batchNumber uint64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last[proverID] = affinityRecord{batchNumber: batchNumber, provedAt: time.Now()}
+}
+
+// forget removes the batch proved by the prover, once disconnected.
+func (b *batchAffinity) forget(proverID string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.last, proverID)
+}
+
+// isWarm returns whether the prover has just proved the batch previous to the
+// given one.
+func (b *batchAffinity) isWarm(proverID string, batchNumber uint64) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	r, ok := b.last[proverID]
+	return ok && r.batchNumber+1 == batchNumber
+}
+
+// preferred returns the prover that has proved the batch previous to the
+// given one and when it was proved.
+func (b *batchAffinity) preferred(batchNumber uint64) (string, time.Time, bool) {
+	if b == nil {
+		return "", time.Time{}, false
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for proverID, r := range b.last {
+		if r.batchNumber+1 == batchNumber {
+			return proverID, r.provedAt, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// deferToPreferredProver returns whether the batch must be left for the
+// prover that has proved the previous one. The affinity is soft: the batch is
+// only left while the preferred prover is not busy and for the configured
+// time since it proved the previous batch.
+func (a *Aggregator) deferToPreferredProver(prover proverInterface, batchNumber uint64) bool {
+	wait := a.cfg.BatchAffinityWait.Duration
+	if wait <= 0 {
+		return false
+	}
+	proverID, provedAt, ok := a.affinity.preferred(batchNumber)
+	if !ok || proverID == prover.ID() {
+		return false
+	}
+	if time.Since(provedAt) > wait || a.assignments.isAssigned(proverID) {
+		return false
+	}
+	log.Debugf("Batch %d left for prover [%s] which proved the previous one, prover { ID [%s], name [%s] } not used",
+		batchNumber, proverID, prover.ID(), prover.Name())
+	return true
+}
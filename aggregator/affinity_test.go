@@ -0,0 +1,55 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeferToPreferredProver(t *testing.T) {
+	prover1 := mocks.NewProverMock(t)
+	prover1.On("ID").Return("prover-1").Maybe()
+	prover1.On("Addr").Return("addr-1").Maybe()
+	prover1.On("Name").Return("addr-1").Maybe()
+	prover2 := mocks.NewProverMock(t)
+	prover2.On("ID").Return("prover-2").Maybe()
+	prover2.On("Addr").Return("addr-2").Maybe()
+	prover2.On("Name").Return("addr-2").Maybe()
+
+	a := Aggregator{
+		cfg:         Config{BatchAffinityWait: types.NewDuration(time.Minute)},
+		assignments: newProverAssignments(),
+		affinity:    newBatchAffinity(),
+	}
+	a.affinity.record("prover-1", 5)
+
+	assert.True(t, a.affinity.isWarm("prover-1", 6))
+	assert.False(t, a.affinity.isWarm("prover-2", 6))
+
+	// batch 6 is left for prover-1
+	assert.True(t, a.deferToPreferredProver(prover2, 6))
+	assert.False(t, a.deferToPreferredProver(prover1, 6))
+	// no affinity for other batches
+	assert.False(t, a.deferToPreferredProver(prover2, 7))
+
+	// the preferred prover is busy
+	a.assignments.assign(prover1, ChannelOperationAggregateProofs, 1, 5)
+	assert.False(t, a.deferToPreferredProver(prover2, 6))
+	a.assignments.clear(prover1)
+
+	// the preferred prover disconnected
+	a.affinity.forget("prover-1")
+	assert.False(t, a.deferToPreferredProver(prover2, 6))
+
+	// the wait expired
+	a.affinity.last["prover-1"] = affinityRecord{batchNumber: 5, provedAt: time.Now().Add(-2 * time.Minute)}
+	assert.False(t, a.deferToPreferredProver(prover2, 6))
+
+	// disabled
+	a.affinity.record("prover-1", 5)
+	a.cfg.BatchAffinityWait = types.NewDuration(0)
+	assert.False(t, a.deferToPreferredProver(prover2, 6))
+}
@@ -0,0 +1,130 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// pendingUnlocks keeps the aggregated proofs that couldn't be unlocked once
+// their aggregation was committed, to retry unlocking them before they are
+// lost: the proofs left locked are deleted on restart.
+type pendingUnlocks struct {
+	mu     sync.Mutex
+	proofs map[batchRange]*state.Proof
+}
+
+func newPendingUnlocks() *pendingUnlocks {
+	return &pendingUnlocks{
+		proofs: make(map[batchRange]*state.Proof),
+	}
+}
+
+// add records the proof to be unlocked. It's safe to call it on a nil
+// tracker.
+func (u *pendingUnlocks) add(proof *state.Proof) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.proofs[batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}] = proof
+}
+
+// take removes and returns the proofs to be unlocked.
+func (u *pendingUnlocks) take() []*state.Proof {
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.proofs) == 0 {
+		return nil
+	}
+	proofs := make([]*state.Proof, 0, len(u.proofs))
+	for r, proof := range u.proofs {
+		proofs = append(proofs, proof)
+		delete(u.proofs, r)
+	}
+	return proofs
+}
+
+// finalizeAggregatedProof tries to build the final proof with the aggregated
+// proof just committed. Unless the final proof is built, which takes over the
+// aggregated proof, the aggregated proof is unlocked whatever the outcome, so
+// it's aggregated or verified later.
+func (a *Aggregator) finalizeAggregatedProof(ctx context.Context, prover proverInterface, proof *state.Proof) (bool, error) {
+	finalProofBuilt, err := a.tryBuildFinalProof(ctx, prover, proof)
+	if errors.Is(err, ErrFinalProofDeduplicated) || errors.Is(err, ErrSyncWaitTimeout) {
+		log.Infof("Final proof not built with the recursive proof, err: %v", err)
+		err = nil
+	}
+	if err != nil {
+		err = fmt.Errorf("Failed trying to check if recursive proof can be verified: %w", err)
+	}
+	if finalProofBuilt {
+		return true, nil
+	}
+
+	// the prover is done, store its result even if the prover disconnects
+	err2 := a.unlockAggregatedProof(proof)
+	if err2 != nil {
+		log.Errorf("Aggregated proof %d-%d left locked, recovering it later, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err2)
+		a.pendingUnlocks.add(proof)
+		if err == nil {
+			err = err2
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// unlockAggregatedProof unlocks the aggregated proof, retrying with an
+// exponential backoff if it fails.
+func (a *Aggregator) unlockAggregatedProof(proof *state.Proof) error {
+	ctx := a.serverContext()
+	proof.Generating = false
+
+	interval := a.cfg.AggregatedProofUnlockRetryInterval.Duration
+	for attempt := 0; ; attempt++ {
+		err := a.State.UpdateGeneratedProof(ctx, proof, nil)
+		if err == nil {
+			return nil
+		}
+		if attempt >= a.cfg.AggregatedProofUnlockRetries {
+			return fmt.Errorf("Failed to unlock aggregated proof %d-%d after %d retries, %w", proof.BatchNumber, proof.BatchNumberFinal, attempt, err)
+		}
+		log.Warnf("Failed to unlock aggregated proof %d-%d, retrying in %v, err: %v", proof.BatchNumber, proof.BatchNumberFinal, interval, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// recoverPendingUnlocks unlocks the aggregated proofs left locked, keeping
+// the ones still failing for the next attempt.
+func (a *Aggregator) recoverPendingUnlocks() {
+	for _, proof := range a.pendingUnlocks.take() {
+		proof.Generating = false
+		err := a.State.UpdateGeneratedProof(a.serverContext(), proof, nil)
+		if err != nil {
+			log.Errorf("Failed to recover aggregated proof %d-%d left locked, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+			a.pendingUnlocks.add(proof)
+			continue
+		}
+		log.Infof("Aggregated proof %d-%d left locked recovered", proof.BatchNumber, proof.BatchNumberFinal)
+	}
+}
@@ -0,0 +1,116 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newFinalizeFailureAggregator returns an aggregator whose aggregation of
+// the proofs 1-3 and 4-8 is committed and then fails to build the final
+// proof. The calls to unlock the aggregated proof are left to the test.
+func newFinalizeFailureAggregator(t *testing.T, errFinalize error) (*Aggregator, *mocks.StateMock, *mocks.ProverMock) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	dbTx := mocks.NewDbTxMock(t)
+	a := &Aggregator{
+		cfg: Config{
+			AggregatedProofUnlockRetries:       1,
+			AggregatedProofUnlockRetryInterval: types.NewDuration(time.Millisecond),
+		},
+		State:                   st,
+		Ethman:                  eth,
+		StateDBMutex:            &sync.Mutex{},
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		pendingUnlocks:          newPendingUnlocks(),
+	}
+	a.ctx = context.Background()
+
+	proofID := "proofID"
+	proof1 := &state.Proof{BatchNumber: 1, BatchNumberFinal: 3, Proof: "proof1"}
+	proof2 := &state.Proof{BatchNumber: 4, BatchNumberFinal: 8, Proof: "proof2"}
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	st.On("GetProofsToAggregate", mock.Anything, uint64(0), uint64(0), nil).Return(proof1, proof2, nil)
+	st.On("BeginStateTransaction", mock.Anything).Return(dbTx, nil).Twice()
+	st.On("UpdateGeneratedProof", mock.Anything, proof1, dbTx).Return(nil).Once()
+	st.On("UpdateGeneratedProof", mock.Anything, proof2, dbTx).Return(nil).Once()
+	dbTx.On("Commit", mock.Anything).Return(nil).Twice()
+	prover.On("AggregatedProof", "proof1", "proof2").Return(&proofID, nil)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("aggregated", nil)
+	st.On("DeleteGeneratedProofs", mock.Anything, uint64(1), uint64(8), dbTx).Return(nil).Once()
+	st.On("AddGeneratedProof", mock.Anything, mock.Anything, dbTx).Return(nil).Once()
+
+	// synced, then the final proof fails to be built
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{}, nil).Once()
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(0), nil).Once()
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(nil, errFinalize).Once()
+
+	return a, st, prover
+}
+
+func isAggregatedProof(proof *state.Proof) bool {
+	return proof.BatchNumber == 1 && proof.BatchNumberFinal == 8
+}
+
+func TestTryAggregateProofsFinalizeFailure(t *testing.T) {
+	errFinalize := errors.New("finalize failure")
+	a, st, prover := newFinalizeFailureAggregator(t, errFinalize)
+
+	var unlocked *state.Proof
+	st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(isAggregatedProof), nil).Return(errors.New("unlock failure")).Once()
+	st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(isAggregatedProof), nil).Return(nil).Once().
+		Run(func(args mock.Arguments) {
+			unlocked = args.Get(1).(*state.Proof)
+		})
+
+	aggregated, err := a.tryAggregateProofs(context.Background(), prover)
+	assert.ErrorIs(t, err, errFinalize)
+	assert.False(t, aggregated)
+
+	// the aggregated proof is unlocked and intact after a retry
+	require.NotNil(t, unlocked)
+	assert.False(t, unlocked.Generating)
+	assert.Equal(t, "aggregated", unlocked.Proof)
+	assert.Equal(t, "proofID", *unlocked.ProofID)
+	assert.Equal(t, uint64(1), unlocked.Depth)
+	assert.Nil(t, a.pendingUnlocks.take())
+	// the final proof verification is enabled again
+	assert.False(t, a.verifyingProof)
+}
+
+func TestTryAggregateProofsFinalizeFailureRecovery(t *testing.T) {
+	errFinalize := errors.New("finalize failure")
+	a, st, prover := newFinalizeFailureAggregator(t, errFinalize)
+
+	st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(isAggregatedProof), nil).Return(errors.New("unlock failure")).Times(3)
+
+	aggregated, err := a.tryAggregateProofs(context.Background(), prover)
+	assert.ErrorIs(t, err, errFinalize)
+	assert.False(t, aggregated)
+
+	// the retries are exhausted, the proof is recovered on the next attempts
+	a.recoverPendingUnlocks()
+
+	var unlocked *state.Proof
+	st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(isAggregatedProof), nil).Return(nil).Once().
+		Run(func(args mock.Arguments) {
+			unlocked = args.Get(1).(*state.Proof)
+		})
+	a.recoverPendingUnlocks()
+
+	require.NotNil(t, unlocked)
+	assert.False(t, unlocked.Generating)
+	assert.Equal(t, "aggregated", unlocked.Proof)
+	assert.Nil(t, a.pendingUnlocks.take())
+}
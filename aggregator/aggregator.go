@@ -5,25 +5,58 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math/big"
 	"net"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/events"
 	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
 	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
 	"github.com/0xPolygonHermez/zkevm-node/aggregator/prover"
-	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
 	"github.com/0xPolygonHermez/zkevm-node/log"
 	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/jackc/pgconn"
 	"google.golang.org/grpc"
 	grpchealth "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/peer"
 )
 
-const (
-	mockedStateRoot     = "0x090bcaf734c4f06c93954a827b45a6e8c67b8e0fd1e0a35a1c5982d6961828f9"
-	mockedLocalExitRoot = "0x17c04c3760510b48c6012742c540a81aba4bca2f78b9d14bfd2f123e2e53ea3e"
+// ErrOverlappingProofs is returned when the proofs to aggregate cover
+// overlapping ranges of batches. It's an ErrStateInconsistent.
+var ErrOverlappingProofs = newLifecycleError(ErrStateInconsistent, errors.New("proofs to aggregate overlap"))
+
+// ErrEmptyProof is returned when the prover reports a proof as generated but
+// returns it empty.
+var ErrEmptyProof = errors.New("prover returned an empty proof")
+
+// ErrNilProof is returned when the prover reports a proof as requested or
+// generated but returns none.
+var ErrNilProof = errors.New("prover returned a nil proof")
+
+// ErrProofTimeout is returned when the prover doesn't generate a proof
+// within its timeout. The prover is considered hung and its connection is
+// closed.
+var ErrProofTimeout = errors.New("proof generation timed out")
+
+// ErrSyncWaitTimeout is returned when the synchronizer doesn't catch up with
+// the batches verified on L1 within MaxSyncWaitTime.
+var ErrSyncWaitTimeout = errors.New("synchronizer not synced in time")
+
+// ErrVerificationReorged is returned when the tx verifying a final proof is
+// no longer found on L1 while waiting for its confirmations.
+var ErrVerificationReorged = errors.New("verification reorged out")
+
+var (
+	// ErrIncompleteSequences is returned when a proof doesn't contain
+	// complete sequences, so it can't be verified. It's an
+	// ErrProofNotEligible.
+	ErrIncompleteSequences = newLifecycleError(ErrProofNotEligible, errors.New("proof doesn't contain complete sequences"))
+	// ErrCompleteSequencesCheckTransient is returned when checking if a proof
+	// contains complete sequences fails for a transient reason, the check
+	// can be retried.
+	ErrCompleteSequencesCheckTransient = errors.New("transient error checking if proof contains complete sequences")
 )
 
 type finalProofMsg struct {
@@ -49,6 +82,69 @@ type Aggregator struct {
 	finalProof     chan finalProofMsg
 	verifyingProof bool
 
+	// serializationSem bounds the number of input provers being serialized
+	// at the same time
+	serializationSem chan struct{}
+
+	events eventPublisher
+
+	verifications *verificationHistory
+
+	leaderLock leaderLock
+	leader     int32
+	leadership *leadership
+
+	quietPeriodOver int32
+
+	dbUnhealthy int32
+
+	assignments *proverAssignments
+	statusSrv   *http.Server
+	adminSrv    *http.Server
+
+	held *heldWorks
+
+	shedder *loadShedder
+
+	circuit *failureCircuit
+
+	throughput *throughputTracker
+
+	affinity *batchAffinity
+
+	preferences *operationPreferences
+
+	verificationWindow *verificationWindow
+
+	gasPrices *gasPriceOverrides
+
+	dryRun *dryRun
+
+	cooldown *submissionCooldown
+
+	submissions *finalProofSubmissions
+
+	stateRootCheck *verifiedStateRootCheck
+
+	batchClaims *batchProofClaims
+
+	finalProofBuilds *finalProofBuilds
+
+	pendingUnlocks *pendingUnlocks
+
+	drain *finalProofDrain
+
+	proverLoads *proverLoads
+
+	batchFilter *batchFilter
+
+	provers  *proverConnectivity
+	l1Health *l1Health
+
+	reorg *verifiedBatchReorg
+
+	l1Frontier *l1VerifiedFrontier
+
 	srv  *grpc.Server
 	ctx  context.Context
 	exit context.CancelFunc
@@ -60,7 +156,73 @@ func New(
 	stateInterface stateInterface,
 	ethTxManager ethTxManager,
 	etherman etherman,
+	leaderLock leaderLock,
 ) (Aggregator, error) {
+	if len(cfg.ChannelOperationsOrder) == 0 {
+		cfg.ChannelOperationsOrder = defaultChannelOperationsOrder
+	}
+	if err := validateChannelOperationsOrder(cfg.ChannelOperationsOrder); err != nil {
+		return Aggregator{}, fmt.Errorf("Invalid channel operations order, %w", err)
+	}
+	switch cfg.ProverSelection {
+	case "":
+		cfg.ProverSelection = ProverSelectionFirstCome
+	case ProverSelectionFirstCome, ProverSelectionLeastLoaded:
+	default:
+		return Aggregator{}, fmt.Errorf("Invalid prover selection policy %q", cfg.ProverSelection)
+	}
+	switch cfg.BatchL2DataCompression {
+	case "":
+		cfg.BatchL2DataCompression = BatchL2DataCompressionNone
+	case BatchL2DataCompressionNone, BatchL2DataCompressionGzip:
+	default:
+		return Aggregator{}, fmt.Errorf("Invalid batch L2 data compression %q", cfg.BatchL2DataCompression)
+	}
+	if err := validateGRPCServerConfig(cfg); err != nil {
+		return Aggregator{}, fmt.Errorf("Invalid gRPC server config, %w", err)
+	}
+	switch cfg.ProofReadyToVerifyPreference {
+	case "":
+		cfg.ProofReadyToVerifyPreference = state.ProofPreferenceLargest
+	case state.ProofPreferenceLargest, state.ProofPreferenceSmallest:
+	default:
+		return Aggregator{}, fmt.Errorf("Invalid proof ready to verify preference %q", cfg.ProofReadyToVerifyPreference)
+	}
+	switch cfg.ProofRegeneration.Priority {
+	case "":
+		cfg.ProofRegeneration.Priority = ProofRegenerationPriorityLow
+	case ProofRegenerationPriorityHigh, ProofRegenerationPriorityLow:
+	default:
+		return Aggregator{}, fmt.Errorf("Invalid proof regeneration priority %q", cfg.ProofRegeneration.Priority)
+	}
+
+	if cfg.MinBatchesToVerify > 1 && cfg.IntervalAfterWhichBatchConsolidateAnyway.Duration == 0 {
+		return Aggregator{}, fmt.Errorf("MinBatchesToVerify requires IntervalAfterWhichBatchConsolidateAnyway to be set")
+	}
+
+	batchFilter, err := newBatchFilter(cfg.BatchFilter)
+	if err != nil {
+		return Aggregator{}, fmt.Errorf("Invalid batch filter, %w", err)
+	}
+
+	stateInterface, err = newProofStore(cfg.ProofStore, stateInterface)
+	if err != nil {
+		return Aggregator{}, err
+	}
+
+	if cfg.ProofCacheSize > 0 {
+		stateInterface = newProofCache(stateInterface, cfg.ProofCacheSize)
+	}
+
+	l1Health := &l1Health{}
+	etherman = &l1HealthEtherman{etherman: etherman, health: l1Health}
+
+	if cfg.L1RateLimit.RequestsPerSecond > 0 {
+		limiter := newL1RateLimiter(cfg.L1RateLimit)
+		etherman = &rateLimitedEtherman{etherman: etherman, limiter: limiter}
+		ethTxManager = &rateLimitedEthTxManager{ethTxManager: ethTxManager, limiter: limiter}
+	}
+
 	var profitabilityChecker aggregatorTxProfitabilityChecker
 	switch cfg.TxProfitabilityCheckerType {
 	case ProfitabilityBase:
@@ -79,13 +241,102 @@ func New(
 		StateDBMutex:            &sync.Mutex{},
 		TimeSendFinalProofMutex: &sync.RWMutex{},
 
-		finalProof: make(chan finalProofMsg),
+		finalProof:    make(chan finalProofMsg),
+		verifications: newVerificationHistory(cfg.VerificationHistorySize),
+		assignments:   newProverAssignments(),
+		held:          newHeldWorks(),
+		affinity:      newBatchAffinity(),
+		preferences:   newOperationPreferences(),
+		gasPrices:     newGasPriceOverrides(),
+		batchClaims:   newBatchProofClaims(cfg.MaxConcurrentBatchProofs),
+
+		pendingUnlocks: newPendingUnlocks(),
+		drain:          newFinalProofDrain(),
+		proverLoads:    newProverLoads(),
+		batchFilter:    batchFilter,
+		provers:        newProverConnectivity(time.Now()),
+		l1Health:       l1Health,
+		l1Frontier:     newL1VerifiedFrontier(cfg.RetryTime.Duration),
+	}
+
+	if cfg.LeaderElection.Enabled {
+		if leaderLock == nil {
+			return Aggregator{}, fmt.Errorf("Leader election enabled without a leader lock")
+		}
+		a.leaderLock = leaderLock
+		a.leadership = &leadership{}
+	}
+
+	if cfg.VerificationWindow.Window.Duration > 0 {
+		a.verificationWindow = newVerificationWindow(cfg.VerificationWindow)
+	}
+
+	if cfg.ThroughputWindow.Duration > 0 {
+		a.throughput = newThroughputTracker(cfg.ThroughputWindow.Duration)
+	}
+
+	if cfg.ProverKeepalive.Interval.Duration > 0 && cfg.ProverKeepalive.Timeout.Duration <= 0 {
+		return Aggregator{}, fmt.Errorf("Prover keepalive enabled without a timeout")
+	}
+
+	if cfg.LoadShedding.Enabled {
+		if cfg.LoadShedding.CheckInterval.Duration <= 0 {
+			return Aggregator{}, fmt.Errorf("Load shedding enabled without a check interval")
+		}
+		a.shedder = newLoadShedder(cfg.LoadShedding)
+	}
+
+	if cfg.FailureCircuit.Enabled {
+		if cfg.FailureCircuit.Window.Duration <= 0 {
+			return Aggregator{}, fmt.Errorf("Failure circuit enabled without a window")
+		}
+		if cfg.FailureCircuit.Threshold <= 0 || cfg.FailureCircuit.Threshold >= 1 {
+			return Aggregator{}, fmt.Errorf("Invalid failure circuit threshold %v, it must be between 0 and 1", cfg.FailureCircuit.Threshold)
+		}
+		a.circuit = newFailureCircuit(cfg.FailureCircuit)
+	}
+
+	if cfg.DryRun {
+		a.dryRun = newDryRun(ethTxManager)
+	}
+
+	if cfg.FinalProofSubmissionCooldown.Duration > 0 {
+		a.cooldown = newSubmissionCooldown(cfg.FinalProofSubmissionCooldown.Duration)
+	}
+
+	if cfg.RecheckBeforeSendingFinalProof {
+		a.submissions = newFinalProofSubmissions()
+	}
+
+	if cfg.CheckVerifiedStateRoot {
+		a.stateRootCheck = newVerifiedStateRootCheck(etherman)
+	}
+
+	if cfg.DeduplicateFinalProofs {
+		a.finalProofBuilds = newFinalProofBuilds()
+	}
+
+	if cfg.DetectVerifiedBatchReorgs {
+		a.reorg = newVerifiedBatchReorg()
+	}
+
+	if cfg.MaxConcurrentSerializations > 0 {
+		a.serializationSem = make(chan struct{}, cfg.MaxConcurrentSerializations)
+	}
+
+	if cfg.Events.Enabled {
+		publisher, err := events.NewNATSPublisher(cfg.Events)
+		if err != nil {
+			return Aggregator{}, fmt.Errorf("Failed to create events publisher, %w", err)
+		}
+		a.events = publisher
 	}
 
 	return a, nil
 }
 
-// Start starts the aggregator
+// Start starts the aggregator. It returns the context error once the context
+// is canceled, including when it's canceled before or during the startup.
 func (a *Aggregator) Start(ctx context.Context) error {
 	var cancel context.CancelFunc
 	if ctx == nil {
@@ -95,66 +346,244 @@ func (a *Aggregator) Start(ctx context.Context) error {
 	a.ctx = ctx
 	a.exit = cancel
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	metrics.Register()
 
-	// Delete ungenerated recursive proofs
-	err := a.State.DeleteUngeneratedProofs(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("Failed to initialize proofs cache %w", err)
+	log.Infow("Aggregator effective config", "config", a.EffectiveConfig())
+
+	if err := a.checkChainIDs(ctx); err != nil {
+		return err
+	}
+
+	if a.leaderLock == nil {
+		// Recover the recursive proofs locked before restarting
+		err := a.recoverGeneratingProofs(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed to initialize proofs cache %w", err)
+		}
+	} else {
+		// the proofs are reclaimed once elected as leader, so the ones being
+		// generated by the current leader are kept while in standby
+		a.setLeader(false)
+		go a.runLeaderElection(ctx)
 	}
 
 	address := fmt.Sprintf("%s:%d", a.cfg.Host, a.cfg.Port)
-	lis, err := net.Listen("tcp", address)
+	var lc net.ListenConfig
+	lis, err := lc.Listen(ctx, "tcp", address)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		cancel()
+		return fmt.Errorf("Failed to listen on %s, %w", address, err)
+	}
+	if ctx.Err() != nil {
+		if err := lis.Close(); err != nil {
+			log.Errorf("Failed to close listener, err: %v", err)
+		}
+		return ctx.Err()
 	}
 
-	a.srv = grpc.NewServer()
+	a.logGRPCServerSettings()
+	a.srv = grpc.NewServer(a.serverOptions()...)
 	pb.RegisterAggregatorServiceServer(a.srv, a)
 
-	healthService := newHealthChecker()
+	healthService := newHealthChecker(a.isDBHealthy, a.hasConnectedProvers, a.isL1Healthy)
+	healthService.watchInterval = a.cfg.HealthCheck.WatchInterval.Duration
+	healthService.done = ctx.Done()
 	grpchealth.RegisterHealthServer(a.srv, healthService)
 
+	serveErr := make(chan error, 1)
 	go func() {
 		log.Infof("Server listening on port %d", a.cfg.Port)
-		if err := a.srv.Serve(lis); err != nil {
-			a.exit()
-			log.Fatalf("Failed to serve: %v", err)
-		}
+		serveErr <- a.srv.Serve(lis)
 	}()
 
-	a.resetVerifyProofTime()
+	if a.cfg.StatusPort != 0 {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/status/provers", a.handleProverAssignments)
+		mux.HandleFunc("/status/throughput", a.handleThroughput)
+		mux.HandleFunc("/status/verification", a.handleVerificationAccumulation)
+		mux.HandleFunc("/status/circuit", a.handleFailureCircuit)
+		mux.HandleFunc("/status/verification/timer", a.handleVerificationTimer)
+		a.statusSrv = &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", a.cfg.Host, a.cfg.StatusPort),
+			Handler: mux,
+		}
+		go func() {
+			log.Infof("Status server listening on port %d", a.cfg.StatusPort)
+			if err := a.statusSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Errorf("Failed to serve status, err: %v", err)
+			}
+		}()
+	}
+
+	if a.cfg.AdminPort != 0 {
+		a.startAdminServer()
+	}
+
+	if a.cfg.ProofRegeneration.Enabled {
+		a.reportRegenerationQueueDepth(ctx)
+	}
+
+	if a.leaderLock == nil {
+		a.resumeVerifyProofTime(ctx)
+		err = a.resumeVerifiedProofs(ctx)
+		if err != nil {
+			log.Errorf("Failed to resume the clean up of the verified proofs, err: %v", err)
+		}
+	}
+
+	if a.shedder != nil {
+		go a.shedder.run(ctx)
+	}
+
+	if a.cfg.DBHealthCheckInterval.Duration > 0 {
+		go a.runDBHealthCheck(ctx)
+	}
+
+	if a.throughput != nil {
+		go a.runThroughputMetrics(ctx)
+	}
+
+	if a.cfg.StartupQuietPeriod.Duration > 0 {
+		go a.runQuietPeriod(ctx, a.isSynced)
+	}
 
 	go a.sendFinalProof()
 
-	<-ctx.Done()
-	return ctx.Err()
+	select {
+	case <-ctx.Done():
+		// stop serving on the listener, as the context may be canceled
+		// without calling Stop
+		a.srv.Stop()
+		return ctx.Err()
+	case err := <-serveErr:
+		cancel()
+		if err == nil {
+			// stopped by Stop
+			return ctx.Err()
+		}
+		return fmt.Errorf("Failed to serve, %w", err)
+	}
 }
 
-// Stop stops the Aggregator server.
+// Stop stops the Aggregator server. The final proof being sent to L1 is given
+// up to the shutdown timeout to finish, and is rolled back if it doesn't.
 func (a *Aggregator) Stop() {
-	a.exit()
-	a.srv.Stop()
+	timeout := a.cfg.ShutdownTimeout.Duration
+	var inFlight *state.Proof
+	if timeout > 0 {
+		inFlight = a.drain.drain(timeout)
+		if inFlight != nil {
+			log.Warnf("Final proof for batches [%d-%d] still being sent after %v, rolling it back",
+				inFlight.BatchNumber, inFlight.BatchNumberFinal, timeout)
+		}
+	}
+	if a.exit != nil {
+		a.exit()
+	}
+	if a.srv != nil {
+		a.stopServer(timeout)
+	}
+	if inFlight != nil {
+		a.rollbackFinalProof(inFlight)
+	}
+	if a.statusSrv != nil {
+		if err := a.statusSrv.Close(); err != nil {
+			log.Errorf("Failed to stop status server, err: %v", err)
+		}
+	}
+	if a.adminSrv != nil {
+		if err := a.adminSrv.Close(); err != nil {
+			log.Errorf("Failed to stop admin server, err: %v", err)
+		}
+	}
+	if a.events != nil {
+		a.events.Close()
+	}
+}
+
+// ReloadProfitabilityParams applies the profitability parameters of the
+// provided config to the running profitability checker. Only the base checker
+// has parameters to reload.
+func (a *Aggregator) ReloadProfitabilityParams(cfg Config) {
+	pc, ok := a.ProfitabilityChecker.(*TxProfitabilityCheckerBase)
+	if !ok {
+		return
+	}
+	log.Infof("Reloading profitability parameters, min reward: %v, interval: %v",
+		cfg.TxProfitabilityMinReward.Int, cfg.IntervalAfterWhichBatchConsolidateAnyway.Duration)
+	pc.SetParams(cfg.IntervalAfterWhichBatchConsolidateAnyway.Duration, cfg.TxProfitabilityMinReward.Int)
+}
+
+// ReloadBatchFilter applies the batch filter of the provided config. An
+// invalid filter is logged and the filter in use is kept.
+func (a *Aggregator) ReloadBatchFilter(cfg Config) {
+	if err := a.batchFilter.set(cfg.BatchFilter); err != nil {
+		log.Errorf("Invalid batch filter, keeping the one in use, err: %v", err)
+		return
+	}
+	log.Infof("Reloading batch filter, allowed batches: %v, denied batches: %v",
+		cfg.BatchFilter.AllowedBatches, cfg.BatchFilter.DeniedBatches)
+}
+
+// proverContext returns the context for the work done with a prover. It is
+// canceled when the prover stream is closed or when the aggregator stops.
+func (a *Aggregator) proverContext(streamCtx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(streamCtx)
+	go func() {
+		select {
+		case <-a.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// serverContext returns the context bound to the aggregator lifecycle. It
+// must be used for the operations that have to complete regardless of the
+// prover, like storing its results or releasing the proofs locked for it.
+func (a *Aggregator) serverContext() context.Context {
+	return a.ctx
 }
 
 // Channel implements the bi-directional communication channel between the
 // Prover client and the Aggregator server.
 func (a *Aggregator) Channel(stream pb.AggregatorService_ChannelServer) error {
-	metrics.ConnectedProver()
-	defer metrics.DisconnectedProver()
+	ctx, cancel := a.proverContext(stream.Context())
+	defer cancel()
 
-	ctx := stream.Context()
 	var proverAddr net.Addr
 	p, ok := peer.FromContext(ctx)
 	if ok {
 		proverAddr = p.Addr
 	}
-	prover, err := prover.New(stream, proverAddr, a.cfg.ProofStatePollingInterval)
+	prover, err := prover.New(stream, proverAddr, a.cfg.ProofStatePollingInterval, a.cfg.ProofTTL, a.cfg.ProverKeepalive.Interval, a.cfg.ProverKeepalive.Timeout)
 	if err != nil {
 		return err
 	}
 
-	log.Debugf("Establishing stream connection with prover ID [%s], addr [%s]", prover.ID(), prover.Addr())
+	capabilities := capabilitiesLabel(prover)
+	metrics.ConnectedProver(prover.Version(), capabilities)
+	defer metrics.DisconnectedProver(prover.Version(), capabilities)
+	a.provers.connect()
+	defer func() { a.provers.disconnect(time.Now()) }()
+
+	log.Debugf("Establishing stream connection with prover ID [%s], name [%s], addr [%s]", prover.ID(), prover.Name(), prover.Addr())
+	defer a.assignments.clear(prover)
+	defer a.affinity.forget(prover.ID())
+
+	a.preferences.register(prover)
+	defer a.preferences.forget(prover.ID())
+	defer a.proverLoads.forget(prover.ID())
+
+	a.resumeHeldWork(ctx, prover)
 
 	for {
 		select {
@@ -166,25 +595,97 @@ func (a *Aggregator) Channel(stream pb.AggregatorService_ChannelServer) error {
 			return ctx.Err()
 
 		default:
-			if !prover.IsIdle() {
-				log.Debugf("Prover { ID [%s], addr [%s] } is not idle", prover.ID(), prover.Addr())
+			if err := prover.Err(); err != nil {
+				log.Warnf("Prover { ID [%s], name [%s] } connection is dead, closing it, err: %v", prover.ID(), prover.Name(), err)
+				return err
+			}
+
+			if hold := a.proverHold(); hold != "" {
+				log.Debugf("%s, prover { ID [%s], name [%s] } kept idle", hold, prover.ID(), prover.Name())
 				time.Sleep(a.cfg.RetryTime.Duration)
 				continue
 			}
 
-			_, err := a.tryBuildFinalProof(ctx, prover, nil)
-			if err != nil {
-				log.Errorf("Error checking proofs to verify: %v", err)
+			idle, load := prover.IdleLoad()
+			if !idle {
+				log.Debugf("Prover { ID [%s], name [%s] } is not idle", prover.ID(), prover.Name())
+				a.proverLoads.forget(prover.ID())
+				time.Sleep(a.cfg.RetryTime.Duration)
+				continue
 			}
+			a.reportProverLoad(prover, load)
 
-			proofGenerated, err := a.tryAggregateProofs(ctx, prover)
-			if err != nil {
-				log.Errorf("Error trying to aggregate proofs: %v", err)
-			}
-			if !proofGenerated {
-				proofGenerated, err = a.tryGenerateBatchProof(ctx, prover)
-				if err != nil {
-					log.Errorf("Error trying to generate proof: %v", err)
+			proofGenerated := false
+			for _, op := range a.channelOperationsOrder(prover) {
+				if a.unableToPerform(prover, op) || a.deferToPreferringProver(prover, op) || a.deferToLessLoadedProver(prover, op) {
+					continue
+				}
+				var opErr error
+				switch op {
+				case ChannelOperationBuildFinalProof:
+					proofBuilt, err := a.tryBuildFinalProof(ctx, prover, nil)
+					if errors.Is(err, ErrFinalProofDeduplicated) {
+						log.Infof("Final proof not built, err: %v", err)
+						err = nil
+					}
+					if err != nil {
+						log.Errorf("Error checking proofs to verify: %v", err)
+					}
+					opErr = err
+					a.recordOutcome(ctx, proofBuilt, err)
+					if proofBuilt {
+						a.recordRouting(prover, op)
+					}
+				case ChannelOperationAggregateProofs:
+					if proofGenerated {
+						continue
+					}
+					proofGenerated, err = a.tryAggregateProofs(ctx, prover)
+					if err != nil {
+						log.Errorf("Error trying to aggregate proofs: %v", err)
+					}
+					opErr = err
+					a.recordOutcome(ctx, proofGenerated, err)
+					if proofGenerated {
+						a.recordRouting(prover, op)
+					}
+				case ChannelOperationGenerateBatchProof:
+					if proofGenerated {
+						continue
+					}
+					proofGenerated, err = a.tryGenerateBatchProof(ctx, prover)
+					if err != nil {
+						log.Errorf("Error trying to generate proof: %v", err)
+					}
+					opErr = err
+					a.recordOutcome(ctx, proofGenerated, err)
+					if proofGenerated {
+						a.recordRouting(prover, op)
+					}
+				case ChannelOperationRegenerateProof:
+					if proofGenerated {
+						continue
+					}
+					proofGenerated, err = a.tryRegenerateProof(ctx, prover)
+					if err != nil {
+						log.Errorf("Error trying to regenerate proof: %v", err)
+					}
+					opErr = err
+					a.recordOutcome(ctx, proofGenerated, err)
+					if proofGenerated {
+						a.recordRouting(prover, op)
+					}
+				}
+				a.assignments.clear(prover)
+				if errors.Is(opErr, ErrProofTimeout) {
+					// the prover is hung, make it reconnect
+					log.Warnf("Prover { ID [%s], name [%s] } flagged as hung, closing its connection, err: %v", prover.ID(), prover.Name(), opErr)
+					return opErr
+				}
+				if errors.Is(opErr, ErrProverNotResponding) || errors.Is(opErr, ErrStateInconsistent) {
+					// the following operations would fail the same way, wait
+					// before retrying
+					break
 				}
 			}
 			if !proofGenerated {
@@ -195,75 +696,89 @@ func (a *Aggregator) Channel(stream pb.AggregatorService_ChannelServer) error {
 	}
 }
 
-// This function waits to receive a final proof from a prover. Once it receives
-// the proof, it performs these steps in order:
-// - send the final proof to L1
-// - wait for the synchronizer to catch up
-// - clean up the cache of recursive proofs
-func (a *Aggregator) sendFinalProof() {
-	for {
-		select {
-		case <-a.ctx.Done():
-			return
-		case msg := <-a.finalProof:
-			ctx := a.ctx
-			proof := msg.recursiveProof
-
-			log.Infof("Verifying final proof with ethereum smart contract, batches %d-%d", proof.BatchNumber, proof.BatchNumberFinal)
-
-			finalBatch, err := a.State.GetBatchByNumber(ctx, proof.BatchNumberFinal, nil)
-			if err != nil {
-				log.Errorf("Failed to retrieve batch with number [%d]", proof.BatchNumberFinal)
-				a.enableProofVerification()
-				continue
-			}
+// recoverGeneratingProofs unlocks the proofs left locked by a previous run,
+// keeping the ones already generated so they are not proven again, and
+// deletes the ones that were still being generated.
+func (a *Aggregator) recoverGeneratingProofs(ctx context.Context) error {
+	recovered, deleted, err := a.State.RecoverGeneratingProofs(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if recovered > 0 || deleted > 0 {
+		log.Infof("Proofs left locked: %d generated proofs recovered, %d ungenerated proofs deleted", recovered, deleted)
+	}
+	return nil
+}
 
-			inputs := ethmanTypes.FinalProofInputs{
-				FinalProof:       msg.finalProof,
-				NewLocalExitRoot: finalBatch.LocalExitRoot.Bytes(),
-				NewStateRoot:     finalBatch.StateRoot.Bytes(),
-			}
+// resumeVerifiedProofs resumes the clean up of the proofs verified on L1
+// before a restart, deleting them once the synchronizer catches up with the
+// verification. The proof verification is held meanwhile, so the proofs
+// following them are not sent before the state is synced.
+func (a *Aggregator) resumeVerifiedProofs(ctx context.Context) error {
+	proofs, err := a.State.GetVerifiedProofs(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to get verified proofs, %w", err)
+	}
+	if len(proofs) == 0 {
+		return nil
+	}
 
-			log.Infof("Final proof inputs: NewLocalExitRoot [%#x], NewStateRoot [%#x]", inputs.NewLocalExitRoot, inputs.NewStateRoot)
+	a.TimeSendFinalProofMutex.Lock()
+	a.verifyingProof = true
+	a.TimeSendFinalProofMutex.Unlock()
 
-			tx, err := a.EthTxManager.VerifyBatches(ctx, proof.BatchNumber-1, proof.BatchNumberFinal, &inputs)
+	go func() {
+		log.Infof("%d proofs verified before restarting, waiting for the network to be synced to clean them up", len(proofs))
+		err := a.waitForSync(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// the clean up is resumed on the next restart
+			log.Warnf("Proofs verified before restarting not cleaned up, err: %v", err)
+			a.resetVerifyProofTime()
+			return
+		}
+		for _, proof := range proofs {
+			err := a.deleteVerifiedProofs(ctx, proof.BatchNumber, proof.BatchNumberFinal)
 			if err != nil {
-				log.Errorf("Error verifiying final proof for batches [%d-%d], err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
-
-				// unlock the underlying proof (generating=false)
-				proof.Generating = false
-				err := a.State.UpdateGeneratedProof(ctx, proof, nil)
-				if err != nil {
-					log.Errorf("Rollback failed updating proof state (false) for proof ID [%v], err: %v", proof.ProofID, err)
-				}
-				a.enableProofVerification()
-				continue
-			}
-
-			log.Infof("Final proof for batches [%d-%d] verified in transaction [%v]", proof.BatchNumber, proof.BatchNumberFinal, tx.Hash())
-
-			// wait for the synchronizer to catch up the verified batches
-			log.Debug("A final proof has been sent, waiting for the network to be synced")
-			for !a.isSynced(a.ctx) {
-				log.Info("Waiting for synchronizer to sync...")
-				time.Sleep(a.cfg.RetryTime.Duration)
+				log.Errorf("Failed to delete verified proof for batches [%d-%d], err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
 			}
+		}
+		a.resetVerifyProofTime()
+	}()
+	return nil
+}
 
-			a.resetVerifyProofTime()
+// getFinalBatch returns the last batch of a final proof. The batch can be
+// missing for a while if the state is lagging, so the read is retried with an
+// exponential backoff to avoid dropping the final proof already built.
+func (a *Aggregator) getFinalBatch(ctx context.Context, batchNumber uint64) (*state.Batch, error) {
+	interval := a.cfg.FinalBatchRetryInterval.Duration
+	for attempt := 0; ; attempt++ {
+		batch, err := a.State.GetBatchByNumber(ctx, batchNumber, nil)
+		if err == nil {
+			return batch, nil
+		}
+		if attempt >= a.cfg.FinalBatchRetries {
+			return nil, fmt.Errorf("Failed to get batch [%d] after %d retries, %w", batchNumber, attempt, err)
+		}
+		log.Warnf("Failed to retrieve batch with number [%d], retrying in %v, err: %v", batchNumber, interval, err)
 
-			// network is synced with the final proof, we can safely delete the recursive proofs
-			err = a.State.DeleteGeneratedProofs(ctx, proof.BatchNumber, proof.BatchNumberFinal, nil)
-			if err != nil {
-				log.Errorf("Failed to store proof aggregation result, err: %v", err)
-			}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
 		}
+		interval *= 2
 	}
 }
 
-// buildFinalProof builds and return the final proof for an aggregated/batch proof.
+// buildFinalProof builds and return the final proof for an aggregated/batch
+// proof. The final proof returned is never nil if the error is nil.
 func (a *Aggregator) buildFinalProof(ctx context.Context, prover proverInterface, proof *state.Proof) (*pb.FinalProof, error) {
-	log.Infof("Prover { ID[%s], addr[%s] }  is going to be used to generate final proof for batches [%d-%d]",
-		prover.ID(), prover.Addr(), proof.BatchNumber, proof.BatchNumberFinal)
+	log.Infof("Prover { ID [%s], name [%s] } is going to be used to generate final proof for batches [%d-%d]",
+		prover.ID(), prover.Name(), proof.BatchNumber, proof.BatchNumberFinal)
 
 	pubAddr, err := a.Ethman.GetPublicAddress()
 	if err != nil {
@@ -274,27 +789,39 @@ func (a *Aggregator) buildFinalProof(ctx context.Context, prover proverInterface
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get final proof id, %w", err)
 	}
+	if finalProofID == nil {
+		return nil, fmt.Errorf("Failed to get final proof id, %w", ErrNilProof)
+	}
 
 	proof.ProofID = finalProofID
 
 	log.Infof("Final proof ID for batches [%d-%d]: %s", proof.BatchNumber, proof.BatchNumberFinal, *proof.ProofID)
 
-	finalProof, err := prover.WaitFinalProof(ctx, *proof.ProofID)
+	waitCtx, cancel := proofTimeoutContext(ctx, a.proofTimeout(a.cfg.FinalProofTimeout.Duration))
+	defer cancel()
+	start := time.Now()
+	finalProof, err := prover.WaitFinalProof(waitCtx, *proof.ProofID)
+	metrics.ProofWaited(metrics.ProofTypeFinal, proverIdentity(prover), time.Since(start), err == nil && finalProof != nil && !isEmptyFinalProof(finalProof))
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get final proof from prover, %w", err)
+		return nil, fmt.Errorf("Failed to get final proof from prover, %w", checkProofTimeout(ctx, waitCtx, err))
+	}
+	if finalProof == nil {
+		return nil, fmt.Errorf("Failed to get final proof %s from prover, %w", *proof.ProofID, ErrNilProof)
+	}
+	if isEmptyFinalProof(finalProof) {
+		return nil, fmt.Errorf("Failed to get final proof %s from prover, %w", *proof.ProofID, ErrEmptyProof)
 	}
 
 	log.Infof("Final proof [%s] generated", *proof.ProofID)
 
-	// mock prover sanity check
-	if string(finalProof.Public.NewStateRoot) == mockedStateRoot && string(finalProof.Public.NewLocalExitRoot) == mockedLocalExitRoot {
-		// This local exit root and state root come from the mock
-		// prover, use the one captured by the executor instead
+	if a.cfg.UseMockProverValues {
+		// the local exit root and state root of a mock prover are not the
+		// ones of the batch, use the ones captured by the executor instead
 		finalBatch, err := a.State.GetBatchByNumber(ctx, proof.BatchNumberFinal, nil)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to retrieve batch with number [%d]", proof.BatchNumberFinal)
+			return nil, fmt.Errorf("Failed to retrieve batch with number [%d], %w", proof.BatchNumberFinal, err)
 		}
-		log.Warnf("NewLocalExitRoot and NewStateRoot look like a mock values, using values from executor instead: LER: %v, SR: %v",
+		log.Warnf("Using the NewLocalExitRoot and NewStateRoot from the executor instead of the prover ones: LER: %v, SR: %v",
 			finalBatch.LocalExitRoot.TerminalString(), finalBatch.StateRoot.TerminalString())
 		finalProof.Public.NewStateRoot = finalBatch.StateRoot.Bytes()
 		finalProof.Public.NewLocalExitRoot = finalBatch.LocalExitRoot.Bytes()
@@ -308,9 +835,13 @@ func (a *Aggregator) buildFinalProof(ctx context.Context, prover proverInterface
 // generated proof.  If the proof is eligible, then the final proof generation
 // is triggered.
 func (a *Aggregator) tryBuildFinalProof(ctx context.Context, prover proverInterface, proof *state.Proof) (bool, error) {
-	log.Debugf("tryBuildFinalProof start prover { ID [%s], addr [%s] }", prover.ID(), prover.Addr())
+	log.Debugf("tryBuildFinalProof start prover { ID [%s], name [%s] }", prover.ID(), prover.Name())
 
 	var err error
+	if proof != nil && a.unableToPerform(prover, ChannelOperationBuildFinalProof) {
+		// the proof is left to a prover able to build the final proof
+		return false, nil
+	}
 	if !a.canVerifyProof() {
 		log.Debug("Time to verify proof not reached")
 		return false, nil
@@ -323,10 +854,9 @@ func (a *Aggregator) tryBuildFinalProof(ctx context.Context, prover proverInterf
 		}
 	}()
 
-	for !a.isSynced(ctx) {
-		log.Info("Waiting for synchronizer to sync...")
-		time.Sleep(a.cfg.RetryTime.Duration)
-		continue
+	err = a.waitForSync(ctx)
+	if err != nil {
+		return false, err
 	}
 
 	var lastVerifiedBatchNum uint64
@@ -335,7 +865,7 @@ func (a *Aggregator) tryBuildFinalProof(ctx context.Context, prover proverInterf
 		return false, fmt.Errorf("Failed to get last verified batch, %w", err)
 	}
 	if lastVerifiedBatch != nil {
-		lastVerifiedBatchNum = lastVerifiedBatch.BatchNumber
+		lastVerifiedBatchNum = a.reorg.cap(lastVerifiedBatch.BatchNumber)
 	}
 
 	if proof == nil {
@@ -356,12 +886,23 @@ func (a *Aggregator) tryBuildFinalProof(ctx context.Context, prover proverInterf
 			if err != nil {
 				// Set the generating state to false for the proof ("unlock" it)
 				proof.Generating = false
-				err2 := a.State.UpdateGeneratedProof(a.ctx, proof, nil)
+				err2 := a.State.UpdateGeneratedProof(a.serverContext(), proof, nil)
 				if err2 != nil {
 					log.Errorf("Failed to delete proof in progress, err: %v", err2)
 				}
 			}
 		}()
+
+		if !a.verificationWindow.ready(proof, time.Now()) {
+			// leave the proof to be aggregated with the following ones
+			proof.Generating = false
+			err = a.State.UpdateGeneratedProof(a.serverContext(), proof, nil)
+			if err != nil {
+				return false, fmt.Errorf("Failed to unlock proof ready to verify, %w", err)
+			}
+			a.enableProofVerification()
+			return false, nil
+		}
 	} else {
 		// we do have a proof generating at the moment, check if it is
 		// eligible to be verified
@@ -371,22 +912,31 @@ func (a *Aggregator) tryBuildFinalProof(ctx context.Context, prover proverInterf
 		if err != nil {
 			return false, fmt.Errorf("Failed to validate eligible final proof, %w", err)
 		}
-		if !eligible {
+		if !eligible || !a.verificationWindow.ready(proof, time.Now()) {
+			// let the next cycle try to verify a proof
+			a.enableProofVerification()
 			return false, nil
 		}
 	}
 
+	err = a.startFinalProof(proof)
+	if err != nil {
+		return false, err
+	}
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			a.finishFinalProof(proof, false)
+		}
+	}()
+
+	a.assignments.assign(prover, ChannelOperationBuildFinalProof, proof.BatchNumber, proof.BatchNumberFinal)
+
 	// at this point we have an eligible proof, build the final one using it
 	finalProof, err := a.buildFinalProof(ctx, prover, proof)
 	if err != nil {
 		return false, fmt.Errorf("Failed to build final proof, %w", err)
 	}
-	if finalProof == nil {
-		// If finalProof has not been generated for any reason,
-		// generate error and return (this also will unlock the proof to verify)
-		err = errors.New("Error generating final proof for proof ready to verify")
-		return false, err
-	}
 
 	msg := finalProofMsg{
 		proverID:       prover.ID(),
@@ -398,6 +948,7 @@ func (a *Aggregator) tryBuildFinalProof(ctx context.Context, prover proverInterf
 	case <-a.ctx.Done():
 		return false, a.ctx.Err()
 	case a.finalProof <- msg:
+		handedOff = true
 	}
 
 	log.Debug("tryBuildFinalProof end")
@@ -412,27 +963,119 @@ func (a *Aggregator) validateEligibleFinalProof(ctx context.Context, proof *stat
 		return false, nil
 	}
 
+	err := a.checkCompleteSequences(ctx, proof)
+	switch {
+	case err == nil:
+		return a.hasMinBatchesToVerify(proof), nil
+	case errors.Is(err, ErrIncompleteSequences):
+		log.Infof("Recursive proof %d-%d not eligible to be verified: not containing complete sequences", proof.BatchNumber, proof.BatchNumberFinal)
+		return false, nil
+	case errors.Is(err, ErrCompleteSequencesCheckTransient):
+		log.Warnf("Recursive proof %d-%d not eligible to be verified in this cycle: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		return false, nil
+	case a.cfg.CompleteSequencesCheckFailOpen:
+		log.Warnf("Recursive proof %d-%d considered eligible to be verified despite: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// hasMinBatchesToVerify returns whether the proof spans at least
+// MinBatchesToVerify batches, or IntervalAfterWhichBatchConsolidateAnyway has
+// elapsed since the last final proof was sent, so a low traffic delays the
+// verification of the batches by that interval at most.
+func (a *Aggregator) hasMinBatchesToVerify(proof *state.Proof) bool {
+	if a.cfg.MinBatchesToVerify <= 1 {
+		return true
+	}
+	batches := proof.BatchNumberFinal - proof.BatchNumber + 1
+	if batches >= a.cfg.MinBatchesToVerify {
+		return true
+	}
+	interval := a.consolidateAnywayInterval()
+	if elapsed := time.Since(a.lastVerifyProofTime()); elapsed >= interval {
+		log.Infof("Recursive proof %d-%d spans %d batches, less than %d, verified anyway after %v",
+			proof.BatchNumber, proof.BatchNumberFinal, batches, a.cfg.MinBatchesToVerify, elapsed.Round(time.Second))
+		return true
+	}
+	log.Infof("Recursive proof %d-%d spans %d batches, waiting for %d batches or %v since the last final proof to verify it",
+		proof.BatchNumber, proof.BatchNumberFinal, batches, a.cfg.MinBatchesToVerify, interval)
+	return false
+}
+
+// consolidateAnywayInterval returns the IntervalAfterWhichBatchConsolidateAnyway
+// in use, reloaded along with the profitability parameters.
+func (a *Aggregator) consolidateAnywayInterval() time.Duration {
+	if pc, ok := a.ProfitabilityChecker.(*TxProfitabilityCheckerBase); ok {
+		interval, _ := pc.Params()
+		return interval
+	}
+	return a.cfg.IntervalAfterWhichBatchConsolidateAnyway.Duration
+}
+
+// checkCompleteSequences returns nil if the proof contains complete
+// sequences, ErrIncompleteSequences if it doesn't and a
+// ErrCompleteSequencesCheckTransient wrapped error if the check failed for a
+// transient reason. Any other error means the check is inconclusive.
+func (a *Aggregator) checkCompleteSequences(ctx context.Context, proof *state.Proof) error {
 	bComplete, err := a.State.CheckProofContainsCompleteSequences(ctx, proof, nil)
 	if err != nil {
-		return false, fmt.Errorf("Failed to check if proof contains compete sequences, %w", err)
+		if isTransientStateError(err) {
+			return fmt.Errorf("%w, %v", ErrCompleteSequencesCheckTransient, err)
+		}
+		return fmt.Errorf("Failed to check if proof contains compete sequences, %w", err)
 	}
 	if !bComplete {
-		log.Infof("Recursive proof %d-%d not eligible to be verified: not containing complete sequences", proof.BatchNumber, proof.BatchNumberFinal)
-		return false, nil
+		return ErrIncompleteSequences
 	}
-	return true, nil
+	return nil
+}
+
+// isTransientStateError returns whether the error returned by the state is
+// caused by a condition that can go away by retrying, like a timeout or a
+// connection lost before sending the query.
+func isTransientStateError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return pgconn.Timeout(err) || pgconn.SafeToRetry(err)
 }
 
 func (a *Aggregator) getAndLockProofReadyToVerify(ctx context.Context, prover proverInterface, lastVerifiedBatchNum uint64) (*state.Proof, error) {
 	a.StateDBMutex.Lock()
 	defer a.StateDBMutex.Unlock()
 
+	if a.cfg.FilterProofsByProverCapabilities && !canBuildFinalProof(prover) {
+		log.Debugf("Prover { ID [%s], name [%s] } can't build final proofs", prover.ID(), prover.Name())
+		return nil, state.ErrNotFound
+	}
+
 	// Get proof ready to be verified
-	proofToVerify, err := a.State.GetProofReadyToVerify(ctx, lastVerifiedBatchNum, nil)
+	proofToVerify, err := a.State.GetProofReadyToVerify(ctx, lastVerifiedBatchNum, a.cfg.ProofReadyToVerifyPreference, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	if a.cfg.FilterProofsByProverCapabilities {
+		forkID := a.forkIDForBatch(proofToVerify.BatchNumberFinal)
+		if prover.ForkID() != 0 && prover.ForkID() != forkID {
+			// don't lock a proof the prover can't finalize
+			log.Debugf("Prover { ID [%s], name [%s] } with fork id %d can't build the final proof for batches [%d-%d] with fork id %d",
+				prover.ID(), prover.Name(), prover.ForkID(), proofToVerify.BatchNumber, proofToVerify.BatchNumberFinal, forkID)
+			return nil, state.ErrNotFound
+		}
+	}
+
+	if !a.hasMinBatchesToVerify(proofToVerify) {
+		// leave the proof to be aggregated with the following ones
+		return nil, state.ErrNotFound
+	}
+
 	proofToVerify.Generating = true
 
 	err = a.State.UpdateGeneratedProof(ctx, proofToVerify, nil)
@@ -475,7 +1118,7 @@ func (a *Aggregator) getAndLockProofsToAggregate(ctx context.Context, prover pro
 	a.StateDBMutex.Lock()
 	defer a.StateDBMutex.Unlock()
 
-	proof1, proof2, err := a.State.GetProofsToAggregate(ctx, nil)
+	proof1, proof2, err := a.State.GetProofsToAggregate(ctx, a.cfg.MaxAggregationDepth, a.cfg.MaxBatchesPerAggregatedProof, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -508,7 +1151,7 @@ func (a *Aggregator) getAndLockProofsToAggregate(ctx context.Context, prover pro
 }
 
 func (a *Aggregator) tryAggregateProofs(ctx context.Context, prover proverInterface) (bool, error) {
-	log.Debugf("tryAggregateProofs start prover { ID [%s], addr [%s] }", prover.ID(), prover.Addr())
+	log.Debugf("tryAggregateProofs start prover { ID [%s], name [%s] }", prover.ID(), prover.Name())
 
 	proof1, proof2, err0 := a.getAndLockProofsToAggregate(ctx, prover)
 	if errors.Is(err0, state.ErrNotFound) {
@@ -524,7 +1167,7 @@ func (a *Aggregator) tryAggregateProofs(ctx context.Context, prover proverInterf
 
 	defer func() {
 		if err != nil {
-			err2 := a.unlockProofsToAggregate(a.ctx, proof1, proof2)
+			err2 := a.unlockProofsToAggregate(a.serverContext(), proof1, proof2)
 			if err2 != nil {
 				log.Errorf("Failed to release aggregated proofs, err: %v", err2)
 			}
@@ -532,8 +1175,16 @@ func (a *Aggregator) tryAggregateProofs(ctx context.Context, prover proverInterf
 		log.Debug("tryAggregateProofs end")
 	}()
 
-	log.Infof("Prover { ID [%s], addr [%s] } is going to be used to aggregate proofs: %d-%d and %d-%d",
-		prover.ID(), prover.Addr(), proof1.BatchNumber, proof1.BatchNumberFinal, proof2.BatchNumber, proof2.BatchNumberFinal)
+	r1 := batchRange{batchNumber: proof1.BatchNumber, batchNumberFinal: proof1.BatchNumberFinal}
+	if r1.overlaps(proof2.BatchNumber, proof2.BatchNumberFinal) {
+		err = fmt.Errorf("%w: %d-%d and %d-%d", ErrOverlappingProofs,
+			proof1.BatchNumber, proof1.BatchNumberFinal, proof2.BatchNumber, proof2.BatchNumberFinal)
+		return false, err
+	}
+
+	log.Infof("Prover { ID [%s], name [%s] } is going to be used to aggregate proofs: %d-%d and %d-%d",
+		prover.ID(), prover.Name(), proof1.BatchNumber, proof1.BatchNumberFinal, proof2.BatchNumber, proof2.BatchNumberFinal)
+	a.assignments.assign(prover, ChannelOperationAggregateProofs, proof1.BatchNumber, proof2.BatchNumberFinal)
 
 	proverID := prover.ID()
 	inputProver := map[string]interface{}{
@@ -551,23 +1202,83 @@ func (a *Aggregator) tryAggregateProofs(ctx context.Context, prover proverInterf
 		Prover:           &proverID,
 		InputProver:      string(b),
 		Generating:       true,
+		Depth:            aggregationDepth(proof1, proof2),
+	}
+
+	if a.cfg.ProofCommitments {
+		proof.Commitment, err = a.aggregatedCommitment(ctx, proof1, proof2)
+		if errors.Is(err, ErrCommitmentMismatch) {
+			// the proof can't be trusted, drop it so its batches are proved again
+			err2 := a.State.DeleteGeneratedProofs(a.serverContext(), proof2.BatchNumber, proof2.BatchNumberFinal, nil)
+			if err2 != nil {
+				log.Errorf("Failed to delete proof %d-%d with a mismatching commitment, err: %v", proof2.BatchNumber, proof2.BatchNumberFinal, err2)
+			}
+		}
+		if err != nil {
+			return false, fmt.Errorf("Failed to compute the aggregated proof commitment, %w", err)
+		}
 	}
 
 	aggrProofID, err := prover.AggregatedProof(proof1.Proof, proof2.Proof)
 	if err != nil {
-		return false, fmt.Errorf("Failed to get aggregated proof id, %w", err)
+		return false, newLifecycleError(ErrProverNotResponding, fmt.Errorf("Failed to get aggregated proof id, %w", err))
 	}
 
 	proof.ProofID = aggrProofID
 
 	log.Infof("Proof ID for aggregated proof %d-%d: %v", proof.BatchNumber, proof.BatchNumberFinal, *proof.ProofID)
+	a.publishEvent(events.EventProofStarted, proof.BatchNumber, proof.BatchNumberFinal, proverID)
+
+	// from now on the proofs are released by completeAggregatedProof
+	return a.completeAggregatedProof(ctx, prover, proof1, proof2, proof)
+}
+
+// aggregationDepth returns the depth of the proof aggregating the given ones.
+func aggregationDepth(proof1, proof2 *state.Proof) uint64 {
+	if proof1.Depth > proof2.Depth {
+		return proof1.Depth + 1
+	}
+	return proof2.Depth + 1
+}
+
+// completeAggregatedProof waits for the aggregated proof requested to the
+// prover and replaces the aggregated proofs with it.
+func (a *Aggregator) completeAggregatedProof(ctx context.Context, prover proverInterface, proof1, proof2, proof *state.Proof) (bool, error) {
+	var err error
+
+	defer func() {
+		if err != nil {
+			resume := func(ctx context.Context, prover proverInterface) (bool, error) {
+				a.assignments.assign(prover, ChannelOperationAggregateProofs, proof.BatchNumber, proof.BatchNumberFinal)
+				return a.completeAggregatedProof(ctx, prover, proof1, proof2, proof)
+			}
+			a.releaseWork(prover, err, resume, func() {
+				err2 := a.unlockProofsToAggregate(a.serverContext(), proof1, proof2)
+				if err2 != nil {
+					log.Errorf("Failed to release aggregated proofs, err: %v", err2)
+				}
+			})
+		}
+	}()
+
+	proverID := prover.ID()
 
-	recursiveProof, err := prover.WaitRecursiveProof(ctx, *proof.ProofID)
+	waitCtx, cancel := proofTimeoutContext(ctx, a.proofTimeout(a.cfg.AggregatedProofTimeout.Duration))
+	defer cancel()
+	start := time.Now()
+	recursiveProof, err := prover.WaitRecursiveProof(waitCtx, *proof.ProofID)
+	metrics.ProofWaited(metrics.ProofTypeAggregated, proverIdentity(prover), time.Since(start), err == nil && !isEmptyRecursiveProof(recursiveProof))
 	if err != nil {
-		return false, fmt.Errorf("Failed to get aggregated proof from prover, %w", err)
+		err = &waitProofError{err: fmt.Errorf("Failed to get aggregated proof from prover, %w", checkProofTimeout(ctx, waitCtx, err))}
+		return false, err
+	}
+	if isEmptyRecursiveProof(recursiveProof) {
+		err = fmt.Errorf("Failed to get aggregated proof %s from prover, %w", *proof.ProofID, ErrEmptyProof)
+		return false, err
 	}
 
 	log.Infof("Aggregated proof %s generated", *proof.ProofID)
+	a.publishEvent(events.EventAggregationDone, proof.BatchNumber, proof.BatchNumberFinal, proverID)
 
 	proof.Proof = recursiveProof
 
@@ -594,58 +1305,77 @@ func (a *Aggregator) tryAggregateProofs(ctx context.Context, prover proverInterf
 		return false, fmt.Errorf("Failed to store the recursive proof %w", err)
 	}
 
-	// state is up to date, check if we can send the final proof using the
-	// one just crafted.
-	finalProofBuilt, err := a.tryBuildFinalProof(ctx, prover, proof)
-	if err != nil {
-		return false, fmt.Errorf("Failed trying to check if recursive proof can be verified: %w", err)
-	}
-
-	// NOTE(pg): prover is done, use a.ctx from now on
-
-	if !finalProofBuilt {
-		proof.Generating = false
-
-		// final proof has not been generated, update the recursive proof
-		err = a.State.UpdateGeneratedProof(a.ctx, proof, nil)
-		if err != nil {
-			log.Errorf("Failed to store batch proof result, err %v", err)
-			return false, err
-		}
-	}
-
-	return true, nil
+	// state is up to date, the aggregated proofs are gone and from now on
+	// the recursive proof is released by finalizeAggregatedProof
+	return a.finalizeAggregatedProof(ctx, prover, proof)
 }
 
-func (a *Aggregator) getAndLockBatchToProve(ctx context.Context, prover *prover.Prover) (*state.Batch, *state.Proof, error) {
-	a.StateDBMutex.Lock()
-	defer a.StateDBMutex.Unlock()
+func (a *Aggregator) getAndLockBatchToProve(ctx context.Context, prover proverInterface) (*state.Batch, *state.Proof, error) {
+	if a.batchClaims.full() {
+		log.Debugf("Max number of batches being proven reached, prover { ID [%s], name [%s] } not used", prover.ID(), prover.Name())
+		return nil, nil, state.ErrNotFound
+	}
 
 	lastVerifiedBatch, err := a.State.GetLastVerifiedBatch(ctx, nil)
 	if err != nil {
 		return nil, nil, err
 	}
+	lastVerifiedBatchNum := a.reorg.cap(lastVerifiedBatch.BatchNumber)
+
+	// never select a batch at or below the L1-verified frontier, it could
+	// have been verified by someone else and the local state is behind
+	lastVerifiedEthBatchNum, err := a.lastVerifiedEthBatchNum()
+	if err != nil {
+		log.Warnf("Failed to get last eth verified batch, selecting after the last verified batch %d, err: %v", lastVerifiedBatchNum, err)
+	} else if lastVerifiedEthBatchNum > lastVerifiedBatchNum {
+		log.Infof("Skipping batches already verified on L1, lastVerifiedBatchNum: %d, lastVerifiedEthBatchNum: %d",
+			lastVerifiedBatchNum, lastVerifiedEthBatchNum)
+		lastVerifiedBatchNum = lastVerifiedEthBatchNum
+	}
 
 	// Get virtual batch pending to generate proof
-	batchToVerify, err := a.State.GetVirtualBatchToProve(ctx, lastVerifiedBatch.BatchNumber, nil)
+	batchToVerify, err := a.claimBatchToProve(ctx, lastVerifiedBatchNum, prover)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	log.Infof("Found virtual batch %d pending to generate proof", batchToVerify.BatchNumber)
 
+	locked := false
+	defer func() {
+		if !locked {
+			a.batchClaims.release(batchToVerify.BatchNumber)
+		}
+	}()
+
+	forkID := a.forkIDForBatch(batchToVerify.BatchNumber)
+	if prover.ForkID() != 0 && prover.ForkID() != forkID {
+		// leave the batch for a prover supporting its fork id
+		log.Infof("Prover { ID [%s], name [%s] } supports fork id %d, batch %d requires fork id %d",
+			prover.ID(), prover.Name(), prover.ForkID(), batchToVerify.BatchNumber, forkID)
+		return nil, nil, state.ErrNotFound
+	}
+
+	if a.deferToPreferredProver(prover, batchToVerify.BatchNumber) {
+		return nil, nil, state.ErrNotFound
+	}
+
 	log.Infof("Checking profitability to aggregate batch, batchNumber: %d", batchToVerify.BatchNumber)
 
-	// pass matic collateral as zero here, bcs in smart contract fee for aggregator is not defined yet
-	isProfitable, err := a.ProfitabilityChecker.IsProfitable(ctx, big.NewInt(0))
+	maticCollateral, err := a.Ethman.GetAggregatorReward()
+	if err != nil {
+		log.Errorf("Failed to get aggregator reward, err: %v", err)
+		return nil, nil, err
+	}
+	isProfitable, err := a.ProfitabilityChecker.IsProfitable(ctx, maticCollateral)
 	if err != nil {
 		log.Errorf("Failed to check aggregator profitability, err: %v", err)
 		return nil, nil, err
 	}
 
 	if !isProfitable {
-		log.Infof("Batch %d is not profitable, matic collateral %d", batchToVerify.BatchNumber, big.NewInt(0))
-		return nil, nil, err
+		log.Infof("Batch %d is not profitable, matic collateral %d", batchToVerify.BatchNumber, maticCollateral)
+		return nil, nil, state.ErrNotFound
 	}
 
 	proverID := prover.ID()
@@ -663,11 +1393,45 @@ func (a *Aggregator) getAndLockBatchToProve(ctx context.Context, prover *prover.
 		return nil, nil, err
 	}
 
+	locked = true
 	return batchToVerify, proof, nil
 }
 
-func (a *Aggregator) tryGenerateBatchProof(ctx context.Context, prover *prover.Prover) (bool, error) {
-	log.Debugf("tryGenerateBatchProof start prover { ID [%s], addr [%s] }", prover.ID(), prover.Addr())
+// claimBatchToProve selects the next virtual batch pending to generate proof
+// that is not being proven by another prover, and claims it for the prover.
+// Only the selection is serialized, so the provers lock distinct batches
+// concurrently.
+func (a *Aggregator) claimBatchToProve(ctx context.Context, lastVerifiedBatchNum uint64, prover proverInterface) (*state.Batch, error) {
+	a.StateDBMutex.Lock()
+	defer a.StateDBMutex.Unlock()
+
+	var batch *state.Batch
+	for {
+		var err error
+		batch, err = a.State.GetVirtualBatchToProve(ctx, lastVerifiedBatchNum, a.batchClaims.excluded(), nil)
+		if err != nil {
+			return nil, err
+		}
+		next, ok := a.batchFilter.nextAllowed(batch.BatchNumber)
+		if !ok {
+			log.Infof("Batch %d skipped by the batch filter, no batch after it is allowed to be proven", batch.BatchNumber)
+			return nil, state.ErrNotFound
+		}
+		if next == batch.BatchNumber {
+			break
+		}
+		log.Infof("Batch %d skipped by the batch filter, the next batch allowed to be proven is %d", batch.BatchNumber, next)
+		lastVerifiedBatchNum = next - 1
+	}
+	if !a.batchClaims.claim(batch.BatchNumber, prover.ID()) {
+		// the max number of batches being proven was reached meanwhile
+		return nil, state.ErrNotFound
+	}
+	return batch, nil
+}
+
+func (a *Aggregator) tryGenerateBatchProof(ctx context.Context, prover proverInterface) (bool, error) {
+	log.Debugf("tryGenerateBatchProof start prover { ID [%s], name [%s] }", prover.ID(), prover.Name())
 
 	batchToProve, proof, err0 := a.getAndLockBatchToProve(ctx, prover)
 	if errors.Is(err0, state.ErrNotFound) {
@@ -678,12 +1442,14 @@ func (a *Aggregator) tryGenerateBatchProof(ctx context.Context, prover *prover.P
 	if err0 != nil {
 		return false, err0
 	}
+	// once returned, the proof is either stored, held or deleted
+	defer a.batchClaims.release(batchToProve.BatchNumber)
 
 	var err error
 
 	defer func() {
 		if err != nil {
-			err2 := a.State.DeleteGeneratedProofs(a.ctx, proof.BatchNumber, proof.BatchNumberFinal, nil)
+			err2 := a.State.DeleteGeneratedProofs(a.serverContext(), proof.BatchNumber, proof.BatchNumberFinal, nil)
 			if err2 != nil {
 				log.Errorf("Failed to delete proof in progress, err: %v", err2)
 			}
@@ -691,54 +1457,103 @@ func (a *Aggregator) tryGenerateBatchProof(ctx context.Context, prover *prover.P
 		log.Debug("tryGenerateBatchProof end")
 	}()
 
-	log.Infof("Prover { ID [%s], addr [%s] } is going to be used to generate proof from batch [%d]", prover.ID(), prover.Addr(), batchToProve.BatchNumber)
+	log.Infof("Prover { ID [%s], name [%s] } is going to be used to generate proof from batch [%d]", prover.ID(), prover.Name(), batchToProve.BatchNumber)
+	a.assignments.assign(prover, ChannelOperationGenerateBatchProof, batchToProve.BatchNumber, batchToProve.BatchNumber)
 
 	log.Infof("Sending zki + batch to the prover, batchNumber [%d]", batchToProve.BatchNumber)
 	inputProver, err := a.buildInputProver(ctx, batchToProve)
 	if err != nil {
-		return false, fmt.Errorf("Failed to build input prover, %w", err)
+		return false, newLifecycleError(ErrStateInconsistent, fmt.Errorf("Failed to build input prover, %w", err))
 	}
 
-	b, err := json.Marshal(inputProver)
+	proof.InputProver, err = a.serializeInputProver(ctx, inputProver)
 	if err != nil {
 		return false, fmt.Errorf("Failed to serialize input prover, %w", err)
 	}
 
-	proof.InputProver = string(b)
+	if a.cfg.ProofCommitments {
+		proof.Commitment = batchCommitment(batchToProve)
+	}
 
 	log.Infof("Sending a batch to the prover. OldStateRoot [%#x], OldBatchNum [%d]",
 		inputProver.PublicInputs.OldStateRoot, inputProver.PublicInputs.OldBatchNum)
 
+	warm := a.affinity.isWarm(prover.ID(), proof.BatchNumber)
+	start := time.Now()
 	genProofID, err := prover.BatchProof(inputProver)
 	if err != nil {
-		return false, fmt.Errorf("Failed to get batch proof id %w", err)
+		return false, newLifecycleError(ErrProverNotResponding, fmt.Errorf("Failed to get batch proof id %w", err))
 	}
 
 	proof.ProofID = genProofID
 
 	log.Infof("Proof ID for batch %d: %v", proof.BatchNumber, *proof.ProofID)
+	a.publishEvent(events.EventProofStarted, proof.BatchNumber, proof.BatchNumberFinal, prover.ID())
 
-	resGetProof, err := prover.WaitRecursiveProof(ctx, *proof.ProofID)
+	// from now on the proof is released by completeBatchProof
+	generated, err2 := a.completeBatchProof(ctx, prover, proof)
+	if err2 == nil {
+		metrics.BatchProofDuration(time.Since(start), warm)
+	}
+	return generated, err2
+}
+
+// completeBatchProof waits for the batch proof requested to the prover and
+// stores it, building the final proof with it if possible.
+func (a *Aggregator) completeBatchProof(ctx context.Context, prover proverInterface, proof *state.Proof) (bool, error) {
+	var err error
+
+	defer func() {
+		if err != nil {
+			resume := func(ctx context.Context, prover proverInterface) (bool, error) {
+				a.assignments.assign(prover, ChannelOperationGenerateBatchProof, proof.BatchNumber, proof.BatchNumberFinal)
+				return a.completeBatchProof(ctx, prover, proof)
+			}
+			a.releaseWork(prover, err, resume, func() {
+				err2 := a.State.DeleteGeneratedProofs(a.serverContext(), proof.BatchNumber, proof.BatchNumberFinal, nil)
+				if err2 != nil {
+					log.Errorf("Failed to delete proof in progress, err: %v", err2)
+				}
+			})
+		}
+	}()
+
+	waitCtx, cancel := proofTimeoutContext(ctx, a.proofTimeout(a.cfg.BatchProofTimeout.Duration))
+	defer cancel()
+	start := time.Now()
+	resGetProof, err := prover.WaitRecursiveProof(waitCtx, *proof.ProofID)
+	metrics.ProofWaited(metrics.ProofTypeBatch, proverIdentity(prover), time.Since(start), err == nil && !isEmptyRecursiveProof(resGetProof))
 	if err != nil {
-		return false, fmt.Errorf("Failed to get proof from prover %w", err)
+		err = &waitProofError{err: fmt.Errorf("Failed to get proof from prover %w", checkProofTimeout(ctx, waitCtx, err))}
+		return false, err
+	}
+	if isEmptyRecursiveProof(resGetProof) {
+		err = fmt.Errorf("Failed to get proof %s from prover, %w", *proof.ProofID, ErrEmptyProof)
+		return false, err
 	}
 
 	log.Infof("Batch proof %s generated", *proof.ProofID)
+	a.publishEvent(events.EventProofGenerated, proof.BatchNumber, proof.BatchNumberFinal, prover.ID())
+	a.affinity.record(prover.ID(), proof.BatchNumber)
 
 	proof.Proof = resGetProof
 
 	finalProofBuilt, err := a.tryBuildFinalProof(ctx, prover, proof)
+	if errors.Is(err, ErrFinalProofDeduplicated) || errors.Is(err, ErrSyncWaitTimeout) {
+		log.Infof("Final proof not built with the batch proof, err: %v", err)
+		err = nil
+	}
 	if err != nil {
 		return false, fmt.Errorf("Failed trying to build final proof %w", err)
 	}
 
-	// NOTE(pg): prover is done, use a.ctx from now on
+	// the prover is done, store its result even if the prover disconnects
 
 	if !finalProofBuilt {
 		proof.Generating = false
 
 		// final proof has not been generated, update the recursive proof
-		err = a.State.UpdateGeneratedProof(a.ctx, proof, nil)
+		err = a.State.UpdateGeneratedProof(a.serverContext(), proof, nil)
 		if err != nil {
 			log.Errorf("Failed to store batch proof result, err %v", err)
 			return false, err
@@ -748,9 +1563,111 @@ func (a *Aggregator) tryGenerateBatchProof(ctx context.Context, prover *prover.P
 	return true, nil
 }
 
+// isEmptyRecursiveProof returns whether the recursive proof returned by the
+// prover is empty or a placeholder.
+func isEmptyRecursiveProof(proof string) bool {
+	switch strings.TrimSpace(proof) {
+	case "", "null", "{}", "[]":
+		return true
+	}
+	return false
+}
+
+// isEmptyFinalProof returns whether the final proof returned by the prover
+// lacks the proof or its public inputs.
+func isEmptyFinalProof(finalProof *pb.FinalProof) bool {
+	if finalProof == nil || finalProof.Proof == nil || finalProof.Public == nil {
+		return true
+	}
+	p := finalProof.Proof
+	return len(p.ProofA) == 0 || len(p.ProofB) == 0 || len(p.ProofC) == 0
+}
+
+// proofTimeout returns the timeout to wait for a proof, the given timeout of
+// its type capped to the proof generation timeout. Zero means no timeout.
+func (a *Aggregator) proofTimeout(timeout time.Duration) time.Duration {
+	max := a.cfg.ProofGenerationTimeout.Duration
+	if max > 0 && (timeout <= 0 || timeout > max) {
+		return max
+	}
+	return timeout
+}
+
+// proofTimeoutError is the error of a proof wait that timed out.
+type proofTimeoutError struct {
+	err error
+}
+
+func (e *proofTimeoutError) Error() string { return fmt.Sprintf("%v, %v", ErrProofTimeout, e.err) }
+
+func (e *proofTimeoutError) Unwrap() error { return e.err }
+
+func (e *proofTimeoutError) Is(target error) bool { return target == ErrProofTimeout }
+
+// checkProofTimeout returns the error of a proof wait as an ErrProofTimeout
+// if the wait timed out, as opposed to the prover or the aggregator being
+// gone.
+func checkProofTimeout(ctx, waitCtx context.Context, err error) error {
+	if ctx.Err() == nil && errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+		return &proofTimeoutError{err: err}
+	}
+	return err
+}
+
+// proofTimeoutContext returns a context to wait for a proof that is canceled
+// once the timeout expires. A zero timeout waits without limit.
+func proofTimeoutContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// serializeInputProver returns the JSON representation of the input prover.
+// The encoding is written straight into the returned string, so the only
+// intermediate buffer is the one pooled by the json package, and the number
+// of concurrent serializations is bounded by MaxConcurrentSerializations.
+func (a *Aggregator) serializeInputProver(ctx context.Context, inputProver *pb.InputProver) (string, error) {
+	if a.serializationSem != nil {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case a.serializationSem <- struct{}{}:
+		}
+		defer func() { <-a.serializationSem }()
+	}
+
+	var sb strings.Builder
+	err := json.NewEncoder(&sb).Encode(inputProver)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// publishEvent publishes a proof lifecycle event if the events publisher is
+// enabled.
+func (a *Aggregator) publishEvent(eventType events.EventType, batchNumber, batchNumberFinal uint64, proverID string) {
+	now := time.Now()
+	a.throughput.record(eventType, batchNumber, batchNumberFinal, now)
+	if a.events == nil {
+		return
+	}
+	a.events.Publish(events.Event{
+		Type:             eventType,
+		BatchNumber:      batchNumber,
+		BatchNumberFinal: batchNumberFinal,
+		ProverID:         proverID,
+		Timestamp:        now,
+	})
+}
+
 // canVerifyProof returns true if we have reached the timeout to verify a proof
 // and no other prover is verifying a proof.
 func (a *Aggregator) canVerifyProof() bool {
+	if !a.quietPeriodElapsed() {
+		return false
+	}
 	a.TimeSendFinalProofMutex.Lock()
 	defer a.TimeSendFinalProofMutex.Unlock()
 	if a.TimeSendFinalProof.Before(time.Now()) {
@@ -769,12 +1686,53 @@ func (a *Aggregator) enableProofVerification() {
 	a.verifyingProof = false
 }
 
-// resetVerifyProofTime updates the timeout to verify a proof.
+// resetVerifyProofTime updates the timeout to verify a proof. The time of the
+// reset is persisted so the timeout survives restarts.
 func (a *Aggregator) resetVerifyProofTime() {
+	now := time.Now()
+	a.setVerifyProofTime(now)
+	err := a.State.SetLastVerifyProofTime(a.serverContext(), now, nil)
+	if err != nil {
+		log.Errorf("Failed to persist the last verify proof time, err: %v", err)
+	}
+}
+
+// setVerifyProofTime sets the timeout to verify a proof to the verify proof
+// interval after the given time.
+func (a *Aggregator) setVerifyProofTime(lastVerifyProofTime time.Time) {
 	a.TimeSendFinalProofMutex.Lock()
 	defer a.TimeSendFinalProofMutex.Unlock()
 	a.verifyingProof = false
-	a.TimeSendFinalProof = time.Now().Add(a.cfg.VerifyProofInterval.Duration)
+	a.TimeSendFinalProof = lastVerifyProofTime.Add(a.cfg.VerifyProofInterval.Duration)
+}
+
+// lastVerifyProofTime returns the time the timeout to verify a proof was last
+// set from, usually the time the last final proof was sent.
+func (a *Aggregator) lastVerifyProofTime() time.Time {
+	a.TimeSendFinalProofMutex.RLock()
+	defer a.TimeSendFinalProofMutex.RUnlock()
+	return a.TimeSendFinalProof.Add(-a.cfg.VerifyProofInterval.Duration)
+}
+
+// resumeVerifyProofTime restores the timeout to verify a proof from the time
+// of the last reset persisted, so a restart doesn't delay the next final
+// proof by a full verify proof interval. The timeout is reset if no time was
+// persisted.
+func (a *Aggregator) resumeVerifyProofTime(ctx context.Context) {
+	lastVerifyProofTime, err := a.State.GetLastVerifyProofTime(ctx, nil)
+	if err != nil {
+		if !errors.Is(err, state.ErrNotFound) {
+			log.Errorf("Failed to get the last verify proof time, err: %v", err)
+		}
+		a.resetVerifyProofTime()
+		return
+	}
+	if now := time.Now(); lastVerifyProofTime.After(now) {
+		// don't hold the verification longer than the interval on a clock skew
+		lastVerifyProofTime = now
+	}
+	a.setVerifyProofTime(lastVerifyProofTime)
+	log.Infof("Verify proof time resumed from %v, final proofs can be built from %v", lastVerifyProofTime, a.VerificationTimer().TimeSendFinalProof)
 }
 
 func (a *Aggregator) isSynced(ctx context.Context) bool {
@@ -791,11 +1749,30 @@ func (a *Aggregator) isSynced(ctx context.Context) bool {
 		log.Warnf("Failed to get last eth batch, err: %v", err)
 		return false
 	}
+	a.l1Frontier.set(lastVerifiedEthBatchNum)
+	reorged, err := a.checkVerifiedBatchReorg(ctx, lastVerifiedBatch, lastVerifiedEthBatchNum)
+	if err != nil {
+		log.Warnf("Failed to check L1 reorgs, err: %v", err)
+		return false
+	}
+	if reorged {
+		// synced up to the fork point, the batches after it are verified again
+		return true
+	}
 	if lastVerifiedBatch.BatchNumber < lastVerifiedEthBatchNum {
 		log.Infof("Waiting for the state to be synced, lastVerifiedBatchNum: %d, lastVerifiedEthBatchNum: %d",
 			lastVerifiedBatch.BatchNumber, lastVerifiedEthBatchNum)
 		return false
 	}
+	if lastVerifiedBatch.BatchNumber > lastVerifiedEthBatchNum {
+		if a.cfg.NotSyncedWhenAheadOfL1 {
+			log.Warnf("State is ahead of L1, waiting for L1 to be reconciled, lastVerifiedBatchNum: %d, lastVerifiedEthBatchNum: %d",
+				lastVerifiedBatch.BatchNumber, lastVerifiedEthBatchNum)
+			return false
+		}
+		log.Errorf("State is ahead of L1, a reconciliation may be needed, lastVerifiedBatchNum: %d, lastVerifiedEthBatchNum: %d",
+			lastVerifiedBatch.BatchNumber, lastVerifiedEthBatchNum)
+	}
 	return true
 }
 
@@ -810,6 +1787,14 @@ func (a *Aggregator) buildInputProver(ctx context.Context, batchToVerify *state.
 		return nil, fmt.Errorf("failed to get public address, err: %w", err)
 	}
 
+	if err := a.checkGlobalExitRoot(ctx, batchToVerify); err != nil {
+		return nil, err
+	}
+
+	if err := a.checkBatchL2Data(previousBatch, batchToVerify); err != nil {
+		return nil, err
+	}
+
 	inputProver := &pb.InputProver{
 		PublicInputs: &pb.PublicInputs{
 			OldStateRoot:    previousBatch.StateRoot.Bytes(),
@@ -821,39 +1806,133 @@ func (a *Aggregator) buildInputProver(ctx context.Context, batchToVerify *state.
 			EthTimestamp:    uint64(batchToVerify.Timestamp.Unix()),
 			SequencerAddr:   batchToVerify.Coinbase.String(),
 			AggregatorAddr:  pubAddr.String(),
+			ForkId:          a.forkIDForBatch(batchToVerify.BatchNumber),
 		},
 		Db:                map[string]string{},
 		ContractsBytecode: map[string]string{},
 	}
 
+	if err := a.compressBatchL2Data(inputProver); err != nil {
+		return nil, err
+	}
+
 	return inputProver, nil
 }
 
+// proverIdentity returns the identity of the prover composed of its name and
+// ID, to label its metrics.
+func proverIdentity(p proverInterface) string {
+	return p.Name() + "/" + p.ID()
+}
+
+// capabilitiesLabel returns the known capabilities supported by the prover
+// joined by commas, to label its metrics. Unknown capabilities advertised by
+// the prover are left out to bound the cardinality of the label.
+func capabilitiesLabel(p proverInterface) string {
+	var capabilities []string
+	for _, capability := range []string{prover.CapabilityBatchProof, prover.CapabilityAggregatedProof, prover.CapabilityFinalProof} {
+		if p.HasCapability(capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	if len(capabilities) == 0 {
+		return "none"
+	}
+	return strings.Join(capabilities, ",")
+}
+
+// canBuildFinalProof returns whether the prover advertised it can build final
+// proofs.
+func canBuildFinalProof(p proverInterface) bool {
+	return p.HasCapability(prover.CapabilityFinalProof)
+}
+
+// forkIDForBatch returns the fork id to be used to prove the given batch.
+func (a *Aggregator) forkIDForBatch(batchNumber uint64) uint64 {
+	for _, interval := range a.cfg.ForkIDIntervals {
+		if batchNumber >= interval.FromBatchNumber && batchNumber <= interval.ToBatchNumber {
+			return interval.ForkID
+		}
+	}
+	return 0
+}
+
 // healthChecker will provide an implementation of the HealthCheck interface.
-type healthChecker struct{}
+type healthChecker struct {
+	checks []func() bool
+	// watchInterval is the interval to evaluate the status for the Watch
+	// streams, defaultHealthWatchInterval if 0
+	watchInterval time.Duration
+	// done is closed once the server is stopping, ending the Watch streams
+	done <-chan struct{}
+}
+
+const defaultHealthWatchInterval = time.Second
 
 // newHealthChecker returns a health checker according to standard package
-// grpc.health.v1.
-func newHealthChecker() *healthChecker {
-	return &healthChecker{}
+// grpc.health.v1, aware of the health of the dependencies of the server:
+// the state database, the provers and L1.
+func newHealthChecker(checks ...func() bool) *healthChecker {
+	return &healthChecker{checks: checks}
+}
+
+// status returns SERVING if the server is up and its dependencies are healthy.
+func (hc *healthChecker) status() grpchealth.HealthCheckResponse_ServingStatus {
+	for _, healthy := range hc.checks {
+		if healthy != nil && !healthy() {
+			return grpchealth.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return grpchealth.HealthCheckResponse_SERVING
 }
 
 // HealthCheck interface implementation.
 
 // Check returns the current status of the server for unary gRPC health requests,
-// for now if the server is up and able to respond we will always return SERVING.
+// SERVING if the server is up and its dependencies are healthy.
 func (hc *healthChecker) Check(ctx context.Context, req *grpchealth.HealthCheckRequest) (*grpchealth.HealthCheckResponse, error) {
 	log.Info("Serving the Check request for health check")
 	return &grpchealth.HealthCheckResponse{
-		Status: grpchealth.HealthCheckResponse_SERVING,
+		Status: hc.status(),
 	}, nil
 }
 
-// Watch returns the current status of the server for stream gRPC health requests,
-// for now if the server is up and able to respond we will always return SERVING.
+// Watch streams the status of the server for stream gRPC health requests,
+// SERVING if the server is up and its dependencies are healthy. The current
+// status is sent right away and then again every time it changes, until the
+// stream is closed or the server stops, so the graceful stop doesn't wait for
+// the streams. NOT_SERVING is sent once the server stops.
 func (hc *healthChecker) Watch(req *grpchealth.HealthCheckRequest, server grpchealth.Health_WatchServer) error {
 	log.Info("Serving the Watch request for health check")
-	return server.Send(&grpchealth.HealthCheckResponse{
-		Status: grpchealth.HealthCheckResponse_SERVING,
-	})
+	interval := hc.watchInterval
+	if interval <= 0 {
+		interval = defaultHealthWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := server.Context()
+	var lastStatus grpchealth.HealthCheckResponse_ServingStatus
+	for {
+		status := hc.status()
+		if status != lastStatus {
+			err := server.Send(&grpchealth.HealthCheckResponse{
+				Status: status,
+			})
+			if err != nil {
+				return err
+			}
+			lastStatus = status
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hc.done:
+			return server.Send(&grpchealth.HealthCheckResponse{
+				Status: grpchealth.HealthCheckResponse_NOT_SERVING,
+			})
+		case <-ticker.C:
+		}
+	}
 }
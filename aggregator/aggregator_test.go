@@ -0,0 +1,1053 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAndLockBatchToProveSkipsL1VerifiedBatches(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		State:                st,
+		Ethman:               eth,
+		ProfitabilityChecker: pc,
+		StateDBMutex:         &sync.Mutex{},
+	}
+	ctx := context.Background()
+	batch := &state.Batch{BatchNumber: 13}
+
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil)
+	st.On("GetVirtualBatchToProve", ctx, uint64(12), []uint64(nil), nil).Return(batch, nil)
+	prover.On("ForkID").Return(uint64(0))
+	pc.On("IsProfitable", ctx, big.NewInt(0)).Return(true, nil)
+	eth.On("GetAggregatorReward").Return(big.NewInt(0), nil)
+	prover.On("ID").Return("prover-1")
+	st.On("AddGeneratedProof", ctx, mock.Anything, nil).Return(nil)
+
+	batchToProve, proof, err := a.getAndLockBatchToProve(ctx, prover)
+	require.NoError(t, err)
+	assert.Equal(t, batch, batchToProve)
+	assert.Equal(t, uint64(13), proof.BatchNumber)
+	assert.Equal(t, uint64(13), proof.BatchNumberFinal)
+	assert.True(t, proof.Generating)
+}
+
+func TestGetAndLockBatchToProveAggregatorReward(t *testing.T) {
+	testCases := []struct {
+		name       string
+		reward     *big.Int
+		profitable bool
+	}{
+		{name: "profitable", reward: big.NewInt(100), profitable: true},
+		{name: "not profitable", reward: big.NewInt(99), profitable: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mocks.NewStateMock(t)
+			eth := mocks.NewEtherman(t)
+			prover := mocks.NewProverMock(t)
+			a := Aggregator{
+				State:                st,
+				Ethman:               eth,
+				ProfitabilityChecker: NewTxProfitabilityCheckerBase(st, 0, big.NewInt(100)),
+				StateDBMutex:         &sync.Mutex{},
+			}
+			ctx := context.Background()
+			batch := &state.Batch{BatchNumber: 11}
+
+			st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+			eth.On("GetLatestVerifiedBatchNum").Return(uint64(10), nil)
+			st.On("GetVirtualBatchToProve", ctx, uint64(10), []uint64(nil), nil).Return(batch, nil)
+			prover.On("ForkID").Return(uint64(0))
+			prover.On("ID").Return("prover-1")
+			// the reward set in the PoE smart contract is checked against
+			// the min reward
+			eth.On("GetAggregatorReward").Return(tc.reward, nil).Once()
+			if tc.profitable {
+				st.On("AddGeneratedProof", ctx, mock.Anything, nil).Return(nil)
+			}
+
+			batchToProve, _, err := a.getAndLockBatchToProve(ctx, prover)
+			if tc.profitable {
+				require.NoError(t, err)
+				assert.Equal(t, batch, batchToProve)
+			} else {
+				assert.ErrorIs(t, err, state.ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestGetAndLockBatchToProveL1Failure(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		State:        st,
+		Ethman:       eth,
+		StateDBMutex: &sync.Mutex{},
+	}
+	ctx := context.Background()
+
+	// the batch is selected after the local last verified batch
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(0), errors.New("connection refused")).Once()
+	st.On("GetVirtualBatchToProve", ctx, uint64(10), []uint64(nil), nil).Return(nil, state.ErrNotFound)
+
+	_, _, err := a.getAndLockBatchToProve(ctx, prover)
+	assert.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestGetAndLockBatchToProveCachedL1Frontier(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		State:        st,
+		Ethman:       eth,
+		StateDBMutex: &sync.Mutex{},
+		l1Frontier:   newL1VerifiedFrontier(time.Minute),
+	}
+	ctx := context.Background()
+
+	// the frontier polled by isSynced is used without querying L1 again
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil).Once()
+	assert.False(t, a.isSynced(ctx))
+	st.On("GetVirtualBatchToProve", ctx, uint64(12), []uint64(nil), nil).Return(nil, state.ErrNotFound).Twice()
+
+	_, _, err := a.getAndLockBatchToProve(ctx, prover)
+	assert.ErrorIs(t, err, state.ErrNotFound)
+	_, _, err = a.getAndLockBatchToProve(ctx, prover)
+	assert.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestGetAndLockBatchToProveLocalStateAhead(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		State:        st,
+		Ethman:       eth,
+		StateDBMutex: &sync.Mutex{},
+	}
+	ctx := context.Background()
+
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(8), nil)
+	st.On("GetVirtualBatchToProve", ctx, uint64(10), []uint64(nil), nil).Return(nil, state.ErrNotFound)
+
+	_, _, err := a.getAndLockBatchToProve(ctx, prover)
+	assert.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestGetAndLockBatchToProveWrongForkID(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		cfg: Config{
+			ForkIDIntervals: []ForkIDInterval{
+				{FromBatchNumber: 0, ToBatchNumber: 10, ForkID: 1},
+				{FromBatchNumber: 11, ToBatchNumber: 100, ForkID: 2},
+			},
+		},
+		State:        st,
+		Ethman:       eth,
+		StateDBMutex: &sync.Mutex{},
+	}
+	ctx := context.Background()
+
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(10), nil)
+	st.On("GetVirtualBatchToProve", ctx, uint64(10), []uint64(nil), nil).Return(&state.Batch{BatchNumber: 11}, nil)
+	prover.On("ForkID").Return(uint64(1))
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+
+	_, _, err := a.getAndLockBatchToProve(ctx, prover)
+	assert.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestForkIDForBatch(t *testing.T) {
+	a := Aggregator{
+		cfg: Config{
+			ForkIDIntervals: []ForkIDInterval{
+				{FromBatchNumber: 0, ToBatchNumber: 10, ForkID: 1},
+				{FromBatchNumber: 11, ToBatchNumber: 100, ForkID: 2},
+			},
+		},
+	}
+	assert.Equal(t, uint64(1), a.forkIDForBatch(0))
+	assert.Equal(t, uint64(1), a.forkIDForBatch(10))
+	assert.Equal(t, uint64(2), a.forkIDForBatch(11))
+	assert.Equal(t, uint64(0), a.forkIDForBatch(101))
+}
+
+func largeInputProver() *pb.InputProver {
+	const batchL2DataSize = 8 * 1024 * 1024
+	return &pb.InputProver{
+		PublicInputs: &pb.PublicInputs{
+			OldStateRoot: make([]byte, 32),
+			BatchL2Data:  make([]byte, batchL2DataSize),
+		},
+		Db:                map[string]string{},
+		ContractsBytecode: map[string]string{},
+	}
+}
+
+func TestSerializeInputProver(t *testing.T) {
+	a := Aggregator{serializationSem: make(chan struct{}, 1)}
+	inputProver := largeInputProver()
+
+	expected, err := json.Marshal(inputProver)
+	require.NoError(t, err)
+
+	actual, err := a.serializeInputProver(context.Background(), inputProver)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), actual)
+}
+
+func BenchmarkSerializeInputProverMarshal(b *testing.B) {
+	inputProver := largeInputProver()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bytes, err := json.Marshal(inputProver)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = string(bytes)
+	}
+}
+
+func BenchmarkSerializeInputProver(b *testing.B) {
+	a := Aggregator{}
+	ctx := context.Background()
+	inputProver := largeInputProver()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := a.serializeInputProver(ctx, inputProver)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestProverContextCancellationDoesNotAbortProofUpdate(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		State:                   st,
+		Ethman:                  eth,
+		ProfitabilityChecker:    pc,
+		StateDBMutex:            &sync.Mutex{},
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		// final proof can't be sent yet
+		TimeSendFinalProof: time.Now().Add(time.Hour),
+	}
+	var cancelServer context.CancelFunc
+	a.ctx, cancelServer = context.WithCancel(context.Background())
+	defer cancelServer()
+
+	streamCtx, cancelStream := context.WithCancel(context.Background())
+	ctx, cancel := a.proverContext(streamCtx)
+	defer cancel()
+
+	proofID := "proofID"
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	prover.On("ForkID").Return(uint64(0))
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{BatchNumber: 1}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(1), nil)
+	st.On("GetVirtualBatchToProve", mock.Anything, uint64(1), []uint64(nil), nil).Return(&state.Batch{BatchNumber: 2}, nil)
+	pc.On("IsProfitable", mock.Anything, big.NewInt(0)).Return(true, nil)
+	eth.On("GetAggregatorReward").Return(big.NewInt(0), nil)
+	st.On("AddGeneratedProof", mock.Anything, mock.Anything, nil).Return(nil)
+	st.On("GetBatchByNumber", mock.Anything, uint64(1), nil).Return(&state.Batch{BatchNumber: 1}, nil)
+	eth.On("GetPublicAddress").Return(common.Address{}, nil)
+	prover.On("BatchProof", mock.Anything).Return(&proofID, nil)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("proof", nil).Run(func(args mock.Arguments) {
+		// the prover disconnects right after returning the proof
+		cancelStream()
+	})
+	st.On("UpdateGeneratedProof", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), mock.Anything, nil).Return(nil)
+
+	generated, err := a.tryGenerateBatchProof(ctx, prover)
+	require.NoError(t, err)
+	assert.True(t, generated)
+	assert.Error(t, ctx.Err())
+}
+
+func TestProverContextCanceledOnServerStop(t *testing.T) {
+	a := Aggregator{}
+	var cancelServer context.CancelFunc
+	a.ctx, cancelServer = context.WithCancel(context.Background())
+
+	ctx, cancel := a.proverContext(context.Background())
+	defer cancel()
+
+	cancelServer()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("prover context not canceled when the server stopped")
+	}
+}
+
+func TestStartWithCanceledContext(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	a := Aggregator{State: st}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// nothing is started, so no state calls are expected
+	err := a.Start(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	a.Stop()
+}
+
+func TestStartListenFailure(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close() //nolint:errcheck
+
+	st := mocks.NewStateMock(t)
+	st.On("RecoverGeneratingProofs", mock.Anything, nil).Return(uint64(0), uint64(0), nil).Once()
+	a := Aggregator{
+		cfg:   Config{Host: "127.0.0.1", Port: lis.Addr().(*net.TCPAddr).Port},
+		State: st,
+	}
+
+	// the port is in use, the error is returned instead of exiting
+	err = a.Start(context.Background())
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, context.Canceled)
+}
+
+func TestIsSyncedAheadOfL1(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		notSyncedWhenAheadOfL1 bool
+		expected               bool
+	}{
+		{name: "anomaly logged", notSyncedWhenAheadOfL1: false, expected: true},
+		{name: "not synced", notSyncedWhenAheadOfL1: true, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mocks.NewStateMock(t)
+			eth := mocks.NewEtherman(t)
+			a := Aggregator{
+				cfg:    Config{NotSyncedWhenAheadOfL1: tc.notSyncedWhenAheadOfL1},
+				State:  st,
+				Ethman: eth,
+			}
+			ctx := context.Background()
+
+			st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+			eth.On("GetLatestVerifiedBatchNum").Return(uint64(8), nil)
+
+			assert.Equal(t, tc.expected, a.isSynced(ctx))
+		})
+	}
+}
+
+func TestTryGenerateBatchProofTimeout(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		cfg: Config{
+			BatchProofTimeout: types.NewDuration(10 * time.Millisecond),
+		},
+		State:                st,
+		Ethman:               eth,
+		ProfitabilityChecker: pc,
+		StateDBMutex:         &sync.Mutex{},
+	}
+	a.ctx = context.Background()
+	ctx := context.Background()
+
+	proofID := "proofID"
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	prover.On("ForkID").Return(uint64(0))
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 1}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(1), nil)
+	st.On("GetVirtualBatchToProve", ctx, uint64(1), []uint64(nil), nil).Return(&state.Batch{BatchNumber: 2}, nil)
+	pc.On("IsProfitable", ctx, big.NewInt(0)).Return(true, nil)
+	eth.On("GetAggregatorReward").Return(big.NewInt(0), nil)
+	st.On("AddGeneratedProof", ctx, mock.Anything, nil).Return(nil)
+	st.On("GetBatchByNumber", ctx, uint64(1), nil).Return(&state.Batch{BatchNumber: 1}, nil)
+	eth.On("GetPublicAddress").Return(common.Address{}, nil)
+	prover.On("BatchProof", mock.Anything).Return(&proofID, nil)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("", context.DeadlineExceeded).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	})
+	st.On("DeleteGeneratedProofs", mock.Anything, uint64(2), uint64(2), nil).Return(nil)
+
+	generated, err := a.tryGenerateBatchProof(ctx, prover)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, ErrProofTimeout)
+	assert.False(t, generated)
+}
+
+func TestTryAggregateProofsHungProver(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	prover := mocks.NewProverMock(t)
+	dbTx := mocks.NewDbTxMock(t)
+	a := Aggregator{
+		cfg: Config{
+			// the proof generation timeout caps the aggregated proof one
+			AggregatedProofTimeout: types.NewDuration(time.Hour),
+			ProofGenerationTimeout: types.NewDuration(10 * time.Millisecond),
+		},
+		State:        st,
+		StateDBMutex: &sync.Mutex{},
+	}
+	a.ctx = context.Background()
+	ctx := context.Background()
+
+	proofID := "proofID"
+	proof1 := &state.Proof{BatchNumber: 1, BatchNumberFinal: 2, Proof: "proof1"}
+	proof2 := &state.Proof{BatchNumber: 3, BatchNumberFinal: 4, Proof: "proof2"}
+	proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 4, ProofID: &proofID}
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	// the prover never returns the proof
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("", context.DeadlineExceeded).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	})
+	// the aggregated proofs are unlocked
+	st.On("BeginStateTransaction", mock.Anything).Return(dbTx, nil).Once()
+	st.On("UpdateGeneratedProof", mock.Anything, proof1, dbTx).Return(nil).Once()
+	st.On("UpdateGeneratedProof", mock.Anything, proof2, dbTx).Return(nil).Once()
+	dbTx.On("Commit", mock.Anything).Return(nil).Once()
+
+	generated, err := a.completeAggregatedProof(ctx, prover, proof1, proof2, proof)
+	assert.ErrorIs(t, err, ErrProofTimeout)
+	assert.False(t, generated)
+}
+
+func TestCheckProofTimeout(t *testing.T) {
+	waitCtx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-waitCtx.Done()
+	assert.ErrorIs(t, checkProofTimeout(context.Background(), waitCtx, waitCtx.Err()), ErrProofTimeout)
+
+	// the prover disconnected, not hung
+	ctx, cancelParent := context.WithCancel(context.Background())
+	cancelParent()
+	waitCtx, cancel = context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	assert.NotErrorIs(t, checkProofTimeout(ctx, waitCtx, waitCtx.Err()), ErrProofTimeout)
+}
+
+func TestProofTimeout(t *testing.T) {
+	a := Aggregator{}
+	assert.Equal(t, time.Duration(0), a.proofTimeout(0))
+	assert.Equal(t, time.Minute, a.proofTimeout(time.Minute))
+
+	a.cfg.ProofGenerationTimeout = types.NewDuration(time.Minute)
+	assert.Equal(t, time.Minute, a.proofTimeout(0))
+	assert.Equal(t, time.Second, a.proofTimeout(time.Second))
+	assert.Equal(t, time.Minute, a.proofTimeout(time.Hour))
+}
+
+func TestTryAggregateProofsOverlapping(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	prover := mocks.NewProverMock(t)
+	dbTx := mocks.NewDbTxMock(t)
+	a := Aggregator{
+		State:        st,
+		StateDBMutex: &sync.Mutex{},
+	}
+	a.ctx = context.Background()
+	ctx := context.Background()
+
+	proof1 := &state.Proof{BatchNumber: 1, BatchNumberFinal: 5}
+	proof2 := &state.Proof{BatchNumber: 4, BatchNumberFinal: 8}
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	st.On("GetProofsToAggregate", ctx, uint64(0), uint64(0), nil).Return(proof1, proof2, nil)
+	st.On("BeginStateTransaction", ctx).Return(dbTx, nil).Twice()
+	st.On("UpdateGeneratedProof", ctx, proof1, dbTx).Return(nil).Twice()
+	st.On("UpdateGeneratedProof", ctx, proof2, dbTx).Return(nil).Twice()
+	dbTx.On("Commit", ctx).Return(nil).Twice()
+
+	aggregated, err := a.tryAggregateProofs(ctx, prover)
+	assert.ErrorIs(t, err, ErrOverlappingProofs)
+	assert.False(t, aggregated)
+	assert.False(t, proof1.Generating)
+	assert.False(t, proof2.Generating)
+}
+
+func TestCapabilitiesLabel(t *testing.T) {
+	testCases := []struct {
+		name         string
+		capabilities map[string]bool
+		expected     string
+	}{
+		{
+			name:         "all capabilities",
+			capabilities: map[string]bool{"batch_proof": true, "aggregated_proof": true, "final_proof": true},
+			expected:     "batch_proof,aggregated_proof,final_proof",
+		},
+		{
+			name:         "no final proof",
+			capabilities: map[string]bool{"batch_proof": true, "aggregated_proof": true, "final_proof": false},
+			expected:     "batch_proof,aggregated_proof",
+		},
+		{
+			name:         "none",
+			capabilities: map[string]bool{"batch_proof": false, "aggregated_proof": false, "final_proof": false},
+			expected:     "none",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prover := mocks.NewProverMock(t)
+			for capability, supported := range tc.capabilities {
+				prover.On("HasCapability", capability).Return(supported)
+			}
+
+			assert.Equal(t, tc.expected, capabilitiesLabel(prover))
+		})
+	}
+}
+
+func TestGetAndLockProofReadyToVerifyProverCapabilities(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{
+		FilterProofsByProverCapabilities: true,
+		ForkIDIntervals: []ForkIDInterval{
+			{FromBatchNumber: 0, ToBatchNumber: 10, ForkID: 1},
+			{FromBatchNumber: 11, ToBatchNumber: 100, ForkID: 2},
+		},
+	}
+
+	t.Run("prover without final proof capability", func(t *testing.T) {
+		st := mocks.NewStateMock(t)
+		prover := mocks.NewProverMock(t)
+		a := Aggregator{cfg: cfg, State: st, StateDBMutex: &sync.Mutex{}}
+
+		prover.On("HasCapability", "final_proof").Return(false)
+		prover.On("ID").Return("prover-1")
+		prover.On("Name").Return("addr")
+
+		_, err := a.getAndLockProofReadyToVerify(ctx, prover, 10)
+		assert.ErrorIs(t, err, state.ErrNotFound)
+	})
+
+	t.Run("prover with another fork id", func(t *testing.T) {
+		st := mocks.NewStateMock(t)
+		prover := mocks.NewProverMock(t)
+		a := Aggregator{cfg: cfg, State: st, StateDBMutex: &sync.Mutex{}}
+
+		prover.On("HasCapability", "final_proof").Return(true)
+		prover.On("ForkID").Return(uint64(1))
+		prover.On("ID").Return("prover-1")
+		prover.On("Name").Return("addr")
+		st.On("GetProofReadyToVerify", ctx, uint64(10), mock.Anything, nil).Return(&state.Proof{BatchNumber: 11, BatchNumberFinal: 12}, nil)
+
+		_, err := a.getAndLockProofReadyToVerify(ctx, prover, 10)
+		assert.ErrorIs(t, err, state.ErrNotFound)
+	})
+
+	t.Run("capable prover", func(t *testing.T) {
+		st := mocks.NewStateMock(t)
+		prover := mocks.NewProverMock(t)
+		a := Aggregator{cfg: cfg, State: st, StateDBMutex: &sync.Mutex{}}
+		proof := &state.Proof{BatchNumber: 11, BatchNumberFinal: 12}
+
+		prover.On("HasCapability", "final_proof").Return(true)
+		prover.On("ForkID").Return(uint64(2))
+		st.On("GetProofReadyToVerify", ctx, uint64(10), mock.Anything, nil).Return(proof, nil)
+		st.On("UpdateGeneratedProof", ctx, proof, nil).Return(nil)
+
+		proofToVerify, err := a.getAndLockProofReadyToVerify(ctx, prover, 10)
+		require.NoError(t, err)
+		assert.True(t, proofToVerify.Generating)
+	})
+}
+
+func TestTryBuildFinalProofBatchOnlyProver(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{cfg: Config{FilterProofsByProverCapabilities: true}, State: st, StateDBMutex: &sync.Mutex{}}
+
+	prover.On("HasCapability", "final_proof").Return(false)
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+
+	// the batch-only prover is never asked to build the final proof, the
+	// proof is left to a prover able to
+	built, err := a.tryBuildFinalProof(context.Background(), prover, &state.Proof{BatchNumber: 1, BatchNumberFinal: 1})
+	require.NoError(t, err)
+	assert.False(t, built)
+}
+
+func TestTryBuildFinalProofMaxSyncWaitTime(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		cfg: Config{
+			RetryTime:       types.NewDuration(10 * time.Millisecond),
+			MaxSyncWaitTime: types.NewDuration(50 * time.Millisecond),
+		},
+		State:                   st,
+		Ethman:                  eth,
+		StateDBMutex:            &sync.Mutex{},
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+	}
+	ctx := context.Background()
+
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	// the synchronizer is permanently behind
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil)
+
+	built, err := a.tryBuildFinalProof(ctx, prover, nil)
+	require.ErrorIs(t, err, ErrSyncWaitTimeout)
+	assert.False(t, built)
+	// the verification is released
+	assert.True(t, a.canVerifyProof())
+}
+
+func TestValidateEligibleFinalProofCompleteSequences(t *testing.T) {
+	errAmbiguous := errors.New("ambiguous")
+	testCases := []struct {
+		name             string
+		failOpen         bool
+		complete         bool
+		checkErr         error
+		expectedCheckErr error
+		expectedEligible bool
+		expectedErr      error
+	}{
+		{
+			name:             "complete sequences",
+			complete:         true,
+			expectedEligible: true,
+		},
+		{
+			name:             "incomplete sequences",
+			expectedCheckErr: ErrIncompleteSequences,
+		},
+		{
+			name:             "transient error",
+			checkErr:         context.DeadlineExceeded,
+			expectedCheckErr: ErrCompleteSequencesCheckTransient,
+		},
+		{
+			name:             "ambiguous error fail closed",
+			checkErr:         errAmbiguous,
+			expectedCheckErr: errAmbiguous,
+			expectedErr:      errAmbiguous,
+		},
+		{
+			name:             "ambiguous error fail open",
+			failOpen:         true,
+			checkErr:         errAmbiguous,
+			expectedCheckErr: errAmbiguous,
+			expectedEligible: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			st := mocks.NewStateMock(t)
+			a := Aggregator{
+				cfg:   Config{CompleteSequencesCheckFailOpen: tc.failOpen},
+				State: st,
+			}
+			proof := &state.Proof{BatchNumber: 2, BatchNumberFinal: 5}
+			st.On("CheckProofContainsCompleteSequences", ctx, proof, nil).Return(tc.complete, tc.checkErr)
+
+			err := a.checkCompleteSequences(ctx, proof)
+			if tc.expectedCheckErr != nil {
+				assert.ErrorIs(t, err, tc.expectedCheckErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			eligible, err := a.validateEligibleFinalProof(ctx, proof, 1)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedEligible, eligible)
+		})
+	}
+}
+
+func TestMinBatchesToVerify(t *testing.T) {
+	testCases := []struct {
+		name             string
+		batchNumberFinal uint64
+		sinceLastVerify  time.Duration
+		expectedEligible bool
+	}{
+		{name: "enough batches", batchNumberFinal: 5, sinceLastVerify: time.Minute, expectedEligible: true},
+		{name: "too few batches", batchNumberFinal: 4, sinceLastVerify: time.Minute},
+		{name: "too few batches after the interval", batchNumberFinal: 4, sinceLastVerify: time.Hour, expectedEligible: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			st := mocks.NewStateMock(t)
+			a := Aggregator{
+				cfg: Config{
+					MinBatchesToVerify:                       4,
+					IntervalAfterWhichBatchConsolidateAnyway: types.NewDuration(30 * time.Minute),
+					VerifyProofInterval:                      types.NewDuration(time.Minute),
+				},
+				State:                   st,
+				TimeSendFinalProofMutex: &sync.RWMutex{},
+				StateDBMutex:            &sync.Mutex{},
+			}
+			a.setVerifyProofTime(time.Now().Add(-tc.sinceLastVerify))
+			proof := &state.Proof{BatchNumber: 2, BatchNumberFinal: tc.batchNumberFinal}
+
+			// the proof being generated
+			st.On("CheckProofContainsCompleteSequences", ctx, proof, nil).Return(true, nil).Once()
+			eligible, err := a.validateEligibleFinalProof(ctx, proof, 1)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedEligible, eligible)
+
+			// the proof ready to verify
+			st.On("GetProofReadyToVerify", ctx, uint64(1), mock.Anything, nil).Return(proof, nil).Once()
+			if tc.expectedEligible {
+				st.On("UpdateGeneratedProof", ctx, proof, nil).Return(nil).Once()
+			}
+			lockedProof, err := a.getAndLockProofReadyToVerify(ctx, mocks.NewProverMock(t), 1)
+			if tc.expectedEligible {
+				require.NoError(t, err)
+				assert.True(t, lockedProof.Generating)
+			} else {
+				assert.ErrorIs(t, err, state.ErrNotFound)
+			}
+		})
+	}
+
+	// the interval is required to not hold the batches forever
+	_, err := New(Config{MinBatchesToVerify: 4}, nil, nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestIsEmptyProof(t *testing.T) {
+	assert.True(t, isEmptyRecursiveProof(""))
+	assert.True(t, isEmptyRecursiveProof(" null "))
+	assert.True(t, isEmptyRecursiveProof("{}"))
+	assert.False(t, isEmptyRecursiveProof(`{"proof":"0x01"}`))
+
+	assert.True(t, isEmptyFinalProof(nil))
+	assert.True(t, isEmptyFinalProof(&pb.FinalProof{}))
+	assert.True(t, isEmptyFinalProof(&pb.FinalProof{Proof: &pb.Proof{}, Public: &pb.PublicInputsExtended{}}))
+	assert.False(t, isEmptyFinalProof(&pb.FinalProof{
+		Proof: &pb.Proof{
+			ProofA: []string{"a"},
+			ProofB: []*pb.ProofB{{Proofs: []string{"b"}}},
+			ProofC: []string{"c"},
+		},
+		Public: &pb.PublicInputsExtended{},
+	}))
+}
+
+func TestTryGenerateBatchProofEmptyProof(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	prover := mocks.NewProverMock(t)
+	a := newDisconnectTestAggregator(st, eth, pc, time.Hour)
+
+	proofID := "proofID"
+	expectBatchProofRequest(st, eth, pc, prover, &proofID)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("", nil)
+	// the proof is unlocked instead of stored
+	st.On("DeleteGeneratedProofs", mock.Anything, uint64(2), uint64(2), nil).Return(nil).Once()
+
+	generated, err := a.tryGenerateBatchProof(context.Background(), prover)
+	assert.ErrorIs(t, err, ErrEmptyProof)
+	assert.False(t, generated)
+	assert.Nil(t, a.held.take("prover-1"))
+}
+
+func TestTryAggregateProofsEmptyProof(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	prover := mocks.NewProverMock(t)
+	dbTx := mocks.NewDbTxMock(t)
+	a := Aggregator{
+		State:        st,
+		StateDBMutex: &sync.Mutex{},
+	}
+	a.ctx = context.Background()
+	ctx := context.Background()
+
+	proofID := "proofID"
+	proof1 := &state.Proof{BatchNumber: 1, BatchNumberFinal: 3, Proof: "proof1"}
+	proof2 := &state.Proof{BatchNumber: 4, BatchNumberFinal: 8, Proof: "proof2"}
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	st.On("GetProofsToAggregate", ctx, uint64(0), uint64(0), nil).Return(proof1, proof2, nil)
+	st.On("BeginStateTransaction", mock.Anything).Return(dbTx, nil).Twice()
+	st.On("UpdateGeneratedProof", mock.Anything, proof1, dbTx).Return(nil).Twice()
+	st.On("UpdateGeneratedProof", mock.Anything, proof2, dbTx).Return(nil).Twice()
+	dbTx.On("Commit", mock.Anything).Return(nil).Twice()
+	prover.On("AggregatedProof", "proof1", "proof2").Return(&proofID, nil)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("null", nil)
+
+	aggregated, err := a.tryAggregateProofs(ctx, prover)
+	assert.ErrorIs(t, err, ErrEmptyProof)
+	assert.False(t, aggregated)
+	// both proofs are unlocked
+	assert.False(t, proof1.Generating)
+	assert.False(t, proof2.Generating)
+}
+
+func TestBuildFinalProofEmptyProof(t *testing.T) {
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{Ethman: eth}
+
+	proofID := "finalProofID"
+	proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 8, Proof: "proof"}
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	eth.On("GetPublicAddress").Return(common.Address{}, nil)
+	prover.On("FinalProof", "proof", common.Address{}.String()).Return(&proofID, nil)
+	prover.On("WaitFinalProof", mock.Anything, proofID).Return(&pb.FinalProof{}, nil)
+
+	finalProof, err := a.buildFinalProof(context.Background(), prover, proof)
+	assert.ErrorIs(t, err, ErrEmptyProof)
+	assert.Nil(t, finalProof)
+}
+
+func TestBuildFinalProofMockProverValues(t *testing.T) {
+	proverRoot := common.HexToHash("0x090bcaf734c4f06c93954a827b45a6e8c67b8e0fd1e0a35a1c5982d6961828f9")
+	proverExitRoot := common.HexToHash("0x17c04c3760510b48c6012742c540a81aba4bca2f78b9d14bfd2f123e2e53ea3e")
+	batch := &state.Batch{BatchNumber: 8, StateRoot: common.HexToHash("0x01"), LocalExitRoot: common.HexToHash("0x02")}
+
+	testCases := []struct {
+		name             string
+		useMockValues    bool
+		expectedRoot     common.Hash
+		expectedExitRoot common.Hash
+	}{
+		{name: "prover values", expectedRoot: proverRoot, expectedExitRoot: proverExitRoot},
+		{name: "executor values", useMockValues: true, expectedRoot: batch.StateRoot, expectedExitRoot: batch.LocalExitRoot},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mocks.NewStateMock(t)
+			eth := mocks.NewEtherman(t)
+			prover := mocks.NewProverMock(t)
+			a := Aggregator{cfg: Config{UseMockProverValues: tc.useMockValues}, State: st, Ethman: eth}
+
+			proofID := "finalProofID"
+			proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 8, Proof: "proof"}
+			prover.On("ID").Return("prover-1")
+			prover.On("Name").Return("addr")
+			eth.On("GetPublicAddress").Return(common.Address{}, nil)
+			prover.On("FinalProof", "proof", common.Address{}.String()).Return(&proofID, nil)
+			prover.On("WaitFinalProof", mock.Anything, proofID).Return(&pb.FinalProof{
+				Proof: &pb.Proof{
+					ProofA: []string{"a"},
+					ProofB: []*pb.ProofB{{Proofs: []string{"b"}}},
+					ProofC: []string{"c"},
+				},
+				Public: &pb.PublicInputsExtended{NewStateRoot: proverRoot.Bytes(), NewLocalExitRoot: proverExitRoot.Bytes()},
+			}, nil)
+			if tc.useMockValues {
+				st.On("GetBatchByNumber", mock.Anything, uint64(8), nil).Return(batch, nil).Once()
+			}
+
+			finalProof, err := a.buildFinalProof(context.Background(), prover, proof)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRoot.Bytes(), finalProof.Public.NewStateRoot)
+			assert.Equal(t, tc.expectedExitRoot.Bytes(), finalProof.Public.NewLocalExitRoot)
+		})
+	}
+}
+
+func TestBuildFinalProofNilProof(t *testing.T) {
+	eth := mocks.NewEtherman(t)
+	a := Aggregator{Ethman: eth}
+	eth.On("GetPublicAddress").Return(common.Address{}, nil)
+
+	t.Run("nil final proof", func(t *testing.T) {
+		prover := mocks.NewProverMock(t)
+		proofID := "finalProofID"
+		proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 8, Proof: "proof"}
+		prover.On("ID").Return("prover-1")
+		prover.On("Name").Return("addr")
+		prover.On("FinalProof", "proof", common.Address{}.String()).Return(&proofID, nil)
+		prover.On("WaitFinalProof", mock.Anything, proofID).Return(nil, nil)
+
+		finalProof, err := a.buildFinalProof(context.Background(), prover, proof)
+		assert.ErrorIs(t, err, ErrNilProof)
+		assert.Nil(t, finalProof)
+	})
+
+	t.Run("nil final proof id", func(t *testing.T) {
+		prover := mocks.NewProverMock(t)
+		proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 8, Proof: "proof"}
+		prover.On("ID").Return("prover-1")
+		prover.On("Name").Return("addr")
+		prover.On("FinalProof", "proof", common.Address{}.String()).Return(nil, nil)
+
+		finalProof, err := a.buildFinalProof(context.Background(), prover, proof)
+		assert.ErrorIs(t, err, ErrNilProof)
+		assert.Nil(t, finalProof)
+	})
+}
+
+func TestGetFinalBatchRetries(t *testing.T) {
+	ctx := context.Background()
+	st := mocks.NewStateMock(t)
+	a := Aggregator{
+		cfg: Config{
+			FinalBatchRetries:       3,
+			FinalBatchRetryInterval: types.NewDuration(time.Millisecond),
+		},
+		State: st,
+	}
+
+	// transient failure followed by success
+	st.On("GetBatchByNumber", ctx, uint64(8), nil).Return(nil, state.ErrNotFound).Twice()
+	st.On("GetBatchByNumber", ctx, uint64(8), nil).Return(&state.Batch{BatchNumber: 8}, nil).Once()
+
+	batch, err := a.getFinalBatch(ctx, 8)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), batch.BatchNumber)
+
+	// retries exhausted
+	st.On("GetBatchByNumber", ctx, uint64(9), nil).Return(nil, state.ErrNotFound).Times(4)
+
+	_, err = a.getFinalBatch(ctx, 9)
+	assert.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestResumeVerifiedProofsAfterRestart(t *testing.T) {
+	proofID := "proofID"
+	proof := &state.Proof{BatchNumber: 11, BatchNumberFinal: 12, ProofID: &proofID, Generating: true}
+
+	// the final proof is verified but the aggregator restarts before the
+	// synchronizer catches up
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	ethTxMan := mocks.NewEthTxManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	a := Aggregator{
+		cfg:                     Config{RetryTime: types.NewDuration(10 * time.Millisecond)},
+		State:                   st,
+		Ethman:                  eth,
+		EthTxManager:            ethTxMan,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		finalProof:              make(chan finalProofMsg),
+		verifications:           newVerificationHistory(10),
+		ctx:                     ctx,
+	}
+	st.On("GetBatchByNumber", ctx, uint64(12), nil).Return(&state.Batch{BatchNumber: 12}, nil).Once()
+	tx := ethTypes.NewTransaction(1, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	ethTxMan.On("VerifyBatches", ctx, uint64(10), uint64(12), mock.Anything, (*big.Int)(nil)).Return(tx, nil).Once()
+	marked := make(chan struct{})
+	st.On("AddProofVerification", ctx, mock.Anything, nil).Return(nil).Once()
+	st.On("MarkProofVerified", ctx, uint64(11), uint64(12), nil).Return(nil).Once().Run(func(mock.Arguments) { close(marked) })
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	st.On("SetLastVerifyProofTime", mock.Anything, mock.Anything, nil).Return(nil).Maybe()
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil)
+
+	done := make(chan struct{})
+	go func() {
+		a.sendFinalProof()
+		close(done)
+	}()
+	a.finalProof <- finalProofMsg{proverID: "prover", recursiveProof: proof, finalProof: &pb.FinalProof{}}
+	<-marked
+	cancel()
+	<-done
+
+	// after restarting the clean up is resumed instead of verifying the
+	// proof again
+	st = mocks.NewStateMock(t)
+	eth = mocks.NewEtherman(t)
+	ctx = context.Background()
+	a = Aggregator{
+		cfg:                     Config{RetryTime: types.NewDuration(100 * time.Millisecond)},
+		State:                   st,
+		Ethman:                  eth,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+	}
+	st.On("GetVerifiedProofs", ctx, nil).Return([]*state.Proof{{BatchNumber: 11, BatchNumberFinal: 12, ProofID: &proofID}}, nil).Once()
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil).Once()
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 12}, nil).Once()
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil)
+	deleted := make(chan struct{})
+	st.On("DeleteGeneratedProofs", ctx, uint64(11), uint64(12), nil).Return(nil).Once().Run(func(mock.Arguments) { close(deleted) })
+	st.On("SetLastVerifyProofTime", mock.Anything, mock.Anything, nil).Return(nil).Once()
+
+	require.NoError(t, a.resumeVerifiedProofs(ctx))
+	// the verification is held until the proof is cleaned up
+	assert.False(t, a.canVerifyProof())
+
+	select {
+	case <-deleted:
+	case <-time.After(time.Second):
+		require.Fail(t, "verified proof not cleaned up")
+	}
+	assert.Eventually(t, a.canVerifyProof, time.Second, 10*time.Millisecond)
+}
+
+func TestTryAggregateProofsMaxDepth(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{
+		cfg:          Config{MaxAggregationDepth: 2},
+		State:        st,
+		StateDBMutex: &sync.Mutex{},
+	}
+	ctx := context.Background()
+
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	// the proofs at the max depth are left to build the final proof
+	st.On("GetProofsToAggregate", ctx, uint64(2), uint64(0), nil).Return(nil, nil, state.ErrNotFound).Once()
+
+	aggregated, err := a.tryAggregateProofs(ctx, prover)
+	assert.NoError(t, err)
+	assert.False(t, aggregated)
+
+	assert.Equal(t, uint64(1), aggregationDepth(&state.Proof{}, &state.Proof{}))
+	assert.Equal(t, uint64(3), aggregationDepth(&state.Proof{Depth: 2}, &state.Proof{Depth: 1}))
+	assert.Equal(t, uint64(3), aggregationDepth(&state.Proof{Depth: 0}, &state.Proof{Depth: 2}))
+}
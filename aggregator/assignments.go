@@ -0,0 +1,124 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// ProverAssignment is the work a connected prover is currently doing.
+type ProverAssignment struct {
+	ProverID         string           `json:"proverId"`
+	ProverAddr       string           `json:"proverAddr"`
+	ProverName       string           `json:"proverName"`
+	Operation        ChannelOperation `json:"operation"`
+	BatchNumber      uint64           `json:"batchNumber"`
+	BatchNumberFinal uint64           `json:"batchNumberFinal"`
+	StartedAt        time.Time        `json:"startedAt"`
+	Elapsed          string           `json:"elapsed"`
+}
+
+type proverKey struct {
+	id   string
+	addr string
+}
+
+// proverAssignments tracks in memory the work assigned to each connected
+// prover.
+type proverAssignments struct {
+	mu          sync.RWMutex
+	assignments map[proverKey]ProverAssignment
+}
+
+func newProverAssignments() *proverAssignments {
+	return &proverAssignments{
+		assignments: make(map[proverKey]ProverAssignment),
+	}
+}
+
+// assign records the work assigned to the prover.
+func (p *proverAssignments) assign(prover proverInterface, op ChannelOperation, batchNumber, batchNumberFinal uint64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.assignments[proverKey{id: prover.ID(), addr: prover.Addr()}] = ProverAssignment{
+		ProverID:         prover.ID(),
+		ProverAddr:       prover.Addr(),
+		ProverName:       prover.Name(),
+		Operation:        op,
+		BatchNumber:      batchNumber,
+		BatchNumberFinal: batchNumberFinal,
+		StartedAt:        time.Now(),
+	}
+}
+
+// clear removes the assignment of the prover, if any.
+func (p *proverAssignments) clear(prover proverInterface) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.assignments, proverKey{id: prover.ID(), addr: prover.Addr()})
+}
+
+// isAssigned returns whether any prover with the given id has work assigned.
+func (p *proverAssignments) isAssigned(proverID string) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for key := range p.assignments {
+		if key.id == proverID {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the current assignments sorted by prover id and address.
+func (p *proverAssignments) list() []ProverAssignment {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	now := time.Now()
+	assignments := make([]ProverAssignment, 0, len(p.assignments))
+	for _, assignment := range p.assignments {
+		assignment.Elapsed = now.Sub(assignment.StartedAt).Round(time.Second).String()
+		assignments = append(assignments, assignment)
+	}
+	sort.Slice(assignments, func(i, j int) bool {
+		if assignments[i].ProverID != assignments[j].ProverID {
+			return assignments[i].ProverID < assignments[j].ProverID
+		}
+		return assignments[i].ProverAddr < assignments[j].ProverAddr
+	})
+	return assignments
+}
+
+// ProverAssignments returns the work currently assigned to each connected
+// prover.
+func (a *Aggregator) ProverAssignments() []ProverAssignment {
+	return a.assignments.list()
+}
+
+// handleProverAssignments serves the current prover assignments as JSON.
+func (a *Aggregator) handleProverAssignments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.ProverAssignments()); err != nil {
+		log.Errorf("Failed to encode prover assignments, err: %v", err)
+	}
+}
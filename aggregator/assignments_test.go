@@ -0,0 +1,49 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProverAssignments(t *testing.T) {
+	prover1 := mocks.NewProverMock(t)
+	prover1.On("ID").Return("prover-1")
+	prover1.On("Addr").Return("addr-1")
+	prover1.On("Name").Return("name-1")
+	prover2 := mocks.NewProverMock(t)
+	prover2.On("ID").Return("prover-2")
+	prover2.On("Addr").Return("addr-2")
+	prover2.On("Name").Return("name-2")
+
+	a := Aggregator{assignments: newProverAssignments()}
+	a.assignments.assign(prover2, ChannelOperationAggregateProofs, 1, 8)
+	a.assignments.assign(prover1, ChannelOperationGenerateBatchProof, 9, 9)
+
+	rec := httptest.NewRecorder()
+	a.handleProverAssignments(rec, httptest.NewRequest(http.MethodGet, "/status/provers", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var assignments []ProverAssignment
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&assignments))
+	require.Len(t, assignments, 2)
+	assert.Equal(t, "prover-1", assignments[0].ProverID)
+	assert.Equal(t, "addr-1", assignments[0].ProverAddr)
+	assert.Equal(t, "name-1", assignments[0].ProverName)
+	assert.Equal(t, ChannelOperationGenerateBatchProof, assignments[0].Operation)
+	assert.Equal(t, uint64(9), assignments[0].BatchNumber)
+	assert.Equal(t, uint64(9), assignments[0].BatchNumberFinal)
+	assert.NotEmpty(t, assignments[0].Elapsed)
+	assert.Equal(t, "prover-2", assignments[1].ProverID)
+	assert.Equal(t, ChannelOperationAggregateProofs, assignments[1].Operation)
+
+	a.assignments.clear(prover1)
+	assignments = a.ProverAssignments()
+	require.Len(t, assignments, 1)
+	assert.Equal(t, "prover-2", assignments[0].ProverID)
+}
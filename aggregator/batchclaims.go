@@ -0,0 +1,84 @@
+package aggregator
+
+import (
+	"sort"
+	"sync"
+)
+
+// batchProofClaims tracks the batches being proven by the connected provers,
+// so each idle prover locks a distinct batch and no two provers are handed
+// the same one while its proof is not stored yet.
+type batchProofClaims struct {
+	mu      sync.Mutex
+	batches map[uint64]string
+	max     int
+}
+
+func newBatchProofClaims(max int) *batchProofClaims {
+	return &batchProofClaims{
+		batches: make(map[uint64]string),
+		max:     max,
+	}
+}
+
+// excluded returns the batch numbers being proven, sorted. It's safe to call
+// it on a nil tracker.
+func (c *batchProofClaims) excluded() []uint64 {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.batches) == 0 {
+		return nil
+	}
+	batchNumbers := make([]uint64, 0, len(c.batches))
+	for batchNumber := range c.batches {
+		batchNumbers = append(batchNumbers, batchNumber)
+	}
+	sort.Slice(batchNumbers, func(i, j int) bool { return batchNumbers[i] < batchNumbers[j] })
+	return batchNumbers
+}
+
+// full returns whether the max number of batches are being proven.
+func (c *batchProofClaims) full() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.max > 0 && len(c.batches) >= c.max
+}
+
+// claim records the batch as being proven by the prover, it returns false if
+// the batch is already being proven or the max number of batches are being
+// proven.
+func (c *batchProofClaims) claim(batchNumber uint64, proverID string) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.batches[batchNumber]; ok {
+		return false
+	}
+	if c.max > 0 && len(c.batches) >= c.max {
+		return false
+	}
+	c.batches[batchNumber] = proverID
+	return true
+}
+
+// release removes the batch, once its proof is stored or given up.
+func (c *batchProofClaims) release(batchNumber uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.batches, batchNumber)
+}
@@ -0,0 +1,129 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// provingStateStub serves the virtual batches to prove. The generated proofs
+// are never stored, so only the claims keep the provers off the batches
+// being proven.
+type provingStateStub struct {
+	*sequencesStateStub
+}
+
+func (s *provingStateStub) GetLastVerifiedBatch(ctx context.Context, dbTx pgx.Tx) (*state.VerifiedBatch, error) {
+	return &state.VerifiedBatch{}, nil
+}
+
+func (s *provingStateStub) AddGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	// widen the window between the selection and the lock of the batch
+	time.Sleep(10 * time.Millisecond)
+	return nil
+}
+
+func newProvingAggregator(t *testing.T, maxConcurrentBatchProofs int) Aggregator {
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(0), nil)
+	pc.On("IsProfitable", mock.Anything, mock.Anything).Return(true, nil).Maybe()
+	eth.On("GetAggregatorReward").Return(big.NewInt(0), nil).Maybe()
+
+	return Aggregator{
+		State:                &provingStateStub{&sequencesStateStub{virtualBatches: []uint64{1, 2, 3, 4, 5}}},
+		Ethman:               eth,
+		ProfitabilityChecker: pc,
+		StateDBMutex:         &sync.Mutex{},
+		batchClaims:          newBatchProofClaims(maxConcurrentBatchProofs),
+	}
+}
+
+func newIdleProver(t *testing.T, id string) *mocks.ProverMock {
+	prover := mocks.NewProverMock(t)
+	prover.On("ID").Return(id)
+	prover.On("Name").Return("addr").Maybe()
+	prover.On("ForkID").Return(uint64(0)).Maybe()
+	return prover
+}
+
+func TestGetAndLockBatchToProveDistinctBatches(t *testing.T) {
+	ctx := context.Background()
+	a := newProvingAggregator(t, 0)
+
+	const provers = 3
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		batches = make([]uint64, provers)
+		errs    = make([]error, provers)
+	)
+	for i := 0; i < provers; i++ {
+		prover := newIdleProver(t, fmt.Sprintf("prover-%d", i))
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			batch, _, err := a.getAndLockBatchToProve(ctx, prover)
+			errs[i] = err
+			if err == nil {
+				batches[i] = batch.BatchNumber
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	seen := make(map[uint64]bool)
+	for i := 0; i < provers; i++ {
+		require.NoError(t, errs[i])
+		assert.False(t, seen[batches[i]], "batch %d handed to two provers", batches[i])
+		seen[batches[i]] = true
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, a.batchClaims.excluded())
+
+	// a released batch is handed again
+	a.batchClaims.release(2)
+	batch, _, err := a.getAndLockBatchToProve(ctx, newIdleProver(t, "prover-3"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), batch.BatchNumber)
+}
+
+func TestGetAndLockBatchToProveMaxConcurrentBatchProofs(t *testing.T) {
+	ctx := context.Background()
+	a := newProvingAggregator(t, 2)
+
+	for i, expected := range []uint64{1, 2} {
+		batch, _, err := a.getAndLockBatchToProve(ctx, newIdleProver(t, fmt.Sprintf("prover-%d", i)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, batch.BatchNumber)
+	}
+
+	// the third prover is left idle while two batches are being proven
+	_, _, err := a.getAndLockBatchToProve(ctx, newIdleProver(t, "prover-2"))
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	a.batchClaims.release(1)
+	batch, _, err := a.getAndLockBatchToProve(ctx, newIdleProver(t, "prover-2"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), batch.BatchNumber)
+}
+
+func TestBatchProofClaimsNil(t *testing.T) {
+	var c *batchProofClaims
+
+	assert.True(t, c.claim(1, "prover"))
+	assert.False(t, c.full())
+	assert.Nil(t, c.excluded())
+	c.release(1)
+}
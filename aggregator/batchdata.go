@@ -0,0 +1,64 @@
+package aggregator
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// BatchL2DataMismatchError is returned when the accumulated input hash
+// computed from the L2 data stored for a batch doesn't match the one recorded
+// for the batch.
+type BatchL2DataMismatchError struct {
+	BatchNumber uint64
+	Expected    common.Hash
+	Computed    common.Hash
+}
+
+func (e *BatchL2DataMismatchError) Error() string {
+	return fmt.Sprintf("batch L2 data mismatch for batch [%d], expected acc input hash %s, computed %s",
+		e.BatchNumber, e.Expected, e.Computed)
+}
+
+// calculateAccInputHash computes the accumulated input hash of a batch the
+// same way the smart contract does when the batch is sequenced.
+func calculateAccInputHash(oldAccInputHash common.Hash, batchL2Data []byte, globalExitRoot common.Hash, timestamp uint64, sequencerAddr common.Address) common.Hash {
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], timestamp)
+	return crypto.Keccak256Hash(
+		oldAccInputHash.Bytes(),
+		crypto.Keccak256(batchL2Data),
+		globalExitRoot.Bytes(),
+		ts[:],
+		sequencerAddr.Bytes(),
+	)
+}
+
+// checkBatchL2Data verifies the L2 data stored for the batch against its
+// accumulated input hash, flagging the batch for inspection on a mismatch.
+// Batches without an accumulated input hash recorded are not checked.
+func (a *Aggregator) checkBatchL2Data(previousBatch, batch *state.Batch) error {
+	if !a.cfg.CheckBatchL2Data || batch.AccInputHash == (common.Hash{}) {
+		return nil
+	}
+
+	computed := calculateAccInputHash(previousBatch.AccInputHash, batch.BatchL2Data,
+		batch.GlobalExitRoot, uint64(batch.Timestamp.Unix()), batch.Coinbase)
+	if computed == batch.AccInputHash {
+		return nil
+	}
+
+	mismatchErr := &BatchL2DataMismatchError{
+		BatchNumber: batch.BatchNumber,
+		Expected:    batch.AccInputHash,
+		Computed:    computed,
+	}
+	log.Errorf("Batch [%d] flagged for inspection, its L2 data may be corrupted: %v", batch.BatchNumber, mismatchErr)
+	metrics.BatchL2DataMismatch()
+	return mismatchErr
+}
@@ -0,0 +1,81 @@
+package aggregator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBatchL2Data(t *testing.T) {
+	previousBatch := &state.Batch{BatchNumber: 9, AccInputHash: common.HexToHash("0x09")}
+	batchL2Data := []byte{0xee, 0x80, 0x84, 0x3b, 0x9a, 0xca, 0x00}
+	globalExitRoot := common.HexToHash("0x02")
+	timestamp := time.Unix(1671000000, 0)
+	coinbase := common.HexToAddress("0x03")
+	accInputHash := calculateAccInputHash(previousBatch.AccInputHash, batchL2Data, globalExitRoot, uint64(timestamp.Unix()), coinbase)
+
+	newBatch := func(data []byte) *state.Batch {
+		return &state.Batch{
+			BatchNumber:    10,
+			AccInputHash:   accInputHash,
+			BatchL2Data:    data,
+			GlobalExitRoot: globalExitRoot,
+			Timestamp:      timestamp,
+			Coinbase:       coinbase,
+		}
+	}
+	tampered := append([]byte{}, batchL2Data...)
+	tampered[len(tampered)-1] ^= 0x01
+
+	testCases := []struct {
+		name     string
+		cfg      Config
+		batch    *state.Batch
+		mismatch bool
+	}{
+		{
+			name:  "disabled",
+			cfg:   Config{},
+			batch: newBatch(tampered),
+		},
+		{
+			name:  "no acc input hash",
+			cfg:   Config{CheckBatchL2Data: true},
+			batch: &state.Batch{BatchNumber: 10, BatchL2Data: tampered, Timestamp: timestamp},
+		},
+		{
+			name:  "matching",
+			cfg:   Config{CheckBatchL2Data: true},
+			batch: newBatch(batchL2Data),
+		},
+		{
+			name:     "tampered",
+			cfg:      Config{CheckBatchL2Data: true},
+			batch:    newBatch(tampered),
+			mismatch: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := Aggregator{cfg: tc.cfg}
+
+			err := a.checkBatchL2Data(previousBatch, tc.batch)
+
+			if !tc.mismatch {
+				assert.NoError(t, err)
+				return
+			}
+			var mismatchErr *BatchL2DataMismatchError
+			require.True(t, errors.As(err, &mismatchErr))
+			assert.Equal(t, tc.batch.BatchNumber, mismatchErr.BatchNumber)
+			assert.Equal(t, accInputHash, mismatchErr.Expected)
+			assert.NotEqual(t, accInputHash, mismatchErr.Computed)
+		})
+	}
+}
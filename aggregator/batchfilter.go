@@ -0,0 +1,126 @@
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// batchFilter holds the ranges of batches allowed and denied to be proven,
+// which can be replaced while the aggregator is running.
+type batchFilter struct {
+	mu      sync.RWMutex
+	allowed []BatchRange
+	denied  []BatchRange
+}
+
+func newBatchFilter(cfg BatchFilterConfig) (*batchFilter, error) {
+	f := &batchFilter{}
+	if err := f.set(cfg); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// validateBatchRanges checks that no range is reversed.
+func validateBatchRanges(ranges []BatchRange) error {
+	for _, r := range ranges {
+		if r.FromBatchNumber > r.ToBatchNumber {
+			return fmt.Errorf("invalid batch range [%d-%d]", r.FromBatchNumber, r.ToBatchNumber)
+		}
+	}
+	return nil
+}
+
+// set replaces the ranges of the filter, keeping the ones in use if any of
+// the new ranges is invalid.
+func (f *batchFilter) set(cfg BatchFilterConfig) error {
+	if err := validateBatchRanges(cfg.AllowedBatches); err != nil {
+		return fmt.Errorf("allowed batches, %w", err)
+	}
+	if err := validateBatchRanges(cfg.DeniedBatches); err != nil {
+		return fmt.Errorf("denied batches, %w", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.allowed = copyBatchRanges(cfg.AllowedBatches)
+	f.denied = copyBatchRanges(cfg.DeniedBatches)
+	return nil
+}
+
+// config returns a copy of the ranges of the filter. It's safe to call it on
+// a nil filter.
+func (f *batchFilter) config() BatchFilterConfig {
+	if f == nil {
+		return BatchFilterConfig{}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return BatchFilterConfig{
+		AllowedBatches: copyBatchRanges(f.allowed),
+		DeniedBatches:  copyBatchRanges(f.denied),
+	}
+}
+
+func copyBatchRanges(ranges []BatchRange) []BatchRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	return append([]BatchRange{}, ranges...)
+}
+
+// nextAllowed returns the first batch allowed to be proven starting from the
+// given one, which is the given batch itself if it's allowed. It returns false
+// if no batch from the given one is allowed. A nil filter allows every batch.
+func (f *batchFilter) nextAllowed(batchNumber uint64) (uint64, bool) {
+	if f == nil {
+		return batchNumber, true
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for {
+		next, ok := f.nextInAllowed(batchNumber)
+		if !ok {
+			return 0, false
+		}
+		denied := false
+		for _, r := range f.denied {
+			if next >= r.FromBatchNumber && next <= r.ToBatchNumber {
+				if r.ToBatchNumber == math.MaxUint64 {
+					return 0, false
+				}
+				next = r.ToBatchNumber + 1
+				denied = true
+			}
+		}
+		if !denied {
+			return next, true
+		}
+		batchNumber = next
+	}
+}
+
+// nextInAllowed returns the first batch in the allowed ranges starting from
+// the given one, any batch if there are no allowed ranges.
+func (f *batchFilter) nextInAllowed(batchNumber uint64) (uint64, bool) {
+	if len(f.allowed) == 0 {
+		return batchNumber, true
+	}
+	next, found := uint64(0), false
+	for _, r := range f.allowed {
+		if r.ToBatchNumber < batchNumber {
+			continue
+		}
+		candidate := r.FromBatchNumber
+		if candidate < batchNumber {
+			candidate = batchNumber
+		}
+		if !found || candidate < next {
+			next, found = candidate, true
+		}
+	}
+	return next, found
+}
@@ -0,0 +1,94 @@
+package aggregator
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchFilterNextAllowed(t *testing.T) {
+	f, err := newBatchFilter(BatchFilterConfig{
+		AllowedBatches: []BatchRange{{FromBatchNumber: 20, ToBatchNumber: 30}, {FromBatchNumber: 10, ToBatchNumber: 15}},
+		DeniedBatches:  []BatchRange{{FromBatchNumber: 12, ToBatchNumber: 14}, {FromBatchNumber: 15, ToBatchNumber: 21}},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		batchNumber uint64
+		expected    uint64
+		allowed     bool
+	}{
+		{batchNumber: 1, expected: 10, allowed: true},
+		{batchNumber: 11, expected: 11, allowed: true},
+		// the denied ranges are skipped, even across the allowed ones
+		{batchNumber: 12, expected: 22, allowed: true},
+		{batchNumber: 30, expected: 30, allowed: true},
+		{batchNumber: 31, allowed: false},
+	}
+	for _, tc := range testCases {
+		next, ok := f.nextAllowed(tc.batchNumber)
+		assert.Equal(t, tc.allowed, ok, "batch %d", tc.batchNumber)
+		assert.Equal(t, tc.expected, next, "batch %d", tc.batchNumber)
+	}
+
+	// an empty filter allows every batch, as a nil one
+	require.NoError(t, f.set(BatchFilterConfig{}))
+	next, ok := f.nextAllowed(31)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(31), next)
+	next, ok = (*batchFilter)(nil).nextAllowed(31)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(31), next)
+
+	require.NoError(t, f.set(BatchFilterConfig{DeniedBatches: []BatchRange{{FromBatchNumber: 5, ToBatchNumber: math.MaxUint64}}}))
+	_, ok = f.nextAllowed(7)
+	assert.False(t, ok)
+}
+
+func TestBatchFilterSetInvalid(t *testing.T) {
+	_, err := newBatchFilter(BatchFilterConfig{AllowedBatches: []BatchRange{{FromBatchNumber: 2, ToBatchNumber: 1}}})
+	assert.Error(t, err)
+
+	cfg := BatchFilterConfig{AllowedBatches: []BatchRange{{FromBatchNumber: 1, ToBatchNumber: 2}}}
+	f, err := newBatchFilter(cfg)
+	require.NoError(t, err)
+
+	// the filter in use is kept
+	a := Aggregator{batchFilter: f}
+	a.ReloadBatchFilter(Config{BatchFilter: BatchFilterConfig{DeniedBatches: []BatchRange{{FromBatchNumber: 4, ToBatchNumber: 3}}}})
+	assert.Equal(t, cfg, f.config())
+
+	reloaded := BatchFilterConfig{AllowedBatches: []BatchRange{{FromBatchNumber: 3, ToBatchNumber: 4}}}
+	a.ReloadBatchFilter(Config{BatchFilter: reloaded})
+	assert.Equal(t, reloaded, a.EffectiveConfig().BatchFilter)
+}
+
+func TestClaimBatchToProveFiltered(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	prover := mocks.NewProverMock(t)
+	f, err := newBatchFilter(BatchFilterConfig{AllowedBatches: []BatchRange{{FromBatchNumber: 10, ToBatchNumber: 20}}})
+	require.NoError(t, err)
+	a := Aggregator{State: st, StateDBMutex: &sync.Mutex{}, batchClaims: newBatchProofClaims(0), batchFilter: f}
+
+	prover.On("ID").Return("prover-1")
+	// the batches before the allowed range are skipped in a single query
+	st.On("GetVirtualBatchToProve", mock.Anything, uint64(1), []uint64(nil), nil).Return(&state.Batch{BatchNumber: 2}, nil).Once()
+	st.On("GetVirtualBatchToProve", mock.Anything, uint64(9), []uint64(nil), nil).Return(&state.Batch{BatchNumber: 10}, nil).Once()
+
+	batch, err := a.claimBatchToProve(context.Background(), 1, prover)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), batch.BatchNumber)
+
+	// nothing is proven past the allowed range
+	st.On("GetVirtualBatchToProve", mock.Anything, uint64(20), []uint64{10}, nil).Return(&state.Batch{BatchNumber: 21}, nil).Once()
+
+	_, err = a.claimBatchToProve(context.Background(), 20, prover)
+	assert.ErrorIs(t, err, state.ErrNotFound)
+}
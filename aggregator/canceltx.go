@@ -0,0 +1,57 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/0xPolygonHermez/zkevm-node/ethtxmanager"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// cancelTxRequest is the body of the tx cancellation endpoint.
+type cancelTxRequest struct {
+	TxHash common.Hash `json:"txHash"`
+}
+
+// cancelTxResponse is the body served back by the tx cancellation endpoint.
+type cancelTxResponse struct {
+	TxHash       common.Hash `json:"txHash"`
+	CancelTxHash common.Hash `json:"cancelTxHash"`
+}
+
+// handleCancelTx cancels the posted stuck tx through the eth tx manager,
+// freeing its nonce, and serves the hash of the cancel tx as JSON.
+func (a *Aggregator) handleCancelTx(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req cancelTxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request, %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.TxHash == (common.Hash{}) {
+		http.Error(w, "invalid request, a tx hash is required", http.StatusBadRequest)
+		return
+	}
+	cancelTx, err := a.EthTxManager.CancelTx(r.Context(), req.TxHash)
+	if errors.Is(err, ethtxmanager.ErrTxAlreadyMined) || errors.Is(err, ethtxmanager.ErrUnknownSender) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Errorf("Failed to cancel tx %s, err: %v", req.TxHash, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Infof("Tx %s canceled by tx %s", req.TxHash, cancelTx.Hash())
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cancelTxResponse{TxHash: req.TxHash, CancelTxHash: cancelTx.Hash()}); err != nil {
+		log.Errorf("Failed to encode canceled tx, err: %v", err)
+	}
+}
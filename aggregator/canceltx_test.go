@@ -0,0 +1,62 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/ethtxmanager"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCancelTx(t *testing.T) {
+	stuckTxHash := common.HexToHash("0x01")
+	minedTxHash := common.HexToHash("0x02")
+	failingTxHash := common.HexToHash("0x03")
+	cancelTx := types.NewTransaction(7, common.Address{}, big.NewInt(0), 21000, big.NewInt(110), nil)
+
+	ethTxMan := mocks.NewEthTxManager(t)
+	ethTxMan.On("CancelTx", mock.Anything, stuckTxHash).Return(cancelTx, nil).Once()
+	ethTxMan.On("CancelTx", mock.Anything, minedTxHash).Return(nil, fmt.Errorf("failed to cancel tx, err: %w", ethtxmanager.ErrTxAlreadyMined)).Once()
+	ethTxMan.On("CancelTx", mock.Anything, failingTxHash).Return(nil, errors.New("unavailable")).Once()
+	a := Aggregator{EthTxManager: ethTxMan}
+
+	testCases := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{name: "cancel", method: http.MethodPost, body: fmt.Sprintf(`{"txHash":"%s"}`, stuckTxHash), expectedStatus: http.StatusOK},
+		{name: "mined", method: http.MethodPost, body: fmt.Sprintf(`{"txHash":"%s"}`, minedTxHash), expectedStatus: http.StatusConflict},
+		{name: "failing", method: http.MethodPost, body: fmt.Sprintf(`{"txHash":"%s"}`, failingTxHash), expectedStatus: http.StatusInternalServerError},
+		{name: "not posted", method: http.MethodGet, expectedStatus: http.StatusMethodNotAllowed},
+		{name: "invalid body", method: http.MethodPost, body: `{`, expectedStatus: http.StatusBadRequest},
+		{name: "no tx hash", method: http.MethodPost, body: `{}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/admin/txs/cancel", strings.NewReader(tc.body))
+			a.adminHandler().ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp cancelTxResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, stuckTxHash, resp.TxHash)
+			assert.Equal(t, cancelTx.Hash(), resp.CancelTxHash)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// ErrChainIDMismatch is returned when a configured chain id doesn't match the
+// one of the chain the etherman is connected to.
+var ErrChainIDMismatch = errors.New("chain id mismatch")
+
+// checkChainIDs checks that the L2 chain id the proofs are built for and the
+// L1 chain id the txs are signed for are the ones of the chain the etherman is
+// connected to. A mismatch, or a failure to read the chain ids, is only logged
+// if the check is configured to warn.
+func (a *Aggregator) checkChainIDs(ctx context.Context) error {
+	if !a.cfg.ChainIDCheck.Enabled {
+		return nil
+	}
+	err := a.compareChainIDs(ctx)
+	if err != nil && a.cfg.ChainIDCheck.WarnOnly {
+		log.Warnf("Chain id check failed, err: %v", err)
+		return nil
+	}
+	return err
+}
+
+func (a *Aggregator) compareChainIDs(ctx context.Context) error {
+	l2ChainID, err := a.Ethman.GetL2ChainID()
+	if err != nil {
+		return fmt.Errorf("Failed to get L2 chain id, %w", err)
+	}
+	if l2ChainID != a.cfg.ChainID {
+		return fmt.Errorf("%w, L2 chain id is %d in the config but %d in the PoE smart contract", ErrChainIDMismatch, a.cfg.ChainID, l2ChainID)
+	}
+
+	// the L1 chain id is unknown if the aggregator isn't given the Etherman
+	// config
+	if a.cfg.L1ChainID == 0 {
+		return nil
+	}
+	l1ChainID, err := a.Ethman.GetL1ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get L1 chain id, %w", err)
+	}
+	if l1ChainID != a.cfg.L1ChainID {
+		return fmt.Errorf("%w, L1 chain id is %d in the config but %d in the L1 node", ErrChainIDMismatch, a.cfg.L1ChainID, l1ChainID)
+	}
+	log.Infof("Chain ids checked, L1: %d, L2: %d", l1ChainID, l2ChainID)
+	return nil
+}
@@ -0,0 +1,55 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckChainIDs(t *testing.T) {
+	ctx := context.Background()
+	errL1 := errors.New("l1 error")
+
+	testCases := []struct {
+		name        string
+		check       ChainIDCheckConfig
+		l2ChainID   uint64
+		l1ChainID   uint64
+		l1Err       error
+		expectedErr error
+	}{
+		{name: "matching", check: ChainIDCheckConfig{Enabled: true}, l2ChainID: 1001, l1ChainID: 1337},
+		{name: "L2 mismatch", check: ChainIDCheckConfig{Enabled: true}, l2ChainID: 1000, expectedErr: ErrChainIDMismatch},
+		{name: "L1 mismatch", check: ChainIDCheckConfig{Enabled: true}, l2ChainID: 1001, l1ChainID: 1, expectedErr: ErrChainIDMismatch},
+		{name: "L1 failure", check: ChainIDCheckConfig{Enabled: true}, l2ChainID: 1001, l1Err: errL1, expectedErr: errL1},
+		{name: "warn only", check: ChainIDCheckConfig{Enabled: true, WarnOnly: true}, l2ChainID: 1000},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eth := mocks.NewEtherman(t)
+			a := Aggregator{
+				cfg:    Config{ChainID: 1001, L1ChainID: 1337, ChainIDCheck: tc.check},
+				Ethman: eth,
+			}
+			eth.On("GetL2ChainID").Return(tc.l2ChainID, nil).Once()
+			if tc.l2ChainID == 1001 {
+				eth.On("GetL1ChainID", ctx).Return(tc.l1ChainID, tc.l1Err).Once()
+			}
+
+			err := a.checkChainIDs(ctx)
+			if tc.expectedErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			}
+		})
+	}
+
+	// the check is skipped if disabled
+	a := Aggregator{cfg: Config{ChainID: 1001}, Ethman: mocks.NewEtherman(t)}
+	require.NoError(t, a.checkChainIDs(ctx))
+}
@@ -0,0 +1,25 @@
+package aggregator
+
+import "time"
+
+// proverHold returns why the connected provers are kept idle, empty if they
+// can be assigned work. They are kept idle while the aggregator is in
+// standby, the state database is unhealthy, the load is being shed or the
+// pipeline is paused by the failure circuit. The unlocks left pending are
+// retried as soon as the state database is healthy.
+func (a *Aggregator) proverHold() string {
+	if !a.isLeader() {
+		return "Aggregator is in standby"
+	}
+	if !a.isDBHealthy() {
+		return "State database unhealthy"
+	}
+	a.recoverPendingUnlocks()
+	if a.shedder.isShedding() {
+		return "Shedding load"
+	}
+	if a.circuit.isTripped(time.Now()) {
+		return "Pipeline paused by the failure circuit"
+	}
+	return ""
+}
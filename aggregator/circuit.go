@@ -0,0 +1,215 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// FailureCircuitStatus is the status of the failure circuit of the pipeline.
+type FailureCircuitStatus struct {
+	Enabled     bool       `json:"enabled"`
+	Tripped     bool       `json:"tripped"`
+	TrippedAt   *time.Time `json:"trippedAt,omitempty"`
+	Window      string     `json:"window,omitempty"`
+	Operations  int        `json:"operations"`
+	Failures    int        `json:"failures"`
+	FailureRate float64    `json:"failureRate"`
+}
+
+type operationOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// failureCircuit pauses the pipeline when the rate of failed operations over
+// a rolling window exceeds the configured threshold.
+type failureCircuit struct {
+	cfg FailureCircuitConfig
+
+	mu        sync.Mutex
+	outcomes  []operationOutcome
+	trippedAt time.Time
+}
+
+func newFailureCircuit(cfg FailureCircuitConfig) *failureCircuit {
+	return &failureCircuit{cfg: cfg}
+}
+
+// record adds the outcome of an operation and trips the circuit if the
+// failure rate exceeds the threshold, it's safe to call it on a nil circuit.
+func (c *failureCircuit) record(failed bool, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.outcomes = append(c.outcomes, operationOutcome{at: now, failed: failed})
+	c.prune(now)
+	if !c.trippedAt.IsZero() {
+		return
+	}
+
+	operations, failures := c.count()
+	if c.exceeded(operations, failures) {
+		c.trippedAt = now
+		log.Errorf("CRITICAL: %d of the last %d operations failed in %v, exceeding the failure threshold %v. The pipeline is paused until resumed",
+			failures, operations, c.cfg.Window.Duration, c.cfg.Threshold)
+		metrics.FailureCircuitTripped(true)
+	}
+}
+
+// isTripped returns whether the pipeline is paused, it's safe to call it on a
+// nil circuit. If auto-resume is allowed, the circuit is resumed once the
+// failure rate over the window falls back below the threshold.
+func (c *failureCircuit) isTripped(now time.Time) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.trippedAt.IsZero() {
+		return false
+	}
+	if !c.cfg.AutoResume {
+		return true
+	}
+
+	c.prune(now)
+	if c.exceeded(c.count()) {
+		return true
+	}
+	log.Infof("Failure rate back below the threshold %v, resuming the pipeline", c.cfg.Threshold)
+	c.trippedAt = time.Time{}
+	metrics.FailureCircuitTripped(false)
+	return false
+}
+
+// resume closes the circuit discarding the outcomes recorded so far, so the
+// failures that tripped it don't trip it again.
+func (c *failureCircuit) resume() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.outcomes = nil
+	if !c.trippedAt.IsZero() {
+		log.Infof("Pipeline resumed, tripped at %v", c.trippedAt)
+		c.trippedAt = time.Time{}
+	}
+	metrics.FailureCircuitTripped(false)
+}
+
+// status returns the status of the circuit over the window ending at the
+// given time.
+func (c *failureCircuit) status(now time.Time) FailureCircuitStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(now)
+	operations, failures := c.count()
+	status := FailureCircuitStatus{
+		Enabled:    true,
+		Tripped:    !c.trippedAt.IsZero(),
+		Window:     c.cfg.Window.Duration.String(),
+		Operations: operations,
+		Failures:   failures,
+	}
+	if status.Tripped {
+		trippedAt := c.trippedAt
+		status.TrippedAt = &trippedAt
+	}
+	if operations > 0 {
+		status.FailureRate = float64(failures) / float64(operations)
+	}
+	return status
+}
+
+// exceeded returns whether the failure rate exceeds the threshold, the rate
+// is not evaluated until the min number of operations is reached.
+func (c *failureCircuit) exceeded(operations, failures int) bool {
+	if operations == 0 || operations < c.cfg.MinOperations {
+		return false
+	}
+	return float64(failures)/float64(operations) > c.cfg.Threshold
+}
+
+// count returns the number of operations and failures in the window, it must
+// be called with the lock held.
+func (c *failureCircuit) count() (int, int) {
+	var failures int
+	for _, o := range c.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+	return len(c.outcomes), failures
+}
+
+// prune removes the outcomes older than the window, it must be called with
+// the lock held.
+func (c *failureCircuit) prune(now time.Time) {
+	since := now.Add(-c.cfg.Window.Duration)
+	i := 0
+	for i < len(c.outcomes) && c.outcomes[i].at.Before(since) {
+		i++
+	}
+	c.outcomes = c.outcomes[i:]
+}
+
+// recordOutcome records the outcome of an operation of the pipeline in the
+// failure circuit. Operations that found nothing to do are not counted, nor
+// the ones aborted because the prover disconnected or the aggregator stopped,
+// nor the proofs not eligible.
+func (a *Aggregator) recordOutcome(ctx context.Context, done bool, err error) {
+	switch {
+	case err != nil && ctx.Err() == nil && !errors.Is(err, ErrProofNotEligible):
+		a.circuit.record(true, time.Now())
+	case err == nil && done:
+		a.circuit.record(false, time.Now())
+	}
+}
+
+// Resume resumes the pipeline after it has been paused because the failure
+// rate exceeded the configured threshold.
+func (a *Aggregator) Resume() {
+	a.circuit.resume()
+}
+
+// FailureCircuit returns the status of the failure circuit of the pipeline.
+func (a *Aggregator) FailureCircuit() FailureCircuitStatus {
+	if a.circuit == nil {
+		return FailureCircuitStatus{}
+	}
+	return a.circuit.status(time.Now())
+}
+
+// handleFailureCircuit serves the status of the failure circuit as JSON.
+func (a *Aggregator) handleFailureCircuit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.FailureCircuit()); err != nil {
+		log.Errorf("Failed to encode failure circuit status, err: %v", err)
+	}
+}
+
+// handleResume resumes the paused pipeline and serves the status of the
+// failure circuit as JSON.
+func (a *Aggregator) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a.Resume()
+	a.handleFailureCircuit(w, r)
+}
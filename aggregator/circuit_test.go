@@ -0,0 +1,120 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureCircuit(t *testing.T) {
+	c := newFailureCircuit(FailureCircuitConfig{
+		Enabled:       true,
+		Window:        types.NewDuration(time.Minute),
+		Threshold:     0.5,
+		MinOperations: 4,
+	})
+	now := time.Now()
+
+	// not evaluated until the min number of operations is reached
+	c.record(true, now)
+	c.record(true, now)
+	c.record(false, now)
+	assert.False(t, c.isTripped(now))
+
+	// 2 of 4 failed, not above the threshold
+	c.record(false, now)
+	assert.False(t, c.isTripped(now))
+
+	c.record(true, now)
+	assert.True(t, c.isTripped(now))
+
+	// stays tripped without auto-resume even once the failures age out
+	later := now.Add(2 * time.Minute)
+	assert.True(t, c.isTripped(later))
+	status := c.status(later)
+	assert.True(t, status.Tripped)
+	assert.Equal(t, 0, status.Operations)
+
+	c.resume()
+	assert.False(t, c.isTripped(later))
+	assert.Equal(t, FailureCircuitStatus{Enabled: true, Window: "1m0s"}, c.status(later))
+
+	var disabled *failureCircuit
+	disabled.record(true, now)
+	assert.False(t, disabled.isTripped(now))
+}
+
+func TestFailureCircuitAutoResume(t *testing.T) {
+	c := newFailureCircuit(FailureCircuitConfig{
+		Enabled:    true,
+		Window:     types.NewDuration(time.Minute),
+		Threshold:  0.5,
+		AutoResume: true,
+	})
+	now := time.Now()
+
+	c.record(true, now)
+	c.record(true, now.Add(time.Second))
+	require.True(t, c.isTripped(now.Add(time.Second)))
+
+	// the first failure leaves the window, the rate is still exceeded
+	assert.True(t, c.isTripped(now.Add(time.Minute+time.Millisecond)))
+	// all the failures leave the window
+	assert.False(t, c.isTripped(now.Add(time.Minute+2*time.Second)))
+}
+
+func TestRecordOutcome(t *testing.T) {
+	cfg := FailureCircuitConfig{
+		Enabled:   true,
+		Window:    types.NewDuration(time.Minute),
+		Threshold: 0.5,
+	}
+	a := Aggregator{cfg: Config{FailureCircuit: cfg}, circuit: newFailureCircuit(cfg)}
+	ctx := context.Background()
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	a.recordOutcome(ctx, false, nil)
+	a.recordOutcome(canceledCtx, false, context.Canceled)
+	a.recordOutcome(ctx, true, nil)
+	a.recordOutcome(ctx, false, errors.New("prover error"))
+
+	status := a.FailureCircuit()
+	assert.Equal(t, 2, status.Operations)
+	assert.Equal(t, 1, status.Failures)
+	assert.False(t, status.Tripped)
+
+	a.recordOutcome(ctx, false, errors.New("prover error"))
+
+	rec := httptest.NewRecorder()
+	a.handleFailureCircuit(rec, httptest.NewRequest(http.MethodGet, "/status/circuit", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got FailureCircuitStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.True(t, got.Tripped)
+	assert.NotNil(t, got.TrippedAt)
+	assert.Equal(t, 3, got.Operations)
+	assert.Equal(t, 2, got.Failures)
+
+	// resumed by an operator on the admin server
+	rec = httptest.NewRecorder()
+	a.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/circuit/resume", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.True(t, a.FailureCircuit().Tripped)
+
+	rec = httptest.NewRecorder()
+	a.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/circuit/resume", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.False(t, got.Tripped)
+	assert.False(t, a.FailureCircuit().Tripped)
+}
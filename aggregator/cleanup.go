@@ -0,0 +1,46 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// generatedProofsDeleter deletes the proofs of a range of batches.
+type generatedProofsDeleter interface {
+	DeleteGeneratedProofs(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error
+}
+
+// deleteProofsInChunks deletes the proofs of the verified range of batches
+// in chunks of chunkSize batches, each chunk in its own statement, or at once
+// if chunkSize is 0. The chunks are deleted from the last one to the first
+// one, with the end of the range as upper bound, so each chunk deletes the
+// proofs starting in it, including the ones spanning the following chunks.
+// The verified proof, starting at the beginning of the range, is deleted
+// last, so a cleanup failing midway is resumed on restart, and it can be
+// retried as a whole as the chunks already deleted are no-ops.
+func deleteProofsInChunks(ctx context.Context, store generatedProofsDeleter, batchNumber, batchNumberFinal, chunkSize uint64) error {
+	if chunkSize == 0 || batchNumberFinal-batchNumber < chunkSize {
+		return store.DeleteGeneratedProofs(ctx, batchNumber, batchNumberFinal, nil)
+	}
+
+	for from := batchNumberFinal + 1; from > batchNumber; {
+		if from-batchNumber > chunkSize {
+			from -= chunkSize
+		} else {
+			from = batchNumber
+		}
+		err := store.DeleteGeneratedProofs(ctx, from, batchNumberFinal, nil)
+		if err != nil {
+			return fmt.Errorf("Failed to delete proofs of batches [%d-%d], %w", from, batchNumberFinal, err)
+		}
+	}
+	return nil
+}
+
+// deleteVerifiedProofs deletes the proofs of the verified range of batches in
+// chunks of VerifiedProofsCleanupChunkSize batches.
+func (a *Aggregator) deleteVerifiedProofs(ctx context.Context, batchNumber, batchNumberFinal uint64) error {
+	return deleteProofsInChunks(ctx, a.State, batchNumber, batchNumberFinal, a.cfg.VerifiedProofsCleanupChunkSize)
+}
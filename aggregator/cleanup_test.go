@@ -0,0 +1,39 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteVerifiedProofsInChunks(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	ctx := context.Background()
+	a := Aggregator{cfg: Config{VerifiedProofsCleanupChunkSize: 3}, State: st}
+
+	// the chunks are deleted from the last one, the verified proof starting
+	// at the beginning of the range being deleted in the last chunk
+	var deleted []uint64
+	for _, from := range []uint64{8, 5, 2, 1} {
+		from := from
+		st.On("DeleteGeneratedProofs", ctx, from, uint64(10), nil).Return(nil).Once().Run(func(mock.Arguments) { deleted = append(deleted, from) })
+	}
+	require.NoError(t, a.deleteVerifiedProofs(ctx, 1, 10))
+	assert.Equal(t, []uint64{8, 5, 2, 1}, deleted)
+
+	// a failing chunk stops the clean up, keeping the verified proof to be
+	// resumed
+	errDB := errors.New("db error")
+	st.On("DeleteGeneratedProofs", ctx, uint64(8), uint64(10), nil).Return(nil).Once()
+	st.On("DeleteGeneratedProofs", ctx, uint64(5), uint64(10), nil).Return(errDB).Once()
+	assert.ErrorIs(t, a.deleteVerifiedProofs(ctx, 1, 10), errDB)
+
+	// a range fitting in a chunk is deleted at once
+	st.On("DeleteGeneratedProofs", ctx, uint64(1), uint64(3), nil).Return(nil).Once()
+	require.NoError(t, a.deleteVerifiedProofs(ctx, 1, 3))
+}
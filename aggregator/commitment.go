@@ -0,0 +1,95 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrCommitmentMismatch is returned when the commitment stored for a proof
+// doesn't match the one expected for the range of batches it claims to cover.
+// It's an ErrStateInconsistent.
+var ErrCommitmentMismatch = newLifecycleError(ErrStateInconsistent, errors.New("proof commitment mismatch"))
+
+// extendCommitment appends a batch state root to the hash chain of a range of
+// batches. The chain of a range starts from the zero hash, so the commitment
+// of two consecutive ranges is the one of the first extended with the state
+// roots of the second.
+func extendCommitment(commitment common.Hash, stateRoot common.Hash) common.Hash {
+	return crypto.Keccak256Hash(commitment.Bytes(), stateRoot.Bytes())
+}
+
+// batchCommitment returns the commitment of a single batch.
+func batchCommitment(batch *state.Batch) *string {
+	commitment := extendCommitment(common.Hash{}, batch.StateRoot).String()
+	return &commitment
+}
+
+// extendRangeCommitment extends the commitment with the state roots of the
+// batches in the range read from the state.
+func (a *Aggregator) extendRangeCommitment(ctx context.Context, commitment common.Hash, batchNumber, batchNumberFinal uint64) (common.Hash, error) {
+	for n := batchNumber; n <= batchNumberFinal; n++ {
+		batch, err := a.State.GetBatchByNumber(ctx, n, nil)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("Failed to get batch [%d] to compute the proof commitment, %w", n, err)
+		}
+		commitment = extendCommitment(commitment, batch.StateRoot)
+	}
+	return commitment, nil
+}
+
+// aggregatedCommitment returns the commitment of the proof aggregating the
+// given ones. If both proofs have a commitment, the one of the second proof is
+// validated against the state roots of its range while extending the
+// commitment of the first one, so the continuity of the proofs is checked on
+// every aggregation round. Otherwise the commitment is computed for the whole
+// range.
+func (a *Aggregator) aggregatedCommitment(ctx context.Context, proof1, proof2 *state.Proof) (*string, error) {
+	if proof1.Commitment == nil || proof2.Commitment == nil {
+		commitment, err := a.extendRangeCommitment(ctx, common.Hash{}, proof1.BatchNumber, proof2.BatchNumberFinal)
+		if err != nil {
+			return nil, err
+		}
+		c := commitment.String()
+		return &c, nil
+	}
+
+	own := common.Hash{}
+	aggregated := common.HexToHash(*proof1.Commitment)
+	for n := proof2.BatchNumber; n <= proof2.BatchNumberFinal; n++ {
+		batch, err := a.State.GetBatchByNumber(ctx, n, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get batch [%d] to compute the proof commitment, %w", n, err)
+		}
+		own = extendCommitment(own, batch.StateRoot)
+		aggregated = extendCommitment(aggregated, batch.StateRoot)
+	}
+	if own.String() != *proof2.Commitment {
+		return nil, fmt.Errorf("%w: proof %d-%d, stored %s, expected %s", ErrCommitmentMismatch,
+			proof2.BatchNumber, proof2.BatchNumberFinal, *proof2.Commitment, own.String())
+	}
+	c := aggregated.String()
+	return &c, nil
+}
+
+// checkCommitment validates the commitment stored for the proof against the
+// state roots of the range of batches it claims to cover. Proofs without a
+// commitment, generated before enabling the commitments, are not checked.
+func (a *Aggregator) checkCommitment(ctx context.Context, proof *state.Proof) error {
+	if proof.Commitment == nil {
+		return nil
+	}
+	expected, err := a.extendRangeCommitment(ctx, common.Hash{}, proof.BatchNumber, proof.BatchNumberFinal)
+	if err != nil {
+		return err
+	}
+	if expected.String() != *proof.Commitment {
+		return fmt.Errorf("%w: proof %d-%d, stored %s, expected %s", ErrCommitmentMismatch,
+			proof.BatchNumber, proof.BatchNumberFinal, *proof.Commitment, expected.String())
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package aggregator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofCommitments(t *testing.T) {
+	ctx := context.Background()
+	st := mocks.NewStateMock(t)
+	a := Aggregator{cfg: Config{ProofCommitments: true}, State: st}
+
+	batches := make(map[uint64]*state.Batch)
+	for n := uint64(1); n <= 4; n++ {
+		batches[n] = &state.Batch{BatchNumber: n, StateRoot: common.BigToHash(new(big.Int).SetUint64(n))}
+		st.On("GetBatchByNumber", ctx, n, nil).Return(batches[n], nil).Maybe()
+	}
+
+	commitment := func(from, to uint64) *string {
+		c := common.Hash{}
+		for n := from; n <= to; n++ {
+			c = extendCommitment(c, batches[n].StateRoot)
+		}
+		s := c.String()
+		return &s
+	}
+
+	assert.Equal(t, commitment(3, 3), batchCommitment(batches[3]))
+
+	proof1 := &state.Proof{BatchNumber: 1, BatchNumberFinal: 2, Commitment: commitment(1, 2)}
+	proof2 := &state.Proof{BatchNumber: 3, BatchNumberFinal: 4, Commitment: commitment(3, 4)}
+
+	aggregated, err := a.aggregatedCommitment(ctx, proof1, proof2)
+	require.NoError(t, err)
+	assert.Equal(t, commitment(1, 4), aggregated)
+	assert.NoError(t, a.checkCommitment(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 4, Commitment: aggregated}))
+
+	// computed for the whole range when a proof has no commitment
+	proof1.Commitment = nil
+	aggregated, err = a.aggregatedCommitment(ctx, proof1, proof2)
+	require.NoError(t, err)
+	assert.Equal(t, commitment(1, 4), aggregated)
+
+	// the commitment of the second proof doesn't match its range
+	proof1.Commitment = commitment(1, 2)
+	proof2.Commitment = commitment(2, 4)
+	_, err = a.aggregatedCommitment(ctx, proof1, proof2)
+	assert.ErrorIs(t, err, ErrCommitmentMismatch)
+
+	// the commitment doesn't cover the claimed range
+	err = a.checkCommitment(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 4, Commitment: commitment(1, 3)})
+	assert.ErrorIs(t, err, ErrCommitmentMismatch)
+
+	// proofs without a commitment are not checked
+	assert.NoError(t, a.checkCommitment(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 4}))
+}
@@ -0,0 +1,37 @@
+package aggregator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+)
+
+// compressBatchL2Data compresses the BatchL2Data of the input prover as
+// configured, flagging it in the input so the prover decompresses it.
+func (a *Aggregator) compressBatchL2Data(inputProver *pb.InputProver) error {
+	if a.cfg.BatchL2DataCompression != BatchL2DataCompressionGzip {
+		return nil
+	}
+	compressed, err := gzipBatchL2Data(inputProver.PublicInputs.BatchL2Data)
+	if err != nil {
+		return fmt.Errorf("Failed to compress batch L2 data, %w", err)
+	}
+	inputProver.PublicInputs.BatchL2Data = compressed
+	inputProver.BatchL2DataCompression = string(BatchL2DataCompressionGzip)
+	return nil
+}
+
+// gzipBatchL2Data returns the batch L2 data gzip compressed.
+func gzipBatchL2Data(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
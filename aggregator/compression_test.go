@@ -0,0 +1,98 @@
+package aggregator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gunzipBatchL2Data returns the batch L2 data gzip decompressed, as the
+// prover does.
+func gunzipBatchL2Data(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck
+	return io.ReadAll(r)
+}
+
+// realisticBatchL2Data returns the L2 data of a batch of ERC20 transfers
+// signed by a few senders.
+func realisticBatchL2Data(t testing.TB, txs int) []byte {
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := range keys {
+		key, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		keys[i] = key
+	}
+	token := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	signer := types.NewEIP155Signer(big.NewInt(1001))
+	transactions := make([]types.Transaction, 0, txs)
+	for i := 0; i < txs; i++ {
+		to := common.BigToAddress(big.NewInt(int64(i)))
+		// transfer(address,uint256)
+		data := append([]byte{0xa9, 0x05, 0x9c, 0xbb}, common.LeftPadBytes(to.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(big.NewInt(int64(1000+i)).Bytes(), 32)...)
+		tx := types.NewTransaction(uint64(i/len(keys)), token, big.NewInt(0), 60000, big.NewInt(1000000000), data)
+		signed, err := types.SignTx(tx, signer, keys[i%len(keys)])
+		require.NoError(t, err)
+		transactions = append(transactions, *signed)
+	}
+	batchL2Data, err := state.EncodeTransactions(transactions)
+	require.NoError(t, err)
+	return batchL2Data
+}
+
+func TestCompressBatchL2Data(t *testing.T) {
+	batchL2Data := realisticBatchL2Data(t, 100)
+	newInputProver := func() *pb.InputProver {
+		return &pb.InputProver{PublicInputs: &pb.PublicInputs{BatchL2Data: append([]byte{}, batchL2Data...)}}
+	}
+
+	// not compressed
+	a := Aggregator{cfg: Config{BatchL2DataCompression: BatchL2DataCompressionNone}}
+	inputProver := newInputProver()
+	require.NoError(t, a.compressBatchL2Data(inputProver))
+	assert.Equal(t, batchL2Data, inputProver.PublicInputs.BatchL2Data)
+	assert.Empty(t, inputProver.BatchL2DataCompression)
+
+	// compressed and flagged
+	a.cfg.BatchL2DataCompression = BatchL2DataCompressionGzip
+	inputProver = newInputProver()
+	require.NoError(t, a.compressBatchL2Data(inputProver))
+	assert.Equal(t, "gzip", inputProver.BatchL2DataCompression)
+	assert.Less(t, len(inputProver.PublicInputs.BatchL2Data), len(batchL2Data))
+	decompressed, err := gunzipBatchL2Data(inputProver.PublicInputs.BatchL2Data)
+	require.NoError(t, err)
+	assert.Equal(t, batchL2Data, decompressed)
+}
+
+// BenchmarkGzipBatchL2Data reports the size of the L2 data of a batch of 1000
+// ERC20 transfers once compressed, as a fraction of the original size.
+func BenchmarkGzipBatchL2Data(b *testing.B) {
+	batchL2Data := realisticBatchL2Data(b, 1000)
+	var compressed []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		compressed, err = gzipBatchL2Data(batchL2Data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(batchL2Data)), "bytes")
+	b.ReportMetric(float64(len(compressed))/float64(len(batchL2Data)), "ratio")
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"math/big"
 
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/events"
 	"github.com/0xPolygonHermez/zkevm-node/config/types"
 	"github.com/0xPolygonHermez/zkevm-node/encoding"
+	"github.com/0xPolygonHermez/zkevm-node/state"
 )
 
 // TokenAmountWithDecimals is a wrapper type that parses token amount with decimals to big int
@@ -28,23 +30,375 @@ func (t *TokenAmountWithDecimals) UnmarshalText(data []byte) error {
 	return nil
 }
 
+// ProofStoreBackend is the backend storing the proofs generated by the
+// provers
+type ProofStoreBackend string
+
+const (
+	// ProofStorePostgres stores the proofs in the state database
+	ProofStorePostgres ProofStoreBackend = "postgres"
+	// ProofStoreMemory keeps the proofs in memory, they are lost on restart
+	ProofStoreMemory ProofStoreBackend = "memory"
+)
+
+// ProverSelectionPolicy is the policy to select the idle prover new work is
+// assigned to
+type ProverSelectionPolicy string
+
+const (
+	// ProverSelectionFirstCome assigns new work to the first idle prover
+	// asking for it
+	ProverSelectionFirstCome ProverSelectionPolicy = "firstcome"
+	// ProverSelectionLeastLoaded assigns new work to the least loaded idle
+	// prover able to perform it
+	ProverSelectionLeastLoaded ProverSelectionPolicy = "leastloaded"
+)
+
+// BatchL2DataCompression is the compression of the batch L2 data sent to the
+// provers
+type BatchL2DataCompression string
+
+const (
+	// BatchL2DataCompressionNone sends the batch L2 data as is
+	BatchL2DataCompressionNone BatchL2DataCompression = "none"
+	// BatchL2DataCompressionGzip sends the batch L2 data gzip compressed
+	BatchL2DataCompressionGzip BatchL2DataCompression = "gzip"
+)
+
+// ProofRegenerationPriority is the priority of the proof regenerations
+// relative to the proofs of new batches
+type ProofRegenerationPriority string
+
+const (
+	// ProofRegenerationPriorityHigh regenerates the queued proofs before
+	// proving new batches
+	ProofRegenerationPriorityHigh ProofRegenerationPriority = "high"
+	// ProofRegenerationPriorityLow regenerates the queued proofs only when
+	// there is no new batch to prove
+	ProofRegenerationPriorityLow ProofRegenerationPriority = "low"
+)
+
+// ChannelOperation is one of the operations performed on every iteration of
+// the prover channel loop
+type ChannelOperation string
+
+const (
+	// ChannelOperationBuildFinalProof builds a final proof from a proof ready
+	// to be verified
+	ChannelOperationBuildFinalProof ChannelOperation = "buildfinalproof"
+	// ChannelOperationAggregateProofs aggregates two recursive proofs
+	ChannelOperationAggregateProofs ChannelOperation = "aggregateproofs"
+	// ChannelOperationGenerateBatchProof generates the proof of a batch
+	ChannelOperationGenerateBatchProof ChannelOperation = "generatebatchproof"
+	// ChannelOperationRegenerateProof regenerates the proof of a batch queued
+	// for regeneration. It's not part of the configured order, it's placed
+	// next to ChannelOperationGenerateBatchProof by the regeneration priority
+	ChannelOperationRegenerateProof ChannelOperation = "regenerateproof"
+)
+
+// defaultChannelOperationsOrder is the order used if none is configured.
+var defaultChannelOperationsOrder = []ChannelOperation{
+	ChannelOperationBuildFinalProof,
+	ChannelOperationAggregateProofs,
+	ChannelOperationGenerateBatchProof,
+}
+
+// validateChannelOperationsOrder checks that the order contains all the
+// channel operations exactly once.
+func validateChannelOperationsOrder(order []ChannelOperation) error {
+	if len(order) != len(defaultChannelOperationsOrder) {
+		return fmt.Errorf("channel operations order must contain %d operations, got %d", len(defaultChannelOperationsOrder), len(order))
+	}
+	seen := make(map[ChannelOperation]bool, len(order))
+	for _, op := range order {
+		switch op {
+		case ChannelOperationBuildFinalProof, ChannelOperationAggregateProofs, ChannelOperationGenerateBatchProof:
+		default:
+			return fmt.Errorf("unknown channel operation %q", op)
+		}
+		if seen[op] {
+			return fmt.Errorf("duplicated channel operation %q", op)
+		}
+		seen[op] = true
+	}
+	return nil
+}
+
+// ForkIDInterval is a range of batches that must be proven using the same
+// fork id
+type ForkIDInterval struct {
+	// FromBatchNumber is the first batch of the interval
+	FromBatchNumber uint64 `mapstructure:"FromBatchNumber"`
+	// ToBatchNumber is the last batch of the interval
+	ToBatchNumber uint64 `mapstructure:"ToBatchNumber"`
+	// ForkID is the fork id of the batches in the interval
+	ForkID uint64 `mapstructure:"ForkID"`
+}
+
+// L1RateLimitConfig is the configuration of the rate limit shared by all the
+// L1 calls of the aggregator
+type L1RateLimitConfig struct {
+	// RequestsPerSecond is the number of L1 calls allowed per second, 0
+	// disables the rate limit
+	RequestsPerSecond float64 `mapstructure:"RequestsPerSecond"`
+	// Burst is the max number of L1 calls allowed at once
+	Burst int `mapstructure:"Burst"`
+	// MaxWait is the max time a call waits for the rate limit before failing,
+	// 0 means no limit
+	MaxWait types.Duration `mapstructure:"MaxWait"`
+}
+
+// VerificationWindowConfig is the configuration of the window to accumulate
+// the eligible proofs before verifying them
+type VerificationWindowConfig struct {
+	// Window is the time a proof starting at the next batch to verify is
+	// kept unverified since it's first eligible, to let it be aggregated with
+	// the following ones. 0 verifies the eligible proofs right away
+	Window types.Duration `mapstructure:"Window"`
+	// MaxRange is the number of batches that, once accumulated, are verified
+	// without waiting for the window to elapse. 0 means no limit
+	MaxRange uint64 `mapstructure:"MaxRange"`
+}
+
+// ProofRegenerationConfig is the configuration of the queue of proofs to
+// regenerate
+type ProofRegenerationConfig struct {
+	// Enabled makes the idle provers drain the regeneration queue, and serves
+	// the /admin/regenerations endpoint on the admin server to enqueue batch
+	// ranges
+	Enabled bool `mapstructure:"Enabled"`
+	// Priority is the priority of the regenerations relative to the proofs
+	// of new batches: high, regenerated first, or low, regenerated only when
+	// there is no new batch to prove
+	Priority ProofRegenerationPriority `mapstructure:"Priority"`
+}
+
+// BatchRange is a range of batches, both ends included
+type BatchRange struct {
+	// FromBatchNumber is the first batch of the range
+	FromBatchNumber uint64 `mapstructure:"FromBatchNumber"`
+	// ToBatchNumber is the last batch of the range
+	ToBatchNumber uint64 `mapstructure:"ToBatchNumber"`
+}
+
+// BatchFilterConfig is the configuration of the filter of the batches to
+// generate the proofs for
+type BatchFilterConfig struct {
+	// AllowedBatches are the ranges of batches to prove, the batches out of
+	// them are skipped. If empty, all the batches are allowed
+	AllowedBatches []BatchRange `mapstructure:"AllowedBatches"`
+	// DeniedBatches are the ranges of batches never proven, even if allowed
+	DeniedBatches []BatchRange `mapstructure:"DeniedBatches"`
+}
+
+// LoadSheddingConfig is the configuration of the load shedding of the work
+// assigned to the provers
+type LoadSheddingConfig struct {
+	// Enabled makes the aggregator stop assigning new work to the provers
+	// while any of the thresholds is exceeded
+	Enabled bool `mapstructure:"Enabled"`
+	// MaxHeapMB is the max heap allocated by the aggregator in MB, 0 means
+	// no limit
+	MaxHeapMB uint64 `mapstructure:"MaxHeapMB"`
+	// MaxGoroutines is the max number of goroutines of the aggregator, 0
+	// means no limit
+	MaxGoroutines int `mapstructure:"MaxGoroutines"`
+	// CheckInterval is the interval to check the resource usage
+	CheckInterval types.Duration `mapstructure:"CheckInterval"`
+}
+
+// ProverKeepaliveConfig is the configuration of the keepalive of the
+// connections with the provers
+type ProverKeepaliveConfig struct {
+	// Interval is the interval to ping a prover while waiting for a proof,
+	// so the stream isn't dropped by the intermediaries for being idle. The
+	// pings are status requests, answered by every prover. 0 disables the
+	// keepalive
+	Interval types.Duration `mapstructure:"Interval"`
+	// Timeout is the max time to wait for a prover to respond to any
+	// message once the keepalive is enabled. Once exceeded the connection is
+	// considered dead and closed, releasing or holding the work of the
+	// prover as on a transient disconnection
+	Timeout types.Duration `mapstructure:"Timeout"`
+}
+
+// GRPCKeepaliveConfig is the configuration of the keepalive of the grpc
+// server, see google.golang.org/grpc/keepalive. 0 keeps the grpc defaults
+type GRPCKeepaliveConfig struct {
+	// Time is the time without activity after which the server pings the
+	// client to check the connection is alive, at least 1s. The grpc
+	// default is 2h
+	Time types.Duration `mapstructure:"Time"`
+	// Timeout is the time the server waits for the response to a ping
+	// before closing the connection. The grpc default is 20s
+	Timeout types.Duration `mapstructure:"Timeout"`
+	// MinTime is the min interval allowed between the pings of a client,
+	// the connections of clients pinging more often are closed. The grpc
+	// default is 5m
+	MinTime types.Duration `mapstructure:"MinTime"`
+	// PermitWithoutStream allows the clients to ping without active streams
+	PermitWithoutStream bool `mapstructure:"PermitWithoutStream"`
+}
+
+// FailureCircuitConfig is the configuration of the circuit that pauses the
+// pipeline when too many operations fail
+type FailureCircuitConfig struct {
+	// Enabled makes the aggregator pause the pipeline when the failure rate
+	// over the window exceeds the threshold
+	Enabled bool `mapstructure:"Enabled"`
+	// Window is the length of the rolling window over which the failure
+	// rate is computed
+	Window types.Duration `mapstructure:"Window"`
+	// Threshold is the fraction of failed operations, between 0 and 1, above
+	// which the pipeline is paused
+	Threshold float64 `mapstructure:"Threshold"`
+	// MinOperations is the min number of operations in the window for the
+	// failure rate to be evaluated
+	MinOperations int `mapstructure:"MinOperations"`
+	// AutoResume resumes the pipeline once the failure rate over the window
+	// falls back below the threshold. If false, the pipeline stays paused
+	// until an operator resumes it on the /admin/circuit/resume endpoint of
+	// the admin server
+	AutoResume bool `mapstructure:"AutoResume"`
+}
+
+// HealthCheckConfig is the configuration of the conditions, besides the
+// health of the state database, making the gRPC health check report
+// NOT_SERVING.
+type HealthCheckConfig struct {
+	// NoProversGracePeriod is the time the aggregator can be without any
+	// prover connected before being reported as not serving, 0 disables it
+	NoProversGracePeriod types.Duration `mapstructure:"NoProversGracePeriod"`
+	// L1 makes the aggregator be reported as not serving while the last call
+	// to L1 failed
+	L1 bool `mapstructure:"L1"`
+	// WatchInterval is the interval to evaluate the status for the clients
+	// watching it, which are sent a new one every time it changes
+	WatchInterval types.Duration `mapstructure:"WatchInterval"`
+}
+
+// ChainIDCheckConfig is the configuration of the check of the chain ids on
+// start up.
+type ChainIDCheckConfig struct {
+	// Enabled makes the aggregator check on start up that ChainID and
+	// L1ChainID match the ones of the chain the etherman is connected to
+	Enabled bool `mapstructure:"Enabled"`
+	// WarnOnly makes the aggregator log a mismatch instead of failing to
+	// start
+	WarnOnly bool `mapstructure:"WarnOnly"`
+}
+
+// LeaderElectionConfig is the configuration of the leader election between
+// aggregators running in HA
+type LeaderElectionConfig struct {
+	// Enabled makes the aggregator run the proof generation and verification
+	// loops only while holding the leader lock
+	Enabled bool `mapstructure:"Enabled"`
+	// Backend is the backend used to hold the leader lock
+	// possible values: postgres
+	Backend LeaderLockBackend `mapstructure:"Backend"`
+	// LockID is the id of the lock shared by all the aggregators
+	LockID int64 `mapstructure:"LockID"`
+	// RetryInterval is the interval to try to acquire the lock while in
+	// standby and to check it's still held while leader
+	RetryInterval types.Duration `mapstructure:"RetryInterval"`
+}
+
 // Config represents the configuration of the aggregator
 type Config struct {
 	// Host for the grpc server
 	Host string `mapstructure:"Host"`
 	// Port for the grpc server
 	Port int `mapstructure:"Port"`
+	// MaxRecvMsgSize is the max size in bytes of the messages received by the
+	// grpc server, 0 keeps the grpc default of 4MB
+	MaxRecvMsgSize int `mapstructure:"MaxRecvMsgSize"`
+	// MaxSendMsgSize is the max size in bytes of the messages sent by the
+	// grpc server, like the input provers, 0 keeps the grpc default
+	MaxSendMsgSize int `mapstructure:"MaxSendMsgSize"`
+	// GRPCKeepalive is the configuration of the keepalive of the grpc server,
+	// so the streams of the provers aren't dropped by the intermediaries
+	GRPCKeepalive GRPCKeepaliveConfig `mapstructure:"GRPCKeepalive"`
+	// StatusPort for the http server exposing the status of the provers, the
+	// throughput of the proof pipeline and the batches accumulated to be
+	// verified, 0 disables it
+	StatusPort int `mapstructure:"StatusPort"`
+	// AdminHost for the http server exposing the admin endpoints, the
+	// loopback interface by default so they are not publicly reachable
+	AdminHost string `mapstructure:"AdminHost"`
+	// AdminPort for the http server exposing the admin endpoints, like the
+	// one overriding the gas price of a verification, the verifications sent
+	// to L1 and the effective configuration, 0 disables it
+	AdminPort int `mapstructure:"AdminPort"`
+	// AdminToken is the bearer token required by the admin endpoints, if
+	// set
+	AdminToken string `mapstructure:"AdminToken"`
 
 	// RetryTime is the time the aggregator main loop sleeps if there are no proofs to aggregate
 	// or batches to generate proofs. It is also used in the isSynced loop
 	RetryTime types.Duration `mapstructure:"RetryTime"`
 
+	// MaxSyncWaitTime is the maximum time to wait for the synchronizer to
+	// catch up with the batches verified on L1 before building or after
+	// sending a final proof. Once elapsed the verification is released and
+	// the proof is left to be verified later. 0 waits with no maximum
+	MaxSyncWaitTime types.Duration `mapstructure:"MaxSyncWaitTime"`
+
 	// VerifyProofInterval is the interval of time to verify/send an proof in L1
 	VerifyProofInterval types.Duration `mapstructure:"VerifyProofInterval"`
 
+	// FinalProofSubmissionCooldown is the minimum interval between consecutive
+	// submissions of final proofs to L1, independently of the verify proof
+	// interval, 0 means no spacing
+	FinalProofSubmissionCooldown types.Duration `mapstructure:"FinalProofSubmissionCooldown"`
+
 	// ProofStatePollingInterval is the interval time to polling the prover about the generation state of a proof
 	ProofStatePollingInterval types.Duration `mapstructure:"ProofStatePollingInterval"`
 
+	// BatchProofTimeout is the maximum time to wait for the prover to generate
+	// a batch proof, 0 means no timeout
+	BatchProofTimeout types.Duration `mapstructure:"BatchProofTimeout"`
+
+	// AggregatedProofTimeout is the maximum time to wait for the prover to
+	// generate an aggregated proof, 0 means no timeout
+	AggregatedProofTimeout types.Duration `mapstructure:"AggregatedProofTimeout"`
+
+	// FinalProofTimeout is the maximum time to wait for the prover to generate
+	// a final proof, 0 means no timeout
+	FinalProofTimeout types.Duration `mapstructure:"FinalProofTimeout"`
+
+	// ProofGenerationTimeout caps the time to wait for the prover to generate
+	// any proof, whatever its type timeout. Once a wait times out the proof
+	// is released and the prover, considered hung, is disconnected. 0 means
+	// no cap
+	ProofGenerationTimeout types.Duration `mapstructure:"ProofGenerationTimeout"`
+
+	// CheckGlobalExitRoot enables checking that the global exit root stored
+	// for a batch was synced from L1 before building its input for the
+	// prover, to detect an unknown global exit root. A batch may use any
+	// global exit root synced, not only the latest one
+	CheckGlobalExitRoot bool `mapstructure:"CheckGlobalExitRoot"`
+
+	// FailOnStaleGlobalExitRoot makes the input for the prover fail to build
+	// on an unknown global exit root, instead of just warning about it
+	FailOnStaleGlobalExitRoot bool `mapstructure:"FailOnStaleGlobalExitRoot"`
+
+	// CheckBatchL2Data enables the verification of the L2 data stored for a
+	// batch against its accumulated input hash before building its input for
+	// the prover, to avoid proving corrupted batch data
+	CheckBatchL2Data bool `mapstructure:"CheckBatchL2Data"`
+
+	// ProofTTL is the time passed to the provers along with each proof
+	// request after which they abandon the proof, freeing their resources
+	// without waiting for the aggregator to cancel it. Provers not supporting
+	// it ignore it. 0 means no TTL
+	ProofTTL types.Duration `mapstructure:"ProofTTL"`
+
+	// TransientDisconnectHold is the time the proof being generated by a
+	// prover that disconnected transiently is kept locked, to let the prover
+	// reconnect and resume it. 0 releases it immediately
+	TransientDisconnectHold types.Duration `mapstructure:"TransientDisconnectHold"`
+
 	// TxProfitabilityCheckerType type for checking is it profitable for aggregator to validate batch
 	// possible values: base/acceptall
 	TxProfitabilityCheckerType TxProfitabilityCheckerType `mapstructure:"TxProfitabilityCheckerType"`
@@ -56,6 +410,262 @@ type Config struct {
 	// IntervalAfterWhichBatchConsolidateAnyway this is interval for the main sequencer, that will check if there is no transactions
 	IntervalAfterWhichBatchConsolidateAnyway types.Duration `mapstructure:"IntervalAfterWhichBatchConsolidateAnyway"`
 
-	// ChainID is the L2 ChainID provided by the Network Config
-	ChainID uint64
+	// MinBatchesToVerify is the min number of batches a final proof is built
+	// for, so the verification of a single batch isn't sent to L1 when the
+	// traffic is low. A proof spanning fewer batches is verified anyway once
+	// IntervalAfterWhichBatchConsolidateAnyway elapses since the last final
+	// proof was sent, which must be set. 0 or 1 means no min
+	MinBatchesToVerify uint64 `mapstructure:"MinBatchesToVerify"`
+
+	// ChainID is the L2 ChainID the proofs are built for. If not set, it's
+	// read from the PoE smart contract
+	ChainID uint64 `mapstructure:"ChainID"`
+
+	// L1ChainID is the L1 ChainID the txs sent to L1 are signed for, provided
+	// by the Etherman config
+	L1ChainID uint64 `mapstructure:"L1ChainID"`
+
+	// ChainIDCheck is the configuration of the check of the chain ids on
+	// start up
+	ChainIDCheck ChainIDCheckConfig `mapstructure:"ChainIDCheck"`
+
+	// CheckVerifiedStateRoot enables reading back from L1 the state root of
+	// the last verified batch once the synchronizer has caught up, to compare
+	// it against the one submitted in the final proof
+	CheckVerifiedStateRoot bool `mapstructure:"CheckVerifiedStateRoot"`
+
+	// ProofStore is the backend storing the proofs: postgres, the state
+	// database, or memory, for tests and ephemeral nodes as the proofs are
+	// lost on restart. There is no redis backend, the proofs must be stored
+	// with the sequences and batches they are checked against in the same
+	// transactions
+	ProofStore ProofStoreBackend `mapstructure:"ProofStore"`
+
+	// ProofCacheSize is the max number of proofs kept in memory to avoid
+	// fetching them from the DB on every iteration. Zero disables the cache
+	ProofCacheSize int `mapstructure:"ProofCacheSize"`
+
+	// ForkIDIntervals maps batch ranges to the fork id to be used to prove
+	// them. Batches not covered by any interval are proven with fork id 0
+	ForkIDIntervals []ForkIDInterval `mapstructure:"ForkIDIntervals"`
+
+	// BatchFilter restricts the batches the aggregator generates the batch
+	// proofs for, e.g. to pin it to a range of batches without running
+	// ahead. It's reloaded when the config file changes
+	BatchFilter BatchFilterConfig `mapstructure:"BatchFilter"`
+
+	// MaxConcurrentSerializations is the max number of input provers that
+	// can be serialized at the same time, bounding the memory used by
+	// provers working on big batches. Zero means no limit
+	MaxConcurrentSerializations int `mapstructure:"MaxConcurrentSerializations"`
+
+	// MaxConcurrentBatchProofs is the max number of batches that can be
+	// proven at the same time by the connected provers, each one proving a
+	// distinct batch. Zero means no limit
+	MaxConcurrentBatchProofs int `mapstructure:"MaxConcurrentBatchProofs"`
+
+	// Events is the configuration of the proof lifecycle events publisher
+	Events events.Config `mapstructure:"Events"`
+
+	// ChannelOperationsOrder is the order in which the operations are tried
+	// on every iteration of the prover channel loop. It must contain
+	// buildfinalproof, aggregateproofs and generatebatchproof exactly once.
+	// Aggregating proofs and generating a batch proof are exclusive: once one
+	// of them has generated a proof the other one is skipped
+	ChannelOperationsOrder []ChannelOperation `mapstructure:"ChannelOperationsOrder"`
+
+	// VerificationHistorySize is the number of verifications sent to L1 kept
+	// in memory to look them up by tx hash, the older ones are looked up in
+	// the state
+	VerificationHistorySize int `mapstructure:"VerificationHistorySize"`
+
+	// NotSyncedWhenAheadOfL1 makes the aggregator consider the state not
+	// synced when its last verified batch is ahead of the one verified on L1,
+	// e.g. after an L1 reorg. If false, the mismatch is logged as an anomaly
+	// and the aggregator proceeds
+	NotSyncedWhenAheadOfL1 bool `mapstructure:"NotSyncedWhenAheadOfL1"`
+
+	// DetectVerifiedBatchReorgs makes the aggregator check that the last
+	// verified batch of the state is still verified on L1 with the same
+	// state root. On an L1 reorg the verified batches are rolled back to the
+	// last one verified on L1, and the batches after it are proved and
+	// verified again while the state is synced back
+	DetectVerifiedBatchReorgs bool `mapstructure:"DetectVerifiedBatchReorgs"`
+
+	// FilterProofsByProverCapabilities makes the aggregator route the work
+	// by the capabilities advertised by the provers during the handshake: a
+	// prover is only asked the operations it can perform, and a proof ready
+	// to verify is locked only if the requesting prover can build its final
+	// proof. Provers advertising no capabilities are assumed capable of
+	// everything
+	FilterProofsByProverCapabilities bool `mapstructure:"FilterProofsByProverCapabilities"`
+
+	// LeaderElection is the configuration of the leader election
+	LeaderElection LeaderElectionConfig `mapstructure:"LeaderElection"`
+
+	// L1RateLimit is the configuration of the rate limit of the L1 calls
+	L1RateLimit L1RateLimitConfig `mapstructure:"L1RateLimit"`
+
+	// LoadShedding is the configuration of the load shedding
+	LoadShedding LoadSheddingConfig `mapstructure:"LoadShedding"`
+
+	// ProverKeepalive is the configuration of the keepalive of the
+	// connections with the provers
+	ProverKeepalive ProverKeepaliveConfig `mapstructure:"ProverKeepalive"`
+
+	// DBHealthCheckInterval is the interval to probe the connection to the
+	// state database. While the probe fails the idle connections are reset
+	// and no work is assigned to the provers. 0 disables the probe
+	DBHealthCheckInterval types.Duration `mapstructure:"DBHealthCheckInterval"`
+
+	// HealthCheck is the configuration of the other conditions reported by
+	// the gRPC health check
+	HealthCheck HealthCheckConfig `mapstructure:"HealthCheck"`
+
+	// RecheckBeforeSendingFinalProof makes the aggregator check, right before
+	// sending a final proof to L1, that its batches are not being nor have
+	// been verified by another path, giving up the final proof if so
+	RecheckBeforeSendingFinalProof bool `mapstructure:"RecheckBeforeSendingFinalProof"`
+
+	// CheckFinalProofInputs makes the aggregator compare, before sending a
+	// final proof to L1, the public inputs the proof commits to against its
+	// batches and the roots sent along with it, giving up the final proof on
+	// a mismatch instead of sending a verification that would revert
+	CheckFinalProofInputs bool `mapstructure:"CheckFinalProofInputs"`
+
+	// DryRun makes the aggregator estimate the tx verifying a final proof,
+	// logging its calldata, gas and cost, instead of sending it to L1. The
+	// proof is kept and verified again once VerifyProofInterval elapses
+	DryRun bool `mapstructure:"DryRun"`
+
+	// UseMockProverValues makes the aggregator replace the NewStateRoot and
+	// NewLocalExitRoot of the final proofs with the ones of the last batch
+	// stored by the executor. It must only be enabled when running a mock
+	// prover, whose roots are not the ones of the batches
+	UseMockProverValues bool `mapstructure:"UseMockProverValues"`
+
+	// DeduplicateFinalProofs makes the aggregator skip building a final
+	// proof for batches whose final proof is already being built or sent, or
+	// has just been submitted
+	DeduplicateFinalProofs bool `mapstructure:"DeduplicateFinalProofs"`
+
+	// MaxAggregationDepth is the max number of aggregation rounds folded
+	// into a proof. A proof at the max depth containing complete sequences is
+	// not aggregated anymore, it can only be used to build a final proof. A
+	// deeper one not ending on a sequence boundary is still aggregated, as it
+	// could never be verified otherwise. 0 means no limit
+	MaxAggregationDepth uint64 `mapstructure:"MaxAggregationDepth"`
+
+	// MaxBatchesPerAggregatedProof is the max number of batches spanned by an
+	// aggregated proof, bounding the memory needed by the prover. Proofs whose
+	// aggregation would exceed it are not aggregated, they are left to build a
+	// final proof as they are once they contain complete sequences. It must
+	// be at least the number of batches of the largest sequence, otherwise the
+	// proofs of such a sequence are never aggregated into a verifiable proof.
+	// 0 means no limit
+	MaxBatchesPerAggregatedProof uint64 `mapstructure:"MaxBatchesPerAggregatedProof"`
+
+	// VerifiedProofsCleanupChunkSize is the number of batches whose proofs
+	// are deleted at once when cleaning up the proofs of a verified range,
+	// each chunk being deleted in its own statement so a wide range doesn't
+	// hold a long-running lock. 0 deletes the whole range at once
+	VerifiedProofsCleanupChunkSize uint64 `mapstructure:"VerifiedProofsCleanupChunkSize"`
+
+	// ProofDeletionConfirmations is the number of L1 confirmations, as
+	// counted by ethtxmanager.Confirmations, of a final proof verification
+	// before deleting the proofs of the verified batches. Until then the
+	// proofs are kept, so they are sent again if the verification is reorged
+	// out. 0 or 1 deletes them as soon as the state is synced with the
+	// verification
+	ProofDeletionConfirmations uint64 `mapstructure:"ProofDeletionConfirmations"`
+
+	// ProofCommitments makes the aggregator store with each proof a hash
+	// chain over the state roots of the batches it covers, checking it on
+	// every aggregation and before sending the final proof to L1. It adds
+	// reads of the batches of each proof
+	ProofCommitments bool `mapstructure:"ProofCommitments"`
+
+	// FailureCircuit is the configuration of the circuit that pauses the
+	// pipeline when too many operations fail
+	FailureCircuit FailureCircuitConfig `mapstructure:"FailureCircuit"`
+
+	// CompleteSequencesCheckFailOpen makes a proof eligible to be verified
+	// when checking if it contains complete sequences fails for a reason that
+	// isn't known to be transient. If false, the final proof build is aborted
+	CompleteSequencesCheckFailOpen bool `mapstructure:"CompleteSequencesCheckFailOpen"`
+
+	// VerificationWindow is the configuration of the window to accumulate
+	// the eligible proofs before verifying them
+	VerificationWindow VerificationWindowConfig `mapstructure:"VerificationWindow"`
+
+	// FinalBatchRetries is the number of times the last batch of a final
+	// proof is read again when it can't be read before sending the final
+	// proof to L1, dropping the final proof once exhausted
+	FinalBatchRetries int `mapstructure:"FinalBatchRetries"`
+
+	// FinalBatchRetryInterval is the time to wait before the first retry to
+	// read the last batch of a final proof, doubled on each retry
+	FinalBatchRetryInterval types.Duration `mapstructure:"FinalBatchRetryInterval"`
+
+	// AggregatedProofUnlockRetries is the number of times unlocking an
+	// aggregated proof is retried when it fails once the aggregation is
+	// stored. The proofs still locked are unlocked later on the next
+	// iterations of the provers, they are lost if the aggregator restarts
+	// meanwhile
+	AggregatedProofUnlockRetries int `mapstructure:"AggregatedProofUnlockRetries"`
+
+	// AggregatedProofUnlockRetryInterval is the time to wait before the first
+	// retry to unlock an aggregated proof, doubled on each retry
+	AggregatedProofUnlockRetryInterval types.Duration `mapstructure:"AggregatedProofUnlockRetryInterval"`
+
+	// StartupQuietPeriod is the time the node must be synced after the
+	// aggregator starts before proofs are verified, proofs are still
+	// generated and aggregated meanwhile. 0 verifies right away
+	StartupQuietPeriod types.Duration `mapstructure:"StartupQuietPeriod"`
+
+	// BatchAffinityWait is the time a batch is left for the prover that has
+	// proved the previous one, to benefit from its warm caches, as long as it
+	// is not busy. 0 disables the affinity
+	BatchAffinityWait types.Duration `mapstructure:"BatchAffinityWait"`
+
+	// PreferredOperationRouting makes the aggregator honor the operations
+	// preferred by the provers, advertised with the capabilities prefixed by
+	// prefer_. The operations a prover prefers are tried first with it, and
+	// the other provers leave them while any prover preferring them is idle
+	PreferredOperationRouting bool `mapstructure:"PreferredOperationRouting"`
+
+	// ProverSelection is the policy to select the idle prover new work is
+	// assigned to: firstcome, the first one asking for it, or leastloaded,
+	// the one with the lowest fraction of its memory in use among the ones
+	// able to perform it. Provers not reporting their memory are considered
+	// fully loaded
+	ProverSelection ProverSelectionPolicy `mapstructure:"ProverSelection"`
+
+	// ProofReadyToVerifyPreference is the preference among the proofs ready
+	// to verify, all starting right after the last verified batch and
+	// containing complete sequences: largest, the one with the largest final
+	// batch number, or smallest, the one with the smallest
+	ProofReadyToVerifyPreference state.ProofPreference `mapstructure:"ProofReadyToVerifyPreference"`
+
+	// BatchL2DataCompression is the compression of the batch L2 data sent to
+	// the provers in the input prover: none, or gzip, flagged in the input so
+	// the prover decompresses it. It requires provers supporting it
+	BatchL2DataCompression BatchL2DataCompression `mapstructure:"BatchL2DataCompression"`
+
+	// ThroughputWindow is the length of the rolling window over which the
+	// batches verified and the proofs generated per hour are computed, 0
+	// disables the throughput tracking
+	ThroughputWindow types.Duration `mapstructure:"ThroughputWindow"`
+
+	// ShutdownTimeout is the time the final proof being sent to L1 is given
+	// to finish when the aggregator stops, and the pending RPCs of the
+	// provers to finish afterwards. The final proof not sent by then is
+	// rolled back. 0 stops right away
+	ShutdownTimeout types.Duration `mapstructure:"ShutdownTimeout"`
+
+	// ProofRegeneration is the configuration of the queue of proofs to
+	// regenerate, e.g. after a corruption or a format migration. The queue is
+	// persisted in the proof store, the regenerated proofs replace the ones
+	// overlapping their batch
+	ProofRegeneration ProofRegenerationConfig `mapstructure:"ProofRegeneration"`
 }
@@ -0,0 +1,50 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateChannelOperationsOrder(t *testing.T) {
+	testCases := []struct {
+		name        string
+		order       []ChannelOperation
+		expectedErr bool
+	}{
+		{
+			name:  "default",
+			order: defaultChannelOperationsOrder,
+		},
+		{
+			name:  "throughput",
+			order: []ChannelOperation{ChannelOperationGenerateBatchProof, ChannelOperationAggregateProofs, ChannelOperationBuildFinalProof},
+		},
+		{
+			name:        "missing operation",
+			order:       []ChannelOperation{ChannelOperationGenerateBatchProof, ChannelOperationAggregateProofs},
+			expectedErr: true,
+		},
+		{
+			name:        "duplicated operation",
+			order:       []ChannelOperation{ChannelOperationGenerateBatchProof, ChannelOperationGenerateBatchProof, ChannelOperationBuildFinalProof},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown operation",
+			order:       []ChannelOperation{ChannelOperationGenerateBatchProof, ChannelOperationAggregateProofs, "verify"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateChannelOperationsOrder(tc.order)
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/ethtxmanager"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// waitForConfirmations waits for the tx to get ProofDeletionConfirmations
+// L1 confirmations, as counted by ethtxmanager.Confirmations, polling every
+// RetryTime. It returns ErrVerificationReorged if the tx is no longer found on
+// L1, or the context error if the context is done before.
+func (a *Aggregator) waitForConfirmations(ctx context.Context, txHash common.Hash) error {
+	err := ethtxmanager.WaitConfirmations(ctx, a.Ethman, txHash, a.cfg.ProofDeletionConfirmations, a.cfg.RetryTime.Duration)
+	if errors.Is(err, ethtxmanager.ErrTxReorged) {
+		return fmt.Errorf("%w: tx %s", ErrVerificationReorged, txHash)
+	}
+	return err
+}
+
+// confirmVerifiedFinalProof keeps the proofs of the final proof verified in
+// the tx until the verification can't be reorged out. The proof is kept to be
+// sent again if the verification is reorged out, and to be cleaned up on
+// restart if the confirmations can't be awaited.
+func (a *Aggregator) confirmVerifiedFinalProof(ctx context.Context, proof *state.Proof, txHash common.Hash) gateResult {
+	err := a.waitForConfirmations(ctx, txHash)
+	if ctx.Err() != nil {
+		return gateStop
+	}
+	if errors.Is(err, ErrVerificationReorged) {
+		log.Warnf("Final proof tx %s for batches [%d-%d] reorged out, the proof is kept to be sent again", txHash, proof.BatchNumber, proof.BatchNumberFinal)
+		unmarkProofVerified(ctx, a.State, proof)
+		a.forgetFinalProof(proof)
+		a.resetVerifyProofTime()
+		return gateSkip
+	}
+	if err != nil {
+		// the clean up is resumed on restart
+		log.Warnf("Proofs for batches [%d-%d] verified but not cleaned up, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		a.resetVerifyProofTime()
+		return gateSkip
+	}
+	return gatePass
+}
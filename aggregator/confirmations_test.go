@@ -0,0 +1,65 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForConfirmations(t *testing.T) {
+	txHash := common.HexToHash("0x1")
+	receipt := &ethTypes.Receipt{TxHash: txHash, BlockNumber: big.NewInt(100)}
+	ctx := context.Background()
+
+	t.Run("disabled", func(t *testing.T) {
+		a := Aggregator{Ethman: mocks.NewEtherman(t)}
+		assert.NoError(t, a.waitForConfirmations(ctx, txHash))
+	})
+
+	t.Run("confirmed", func(t *testing.T) {
+		eth := mocks.NewEtherman(t)
+		a := Aggregator{
+			cfg:    Config{ProofDeletionConfirmations: 3, RetryTime: types.NewDuration(time.Millisecond)},
+			Ethman: eth,
+		}
+		eth.On("GetTxReceipt", ctx, txHash).Return(receipt, nil).Twice()
+		eth.On("GetLatestBlockNumber", ctx).Return(uint64(101), nil).Once()
+		eth.On("GetLatestBlockNumber", ctx).Return(uint64(102), nil).Once()
+		assert.NoError(t, a.waitForConfirmations(ctx, txHash))
+	})
+
+	t.Run("reorged", func(t *testing.T) {
+		eth := mocks.NewEtherman(t)
+		a := Aggregator{
+			cfg:    Config{ProofDeletionConfirmations: 3, RetryTime: types.NewDuration(time.Millisecond)},
+			Ethman: eth,
+		}
+		eth.On("GetTxReceipt", ctx, txHash).Return(receipt, nil).Once()
+		eth.On("GetLatestBlockNumber", ctx).Return(uint64(101), nil).Once()
+		// a single miss is retried, the receipt may not be indexed yet
+		eth.On("GetTxReceipt", ctx, txHash).Return(nil, ethereum.NotFound).Times(3)
+		assert.ErrorIs(t, a.waitForConfirmations(ctx, txHash), ErrVerificationReorged)
+	})
+
+	t.Run("L1 failure", func(t *testing.T) {
+		eth := mocks.NewEtherman(t)
+		a := Aggregator{
+			cfg:    Config{ProofDeletionConfirmations: 3, RetryTime: types.NewDuration(time.Millisecond)},
+			Ethman: eth,
+		}
+		// the failure is retried
+		eth.On("GetTxReceipt", ctx, txHash).Return(nil, errors.New("connection refused")).Once()
+		eth.On("GetTxReceipt", ctx, txHash).Return(receipt, nil).Once()
+		eth.On("GetLatestBlockNumber", ctx).Return(uint64(102), nil).Once()
+		assert.NoError(t, a.waitForConfirmations(ctx, txHash))
+	})
+}
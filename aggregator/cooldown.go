@@ -0,0 +1,69 @@
+package aggregator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// submissionCooldown holds the submission of a final proof to L1 until the
+// cool-down started by the previous submission elapses, independently of the
+// verify proof interval.
+type submissionCooldown struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newSubmissionCooldown(interval time.Duration) *submissionCooldown {
+	return &submissionCooldown{interval: interval}
+}
+
+// start holds the next submission until the cool-down elapses, it's safe to
+// call it on a nil cool-down.
+func (c *submissionCooldown) start(now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.next = now.Add(c.interval)
+}
+
+// remaining returns the time left for the next submission to be allowed, 0 if
+// already allowed or the cool-down is nil.
+func (c *submissionCooldown) remaining(now time.Time) time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if remaining := c.next.Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// wait waits for the cool-down to elapse. It returns false if the context is
+// done meanwhile.
+func (c *submissionCooldown) wait(ctx context.Context) bool {
+	wait := c.remaining(time.Now())
+	if wait <= 0 {
+		return true
+	}
+
+	log.Infof("Waiting %v for the submission cool-down before sending the final proof", wait.Round(time.Millisecond))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,35 @@
+package aggregator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissionCooldown(t *testing.T) {
+	a := Aggregator{TimeSendFinalProofMutex: &sync.RWMutex{}}
+
+	// disabled by default
+	a.cooldown.start(time.Now())
+	assert.Equal(t, "0s", a.VerificationTimer().UntilNextSubmission)
+	assert.True(t, a.cooldown.wait(context.Background()))
+
+	a.cooldown = newSubmissionCooldown(time.Hour)
+	a.cooldown.start(time.Now())
+	assert.Equal(t, "1h0m0s", a.VerificationTimer().UntilNextSubmission)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, a.cooldown.wait(ctx))
+
+	a.cooldown = newSubmissionCooldown(20 * time.Millisecond)
+	a.cooldown.start(time.Now())
+	start := time.Now()
+	require.True(t, a.cooldown.wait(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 15*time.Millisecond)
+	assert.Equal(t, "0s", a.VerificationTimer().UntilNextSubmission)
+}
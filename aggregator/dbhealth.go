@@ -0,0 +1,59 @@
+package aggregator
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// isDBHealthy returns whether the last probe of the state database succeeded,
+// it's always true if the probe is disabled.
+func (a *Aggregator) isDBHealthy() bool {
+	return atomic.LoadInt32(&a.dbUnhealthy) == 0
+}
+
+// probeDB checks the connection to the state database with a cheap query,
+// resetting the idle connections of the pool on failure so they are
+// established again.
+func (a *Aggregator) probeDB(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(ctx, a.cfg.DBHealthCheckInterval.Duration)
+	defer cancel()
+	err := a.State.Ping(probeCtx)
+	if err != nil && ctx.Err() != nil {
+		// stopping, not a database failure
+		return
+	}
+
+	var unhealthy int32
+	if err != nil {
+		unhealthy = 1
+		a.State.ResetIdleConnections(ctx)
+	}
+	if atomic.SwapInt32(&a.dbUnhealthy, unhealthy) != unhealthy {
+		if err != nil {
+			log.Errorf("State database unhealthy, pausing the work until it recovers, err: %v", err)
+		} else {
+			log.Info("State database healthy again, resuming the work")
+		}
+	}
+	metrics.DBHealthy(err == nil)
+}
+
+// runDBHealthCheck probes the state database every interval until the
+// context is done.
+func (a *Aggregator) runDBHealthCheck(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.DBHealthCheckInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		a.probeDB(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
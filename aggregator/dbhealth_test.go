@@ -0,0 +1,48 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	grpchealth "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestProbeDB(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	a := Aggregator{
+		cfg:   Config{DBHealthCheckInterval: types.NewDuration(time.Second)},
+		State: st,
+	}
+	hc := newHealthChecker(a.isDBHealthy)
+	ctx := context.Background()
+
+	require.True(t, a.isDBHealthy())
+	resp, err := hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, resp.Status)
+
+	// the database goes down
+	st.On("Ping", mock.Anything).Return(errors.New("connection refused")).Twice()
+	st.On("ResetIdleConnections", mock.Anything).Return().Twice()
+	a.probeDB(ctx)
+	a.probeDB(ctx)
+	assert.False(t, a.isDBHealthy())
+	resp, err = hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	// the database recovers
+	st.On("Ping", mock.Anything).Return(nil).Once()
+	a.probeDB(ctx)
+	assert.True(t, a.isDBHealthy())
+	resp, err = hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, resp.Status)
+}
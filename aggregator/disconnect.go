@@ -0,0 +1,145 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/prover"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resumeFunc resumes the work of a prover on its new connection.
+type resumeFunc func(ctx context.Context, prover proverInterface) (bool, error)
+
+// waitProofError is returned when waiting for a proof requested to the prover
+// fails. It's an ErrProverNotResponding.
+type waitProofError struct {
+	err error
+}
+
+func (e *waitProofError) Error() string { return e.err.Error() }
+
+func (e *waitProofError) Unwrap() error { return e.err }
+
+func (e *waitProofError) Is(target error) bool { return target == ErrProverNotResponding }
+
+// heldWork is the work of a prover that disconnected transiently while
+// generating a proof. Its proofs are kept locked until the prover reconnects
+// or the hold expires.
+type heldWork struct {
+	resume  resumeFunc
+	release func()
+	timer   *time.Timer
+}
+
+// heldWorks are the held works indexed by prover id.
+type heldWorks struct {
+	mu    sync.Mutex
+	works map[string]*heldWork
+}
+
+func newHeldWorks() *heldWorks {
+	return &heldWorks{
+		works: make(map[string]*heldWork),
+	}
+}
+
+// take removes and returns the work held for the prover, if any.
+func (h *heldWorks) take(proverID string) *heldWork {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.works[proverID]
+	if !ok {
+		return nil
+	}
+	delete(h.works, proverID)
+	return w
+}
+
+// isTransientDisconnect returns whether the error is caused by the connection
+// with the prover being lost, as opposed to the prover closing the stream or
+// the aggregator being stopped.
+func (a *Aggregator) isTransientDisconnect(err error) bool {
+	if a.ctx != nil && a.ctx.Err() != nil {
+		// the aggregator is stopping
+		return false
+	}
+	if errors.Is(err, io.EOF) {
+		// the prover closed the stream
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, prover.ErrDeadConnection) {
+		return true
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		switch grpcErr.GRPCStatus().Code() {
+		case codes.Canceled, codes.Unavailable:
+			return true
+		}
+	}
+	return false
+}
+
+// releaseWork releases the proofs locked by a work that failed with the given
+// error. If the prover disconnected transiently while waiting for the proof,
+// they are held for the configured time to let the prover resume the work
+// once reconnected.
+func (a *Aggregator) releaseWork(prover proverInterface, err error, resume resumeFunc, release func()) {
+	var waitErr *waitProofError
+	if a.held == nil || a.cfg.TransientDisconnectHold.Duration <= 0 || !errors.As(err, &waitErr) || !a.isTransientDisconnect(waitErr.err) {
+		release()
+		return
+	}
+
+	proverID := prover.ID()
+	log.Infof("Prover { ID [%s], name [%s] } disconnected transiently, holding its work for %v",
+		proverID, prover.Name(), a.cfg.TransientDisconnectHold.Duration)
+
+	a.held.mu.Lock()
+	defer a.held.mu.Unlock()
+
+	if prev, ok := a.held.works[proverID]; ok && prev.timer.Stop() {
+		go prev.release()
+	}
+	w := &heldWork{resume: resume, release: release}
+	w.timer = time.AfterFunc(a.cfg.TransientDisconnectHold.Duration, func() {
+		a.held.mu.Lock()
+		if a.held.works[proverID] != w {
+			// resumed or replaced
+			a.held.mu.Unlock()
+			return
+		}
+		delete(a.held.works, proverID)
+		a.held.mu.Unlock()
+
+		log.Infof("Prover [%s] didn't reconnect, releasing its work", proverID)
+		release()
+	})
+	a.held.works[proverID] = w
+}
+
+// resumeHeldWork resumes the work held for the prover, if any.
+func (a *Aggregator) resumeHeldWork(ctx context.Context, prover proverInterface) {
+	w := a.held.take(prover.ID())
+	if w == nil {
+		return
+	}
+	w.timer.Stop()
+
+	log.Infof("Prover { ID [%s], name [%s] } reconnected, resuming its work", prover.ID(), prover.Name())
+	_, err := w.resume(ctx, prover)
+	if err != nil {
+		log.Errorf("Failed to resume the work of the prover, err: %v", err)
+	}
+	a.assignments.clear(prover)
+}
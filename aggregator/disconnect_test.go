@@ -0,0 +1,139 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/prover"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsTransientDisconnect(t *testing.T) {
+	a := Aggregator{ctx: context.Background()}
+	assert.True(t, a.isTransientDisconnect(context.Canceled))
+	assert.True(t, a.isTransientDisconnect(status.Error(codes.Unavailable, "transport is closing")))
+	assert.True(t, a.isTransientDisconnect(fmt.Errorf("Failed to get proof from prover %w", prover.ErrDeadConnection)))
+	assert.False(t, a.isTransientDisconnect(io.EOF))
+	assert.False(t, a.isTransientDisconnect(context.DeadlineExceeded))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.ctx = ctx
+	assert.False(t, a.isTransientDisconnect(context.Canceled))
+}
+
+func newDisconnectTestAggregator(st *mocks.StateMock, eth *mocks.Etherman, pc *mocks.ProfitabilityCheckerMock, hold time.Duration) Aggregator {
+	return Aggregator{
+		cfg: Config{
+			TransientDisconnectHold: types.NewDuration(hold),
+		},
+		ctx:                     context.Background(),
+		State:                   st,
+		Ethman:                  eth,
+		ProfitabilityChecker:    pc,
+		StateDBMutex:            &sync.Mutex{},
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		// final proof can't be sent yet
+		TimeSendFinalProof: time.Now().Add(time.Hour),
+		held:               newHeldWorks(),
+	}
+}
+
+func expectBatchProofRequest(st *mocks.StateMock, eth *mocks.Etherman, pc *mocks.ProfitabilityCheckerMock, prover *mocks.ProverMock, proofID *string) {
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	prover.On("ForkID").Return(uint64(0))
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{BatchNumber: 1}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(1), nil)
+	st.On("GetVirtualBatchToProve", mock.Anything, uint64(1), []uint64(nil), nil).Return(&state.Batch{BatchNumber: 2}, nil)
+	pc.On("IsProfitable", mock.Anything, big.NewInt(0)).Return(true, nil)
+	eth.On("GetAggregatorReward").Return(big.NewInt(0), nil)
+	st.On("AddGeneratedProof", mock.Anything, mock.Anything, nil).Return(nil)
+	st.On("GetBatchByNumber", mock.Anything, uint64(1), nil).Return(&state.Batch{BatchNumber: 1}, nil)
+	eth.On("GetPublicAddress").Return(common.Address{}, nil)
+	prover.On("BatchProof", mock.Anything).Return(proofID, nil)
+}
+
+func TestTransientDisconnectResume(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	prover := mocks.NewProverMock(t)
+	a := newDisconnectTestAggregator(st, eth, pc, time.Hour)
+
+	proofID := "proofID"
+	expectBatchProofRequest(st, eth, pc, prover, &proofID)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("", context.Canceled)
+
+	_, err := a.tryGenerateBatchProof(context.Background(), prover)
+	require.ErrorIs(t, err, context.Canceled)
+	// the proof is held, not deleted
+	st.AssertNotCalled(t, "DeleteGeneratedProofs", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// the prover reconnects and the proof is stored once generated
+	reconnected := mocks.NewProverMock(t)
+	reconnected.On("ID").Return("prover-1")
+	reconnected.On("Name").Return("addr")
+	reconnected.On("WaitRecursiveProof", mock.Anything, proofID).Return("proof", nil)
+	st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(func(proof *state.Proof) bool {
+		return proof.BatchNumber == 2 && proof.Proof == "proof" && !proof.Generating
+	}), nil).Return(nil)
+
+	a.resumeHeldWork(context.Background(), reconnected)
+	assert.Nil(t, a.held.take("prover-1"))
+}
+
+func TestTransientDisconnectHoldExpires(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	prover := mocks.NewProverMock(t)
+	a := newDisconnectTestAggregator(st, eth, pc, 10*time.Millisecond)
+
+	proofID := "proofID"
+	expectBatchProofRequest(st, eth, pc, prover, &proofID)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("", context.Canceled)
+	deleted := make(chan struct{})
+	st.On("DeleteGeneratedProofs", mock.Anything, uint64(2), uint64(2), nil).Return(nil).Run(func(args mock.Arguments) {
+		close(deleted)
+	})
+
+	_, err := a.tryGenerateBatchProof(context.Background(), prover)
+	require.ErrorIs(t, err, context.Canceled)
+
+	select {
+	case <-deleted:
+	case <-time.After(time.Second):
+		t.Fatal("held proof not released")
+	}
+}
+
+func TestPermanentDisconnectReleasesImmediately(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	pc := mocks.NewProfitabilityCheckerMock(t)
+	prover := mocks.NewProverMock(t)
+	a := newDisconnectTestAggregator(st, eth, pc, time.Hour)
+
+	proofID := "proofID"
+	expectBatchProofRequest(st, eth, pc, prover, &proofID)
+	prover.On("WaitRecursiveProof", mock.Anything, proofID).Return("", io.EOF)
+	st.On("DeleteGeneratedProofs", mock.Anything, uint64(2), uint64(2), nil).Return(nil).Once()
+
+	_, err := a.tryGenerateBatchProof(context.Background(), prover)
+	require.ErrorIs(t, err, io.EOF)
+	assert.Nil(t, a.held.take("prover-1"))
+}
@@ -0,0 +1,61 @@
+package aggregator
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// finalProofEstimator estimates the tx verifying a final proof.
+type finalProofEstimator interface {
+	EstimateVerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error)
+}
+
+// dryRun estimates the tx verifying the final proofs instead of sending
+// them, so the calldata and the gas can be checked without spending it.
+type dryRun struct {
+	estimator finalProofEstimator
+}
+
+func newDryRun(estimator finalProofEstimator) *dryRun {
+	return &dryRun{estimator: estimator}
+}
+
+// enabled returns whether the final proofs are estimated instead of sent,
+// it's safe to call it on a nil dry run.
+func (d *dryRun) enabled() bool {
+	return d != nil
+}
+
+// estimate logs the estimation of the tx verifying the final proof.
+func (d *dryRun) estimate(ctx context.Context, proof *state.Proof, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) {
+	tx, err := d.estimator.EstimateVerifyBatches(ctx, proof.BatchNumber-1, proof.BatchNumberFinal, inputs, gasPrice)
+	if err != nil {
+		log.Errorf("Dry run: failed to estimate the verification of batches [%d-%d], err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		return
+	}
+	log.Infof("Dry run: final proof for batches [%d-%d] not sent, calldata: %d bytes [%#x], gas: %d, gas price: %d, cost: %d",
+		proof.BatchNumber, proof.BatchNumberFinal, len(tx.Data()), tx.Data(), tx.Gas(), tx.GasPrice(), tx.Cost())
+}
+
+// dryRunFinalProof estimates the tx verifying the final proof instead of
+// sending it. The proof is unlocked, and verified again once the verify proof
+// interval elapses, so it's not estimated again on every iteration.
+func (a *Aggregator) dryRunFinalProof(ctx context.Context, proof *state.Proof, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) {
+	a.dryRun.estimate(ctx, proof, inputs, gasPrice)
+
+	// unlock the underlying proof (generating=false)
+	proof.Generating = false
+	err := a.State.UpdateGeneratedProof(ctx, proof, nil)
+	if err != nil {
+		log.Errorf("Rollback failed updating proof state (false) for proof ID [%v], err: %v", proof.ProofID, err)
+	}
+	a.finishFinalProof(proof, false)
+	// not persisted, no proof was verified
+	a.setVerifyProofTime(time.Now())
+}
@@ -0,0 +1,61 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	coretypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDryRunFinalProof(t *testing.T) {
+	testCases := []struct {
+		name        string
+		estimateErr error
+	}{
+		{name: "estimated"},
+		{name: "estimation failed", estimateErr: errors.New("execution reverted")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mocks.NewStateMock(t)
+			ethTxManager := mocks.NewEthTxManager(t)
+			a := Aggregator{
+				cfg:                     Config{VerifyProofInterval: types.NewDuration(time.Hour)},
+				State:                   st,
+				TimeSendFinalProofMutex: &sync.RWMutex{},
+				verifyingProof:          true,
+				dryRun:                  newDryRun(ethTxManager),
+			}
+			proofID := "proofID"
+			proof := &state.Proof{BatchNumber: 3, BatchNumberFinal: 5, ProofID: &proofID, Generating: true}
+			inputs := &ethmanTypes.FinalProofInputs{}
+
+			var tx *coretypes.Transaction
+			if tc.estimateErr == nil {
+				tx = coretypes.NewTransaction(1, common.Address{}, big.NewInt(0), 100, big.NewInt(2), []byte{0x01})
+			}
+			// the proof is estimated, never sent
+			ethTxManager.On("EstimateVerifyBatches", mock.Anything, uint64(2), uint64(5), inputs, (*big.Int)(nil)).Return(tx, tc.estimateErr).Once()
+			st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(func(p *state.Proof) bool {
+				return p.BatchNumber == 3 && p.BatchNumberFinal == 5 && !p.Generating
+			}), nil).Return(nil).Once()
+
+			a.dryRunFinalProof(context.Background(), proof, inputs, nil)
+
+			// the proof is verified again once the interval elapses
+			assert.False(t, a.verifyingProof)
+			assert.False(t, a.canVerifyProof())
+		})
+	}
+}
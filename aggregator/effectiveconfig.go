@@ -0,0 +1,77 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// redacted replaces the secrets in the effective config.
+const redacted = "redacted"
+
+// EffectiveConfig returns the config in use by the aggregator, with the
+// defaults applied on creation and the profitability parameters and batch
+// filter reloaded since then. The secrets are redacted, so it's safe to expose it.
+func (a *Aggregator) EffectiveConfig() Config {
+	cfg := a.cfg
+	cfg.ChannelOperationsOrder = append([]ChannelOperation{}, a.cfg.ChannelOperationsOrder...)
+	cfg.ForkIDIntervals = append([]ForkIDInterval{}, a.cfg.ForkIDIntervals...)
+	cfg.BatchFilter = a.batchFilter.config()
+
+	if pc, ok := a.ProfitabilityChecker.(*TxProfitabilityCheckerBase); ok {
+		interval, minReward := pc.Params()
+		cfg.IntervalAfterWhichBatchConsolidateAnyway = types.NewDuration(interval)
+		if minReward != nil {
+			cfg.TxProfitabilityMinReward = TokenAmountWithDecimals{new(big.Int).Set(minReward)}
+		}
+	}
+
+	return redactConfig(cfg)
+}
+
+// redactConfig returns the config with its secrets redacted.
+func redactConfig(cfg Config) Config {
+	cfg.Events.URL = redactURL(cfg.Events.URL)
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = redacted
+	}
+	return cfg
+}
+
+// redactURL redacts the credentials of the URL, either in its user info or
+// in its query parameters. A URL that can't be parsed is redacted entirely as
+// it could leak them.
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, redacted)
+		}
+		u.RawQuery = query.Encode()
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// handleEffectiveConfig serves the effective config as JSON.
+func (a *Aggregator) handleEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.EffectiveConfig()); err != nil {
+		log.Errorf("Failed to encode effective config, err: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package aggregator
+
+import "errors"
+
+// The classes of the errors of the proof lifecycle, so the callers can tell
+// them apart with errors.Is regardless of the error wrapped.
+var (
+	// ErrProverNotResponding is returned when the prover doesn't take a proof
+	// request or doesn't return the proof requested.
+	ErrProverNotResponding = errors.New("prover not responding")
+	// ErrProofNotEligible is returned when a proof can't be verified or
+	// built into a final proof for now. It's not a failure.
+	ErrProofNotEligible = errors.New("proof not eligible")
+	// ErrL1SubmissionFailed is returned when a final proof can't be verified
+	// on L1.
+	ErrL1SubmissionFailed = errors.New("L1 submission failed")
+	// ErrStateInconsistent is returned when the state doesn't provide
+	// consistent data to prove, aggregate or verify, like overlapping proofs
+	// or an input not matching the previous batch.
+	ErrStateInconsistent = errors.New("state inconsistent")
+)
+
+// lifecycleError is an error of the proof lifecycle belonging to one of the
+// classes above.
+type lifecycleError struct {
+	class error
+	err   error
+}
+
+// newLifecycleError returns the error as belonging to the class.
+func newLifecycleError(class error, err error) error {
+	return &lifecycleError{class: class, err: err}
+}
+
+func (e *lifecycleError) Error() string { return e.err.Error() }
+
+func (e *lifecycleError) Unwrap() error { return e.err }
+
+func (e *lifecycleError) Is(target error) bool { return target == e.class }
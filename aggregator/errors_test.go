@@ -0,0 +1,84 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/ethtxmanager"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycleErrorClasses(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		class    error
+		notClass []error
+	}{
+		{
+			name:     "overlapping proofs",
+			err:      fmt.Errorf("%w: 1-2 and 2-3", ErrOverlappingProofs),
+			class:    ErrStateInconsistent,
+			notClass: []error{ErrProofNotEligible, ErrProverNotResponding},
+		},
+		{
+			name:     "commitment mismatch",
+			err:      fmt.Errorf("Failed to compute the aggregated proof commitment, %w", ErrCommitmentMismatch),
+			class:    ErrStateInconsistent,
+			notClass: []error{ErrProofNotEligible},
+		},
+		{
+			name:     "incomplete sequences",
+			err:      fmt.Errorf("Failed to validate eligible final proof, %w", ErrIncompleteSequences),
+			class:    ErrProofNotEligible,
+			notClass: []error{ErrStateInconsistent},
+		},
+		{
+			name:     "deduplicated final proof",
+			err:      ErrFinalProofDeduplicated,
+			class:    ErrProofNotEligible,
+			notClass: []error{ErrL1SubmissionFailed},
+		},
+		{
+			name:     "proof wait",
+			err:      &waitProofError{err: &proofTimeoutError{err: context.DeadlineExceeded}},
+			class:    ErrProverNotResponding,
+			notClass: []error{ErrStateInconsistent},
+		},
+		{
+			name:     "L1 submission",
+			err:      newLifecycleError(ErrL1SubmissionFailed, ethtxmanager.ErrTxNotMined),
+			class:    ErrL1SubmissionFailed,
+			notClass: []error{ErrProverNotResponding},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.err, tc.class)
+			for _, class := range tc.notClass {
+				assert.NotErrorIs(t, tc.err, class)
+			}
+		})
+	}
+
+	// the wrapped errors are still matched
+	assert.ErrorIs(t, fmt.Errorf("%w: 1-2 and 2-3", ErrOverlappingProofs), ErrOverlappingProofs)
+	assert.ErrorIs(t, &waitProofError{err: &proofTimeoutError{err: context.DeadlineExceeded}}, ErrProofTimeout)
+	assert.ErrorIs(t, newLifecycleError(ErrL1SubmissionFailed, ethtxmanager.ErrTxNotMined), ethtxmanager.ErrTxNotMined)
+	assert.Equal(t, "proof commitment mismatch", ErrCommitmentMismatch.Error())
+}
+
+func TestRecordOutcomeProofNotEligible(t *testing.T) {
+	a := Aggregator{circuit: newFailureCircuit(FailureCircuitConfig{Enabled: true, Window: types.NewDuration(time.Minute), Threshold: 0.5, MinOperations: 10})}
+	ctx := context.Background()
+
+	a.recordOutcome(ctx, false, ErrFinalProofDeduplicated)
+	assert.Equal(t, 0, a.FailureCircuit().Operations)
+
+	a.recordOutcome(ctx, false, errors.New("failure"))
+	assert.Equal(t, 1, a.FailureCircuit().Operations)
+}
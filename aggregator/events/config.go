@@ -0,0 +1,24 @@
+package events
+
+// Config represents the configuration of the proof lifecycle events
+// publisher.
+type Config struct {
+	// Enabled enables publishing the proof lifecycle events
+	Enabled bool `mapstructure:"Enabled"`
+	// URL is the address of the NATS server, e.g. nats://127.0.0.1:4222,
+	// or tls://127.0.0.1:4222 to connect over TLS. The credentials are set
+	// in its user info, either user:password or a token
+	URL string `mapstructure:"URL"`
+	// TLSCAFile is the CA certificate file to verify the server certificate
+	// with instead of the system ones, enabling TLS
+	TLSCAFile string `mapstructure:"TLSCAFile"`
+	// TLSCertFile and TLSKeyFile are the client certificate and key files
+	// to authenticate to the server with, enabling TLS
+	TLSCertFile string `mapstructure:"TLSCertFile"`
+	TLSKeyFile  string `mapstructure:"TLSKeyFile"`
+	// Subject is the NATS subject the events are published to
+	Subject string `mapstructure:"Subject"`
+	// BufferSize is the number of events that can be queued waiting to be
+	// published before new events start being dropped
+	BufferSize int `mapstructure:"BufferSize"`
+}
@@ -0,0 +1,36 @@
+// Package events publishes the aggregator proof lifecycle events so they can
+// be consumed by downstream services without scraping logs or metrics.
+package events
+
+import (
+	"time"
+)
+
+// EventType is the type of a proof lifecycle event.
+type EventType string
+
+const (
+	// EventProofStarted is emitted when a prover starts generating a batch
+	// or aggregated proof
+	EventProofStarted EventType = "proof_started"
+	// EventProofGenerated is emitted when a batch proof has been generated
+	EventProofGenerated EventType = "proof_generated"
+	// EventAggregationDone is emitted when an aggregated proof has been
+	// generated
+	EventAggregationDone EventType = "aggregation_done"
+	// EventFinalProofSubmitted is emitted when a final proof has been sent
+	// to L1
+	EventFinalProofSubmitted EventType = "final_proof_submitted"
+	// EventVerified is emitted when the synchronizer has caught up with the
+	// batches verified by a final proof
+	EventVerified EventType = "verified"
+)
+
+// Event is a proof lifecycle event.
+type Event struct {
+	Type             EventType `json:"type"`
+	BatchNumber      uint64    `json:"batchNumber"`
+	BatchNumberFinal uint64    `json:"batchNumberFinal"`
+	ProverID         string    `json:"proverId,omitempty"`
+	Timestamp        time.Time `json:"timestamp"`
+}
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultBufferSize = 100
+	dialTimeout       = 5 * time.Second
+	reconnectDelay    = 5 * time.Second
+)
+
+// Publisher publishes messages to a subject, as the connection of the NATS
+// client does.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+	Close()
+}
+
+// NATSPublisher publishes events to a NATS server through the NATS client,
+// authenticating with the credentials of the URL and over TLS if the URL
+// scheme is tls or a TLS file is set. Publishing is best-effort: events are
+// queued and sent from a background goroutine, and they are dropped if the
+// queue is full or the server can't be reached. The client reconnects to the
+// server in the background.
+type NATSPublisher struct {
+	conn    Publisher
+	subject string
+	events  chan Event
+
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewNATSPublisher connects to the NATS server and starts the publishing
+// loop, which runs until the publisher is closed. The server not being
+// reachable yet is not an error, the connection is retried in the background.
+func NewNATSPublisher(cfg Config) (*NATSPublisher, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS URL %s, %w", cfg.URL, err)
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported events queue scheme %s", u.Scheme)
+	}
+	if cfg.Subject == "" {
+		return nil, fmt.Errorf("missing NATS subject")
+	}
+	tlsConfig, err := newTLSConfig(cfg, u)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []nats.Option{
+		nats.Name("zkevm-aggregator"),
+		nats.Timeout(dialTimeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(reconnectDelay),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Warnf("Disconnected from the NATS server, err: %v", err)
+			}
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			log.Warnf("NATS server error: %v", err)
+		}),
+	}
+	if tlsConfig != nil {
+		options = append(options, nats.Secure(tlsConfig))
+	}
+	conn, err := nats.Connect(cfg.URL, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the NATS server, %w", err)
+	}
+	return newNATSPublisher(conn, cfg.Subject, cfg.BufferSize), nil
+}
+
+// newNATSPublisher starts the publishing loop of the events to the subject
+// through the publisher, queueing up to bufferSize events.
+func newNATSPublisher(conn Publisher, subject string, bufferSize int) *NATSPublisher {
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+	p := &NATSPublisher{
+		conn:    conn,
+		subject: subject,
+		events:  make(chan Event, bufferSize),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// newTLSConfig returns the TLS config to connect to the server, nil if the
+// TLS is not enabled by the URL scheme nor by any TLS file.
+func newTLSConfig(cfg Config, u *url.URL) (*tls.Config, error) {
+	if u.Scheme != "tls" && cfg.TLSCAFile == "" && cfg.TLSCertFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName: u.Hostname(),
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.TLSCAFile != "" {
+		ca, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read NATS CA file %s, %w", cfg.TLSCAFile, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in NATS CA file %s", cfg.TLSCAFile)
+		}
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load NATS client certificate, %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Publish queues the event to be published, it never blocks.
+func (p *NATSPublisher) Publish(event Event) {
+	select {
+	case p.events <- event:
+	default:
+		log.Warnf("Events queue full, dropping event %s for batches [%d-%d]", event.Type, event.BatchNumber, event.BatchNumberFinal)
+	}
+}
+
+// Close stops the publishing loop and closes the connection, dropping the
+// events still queued.
+func (p *NATSPublisher) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.stopped
+	})
+}
+
+func (p *NATSPublisher) loop() {
+	defer close(p.stopped)
+	defer p.conn.Close()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case event := <-p.events:
+			if err := p.publish(event); err != nil {
+				log.Warnf("Failed to publish event %s for batches [%d-%d], err: %v", event.Type, event.BatchNumber, event.BatchNumberFinal, err)
+			}
+		}
+	}
+}
+
+func (p *NATSPublisher) publish(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.subject, payload)
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publisherStub records the published messages, failing the ones published
+// while failing is set and blocking while block is not closed.
+type publisherStub struct {
+	mu       sync.Mutex
+	messages [][]byte
+	subjects []string
+	failing  bool
+	failures int
+	block    chan struct{}
+	closed   bool
+}
+
+func (p *publisherStub) Publish(subject string, data []byte) error {
+	if p.block != nil {
+		<-p.block
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing {
+		p.failures++
+		return errors.New("connection closed")
+	}
+	p.subjects = append(p.subjects, subject)
+	p.messages = append(p.messages, data)
+	return nil
+}
+
+func (p *publisherStub) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+}
+
+func (p *publisherStub) published() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.messages)
+}
+
+func TestNATSPublisher(t *testing.T) {
+	conn := &publisherStub{}
+	p := newNATSPublisher(conn, "proofs", 0)
+
+	event := Event{Type: EventProofGenerated, BatchNumber: 1, BatchNumberFinal: 2, ProverID: "prover", Timestamp: time.Now().UTC()}
+	p.Publish(event)
+	require.Eventually(t, func() bool { return conn.published() == 1 }, time.Second, 10*time.Millisecond)
+	p.Close()
+
+	assert.True(t, conn.closed)
+	assert.Equal(t, []string{"proofs"}, conn.subjects)
+	var actual Event
+	require.NoError(t, json.Unmarshal(conn.messages[0], &actual))
+	assert.Equal(t, event.Type, actual.Type)
+	assert.Equal(t, event.BatchNumberFinal, actual.BatchNumberFinal)
+	assert.True(t, event.Timestamp.Equal(actual.Timestamp))
+}
+
+func TestNATSPublisherFailure(t *testing.T) {
+	conn := &publisherStub{failing: true}
+	p := newNATSPublisher(conn, "proofs", 0)
+	defer p.Close()
+
+	// the failed events are dropped and the next ones published
+	p.Publish(Event{Type: EventVerified, BatchNumber: 1, BatchNumberFinal: 1})
+	require.Eventually(t, func() bool {
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+		return conn.failures == 1
+	}, time.Second, 10*time.Millisecond)
+	conn.mu.Lock()
+	conn.failing = false
+	conn.mu.Unlock()
+	p.Publish(Event{Type: EventVerified, BatchNumber: 2, BatchNumberFinal: 2})
+	require.Eventually(t, func() bool { return conn.published() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestNATSPublisherQueueFull(t *testing.T) {
+	conn := &publisherStub{block: make(chan struct{})}
+	p := newNATSPublisher(conn, "proofs", 1)
+
+	// publishing never blocks, the events not fitting in the queue are
+	// dropped
+	published := make(chan struct{})
+	go func() {
+		for i := uint64(0); i < 10; i++ {
+			p.Publish(Event{Type: EventVerified, BatchNumber: i, BatchNumberFinal: i})
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked")
+	}
+
+	close(conn.block)
+	p.Close()
+	assert.LessOrEqual(t, conn.published(), 2)
+}
+
+func TestNATSPublisherServerUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	// the connection is retried in the background
+	p, err := NewNATSPublisher(Config{URL: "nats://user:pass@" + addr, Subject: "proofs"})
+	require.NoError(t, err)
+	p.Publish(Event{Type: EventVerified})
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher not closed")
+	}
+}
+
+func TestNATSPublisherInvalidURL(t *testing.T) {
+	_, err := NewNATSPublisher(Config{URL: "kafka://127.0.0.1:9092", Subject: "proofs"})
+	require.Error(t, err)
+
+	_, err = NewNATSPublisher(Config{URL: "nats://127.0.0.1:4222"})
+	require.Error(t, err)
+
+	_, err = NewNATSPublisher(Config{URL: "tls://127.0.0.1:4222", Subject: "proofs", TLSCAFile: "/nonexistent/ca.pem"})
+	require.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package aggregator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// ErrFinalProofDeduplicated is returned when the final proof is not built
+// because one for an overlapping range of batches is already being built or
+// has already been submitted. It's an ErrProofNotEligible.
+var ErrFinalProofDeduplicated = newLifecycleError(ErrProofNotEligible, errors.New("final proof already in flight or submitted"))
+
+// submittedFinalProofsKept is the number of ranges of batches of the last
+// submitted final proofs kept to deduplicate the builds.
+const submittedFinalProofsKept = 16
+
+// finalProofBuilds tracks the ranges of batches whose final proof is being
+// built or sent, and the ones of the last final proofs submitted, so the
+// final proof of the same batches is never built twice.
+type finalProofBuilds struct {
+	mu        sync.Mutex
+	inFlight  map[batchRange]struct{}
+	submitted []batchRange
+}
+
+func newFinalProofBuilds() *finalProofBuilds {
+	return &finalProofBuilds{
+		inFlight: make(map[batchRange]struct{}),
+	}
+}
+
+// start records the range as in flight, it returns false if an overlapping
+// range is already in flight or has been submitted. It's safe to call it on a
+// nil tracker.
+func (b *finalProofBuilds) start(r batchRange) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for inFlight := range b.inFlight {
+		if inFlight.overlaps(r.batchNumber, r.batchNumberFinal) {
+			return false
+		}
+	}
+	for _, submitted := range b.submitted {
+		if submitted.overlaps(r.batchNumber, r.batchNumberFinal) {
+			return false
+		}
+	}
+	b.inFlight[r] = struct{}{}
+	return true
+}
+
+// done removes the range from the ones in flight, recording it as submitted
+// if so.
+func (b *finalProofBuilds) done(r batchRange, submitted bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.inFlight, r)
+	if submitted {
+		b.submitted = append(b.submitted, r)
+		if len(b.submitted) > submittedFinalProofsKept {
+			b.submitted = b.submitted[len(b.submitted)-submittedFinalProofsKept:]
+		}
+	}
+}
+
+// forget removes the range from the submitted ones, so its final proof can
+// be built again.
+func (b *finalProofBuilds) forget(r batchRange) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, submitted := range b.submitted {
+		if submitted == r {
+			b.submitted = append(b.submitted[:i], b.submitted[i+1:]...)
+			return
+		}
+	}
+}
+
+// forgetAfter removes the submitted ranges ending after the batch number, so
+// their final proofs can be built again.
+func (b *finalProofBuilds) forgetAfter(batchNumber uint64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	submitted := b.submitted[:0]
+	for _, r := range b.submitted {
+		if r.batchNumberFinal <= batchNumber {
+			submitted = append(submitted, r)
+		}
+	}
+	b.submitted = submitted
+}
+
+// startFinalProof records the final proof of the batches of the proof as in
+// flight. It returns ErrFinalProofDeduplicated if an overlapping one is
+// already in flight or submitted.
+func (a *Aggregator) startFinalProof(proof *state.Proof) error {
+	if !a.finalProofBuilds.start(batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}) {
+		return fmt.Errorf("%w: batches [%d-%d]", ErrFinalProofDeduplicated, proof.BatchNumber, proof.BatchNumberFinal)
+	}
+	return nil
+}
+
+// finishFinalProof removes the final proof of the batches of the proof from
+// the ones in flight, once submitted or given up.
+func (a *Aggregator) finishFinalProof(proof *state.Proof, submitted bool) {
+	a.finalProofBuilds.done(batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}, submitted)
+}
+
+// forgetFinalProof forgets the final proof submitted for the batches of the
+// proof, once its verification is reorged out, so it's built and sent again.
+func (a *Aggregator) forgetFinalProof(proof *state.Proof) {
+	a.finalProofBuilds.forget(batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal})
+}
@@ -0,0 +1,97 @@
+package aggregator
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartFinalProofConcurrent(t *testing.T) {
+	a := Aggregator{finalProofBuilds: newFinalProofBuilds()}
+
+	proofs := []*state.Proof{
+		{BatchNumber: 1, BatchNumberFinal: 8},
+		{BatchNumber: 1, BatchNumberFinal: 8},
+		{BatchNumber: 1, BatchNumberFinal: 4},
+		{BatchNumber: 4, BatchNumberFinal: 10},
+		{BatchNumber: 3, BatchNumberFinal: 5},
+	}
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errs  = make([]error, len(proofs))
+	)
+	for i := range proofs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = a.startFinalProof(proofs[i])
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	started := -1
+	for i, err := range errs {
+		if err == nil {
+			require.Equal(t, -1, started, "overlapping final proofs started concurrently")
+			started = i
+			continue
+		}
+		assert.ErrorIs(t, err, ErrFinalProofDeduplicated)
+	}
+	require.NotEqual(t, -1, started, "no final proof started")
+
+	// a range given up can be started again
+	a.finishFinalProof(proofs[started], false)
+	require.NoError(t, a.startFinalProof(proofs[0]))
+
+	// a range submitted is never started again
+	a.finishFinalProof(proofs[0], true)
+	assert.ErrorIs(t, a.startFinalProof(proofs[0]), ErrFinalProofDeduplicated)
+	assert.ErrorIs(t, a.startFinalProof(&state.Proof{BatchNumber: 8, BatchNumberFinal: 9}), ErrFinalProofDeduplicated)
+
+	// the following range is not affected
+	assert.NoError(t, a.startFinalProof(&state.Proof{BatchNumber: 9, BatchNumberFinal: 12}))
+}
+
+func TestFinalProofBuildsSubmittedKept(t *testing.T) {
+	b := newFinalProofBuilds()
+
+	for i := uint64(0); i < submittedFinalProofsKept+1; i++ {
+		r := batchRange{batchNumber: i, batchNumberFinal: i}
+		require.True(t, b.start(r))
+		b.done(r, true)
+	}
+
+	// the oldest submitted range is forgotten
+	assert.True(t, b.start(batchRange{batchNumber: 0, batchNumberFinal: 0}))
+	assert.False(t, b.start(batchRange{batchNumber: 1, batchNumberFinal: 1}))
+}
+
+func TestForgetFinalProof(t *testing.T) {
+	a := Aggregator{finalProofBuilds: newFinalProofBuilds()}
+	proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 8}
+
+	require.NoError(t, a.startFinalProof(proof))
+	a.finishFinalProof(proof, true)
+	assert.ErrorIs(t, a.startFinalProof(proof), ErrFinalProofDeduplicated)
+
+	// the verification is reorged out
+	a.forgetFinalProof(proof)
+	assert.NoError(t, a.startFinalProof(proof))
+}
+
+func TestFinalProofBuildsNil(t *testing.T) {
+	a := Aggregator{}
+	proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 8}
+
+	assert.NoError(t, a.startFinalProof(proof))
+	assert.NoError(t, a.startFinalProof(proof))
+	a.finishFinalProof(proof, true)
+	assert.NoError(t, a.startFinalProof(proof))
+}
@@ -0,0 +1,46 @@
+package aggregator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// ErrFinalProofInputsMismatch is returned when the public inputs the final
+// proof commits to don't match the batches it's sent for, so its
+// verification would revert on L1. It's an ErrStateInconsistent.
+var ErrFinalProofInputsMismatch = newLifecycleError(ErrStateInconsistent, errors.New("final proof public inputs mismatch"))
+
+// checkFinalProofInputs compares the public inputs of the final proof against
+// the range of batches of the proof and the roots of its last batch, the ones
+// sent along with the proof to L1. The batch numbers are not checked if
+// UseMockProverValues is enabled, as a mock prover doesn't compute them.
+func (a *Aggregator) checkFinalProofInputs(proof *state.Proof, finalProof *pb.FinalProof, finalBatch *state.Batch) error {
+	public := finalProof.GetPublic()
+	if public == nil {
+		return fmt.Errorf("%w: no public inputs", ErrFinalProofInputsMismatch)
+	}
+	if !bytes.Equal(public.NewStateRoot, finalBatch.StateRoot.Bytes()) {
+		return fmt.Errorf("%w: new state root %#x, batch %d state root %#x", ErrFinalProofInputsMismatch,
+			public.NewStateRoot, finalBatch.BatchNumber, finalBatch.StateRoot.Bytes())
+	}
+	if !bytes.Equal(public.NewLocalExitRoot, finalBatch.LocalExitRoot.Bytes()) {
+		return fmt.Errorf("%w: new local exit root %#x, batch %d local exit root %#x", ErrFinalProofInputsMismatch,
+			public.NewLocalExitRoot, finalBatch.BatchNumber, finalBatch.LocalExitRoot.Bytes())
+	}
+	if a.cfg.UseMockProverValues {
+		return nil
+	}
+	if public.NewBatchNum != proof.BatchNumberFinal {
+		return fmt.Errorf("%w: new batch number %d, proof final batch %d", ErrFinalProofInputsMismatch,
+			public.NewBatchNum, proof.BatchNumberFinal)
+	}
+	if oldBatchNum := public.GetPublicInputs().GetOldBatchNum(); oldBatchNum != proof.BatchNumber-1 {
+		return fmt.Errorf("%w: old batch number %d, proof first batch %d", ErrFinalProofInputsMismatch,
+			oldBatchNum, proof.BatchNumber)
+	}
+	return nil
+}
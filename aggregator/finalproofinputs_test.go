@@ -0,0 +1,135 @@
+package aggregator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckFinalProofInputs(t *testing.T) {
+	proof := &state.Proof{BatchNumber: 11, BatchNumberFinal: 12}
+	finalBatch := &state.Batch{BatchNumber: 12, StateRoot: common.HexToHash("0x1"), LocalExitRoot: common.HexToHash("0x2")}
+	public := func() *pb.PublicInputsExtended {
+		return &pb.PublicInputsExtended{
+			PublicInputs:     &pb.PublicInputs{OldBatchNum: 10},
+			NewStateRoot:     finalBatch.StateRoot.Bytes(),
+			NewLocalExitRoot: finalBatch.LocalExitRoot.Bytes(),
+			NewBatchNum:      12,
+		}
+	}
+
+	testCases := []struct {
+		name                string
+		public              func() *pb.PublicInputsExtended
+		useMockProverValues bool
+		expectedErr         bool
+	}{
+		{name: "matching", public: public},
+		{name: "no public inputs", public: func() *pb.PublicInputsExtended { return nil }, expectedErr: true},
+		{
+			name: "state root mismatch",
+			public: func() *pb.PublicInputsExtended {
+				p := public()
+				p.NewStateRoot = common.HexToHash("0x3").Bytes()
+				return p
+			},
+			expectedErr: true,
+		},
+		{
+			name: "local exit root mismatch",
+			public: func() *pb.PublicInputsExtended {
+				p := public()
+				p.NewLocalExitRoot = nil
+				return p
+			},
+			expectedErr: true,
+		},
+		{
+			name: "new batch number mismatch",
+			public: func() *pb.PublicInputsExtended {
+				p := public()
+				p.NewBatchNum = 13
+				return p
+			},
+			expectedErr: true,
+		},
+		{
+			name: "old batch number mismatch",
+			public: func() *pb.PublicInputsExtended {
+				p := public()
+				p.PublicInputs.OldBatchNum = 9
+				return p
+			},
+			expectedErr: true,
+		},
+		{
+			name: "batch numbers of a mock prover",
+			public: func() *pb.PublicInputsExtended {
+				p := public()
+				p.PublicInputs = nil
+				p.NewBatchNum = 0
+				return p
+			},
+			useMockProverValues: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := Aggregator{cfg: Config{UseMockProverValues: tc.useMockProverValues}}
+			err := a.checkFinalProofInputs(proof, &pb.FinalProof{Public: tc.public()}, finalBatch)
+			if tc.expectedErr {
+				assert.ErrorIs(t, err, ErrFinalProofInputsMismatch)
+				assert.ErrorIs(t, err, ErrStateInconsistent)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSendFinalProofInputsMismatch(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	ethTxMan := mocks.NewEthTxManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	a := Aggregator{
+		cfg: Config{
+			RetryTime:             types.NewDuration(10 * time.Millisecond),
+			CheckFinalProofInputs: true,
+		},
+		State:                   st,
+		EthTxManager:            ethTxMan,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		finalProof:              make(chan finalProofMsg),
+		ctx:                     ctx,
+	}
+	st.On("GetBatchByNumber", ctx, uint64(12), nil).Return(&state.Batch{BatchNumber: 12, StateRoot: common.HexToHash("0x1")}, nil).Once()
+	unlocked := make(chan struct{})
+	st.On("UpdateGeneratedProof", ctx, mock.MatchedBy(func(proof *state.Proof) bool {
+		return proof.BatchNumber == 11 && proof.BatchNumberFinal == 12 && !proof.Generating
+	}), nil).Return(nil).Once().Run(func(mock.Arguments) { close(unlocked) })
+
+	done := make(chan struct{})
+	go func() {
+		a.sendFinalProof()
+		close(done)
+	}()
+	msg := newFinalProofMsg()
+	msg.finalProof.Public = &pb.PublicInputsExtended{NewStateRoot: common.HexToHash("0x2").Bytes(), NewBatchNum: 12}
+	a.finalProof <- msg
+	<-unlocked
+	cancel()
+	<-done
+
+	// the final proof is not sent and the verification is released
+	ethTxMan.AssertNotCalled(t, "VerifyBatches", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	assert.True(t, a.canVerifyProof())
+}
@@ -0,0 +1,106 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// gasPriceOverrides are the gas prices set to seed the verification txs of
+// specific batches.
+type gasPriceOverrides struct {
+	mu        sync.Mutex
+	gasPrices map[uint64]*big.Int
+}
+
+func newGasPriceOverrides() *gasPriceOverrides {
+	return &gasPriceOverrides{
+		gasPrices: make(map[uint64]*big.Int),
+	}
+}
+
+// set stores the gas price for the verification covering the batch.
+func (o *gasPriceOverrides) set(batchNumber uint64, gasPrice *big.Int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.gasPrices[batchNumber] = new(big.Int).Set(gasPrice)
+}
+
+// get returns the highest gas price set for the batches in the range, if any.
+func (o *gasPriceOverrides) get(batchNumber, batchNumberFinal uint64) *big.Int {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var gasPrice *big.Int
+	for n, p := range o.gasPrices {
+		if n >= batchNumber && n <= batchNumberFinal && (gasPrice == nil || p.Cmp(gasPrice) > 0) {
+			gasPrice = p
+		}
+	}
+	return gasPrice
+}
+
+// remove deletes the gas prices set for the batches in the range, once
+// verified.
+func (o *gasPriceOverrides) remove(batchNumber, batchNumberFinal uint64) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for n := range o.gasPrices {
+		if n >= batchNumber && n <= batchNumberFinal {
+			delete(o.gasPrices, n)
+		}
+	}
+}
+
+// SetVerificationGasPrice sets the gas price, in wei, used to send the
+// verification tx of the range of batches containing the given one, instead
+// of the suggested gas price. It's still limited by the max gas price of the
+// eth tx manager.
+func (a *Aggregator) SetVerificationGasPrice(batchNumber uint64, gasPrice *big.Int) {
+	a.gasPrices.set(batchNumber, gasPrice)
+}
+
+// verificationGasPriceRequest is the gas price override posted to the admin
+// endpoint.
+type verificationGasPriceRequest struct {
+	BatchNumber uint64   `json:"batchNumber"`
+	GasPriceWei *big.Int `json:"gasPriceWei"`
+}
+
+// handleVerificationGasPrice sets the posted gas price override and serves it
+// back as JSON.
+func (a *Aggregator) handleVerificationGasPrice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req verificationGasPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request, %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.BatchNumber == 0 || req.GasPriceWei == nil || req.GasPriceWei.Sign() <= 0 {
+		http.Error(w, "invalid request, a batch number and a positive gas price are required", http.StatusBadRequest)
+		return
+	}
+	a.SetVerificationGasPrice(req.BatchNumber, req.GasPriceWei)
+	log.Infof("Gas price %d set for the verification of batch %d", req.GasPriceWei, req.BatchNumber)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(req); err != nil {
+		log.Errorf("Failed to encode verification gas price, err: %v", err)
+	}
+}
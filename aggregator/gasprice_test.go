@@ -0,0 +1,56 @@
+package aggregator
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGasPriceOverrides(t *testing.T) {
+	a := Aggregator{gasPrices: newGasPriceOverrides()}
+	a.SetVerificationGasPrice(3, big.NewInt(100))
+	a.SetVerificationGasPrice(5, big.NewInt(200))
+	a.SetVerificationGasPrice(9, big.NewInt(300))
+
+	assert.Nil(t, a.gasPrices.get(1, 2))
+	assert.Equal(t, big.NewInt(100), a.gasPrices.get(1, 4))
+	// the highest one set for the range is used
+	assert.Equal(t, big.NewInt(200), a.gasPrices.get(1, 8))
+
+	a.gasPrices.remove(1, 8)
+	assert.Nil(t, a.gasPrices.get(1, 8))
+	assert.Equal(t, big.NewInt(300), a.gasPrices.get(9, 9))
+}
+
+func TestHandleVerificationGasPrice(t *testing.T) {
+	a := Aggregator{gasPrices: newGasPriceOverrides()}
+
+	testCases := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{name: "set", method: http.MethodPost, body: `{"batchNumber":3,"gasPriceWei":100000000000}`, expectedStatus: http.StatusOK},
+		{name: "not posted", method: http.MethodGet, expectedStatus: http.StatusMethodNotAllowed},
+		{name: "invalid body", method: http.MethodPost, body: `{`, expectedStatus: http.StatusBadRequest},
+		{name: "no batch", method: http.MethodPost, body: `{"gasPriceWei":1}`, expectedStatus: http.StatusBadRequest},
+		{name: "no gas price", method: http.MethodPost, body: `{"batchNumber":3}`, expectedStatus: http.StatusBadRequest},
+		{name: "negative gas price", method: http.MethodPost, body: `{"batchNumber":3,"gasPriceWei":-1}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/admin/verification/gasprice", strings.NewReader(tc.body))
+			a.adminHandler().ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+
+	// only the valid override is set
+	assert.Equal(t, big.NewInt(100000000000), a.gasPrices.get(1, 5))
+}
@@ -0,0 +1,50 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// UnknownGlobalExitRootError is returned when the global exit root stored for
+// a batch was never synced from L1.
+type UnknownGlobalExitRootError struct {
+	BatchNumber    uint64
+	GlobalExitRoot common.Hash
+}
+
+func (e *UnknownGlobalExitRootError) Error() string {
+	return fmt.Sprintf("unknown global exit root %s for batch [%d]", e.GlobalExitRoot, e.BatchNumber)
+}
+
+// checkGlobalExitRoot checks that the global exit root stored for the batch
+// was synced from L1. A batch may use an older global exit root than the
+// latest one, so only its existence is checked. Batches not updating the
+// global exit root, with the zero hash, are not checked.
+func (a *Aggregator) checkGlobalExitRoot(ctx context.Context, batch *state.Batch) error {
+	if !a.cfg.CheckGlobalExitRoot || batch.GlobalExitRoot == (common.Hash{}) {
+		return nil
+	}
+
+	_, err := a.State.GetExitRootByGlobalExitRoot(ctx, batch.GlobalExitRoot, nil)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, state.ErrNotFound) {
+		return fmt.Errorf("Failed to get global exit root of batch [%d], %w", batch.BatchNumber, err)
+	}
+
+	unknownErr := &UnknownGlobalExitRootError{
+		BatchNumber:    batch.BatchNumber,
+		GlobalExitRoot: batch.GlobalExitRoot,
+	}
+	if a.cfg.FailOnStaleGlobalExitRoot {
+		return unknownErr
+	}
+	log.Warn(unknownErr.Error())
+	return nil
+}
@@ -0,0 +1,91 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckGlobalExitRoot(t *testing.T) {
+	stored := common.HexToHash("0x01")
+	batch := &state.Batch{BatchNumber: 10, GlobalExitRoot: stored, Timestamp: time.Now()}
+
+	testCases := []struct {
+		name      string
+		cfg       Config
+		batch     *state.Batch
+		synced    *state.GlobalExitRoot
+		getErr    error
+		expectErr bool
+		unknown   bool
+	}{
+		{
+			name:  "disabled",
+			cfg:   Config{},
+			batch: batch,
+		},
+		{
+			name:  "zero global exit root",
+			cfg:   Config{CheckGlobalExitRoot: true, FailOnStaleGlobalExitRoot: true},
+			batch: &state.Batch{BatchNumber: 10},
+		},
+		{
+			// an older global exit root than the latest one is valid
+			name:   "synced",
+			cfg:    Config{CheckGlobalExitRoot: true, FailOnStaleGlobalExitRoot: true},
+			batch:  batch,
+			synced: &state.GlobalExitRoot{GlobalExitRoot: stored},
+		},
+		{
+			name:      "state error",
+			cfg:       Config{CheckGlobalExitRoot: true},
+			batch:     batch,
+			getErr:    errors.New("db error"),
+			expectErr: true,
+		},
+		{
+			name:   "unknown warning",
+			cfg:    Config{CheckGlobalExitRoot: true},
+			batch:  batch,
+			getErr: state.ErrNotFound,
+		},
+		{
+			name:      "unknown failing",
+			cfg:       Config{CheckGlobalExitRoot: true, FailOnStaleGlobalExitRoot: true},
+			batch:     batch,
+			getErr:    state.ErrNotFound,
+			expectErr: true,
+			unknown:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mocks.NewStateMock(t)
+			a := Aggregator{cfg: tc.cfg, State: st}
+			if tc.synced != nil || tc.getErr != nil {
+				st.On("GetExitRootByGlobalExitRoot", mock.Anything, stored, nil).Return(tc.synced, tc.getErr).Once()
+			}
+
+			err := a.checkGlobalExitRoot(context.Background(), tc.batch)
+			if !tc.expectErr {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			var unknownErr *UnknownGlobalExitRootError
+			if assert.Equal(t, tc.unknown, errors.As(err, &unknownErr)) && tc.unknown {
+				assert.Equal(t, uint64(10), unknownErr.BatchNumber)
+				assert.Equal(t, stored, unknownErr.GlobalExitRoot)
+			}
+		})
+	}
+}
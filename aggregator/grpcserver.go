@@ -0,0 +1,89 @@
+package aggregator
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// The grpc defaults applied to the settings left to 0.
+const (
+	defaultGRPCMaxRecvMsgSize   = 4 * 1024 * 1024
+	defaultGRPCMaxSendMsgSize   = math.MaxInt32
+	defaultGRPCKeepaliveTime    = 2 * time.Hour
+	defaultGRPCKeepaliveTimeout = 20 * time.Second
+	defaultGRPCKeepaliveMinTime = 5 * time.Minute
+	minGRPCKeepaliveTime        = time.Second
+)
+
+// validateGRPCServerConfig checks the settings of the grpc server.
+func validateGRPCServerConfig(cfg Config) error {
+	if cfg.MaxRecvMsgSize < 0 || cfg.MaxSendMsgSize < 0 {
+		return fmt.Errorf("negative max message sizes, recv %d, send %d", cfg.MaxRecvMsgSize, cfg.MaxSendMsgSize)
+	}
+	ka := cfg.GRPCKeepalive
+	if ka.Time.Duration < 0 || ka.Timeout.Duration < 0 || ka.MinTime.Duration < 0 {
+		return fmt.Errorf("negative keepalive durations, time %v, timeout %v, min time %v", ka.Time, ka.Timeout, ka.MinTime)
+	}
+	if ka.Time.Duration > 0 && ka.Time.Duration < minGRPCKeepaliveTime {
+		return fmt.Errorf("keepalive time %v below the min of %v", ka.Time, minGRPCKeepaliveTime)
+	}
+	return nil
+}
+
+// serverOptions returns the options of the grpc server from the config.
+func (a *Aggregator) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if a.cfg.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(a.cfg.MaxRecvMsgSize))
+	}
+	if a.cfg.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(a.cfg.MaxSendMsgSize))
+	}
+	ka := a.cfg.GRPCKeepalive
+	if ka.Time.Duration > 0 || ka.Timeout.Duration > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    ka.Time.Duration,
+			Timeout: ka.Timeout.Duration,
+		}))
+	}
+	if ka.MinTime.Duration > 0 || ka.PermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             valueOrDefault(ka.MinTime.Duration, defaultGRPCKeepaliveMinTime),
+			PermitWithoutStream: ka.PermitWithoutStream,
+		}))
+	}
+	return opts
+}
+
+// logGRPCServerSettings logs the effective settings of the grpc server, with
+// the grpc defaults applied.
+func (a *Aggregator) logGRPCServerSettings() {
+	ka := a.cfg.GRPCKeepalive
+	maxRecvMsgSize := a.cfg.MaxRecvMsgSize
+	if maxRecvMsgSize == 0 {
+		maxRecvMsgSize = defaultGRPCMaxRecvMsgSize
+	}
+	maxSendMsgSize := a.cfg.MaxSendMsgSize
+	if maxSendMsgSize == 0 {
+		maxSendMsgSize = defaultGRPCMaxSendMsgSize
+	}
+	log.Infof("gRPC server settings: maxRecvMsgSize %d, maxSendMsgSize %d, keepalive time %v, timeout %v, min time %v, permit without stream %t",
+		maxRecvMsgSize, maxSendMsgSize,
+		valueOrDefault(ka.Time.Duration, defaultGRPCKeepaliveTime),
+		valueOrDefault(ka.Timeout.Duration, defaultGRPCKeepaliveTimeout),
+		valueOrDefault(ka.MinTime.Duration, defaultGRPCKeepaliveMinTime),
+		ka.PermitWithoutStream)
+}
+
+// valueOrDefault returns the duration, or the default if it's 0.
+func valueOrDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,60 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateGRPCServerConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         Config
+		expectedErr bool
+	}{
+		{name: "defaults"},
+		{
+			name: "valid",
+			cfg: Config{
+				MaxRecvMsgSize: 64 * 1024 * 1024,
+				MaxSendMsgSize: 64 * 1024 * 1024,
+				GRPCKeepalive: GRPCKeepaliveConfig{
+					Time:    types.NewDuration(time.Minute),
+					Timeout: types.NewDuration(10 * time.Second),
+					MinTime: types.NewDuration(30 * time.Second),
+				},
+			},
+		},
+		{name: "negative max message size", cfg: Config{MaxRecvMsgSize: -1}, expectedErr: true},
+		{name: "negative keepalive timeout", cfg: Config{GRPCKeepalive: GRPCKeepaliveConfig{Timeout: types.NewDuration(-time.Second)}}, expectedErr: true},
+		{name: "keepalive time too short", cfg: Config{GRPCKeepalive: GRPCKeepaliveConfig{Time: types.NewDuration(time.Millisecond)}}, expectedErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateGRPCServerConfig(tc.cfg)
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	a := Aggregator{}
+	assert.Empty(t, a.serverOptions())
+
+	a.cfg = Config{
+		MaxRecvMsgSize: 64 * 1024 * 1024,
+		MaxSendMsgSize: 64 * 1024 * 1024,
+		GRPCKeepalive: GRPCKeepaliveConfig{
+			Time:                types.NewDuration(time.Minute),
+			MinTime:             types.NewDuration(30 * time.Second),
+			PermitWithoutStream: true,
+		},
+	}
+	assert.Len(t, a.serverOptions(), 4)
+}
@@ -0,0 +1,189 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// proverConnectivity tracks the number of provers connected and since when
+// there is none.
+type proverConnectivity struct {
+	mu        sync.Mutex
+	connected int
+	noneSince time.Time
+}
+
+// newProverConnectivity returns a tracker with no prover connected since now.
+func newProverConnectivity(now time.Time) *proverConnectivity {
+	return &proverConnectivity{noneSince: now}
+}
+
+// connect records a prover connected.
+func (c *proverConnectivity) connect() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected++
+}
+
+// disconnect records a prover disconnected at now.
+func (c *proverConnectivity) disconnect(now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.connected == 0 {
+		return
+	}
+	c.connected--
+	if c.connected == 0 {
+		c.noneSince = now
+	}
+}
+
+// healthy returns whether a prover is connected, or there has been none for
+// less than the grace period.
+func (c *proverConnectivity) healthy(now time.Time, gracePeriod time.Duration) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected > 0 || now.Sub(c.noneSince) < gracePeriod
+}
+
+// hasConnectedProvers returns whether a prover is connected or the grace
+// period without provers has not elapsed yet, it's always true if
+// HealthCheck.NoProversGracePeriod is 0.
+func (a *Aggregator) hasConnectedProvers() bool {
+	gracePeriod := a.cfg.HealthCheck.NoProversGracePeriod.Duration
+	if gracePeriod <= 0 {
+		return true
+	}
+	return a.provers.healthy(time.Now(), gracePeriod)
+}
+
+// l1Health records whether the last call to L1 failed.
+type l1Health struct {
+	failed int32
+}
+
+// record records the result of a call to L1, logging the changes.
+func (h *l1Health) record(err error) {
+	if h == nil {
+		return
+	}
+	var failed int32
+	if err != nil {
+		failed = 1
+	}
+	if atomic.SwapInt32(&h.failed, failed) != failed {
+		if err != nil {
+			log.Warnf("L1 unreachable, err: %v", err)
+		} else {
+			log.Info("L1 reachable again")
+		}
+	}
+}
+
+// healthy returns whether the last call to L1 succeeded.
+func (h *l1Health) healthy() bool {
+	return h == nil || atomic.LoadInt32(&h.failed) == 0
+}
+
+// isL1Healthy returns whether the last call to L1 succeeded, it's always true
+// if HealthCheck.L1 is disabled.
+func (a *Aggregator) isL1Healthy() bool {
+	if !a.cfg.HealthCheck.L1 {
+		return true
+	}
+	return a.l1Health.healthy()
+}
+
+// l1HealthEtherman is an etherman wrapper recording the result of the calls
+// to L1.
+type l1HealthEtherman struct {
+	etherman
+	health *l1Health
+}
+
+// CheckReorg implements etherman.
+func (e *l1HealthEtherman) CheckReorg(batchNumber uint64, stateRoot common.Hash) (bool, error) {
+	reorged, err := e.etherman.CheckReorg(batchNumber, stateRoot)
+	e.health.record(err)
+	return reorged, err
+}
+
+// GetAggregatorReward implements etherman.
+func (e *l1HealthEtherman) GetAggregatorReward() (*big.Int, error) {
+	reward, err := e.etherman.GetAggregatorReward()
+	e.health.record(err)
+	return reward, err
+}
+
+// GetL1ChainID implements etherman.
+func (e *l1HealthEtherman) GetL1ChainID(ctx context.Context) (uint64, error) {
+	chainID, err := e.etherman.GetL1ChainID(ctx)
+	if ctx.Err() == nil {
+		e.health.record(err)
+	}
+	return chainID, err
+}
+
+// GetL2ChainID implements etherman.
+func (e *l1HealthEtherman) GetL2ChainID() (uint64, error) {
+	chainID, err := e.etherman.GetL2ChainID()
+	e.health.record(err)
+	return chainID, err
+}
+
+// GetLatestBlockNumber implements etherman.
+func (e *l1HealthEtherman) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	blockNumber, err := e.etherman.GetLatestBlockNumber(ctx)
+	if ctx.Err() == nil {
+		e.health.record(err)
+	}
+	return blockNumber, err
+}
+
+// GetLatestVerifiedBatchNum implements etherman.
+func (e *l1HealthEtherman) GetLatestVerifiedBatchNum() (uint64, error) {
+	batchNumber, err := e.etherman.GetLatestVerifiedBatchNum()
+	e.health.record(err)
+	return batchNumber, err
+}
+
+// GetTxReceipt implements etherman. A receipt not found is a reply from L1,
+// not a failure.
+func (e *l1HealthEtherman) GetTxReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	receipt, err := e.etherman.GetTxReceipt(ctx, txHash)
+	if ctx.Err() == nil {
+		if errors.Is(err, ethereum.NotFound) {
+			e.health.record(nil)
+		} else {
+			e.health.record(err)
+		}
+	}
+	return receipt, err
+}
+
+// GetVerifiedBatchStateRoot implements etherman.
+func (e *l1HealthEtherman) GetVerifiedBatchStateRoot(batchNumber uint64) (common.Hash, error) {
+	stateRoot, err := e.etherman.GetVerifiedBatchStateRoot(batchNumber)
+	e.health.record(err)
+	return stateRoot, err
+}
+
+// GetPublicAddress is not recorded as the address is known locally.
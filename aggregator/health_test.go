@@ -0,0 +1,166 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestProverConnectivity(t *testing.T) {
+	start := time.Now()
+	c := newProverConnectivity(start)
+	gracePeriod := time.Minute
+
+	assert.True(t, c.healthy(start.Add(time.Second), gracePeriod))
+	assert.False(t, c.healthy(start.Add(gracePeriod), gracePeriod))
+
+	c.connect()
+	c.connect()
+	assert.True(t, c.healthy(start.Add(time.Hour), gracePeriod))
+
+	c.disconnect(start.Add(time.Hour))
+	assert.True(t, c.healthy(start.Add(2*time.Hour), gracePeriod))
+
+	// the grace period starts again when the last prover disconnects
+	c.disconnect(start.Add(2 * time.Hour))
+	assert.True(t, c.healthy(start.Add(2*time.Hour+time.Second), gracePeriod))
+	assert.False(t, c.healthy(start.Add(2*time.Hour+gracePeriod), gracePeriod))
+
+	var nilConnectivity *proverConnectivity
+	nilConnectivity.connect()
+	nilConnectivity.disconnect(start)
+	assert.True(t, nilConnectivity.healthy(start.Add(time.Hour), gracePeriod))
+}
+
+func TestHealthCheckProvers(t *testing.T) {
+	a := Aggregator{
+		cfg:     Config{HealthCheck: HealthCheckConfig{NoProversGracePeriod: types.NewDuration(time.Minute)}},
+		provers: newProverConnectivity(time.Now().Add(-time.Hour)),
+	}
+	hc := newHealthChecker(a.isDBHealthy, a.hasConnectedProvers, a.isL1Healthy)
+	ctx := context.Background()
+
+	resp, err := hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	a.provers.connect()
+	resp, err = hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, resp.Status)
+
+	// disabled
+	a.provers.disconnect(time.Now().Add(-time.Hour))
+	a.cfg.HealthCheck.NoProversGracePeriod = types.NewDuration(0)
+	resp, err = hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestHealthCheckL1(t *testing.T) {
+	eth := mocks.NewEtherman(t)
+	health := &l1Health{}
+	a := Aggregator{
+		cfg:      Config{HealthCheck: HealthCheckConfig{L1: true}},
+		Ethman:   &l1HealthEtherman{etherman: eth, health: health},
+		l1Health: health,
+	}
+	hc := newHealthChecker(a.isDBHealthy, a.hasConnectedProvers, a.isL1Healthy)
+	ctx := context.Background()
+
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(0), errors.New("connection refused")).Once()
+	_, err := a.Ethman.GetLatestVerifiedBatchNum()
+	require.Error(t, err)
+	assert.False(t, a.isL1Healthy())
+	resp, err := hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	// disabled
+	a.cfg.HealthCheck.L1 = false
+	assert.True(t, a.isL1Healthy())
+	a.cfg.HealthCheck.L1 = true
+
+	// L1 recovers
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(10), nil).Once()
+	_, err = a.Ethman.GetLatestVerifiedBatchNum()
+	require.NoError(t, err)
+	assert.True(t, a.isL1Healthy())
+	resp, err = hc.Check(ctx, &grpchealth.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, resp.Status)
+}
+
+// healthWatchStream is a health Watch stream forwarding the statuses sent.
+type healthWatchStream struct {
+	grpc.ServerStream
+
+	ctx      context.Context
+	statuses chan grpchealth.HealthCheckResponse_ServingStatus
+}
+
+func (s *healthWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *healthWatchStream) Send(resp *grpchealth.HealthCheckResponse) error {
+	s.statuses <- resp.Status
+	return nil
+}
+
+func TestHealthCheckWatch(t *testing.T) {
+	a := Aggregator{
+		cfg:     Config{HealthCheck: HealthCheckConfig{NoProversGracePeriod: types.NewDuration(time.Minute)}},
+		provers: newProverConnectivity(time.Now()),
+	}
+	hc := newHealthChecker(a.isDBHealthy, a.hasConnectedProvers, a.isL1Healthy)
+	hc.watchInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &healthWatchStream{ctx: ctx, statuses: make(chan grpchealth.HealthCheckResponse_ServingStatus, 10)}
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- hc.Watch(&grpchealth.HealthCheckRequest{}, stream) }()
+
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, <-stream.statuses)
+
+	// the grace period without provers elapses
+	a.provers.connect()
+	a.provers.disconnect(time.Now().Add(-time.Hour))
+	assert.Equal(t, grpchealth.HealthCheckResponse_NOT_SERVING, <-stream.statuses)
+
+	// a prover connects
+	a.provers.connect()
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, <-stream.statuses)
+
+	// no message is sent while the status doesn't change
+	time.Sleep(5 * hc.watchInterval)
+	assert.Empty(t, stream.statuses)
+
+	cancel()
+	assert.ErrorIs(t, <-watchErr, context.Canceled)
+}
+
+func TestHealthCheckWatchServerStopping(t *testing.T) {
+	hc := newHealthChecker()
+	hc.watchInterval = 10 * time.Millisecond
+	done := make(chan struct{})
+	hc.done = done
+
+	stream := &healthWatchStream{ctx: context.Background(), statuses: make(chan grpchealth.HealthCheckResponse_ServingStatus, 10)}
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- hc.Watch(&grpchealth.HealthCheckRequest{}, stream) }()
+	assert.Equal(t, grpchealth.HealthCheckResponse_SERVING, <-stream.statuses)
+
+	// the stream ends once the server stops, even if still open
+	close(done)
+	assert.NoError(t, <-watchErr)
+	assert.Equal(t, grpchealth.HealthCheckResponse_NOT_SERVING, <-stream.statuses)
+}
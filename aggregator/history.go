@@ -0,0 +1,134 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrVerificationNotFound is returned when there is no verification recorded
+// for the requested L1 tx hash.
+var ErrVerificationNotFound = errors.New("verification not found")
+
+// Verification represents a final proof sent to L1 to verify a range of
+// batches.
+type Verification struct {
+	TxHash           common.Hash `json:"txHash"`
+	BatchNumber      uint64      `json:"batchNumber"`
+	BatchNumberFinal uint64      `json:"batchNumberFinal"`
+	ProofID          string      `json:"proofId"`
+}
+
+// verificationHistory keeps the most recent verifications sent to L1 indexed
+// by tx hash, in front of the ones stored in the state.
+type verificationHistory struct {
+	mu     sync.RWMutex
+	size   int
+	byHash map[common.Hash]Verification
+	order  []common.Hash
+}
+
+func newVerificationHistory(size int) *verificationHistory {
+	return &verificationHistory{
+		size:   size,
+		byHash: make(map[common.Hash]Verification),
+	}
+}
+
+// add records a verification, evicting the oldest one if the history is full.
+func (h *verificationHistory) add(v Verification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.byHash[v.TxHash]; !ok {
+		h.order = append(h.order, v.TxHash)
+	}
+	h.byHash[v.TxHash] = v
+	if len(h.order) > h.size {
+		delete(h.byHash, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+// get returns the verification sent in the given tx.
+func (h *verificationHistory) get(txHash common.Hash) (Verification, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	v, ok := h.byHash[txHash]
+	if !ok {
+		return Verification{}, ErrVerificationNotFound
+	}
+	return v, nil
+}
+
+// recordVerification adds the verification sent to L1 to the history, and
+// stores it in the state so it can be looked up after a restart.
+func (a *Aggregator) recordVerification(ctx context.Context, v Verification) {
+	a.verifications.add(v)
+	err := a.State.AddProofVerification(ctx, &state.ProofVerification{
+		TxHash:           v.TxHash,
+		BatchNumber:      v.BatchNumber,
+		BatchNumberFinal: v.BatchNumberFinal,
+		ProofID:          v.ProofID,
+	}, nil)
+	if err != nil {
+		log.Errorf("Failed to store the verification of batches [%d-%d] sent in tx %s, err: %v", v.BatchNumber, v.BatchNumberFinal, v.TxHash, err)
+	}
+}
+
+// GetVerificationByTxHash returns the range of batches and the final proof
+// id verified by the given L1 tx, from the history or else from the state. It
+// returns ErrVerificationNotFound if the tx is unknown.
+func (a *Aggregator) GetVerificationByTxHash(ctx context.Context, txHash common.Hash) (Verification, error) {
+	v, err := a.verifications.get(txHash)
+	if !errors.Is(err, ErrVerificationNotFound) {
+		return v, err
+	}
+	stored, err := a.State.GetProofVerification(ctx, txHash, nil)
+	if errors.Is(err, state.ErrNotFound) {
+		return Verification{}, ErrVerificationNotFound
+	}
+	if err != nil {
+		return Verification{}, fmt.Errorf("Failed to get the verification sent in tx %s, %w", txHash, err)
+	}
+	return Verification{
+		TxHash:           stored.TxHash,
+		BatchNumber:      stored.BatchNumber,
+		BatchNumberFinal: stored.BatchNumberFinal,
+		ProofID:          stored.ProofID,
+	}, nil
+}
+
+// handleVerificationByTxHash serves as JSON the verification sent in the L1
+// tx given by the txHash query parameter.
+func (a *Aggregator) handleVerificationByTxHash(w http.ResponseWriter, r *http.Request) {
+	txHash, err := hexutil.Decode(r.URL.Query().Get("txHash"))
+	if err != nil || len(txHash) != common.HashLength {
+		http.Error(w, "invalid request, a txHash query parameter is required", http.StatusBadRequest)
+		return
+	}
+	v, err := a.GetVerificationByTxHash(r.Context(), common.BytesToHash(txHash))
+	if errors.Is(err, ErrVerificationNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("Failed to get verification, err: %v", err)
+		http.Error(w, "failed to get the verification", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to encode verification, err: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerificationHistory(t *testing.T) {
+	h := newVerificationHistory(2)
+	v1 := Verification{TxHash: common.HexToHash("0x1"), BatchNumber: 1, BatchNumberFinal: 5, ProofID: "a"}
+	v2 := Verification{TxHash: common.HexToHash("0x2"), BatchNumber: 6, BatchNumberFinal: 9, ProofID: "b"}
+	v3 := Verification{TxHash: common.HexToHash("0x3"), BatchNumber: 10, BatchNumberFinal: 12, ProofID: "c"}
+
+	h.add(v1)
+	h.add(v2)
+
+	v, err := h.get(v1.TxHash)
+	require.NoError(t, err)
+	assert.Equal(t, v1, v)
+
+	_, err = h.get(common.HexToHash("0x4"))
+	assert.ErrorIs(t, err, ErrVerificationNotFound)
+
+	// the oldest verification is evicted
+	h.add(v3)
+	_, err = h.get(v1.TxHash)
+	assert.ErrorIs(t, err, ErrVerificationNotFound)
+	v, err = h.get(v3.TxHash)
+	require.NoError(t, err)
+	assert.Equal(t, v3, v)
+}
+
+func TestGetVerificationByTxHash(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	a := Aggregator{State: st, verifications: newVerificationHistory(1)}
+	ctx := context.Background()
+	v1 := Verification{TxHash: common.HexToHash("0x1"), BatchNumber: 1, BatchNumberFinal: 5, ProofID: "a"}
+	v2 := Verification{TxHash: common.HexToHash("0x2"), BatchNumber: 6, BatchNumberFinal: 9, ProofID: "b"}
+
+	st.On("AddProofVerification", ctx, &state.ProofVerification{TxHash: v1.TxHash, BatchNumber: 1, BatchNumberFinal: 5, ProofID: "a"}, nil).Return(nil).Once()
+	st.On("AddProofVerification", ctx, &state.ProofVerification{TxHash: v2.TxHash, BatchNumber: 6, BatchNumberFinal: 9, ProofID: "b"}, nil).Return(errors.New("connection refused")).Once()
+	a.recordVerification(ctx, v1)
+	// kept in the history even if it can't be stored
+	a.recordVerification(ctx, v2)
+
+	v, err := a.GetVerificationByTxHash(ctx, v2.TxHash)
+	require.NoError(t, err)
+	assert.Equal(t, v2, v)
+
+	// evicted from the history, found in the state
+	st.On("GetProofVerification", ctx, v1.TxHash, nil).Return(&state.ProofVerification{TxHash: v1.TxHash, BatchNumber: 1, BatchNumberFinal: 5, ProofID: "a"}, nil).Once()
+	v, err = a.GetVerificationByTxHash(ctx, v1.TxHash)
+	require.NoError(t, err)
+	assert.Equal(t, v1, v)
+
+	st.On("GetProofVerification", ctx, common.HexToHash("0x3"), nil).Return(nil, state.ErrNotFound).Once()
+	_, err = a.GetVerificationByTxHash(ctx, common.HexToHash("0x3"))
+	assert.ErrorIs(t, err, ErrVerificationNotFound)
+}
+
+func TestHandleVerificationByTxHash(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	a := Aggregator{State: st, verifications: newVerificationHistory(1)}
+	v1 := Verification{TxHash: common.HexToHash("0x1"), BatchNumber: 1, BatchNumberFinal: 5, ProofID: "a"}
+	a.verifications.add(v1)
+
+	rec := httptest.NewRecorder()
+	a.handleVerificationByTxHash(rec, httptest.NewRequest(http.MethodGet, "/admin/verifications?txHash="+v1.TxHash.String(), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got Verification
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(t, v1, got)
+
+	st.On("GetProofVerification", mock.Anything, common.HexToHash("0x2"), nil).Return(nil, state.ErrNotFound).Once()
+	rec = httptest.NewRecorder()
+	a.handleVerificationByTxHash(rec, httptest.NewRequest(http.MethodGet, "/admin/verifications?txHash="+common.HexToHash("0x2").String(), nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	for _, query := range []string{"", "?txHash=0x1", "?txHash=zz"} {
+		rec = httptest.NewRecorder()
+		a.handleVerificationByTxHash(rec, httptest.NewRequest(http.MethodGet, "/admin/verifications"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
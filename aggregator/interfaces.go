@@ -3,7 +3,9 @@ package aggregator
 import (
 	"context"
 	"math/big"
+	"time"
 
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/events"
 	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
 	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
 	"github.com/0xPolygonHermez/zkevm-node/state"
@@ -16,7 +18,11 @@ import (
 
 type proverInterface interface {
 	ID() string
+	ForkID() uint64
+	HasCapability(capability string) bool
+	Prefers(capability string) bool
 	Addr() string
+	Name() string
 	IsIdle() bool
 	BatchProof(input *pb.InputProver) (*string, error)
 	AggregatedProof(inputProof1, inputProof2 string) (*string, error)
@@ -25,16 +31,38 @@ type proverInterface interface {
 	WaitFinalProof(ctx context.Context, proofID string) (*pb.FinalProof, error)
 }
 
+// eventPublisher publishes proof lifecycle events.
+type eventPublisher interface {
+	Publish(event events.Event)
+	Close()
+}
+
+// leaderLock is the lock held by the leader aggregator.
+type leaderLock interface {
+	TryAcquire(ctx context.Context) (bool, error)
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
 // ethTxManager contains the methods required to send txs to
 // ethereum.
 type ethTxManager interface {
-	VerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *ethmanTypes.FinalProofInputs) (*types.Transaction, error)
+	CancelTx(ctx context.Context, txHash common.Hash) (*types.Transaction, error)
+	VerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error)
+	EstimateVerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error)
 }
 
 // etherman contains the methods required to interact with ethereum
 type etherman interface {
+	CheckReorg(batchNumber uint64, stateRoot common.Hash) (bool, error)
+	GetAggregatorReward() (*big.Int, error)
+	GetL1ChainID(ctx context.Context) (uint64, error)
+	GetL2ChainID() (uint64, error)
 	GetLatestVerifiedBatchNum() (uint64, error)
+	GetLatestBlockNumber(ctx context.Context) (uint64, error)
 	GetPublicAddress() (common.Address, error)
+	GetTxReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	GetVerifiedBatchStateRoot(batchNumber uint64) (common.Hash, error)
 }
 
 // aggregatorTxProfitabilityChecker interface for different profitability
@@ -45,15 +73,38 @@ type aggregatorTxProfitabilityChecker interface {
 
 // stateInterface gathers the methods to interact with the state.
 type stateInterface interface {
+	proofStore
+
 	BeginStateTransaction(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	ResetIdleConnections(ctx context.Context)
 	CheckProofContainsCompleteSequences(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) (bool, error)
+	CheckBatchesInSameSequence(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error)
 	GetLastVerifiedBatch(ctx context.Context, dbTx pgx.Tx) (*state.VerifiedBatch, error)
-	GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (*state.Proof, error)
-	GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (*state.Batch, error)
-	GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx) (*state.Proof, *state.Proof, error)
 	GetBatchByNumber(ctx context.Context, batchNumber uint64, dbTx pgx.Tx) (*state.Batch, error)
+	GetExitRootByGlobalExitRoot(ctx context.Context, ger common.Hash, dbTx pgx.Tx) (*state.GlobalExitRoot, error)
+	GetLastVerifyProofTime(ctx context.Context, dbTx pgx.Tx) (time.Time, error)
+	SetLastVerifyProofTime(ctx context.Context, lastVerifyProofTime time.Time, dbTx pgx.Tx) error
+}
+
+// proofStore gathers the methods to store the proofs generated by the
+// provers, along with the ones whose result depends on the stored proofs.
+type proofStore interface {
+	CheckProofPendingVerification(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error)
+	GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, preference state.ProofPreference, dbTx pgx.Tx) (*state.Proof, error)
+	GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*state.Batch, error)
+	GetProofsToAggregate(ctx context.Context, maxDepth, maxBatches uint64, dbTx pgx.Tx) (*state.Proof, *state.Proof, error)
 	AddGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error
 	UpdateGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error
 	DeleteGeneratedProofs(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error
-	DeleteUngeneratedProofs(ctx context.Context, dbTx pgx.Tx) error
+	RecoverGeneratingProofs(ctx context.Context, dbTx pgx.Tx) (uint64, uint64, error)
+	MarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error
+	UnmarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error
+	AddProofVerification(ctx context.Context, verification *state.ProofVerification, dbTx pgx.Tx) error
+	GetProofVerification(ctx context.Context, txHash common.Hash, dbTx pgx.Tx) (*state.ProofVerification, error)
+	GetVerifiedProofs(ctx context.Context, dbTx pgx.Tx) ([]*state.Proof, error)
+	AddProofRegenerations(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (uint64, error)
+	GetBatchToRegenerate(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*state.Batch, error)
+	CountProofRegenerations(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (uint64, error)
+	ReplaceRegeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error
 }
@@ -0,0 +1,62 @@
+package aggregator
+
+import (
+	"sync"
+	"time"
+)
+
+// l1VerifiedFrontier caches the last batch verified on L1, refreshed by the
+// isSynced polling, so the batch to prove is selected without querying L1
+// every time.
+type l1VerifiedFrontier struct {
+	mu          sync.Mutex
+	maxAge      time.Duration
+	batchNumber uint64
+	updatedAt   time.Time
+}
+
+func newL1VerifiedFrontier(maxAge time.Duration) *l1VerifiedFrontier {
+	return &l1VerifiedFrontier{maxAge: maxAge}
+}
+
+// set records the last batch verified on L1. It's safe to call it on a nil
+// frontier.
+func (f *l1VerifiedFrontier) set(batchNumber uint64) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.batchNumber = batchNumber
+	f.updatedAt = time.Now()
+}
+
+// get returns the last batch verified on L1 and whether it was recorded
+// within maxAge. A nil frontier has nothing recorded.
+func (f *l1VerifiedFrontier) get() (uint64, bool) {
+	if f == nil {
+		return 0, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.updatedAt.IsZero() || time.Since(f.updatedAt) > f.maxAge {
+		return 0, false
+	}
+	return f.batchNumber, true
+}
+
+// lastVerifiedEthBatchNum returns the last batch verified on L1, from the
+// frontier if recorded within RetryTime, or from L1 otherwise.
+func (a *Aggregator) lastVerifiedEthBatchNum() (uint64, error) {
+	if batchNumber, ok := a.l1Frontier.get(); ok {
+		return batchNumber, nil
+	}
+	batchNumber, err := a.Ethman.GetLatestVerifiedBatchNum()
+	if err != nil {
+		return 0, err
+	}
+	a.l1Frontier.set(batchNumber)
+	return batchNumber, nil
+}
@@ -0,0 +1,291 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// LeaderLockBackend is the backend used to hold the leader lock
+type LeaderLockBackend string
+
+const (
+	// LeaderLockPostgres holds the leader lock as a PostgreSQL session
+	// advisory lock
+	LeaderLockPostgres LeaderLockBackend = "postgres"
+)
+
+// closeLockConnTimeout is the time given to close the connection of a
+// session that may hold the lock.
+const closeLockConnTimeout = 5 * time.Second
+
+// PGLeaderLock is a leader lock backed by a PostgreSQL session advisory lock.
+// The lock is held by a dedicated connection of the pool, so it's released by
+// the DB as soon as the connection of the leader is lost.
+type PGLeaderLock struct {
+	pool   *pgxpool.Pool
+	lockID int64
+
+	mu   sync.Mutex
+	conn *pgxpool.Conn
+}
+
+// NewPGLeaderLock creates a new PGLeaderLock.
+func NewPGLeaderLock(pool *pgxpool.Pool, lockID int64) *PGLeaderLock {
+	return &PGLeaderLock{
+		pool:   pool,
+		lockID: lockID,
+	}
+}
+
+// TryAcquire tries to acquire the lock without blocking.
+func (l *PGLeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		return true, nil
+	}
+
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var acquired bool
+	err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", l.lockID).Scan(&acquired)
+	if err != nil {
+		// the lock may have been acquired by the session anyway
+		closeLockConn(conn)
+		return false, err
+	}
+	if !acquired {
+		conn.Release()
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+// Check returns an error if the lock is no longer held.
+func (l *PGLeaderLock) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return fmt.Errorf("lock not acquired")
+	}
+
+	err := l.conn.Conn().Ping(ctx)
+	if err != nil {
+		// the session holding the lock may still be alive, it's closed so
+		// the lock isn't handed over with it to another user of the pool
+		closeLockConn(l.conn)
+		l.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Release releases the lock if held.
+func (l *PGLeaderLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	conn := l.conn
+	l.conn = nil
+
+	_, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.lockID)
+	if err != nil {
+		// the lock may still be held by the session, it's closed to release it
+		closeLockConn(conn)
+		return err
+	}
+	conn.Release()
+	return nil
+}
+
+// closeLockConn closes the connection of a session that may hold the lock,
+// instead of returning it to the pool, so the DB releases the lock with the
+// session.
+func closeLockConn(conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), closeLockConnTimeout)
+	defer cancel()
+	if err := conn.Hijack().Close(ctx); err != nil {
+		log.Warnf("Failed to close the leader lock connection, err: %v", err)
+	}
+}
+
+// isLeader returns whether the aggregator is allowed to run the proof
+// generation and verification loops. It's always true if leader election is
+// disabled.
+func (a *Aggregator) isLeader() bool {
+	return a.leaderLock == nil || atomic.LoadInt32(&a.leader) == 1
+}
+
+// leadership holds the context of the current leadership, canceled as soon
+// as it's lost.
+type leadership struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// set starts a new leadership context derived from parent on election, and
+// cancels the current one otherwise.
+func (l *leadership) set(parent context.Context, leader bool) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cancel != nil {
+		l.cancel()
+		l.ctx, l.cancel = nil, nil
+	}
+	if leader {
+		if parent == nil {
+			parent = context.Background()
+		}
+		l.ctx, l.cancel = context.WithCancel(parent)
+	}
+}
+
+// context returns the context of the current leadership, already canceled
+// if not elected.
+func (l *leadership) context() context.Context {
+	var ctx context.Context
+	if l != nil {
+		l.mu.Lock()
+		ctx = l.ctx
+		l.mu.Unlock()
+	}
+	if ctx == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+	}
+	return ctx
+}
+
+// setLeader sets whether the aggregator is the leader, canceling the
+// leadership context as soon as the leadership is lost, so the final proof
+// being sent is aborted.
+func (a *Aggregator) setLeader(leader bool) {
+	a.leadership.set(a.ctx, leader)
+	var value int32
+	if leader {
+		value = 1
+	}
+	atomic.StoreInt32(&a.leader, value)
+	metrics.Leader(leader)
+}
+
+// leaderContext returns the context of the current leadership, or the
+// aggregator context if leader election is disabled.
+func (a *Aggregator) leaderContext() context.Context {
+	if a.leaderLock == nil {
+		return a.ctx
+	}
+	return a.leadership.context()
+}
+
+// leaderSendContext returns the context to send the final proof with, the
+// leadership context, so the send is aborted as soon as the leadership is
+// lost. The proof is given up if the leadership is already lost.
+func (a *Aggregator) leaderSendContext(ctx context.Context, proof *state.Proof) (context.Context, bool) {
+	sendCtx := a.leaderContext()
+	if sendCtx.Err() != nil {
+		log.Warnf("Leadership lost, final proof for batches [%d-%d] not sent", proof.BatchNumber, proof.BatchNumberFinal)
+		a.dropFinalProof(ctx, proof)
+		return nil, false
+	}
+	return sendCtx, true
+}
+
+// leadershipLostWhileSending gives up the final proof whose send failed
+// because the leadership was lost, as opposed to the aggregator stopping, so
+// the next leader sends it. It returns false if the leadership is kept.
+func (a *Aggregator) leadershipLostWhileSending(ctx, sendCtx context.Context, proof *state.Proof, err error) bool {
+	if sendCtx.Err() == nil || ctx.Err() != nil {
+		return false
+	}
+	log.Warnf("Leadership lost while sending final proof for batches [%d-%d], err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+	a.releaseSubmission(proof)
+	a.dropFinalProof(ctx, proof)
+	return true
+}
+
+// runLeaderElection campaigns for the leader lock until the context is
+// canceled. Once the lock is acquired, the proofs locked by a previous leader
+// are reclaimed before starting to work, and the lock is periodically checked
+// to step down as soon as it's lost.
+func (a *Aggregator) runLeaderElection(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.LeaderElection.RetryInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		if a.isLeader() {
+			err := a.leaderLock.Check(ctx)
+			if err != nil {
+				log.Errorf("Aggregator leadership lost, err: %v", err)
+				a.setLeader(false)
+			}
+		} else {
+			acquired, err := a.leaderLock.TryAcquire(ctx)
+			if err != nil {
+				log.Warnf("Failed to acquire leader lock, err: %v", err)
+			} else if acquired {
+				a.becomeLeader(ctx)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if a.isLeader() {
+				// use a fresh context as the server one is already canceled
+				err := a.leaderLock.Release(context.Background())
+				if err != nil {
+					log.Errorf("Failed to release leader lock, err: %v", err)
+				}
+				a.setLeader(false)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Aggregator) becomeLeader(ctx context.Context) {
+	log.Info("Aggregator elected as leader")
+
+	// reclaim the proofs locked by the previous leader
+	err := a.recoverGeneratingProofs(ctx)
+	if err != nil {
+		log.Errorf("Failed to reclaim proofs locked by the previous leader, err: %v", err)
+		err = a.leaderLock.Release(ctx)
+		if err != nil {
+			log.Errorf("Failed to release leader lock, err: %v", err)
+		}
+		return
+	}
+
+	a.resumeVerifyProofTime(ctx)
+	err = a.resumeVerifiedProofs(ctx)
+	if err != nil {
+		log.Errorf("Failed to resume the clean up of the verified proofs, err: %v", err)
+	}
+	a.setLeader(true)
+}
@@ -0,0 +1,159 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type leaderLockStub struct {
+	mu       sync.Mutex
+	acquired bool
+	lost     bool
+	released bool
+}
+
+func (l *leaderLockStub) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.acquired = !l.lost
+	return l.acquired, nil
+}
+
+func (l *leaderLockStub) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lost {
+		return errors.New("lock lost")
+	}
+	return nil
+}
+
+func (l *leaderLockStub) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released = true
+	return nil
+}
+
+func (l *leaderLockStub) lose() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lost = true
+}
+
+func TestLeaderElection(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	lock := &leaderLockStub{}
+	a := Aggregator{
+		cfg: Config{
+			LeaderElection: LeaderElectionConfig{
+				Enabled:       true,
+				RetryInterval: types.NewDuration(10 * time.Millisecond),
+			},
+		},
+		State:                   st,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		leaderLock:              lock,
+		leadership:              &leadership{},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the proofs locked by the previous leader are reclaimed once elected
+	st.On("RecoverGeneratingProofs", mock.Anything, nil).Return(uint64(1), uint64(1), nil).Once()
+	st.On("GetVerifiedProofs", mock.Anything, nil).Return(nil, nil).Once()
+	// the verify proof time of the previous leader is resumed
+	lastVerifyProofTime := time.Now().Add(-time.Minute)
+	st.On("GetLastVerifyProofTime", mock.Anything, nil).Return(lastVerifyProofTime, nil).Once()
+
+	done := make(chan struct{})
+	go func() {
+		a.runLeaderElection(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, a.isLeader, time.Second, 10*time.Millisecond)
+	assert.Equal(t, lastVerifyProofTime, a.VerificationTimer().TimeSendFinalProof)
+
+	lock.lose()
+	assert.Eventually(t, func() bool { return !a.isLeader() }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.False(t, a.isLeader())
+}
+
+func TestLeaderContext(t *testing.T) {
+	a := Aggregator{leaderLock: &leaderLockStub{}, leadership: &leadership{}}
+
+	// not elected yet
+	assert.Error(t, a.leaderContext().Err())
+
+	a.setLeader(true)
+	ctx := a.leaderContext()
+	assert.NoError(t, ctx.Err())
+
+	a.setLeader(false)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Error(t, a.leaderContext().Err())
+}
+
+func TestSendFinalProofLeadershipLost(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	ethTxMan := mocks.NewEthTxManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	a := Aggregator{
+		State:                   st,
+		EthTxManager:            ethTxMan,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		finalProof:              make(chan finalProofMsg),
+		leaderLock:              &leaderLockStub{},
+		leadership:              &leadership{},
+		ctx:                     ctx,
+		exit:                    cancel,
+	}
+	a.setLeader(true)
+	newMsg := func() finalProofMsg {
+		return finalProofMsg{
+			recursiveProof: &state.Proof{BatchNumber: 11, BatchNumberFinal: 12, Generating: true},
+			finalProof:     &pb.FinalProof{},
+		}
+	}
+
+	// the send is aborted once the leadership is lost
+	st.On("GetBatchByNumber", mock.Anything, uint64(12), nil).Return(&state.Batch{BatchNumber: 12}, nil).Once()
+	ethTxMan.On("VerifyBatches", mock.Anything, uint64(10), uint64(12), mock.Anything, (*big.Int)(nil)).Return(nil, context.Canceled).Once().
+		Run(func(args mock.Arguments) {
+			a.setLeader(false)
+			<-args.Get(0).(context.Context).Done()
+		})
+	unlocked := make(chan struct{}, 2)
+	st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(func(p *state.Proof) bool { return !p.Generating }), nil).Return(nil).Twice().
+		Run(func(mock.Arguments) { unlocked <- struct{}{} })
+
+	done := make(chan struct{})
+	go func() {
+		a.sendFinalProof()
+		close(done)
+	}()
+
+	a.finalProof <- newMsg()
+	<-unlocked
+
+	// a final proof queued once the leadership is lost is not sent
+	a.finalProof <- newMsg()
+	<-unlocked
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,92 @@
+package aggregator
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// resourceUsage is a sample of the resources used by the aggregator process.
+type resourceUsage struct {
+	heapMB     uint64
+	goroutines int
+}
+
+func readResourceUsage() resourceUsage {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return resourceUsage{
+		heapMB:     m.HeapAlloc / (1024 * 1024), //nolint:gomnd
+		goroutines: runtime.NumGoroutine(),
+	}
+}
+
+// loadShedder stops assigning new work to the provers while the resources
+// used by the aggregator exceed the configured thresholds.
+type loadShedder struct {
+	cfg      LoadSheddingConfig
+	sample   func() resourceUsage
+	shedding int32
+}
+
+func newLoadShedder(cfg LoadSheddingConfig) *loadShedder {
+	return &loadShedder{
+		cfg:    cfg,
+		sample: readResourceUsage,
+	}
+}
+
+// isShedding returns whether new work must not be assigned, it's safe to call
+// it on a nil load shedder.
+func (s *loadShedder) isShedding() bool {
+	return s != nil && atomic.LoadInt32(&s.shedding) == 1
+}
+
+// exceeded returns whether the usage exceeds any of the thresholds.
+func (s *loadShedder) exceeded(usage resourceUsage) bool {
+	if s.cfg.MaxHeapMB > 0 && usage.heapMB > s.cfg.MaxHeapMB {
+		return true
+	}
+	return s.cfg.MaxGoroutines > 0 && usage.goroutines > s.cfg.MaxGoroutines
+}
+
+// check samples the resource usage and updates the shedding state.
+func (s *loadShedder) check() {
+	usage := s.sample()
+	shedding := s.exceeded(usage)
+
+	var value int32
+	if shedding {
+		value = 1
+	}
+	if atomic.SwapInt32(&s.shedding, value) != value {
+		if shedding {
+			log.Warnf("Resource thresholds exceeded (heap %d MB, %d goroutines), not assigning new work to the provers",
+				usage.heapMB, usage.goroutines)
+		} else {
+			log.Infof("Resource usage recovered (heap %d MB, %d goroutines), assigning work to the provers",
+				usage.heapMB, usage.goroutines)
+		}
+	}
+	metrics.LoadShedding(shedding)
+}
+
+// run checks the resource usage every check interval until the context is
+// done.
+func (s *loadShedder) run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CheckInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		s.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
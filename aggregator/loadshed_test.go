@@ -0,0 +1,37 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedder(t *testing.T) {
+	s := newLoadShedder(LoadSheddingConfig{
+		Enabled:       true,
+		MaxHeapMB:     100,
+		MaxGoroutines: 1000,
+	})
+	usage := resourceUsage{heapMB: 50, goroutines: 10}
+	s.sample = func() resourceUsage { return usage }
+
+	s.check()
+	assert.False(t, s.isShedding())
+
+	usage.heapMB = 150
+	s.check()
+	assert.True(t, s.isShedding())
+
+	usage.heapMB = 50
+	usage.goroutines = 2000
+	s.check()
+	assert.True(t, s.isShedding())
+
+	// resumes once recovered
+	usage.goroutines = 10
+	s.check()
+	assert.False(t, s.isShedding())
+
+	var disabled *loadShedder
+	assert.False(t, disabled.isShedding())
+}
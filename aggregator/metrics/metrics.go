@@ -1,6 +1,10 @@
 package metrics
 
 import (
+	"math/big"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/encoding"
 	"github.com/0xPolygonHermez/zkevm-node/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -9,11 +13,61 @@ const (
 	prefix                      = "aggregator_"
 	currentConnectedProversName = prefix + "current_connected_provers"
 	currentWorkingProversName   = prefix + "current_working_provers"
+	connectedProversFleetName   = prefix + "connected_provers_fleet"
+	stateRootMismatchName       = prefix + "state_root_mismatch"
+	batchL2DataMismatchName     = prefix + "batch_l2_data_mismatch"
+	leaderName                  = prefix + "leader"
+	l1RateLimiterWaitName       = prefix + "l1_rate_limiter_wait"
+	profitabilityRewardName     = prefix + "profitability_reward"
+	profitabilityMarginName     = prefix + "profitability_margin"
+	loadSheddingName            = prefix + "load_shedding"
+	failureCircuitTrippedName   = prefix + "failure_circuit_tripped"
+	dbHealthyName               = prefix + "db_healthy"
+	batchesVerifiedPerHourName  = prefix + "batches_verified_per_hour"
+	proofsGeneratedPerHourName  = prefix + "proofs_generated_per_hour"
+	batchProofDurationName      = prefix + "batch_proof_duration"
+	proofDurationName           = prefix + "proof_duration"
+	proofsName                  = prefix + "proofs"
+	preferredOperationsName     = prefix + "preferred_operations"
+	fallbackOperationsName      = prefix + "fallback_operations"
+	regenerationQueueDepthName  = prefix + "proof_regeneration_queue_depth"
+)
+
+// Types of the proofs generated by the provers, to label the proof metrics.
+const (
+	// ProofTypeBatch is the type of the batch proofs.
+	ProofTypeBatch = "batch"
+	// ProofTypeAggregated is the type of the aggregated proofs.
+	ProofTypeAggregated = "aggregated"
+	// ProofTypeFinal is the type of the final proofs.
+	ProofTypeFinal = "final"
 )
 
+// zeroCollateralCaveat is appended to the help of the profitability metrics,
+// the fee for the aggregator is not defined in the smart contract yet so the
+// batches are evaluated with a matic collateral of zero.
+const zeroCollateralCaveat = " (the matic collateral is always 0 until the aggregator fee is defined in the smart contract)"
+
 // Register the metrics for the sequencer package.
 func Register() {
-	gauges := []prometheus.GaugeOpts{
+	var (
+		counters   []prometheus.CounterOpts
+		gauges     []prometheus.GaugeOpts
+		histograms []prometheus.HistogramOpts
+	)
+
+	counters = []prometheus.CounterOpts{
+		{
+			Name: stateRootMismatchName,
+			Help: "[AGGREGATOR] total count of verified state roots on L1 not matching the submitted ones",
+		},
+		{
+			Name: batchL2DataMismatchName,
+			Help: "[AGGREGATOR] total count of batches whose stored L2 data doesn't match their accumulated input hash",
+		},
+	}
+
+	gauges = []prometheus.GaugeOpts{
 		{
 			Name: currentConnectedProversName,
 			Help: "[AGGREGATOR] current connected provers",
@@ -22,21 +76,147 @@ func Register() {
 			Name: currentWorkingProversName,
 			Help: "[AGGREGATOR] current working provers",
 		},
+		{
+			Name: leaderName,
+			Help: "[AGGREGATOR] whether the aggregator is the leader (1) or in standby (0)",
+		},
+		{
+			Name: loadSheddingName,
+			Help: "[AGGREGATOR] whether the aggregator is shedding load (1) or assigning work to the provers (0)",
+		},
+		{
+			Name: failureCircuitTrippedName,
+			Help: "[AGGREGATOR] whether the pipeline is paused by the failure circuit (1) or running (0)",
+		},
+		{
+			Name: dbHealthyName,
+			Help: "[AGGREGATOR] whether the last probe of the state database succeeded (1) or failed (0)",
+		},
+		{
+			Name: batchesVerifiedPerHourName,
+			Help: "[AGGREGATOR] batches verified per hour over the throughput window",
+		},
+		{
+			Name: proofsGeneratedPerHourName,
+			Help: "[AGGREGATOR] batch and aggregated proofs generated per hour over the throughput window",
+		},
+		{
+			Name: regenerationQueueDepthName,
+			Help: "[AGGREGATOR] batches queued for their proofs to be regenerated",
+		},
+		{
+			Name: profitabilityMarginName,
+			Help: "[AGGREGATOR] margin in MATIC of the matic collateral over the min reward of the last batch evaluated by the profitability checker" + zeroCollateralCaveat,
+		},
+	}
+
+	histograms = []prometheus.HistogramOpts{
+		{
+			Name: l1RateLimiterWaitName,
+			Help: "[AGGREGATOR] time in seconds waited by the L1 calls for the rate limiter",
+		},
+		{
+			Name: profitabilityRewardName,
+			Help: "[AGGREGATOR] matic collateral in MATIC evaluated per batch by the profitability checker" + zeroCollateralCaveat,
+		},
+	}
+
+	counterVecs := []metrics.CounterVecOpts{
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: preferredOperationsName,
+				Help: "[AGGREGATOR] total count of operations performed by a prover preferring them, by operation",
+			},
+			Labels: []string{"operation"},
+		},
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: fallbackOperationsName,
+				Help: "[AGGREGATOR] total count of operations performed by a prover not preferring them, by operation",
+			},
+			Labels: []string{"operation"},
+		},
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: proofsName,
+				Help: "[AGGREGATOR] total count of proofs waited for, by proof type, prover id and whether the proof was generated (success) or not (failure)",
+			},
+			Labels: []string{"type", "prover", "result"},
+		},
+	}
+
+	// The connected provers are labelled by version and capabilities, not by
+	// prover id: a gauge per prover id would leave a series behind for every
+	// prover ever connected. The capabilities label only combines the known
+	// capabilities and the version is truncated, see ConnectedProver, so the
+	// number of series is bounded by the versions deployed in the fleet.
+	gaugeVecs := []metrics.GaugeVecOpts{
+		{
+			GaugeOpts: prometheus.GaugeOpts{
+				Name: connectedProversFleetName,
+				Help: "[AGGREGATOR] current connected provers, by prover version and advertised capabilities",
+			},
+			Labels: []string{"version", "capabilities"},
+		},
+	}
+
+	histogramVecs := []metrics.HistogramVecOpts{
+		{
+			HistogramOpts: prometheus.HistogramOpts{
+				Name:    batchProofDurationName,
+				Help:    "[AGGREGATOR] time in seconds to generate a batch proof, by whether the prover had proved the previous batch (warm) or not (cold)",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 14), //nolint:gomnd
+			},
+			Labels: []string{"cache"},
+		},
+		// Unlike the connected provers, the proofs are labelled by prover id
+		// to compare the provers of the fleet, the prover ids are expected
+		// to be stable across the reconnections of a prover.
+		{
+			HistogramOpts: prometheus.HistogramOpts{
+				Name:    proofDurationName,
+				Help:    "[AGGREGATOR] time in seconds waited for a proof to be generated, by proof type and prover id",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 14), //nolint:gomnd
+			},
+			Labels: []string{"type", "prover"},
+		},
 	}
 
+	metrics.RegisterCounters(counters...)
+	metrics.RegisterCounterVecs(counterVecs...)
 	metrics.RegisterGauges(gauges...)
+	metrics.RegisterGaugeVecs(gaugeVecs...)
+	metrics.RegisterHistograms(histograms...)
+	metrics.RegisterHistogramVecs(histogramVecs...)
 }
 
-// ConnectedProver increments the gauge for the current number of connected
-// provers.
-func ConnectedProver() {
+// maxVersionLabelLength bounds the length of the prover version reported as
+// a label, the version is advertised by the provers so it's not trusted.
+const maxVersionLabelLength = 32
+
+// ConnectedProver increments the gauges for the current number of connected
+// provers, in total and for the given prover version and capabilities.
+func ConnectedProver(version, capabilities string) {
 	metrics.GaugeInc(currentConnectedProversName)
+	metrics.GaugeVecInc(connectedProversFleetName, versionLabel(version), capabilities)
 }
 
-// DisconnectedProver decrements the gauge for the current number of connected
-// provers.
-func DisconnectedProver() {
+// DisconnectedProver decrements the gauges for the current number of
+// connected provers, in total and for the given prover version and
+// capabilities.
+func DisconnectedProver(version, capabilities string) {
 	metrics.GaugeDec(currentConnectedProversName)
+	metrics.GaugeVecDec(connectedProversFleetName, versionLabel(version), capabilities)
+}
+
+func versionLabel(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	if len(version) > maxVersionLabelLength {
+		return version[:maxVersionLabelLength]
+	}
+	return version
 }
 
 // WorkingProver increments the gauge for the current number of working
@@ -49,3 +229,122 @@ func WorkingProver() {
 func IdlingProver() {
 	metrics.GaugeDec(currentWorkingProversName)
 }
+
+// StateRootMismatch increments the counter for the verified state roots read
+// from L1 that don't match the ones submitted by the aggregator.
+func StateRootMismatch() {
+	metrics.CounterInc(stateRootMismatchName)
+}
+
+// BatchL2DataMismatch increments the counter for the batches whose stored L2
+// data doesn't match their accumulated input hash.
+func BatchL2DataMismatch() {
+	metrics.CounterInc(batchL2DataMismatchName)
+}
+
+// Leader sets the gauge for the leader status of the aggregator.
+func Leader(leader bool) {
+	var value float64
+	if leader {
+		value = 1
+	}
+	metrics.GaugeSet(leaderName, value)
+}
+
+// LoadShedding sets the gauge for the load shedding status of the aggregator.
+func LoadShedding(shedding bool) {
+	var value float64
+	if shedding {
+		value = 1
+	}
+	metrics.GaugeSet(loadSheddingName, value)
+}
+
+// FailureCircuitTripped sets the gauge for the failure circuit status of the
+// pipeline.
+func FailureCircuitTripped(tripped bool) {
+	var value float64
+	if tripped {
+		value = 1
+	}
+	metrics.GaugeSet(failureCircuitTrippedName, value)
+}
+
+// DBHealthy sets the gauge for the health of the state database.
+func DBHealthy(healthy bool) {
+	var value float64
+	if healthy {
+		value = 1
+	}
+	metrics.GaugeSet(dbHealthyName, value)
+}
+
+// ProofRegenerationQueueDepth sets the gauge for the number of batches queued
+// for their proofs to be regenerated.
+func ProofRegenerationQueueDepth(depth uint64) {
+	metrics.GaugeSet(regenerationQueueDepthName, float64(depth))
+}
+
+// Throughput sets the gauges for the throughput of the proof pipeline.
+func Throughput(batchesVerifiedPerHour, proofsGeneratedPerHour float64) {
+	metrics.GaugeSet(batchesVerifiedPerHourName, batchesVerifiedPerHour)
+	metrics.GaugeSet(proofsGeneratedPerHourName, proofsGeneratedPerHour)
+}
+
+// BatchProofDuration observes the time to generate a batch proof on the
+// histogram, labeled by whether the prover had proved the previous batch.
+func BatchProofDuration(duration time.Duration, warm bool) {
+	label := "cold"
+	if warm {
+		label = "warm"
+	}
+	metrics.HistogramVecObserve(batchProofDurationName, label, duration.Seconds())
+}
+
+// ProofWaited increments the counter for the proofs waited for, labeled by
+// proof type, prover identity, its name and ID, and result, and, if the proof
+// was generated, observes the time waited for it on the histogram.
+func ProofWaited(proofType, prover string, duration time.Duration, generated bool) {
+	result := "failure"
+	if generated {
+		result = "success"
+		metrics.HistogramVecObserveWithLabels(proofDurationName, duration.Seconds(), proofType, prover)
+	}
+	metrics.CounterVecInc(proofsName, proofType, prover, result)
+}
+
+// OperationRouted increments the counter for the operations performed by a
+// prover preferring them or not, labeled by operation.
+func OperationRouted(operation string, preferred bool) {
+	name := fallbackOperationsName
+	if preferred {
+		name = preferredOperationsName
+	}
+	metrics.CounterVecInc(name, operation)
+}
+
+// L1RateLimiterWait observes the time waited by an L1 call for the rate
+// limiter on the histogram.
+func L1RateLimiterWait(wait time.Duration) {
+	metrics.HistogramObserve(l1RateLimiterWaitName, wait.Seconds())
+}
+
+// ProfitabilityReward observes the matic collateral evaluated for a batch by
+// the profitability checker on the histogram.
+func ProfitabilityReward(maticCollateral *big.Int) {
+	metrics.HistogramObserve(profitabilityRewardName, toMatic(maticCollateral))
+}
+
+// ProfitabilityMargin sets the gauge for the margin of the matic collateral
+// evaluated for a batch over the min reward.
+func ProfitabilityMargin(maticCollateral, minReward *big.Int) {
+	margin := new(big.Int).Sub(maticCollateral, minReward)
+	metrics.GaugeSet(profitabilityMarginName, toMatic(margin))
+}
+
+// toMatic converts an amount of the smallest unit of MATIC to MATIC.
+func toMatic(amount *big.Int) float64 {
+	coin := new(big.Float).SetInt(big.NewInt(encoding.TenToThePowerOf18))
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), coin).Float64()
+	return value
+}
@@ -3,8 +3,13 @@
 package mocks
 
 import (
+	context "context"
+	big "math/big"
+
 	common "github.com/ethereum/go-ethereum/common"
 	mock "github.com/stretchr/testify/mock"
+
+	types "github.com/ethereum/go-ethereum/core/types"
 )
 
 // Etherman is an autogenerated mock type for the etherman type
@@ -12,6 +17,113 @@ type Etherman struct {
 	mock.Mock
 }
 
+// CheckReorg provides a mock function with given fields: batchNumber, stateRoot
+func (_m *Etherman) CheckReorg(batchNumber uint64, stateRoot common.Hash) (bool, error) {
+	ret := _m.Called(batchNumber, stateRoot)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(uint64, common.Hash) bool); ok {
+		r0 = rf(batchNumber, stateRoot)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(uint64, common.Hash) error); ok {
+		r1 = rf(batchNumber, stateRoot)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAggregatorReward provides a mock function with given fields:
+func (_m *Etherman) GetAggregatorReward() (*big.Int, error) {
+	ret := _m.Called()
+
+	var r0 *big.Int
+	if rf, ok := ret.Get(0).(func() *big.Int); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*big.Int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetL1ChainID provides a mock function with given fields: ctx
+func (_m *Etherman) GetL1ChainID(ctx context.Context) (uint64, error) {
+	ret := _m.Called(ctx)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context) uint64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetL2ChainID provides a mock function with given fields:
+func (_m *Etherman) GetL2ChainID() (uint64, error) {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLatestBlockNumber provides a mock function with given fields: ctx
+func (_m *Etherman) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	ret := _m.Called(ctx)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context) uint64); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetLatestVerifiedBatchNum provides a mock function with given fields:
 func (_m *Etherman) GetLatestVerifiedBatchNum() (uint64, error) {
 	ret := _m.Called()
@@ -56,6 +168,52 @@ func (_m *Etherman) GetPublicAddress() (common.Address, error) {
 	return r0, r1
 }
 
+// GetTxReceipt provides a mock function with given fields: ctx, txHash
+func (_m *Etherman) GetTxReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	ret := _m.Called(ctx, txHash)
+
+	var r0 *types.Receipt
+	if rf, ok := ret.Get(0).(func(context.Context, common.Hash) *types.Receipt); ok {
+		r0 = rf(ctx, txHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.Receipt)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, common.Hash) error); ok {
+		r1 = rf(ctx, txHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetVerifiedBatchStateRoot provides a mock function with given fields: batchNumber
+func (_m *Etherman) GetVerifiedBatchStateRoot(batchNumber uint64) (common.Hash, error) {
+	ret := _m.Called(batchNumber)
+
+	var r0 common.Hash
+	if rf, ok := ret.Get(0).(func(uint64) common.Hash); ok {
+		r0 = rf(batchNumber)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(common.Hash)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(uint64) error); ok {
+		r1 = rf(batchNumber)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewEtherman interface {
 	mock.TestingT
 	Cleanup(func())
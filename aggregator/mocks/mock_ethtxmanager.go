@@ -4,7 +4,9 @@ package mocks
 
 import (
 	context "context"
+	big "math/big"
 
+	common "github.com/ethereum/go-ethereum/common"
 	coretypes "github.com/ethereum/go-ethereum/core/types"
 	mock "github.com/stretchr/testify/mock"
 
@@ -16,13 +18,13 @@ type EthTxManager struct {
 	mock.Mock
 }
 
-// VerifyBatches provides a mock function with given fields: ctx, lastVerifiedBatch, batchNum, inputs
-func (_m *EthTxManager) VerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *types.FinalProofInputs) (*coretypes.Transaction, error) {
-	ret := _m.Called(ctx, lastVerifiedBatch, batchNum, inputs)
+// CancelTx provides a mock function with given fields: ctx, txHash
+func (_m *EthTxManager) CancelTx(ctx context.Context, txHash common.Hash) (*coretypes.Transaction, error) {
+	ret := _m.Called(ctx, txHash)
 
 	var r0 *coretypes.Transaction
-	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, *types.FinalProofInputs) *coretypes.Transaction); ok {
-		r0 = rf(ctx, lastVerifiedBatch, batchNum, inputs)
+	if rf, ok := ret.Get(0).(func(context.Context, common.Hash) *coretypes.Transaction); ok {
+		r0 = rf(ctx, txHash)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*coretypes.Transaction)
@@ -30,8 +32,54 @@ func (_m *EthTxManager) VerifyBatches(ctx context.Context, lastVerifiedBatch uin
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, *types.FinalProofInputs) error); ok {
-		r1 = rf(ctx, lastVerifiedBatch, batchNum, inputs)
+	if rf, ok := ret.Get(1).(func(context.Context, common.Hash) error); ok {
+		r1 = rf(ctx, txHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EstimateVerifyBatches provides a mock function with given fields: ctx, lastVerifiedBatch, batchNum, inputs, gasPrice
+func (_m *EthTxManager) EstimateVerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *types.FinalProofInputs, gasPrice *big.Int) (*coretypes.Transaction, error) {
+	ret := _m.Called(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
+
+	var r0 *coretypes.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, *types.FinalProofInputs, *big.Int) *coretypes.Transaction); ok {
+		r0 = rf(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*coretypes.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, *types.FinalProofInputs, *big.Int) error); ok {
+		r1 = rf(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// VerifyBatches provides a mock function with given fields: ctx, lastVerifiedBatch, batchNum, inputs, gasPrice
+func (_m *EthTxManager) VerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *types.FinalProofInputs, gasPrice *big.Int) (*coretypes.Transaction, error) {
+	ret := _m.Called(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
+
+	var r0 *coretypes.Transaction
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, *types.FinalProofInputs, *big.Int) *coretypes.Transaction); ok {
+		r0 = rf(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*coretypes.Transaction)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, *types.FinalProofInputs, *big.Int) error); ok {
+		r1 = rf(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -97,6 +97,34 @@ func (_m *ProverMock) FinalProof(inputProof string, aggregatorAddr string) (*str
 	return r0, r1
 }
 
+// ForkID provides a mock function with given fields:
+func (_m *ProverMock) ForkID() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
+// HasCapability provides a mock function with given fields: capability
+func (_m *ProverMock) HasCapability(capability string) bool {
+	ret := _m.Called(capability)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(capability)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // ID provides a mock function with given fields:
 func (_m *ProverMock) ID() string {
 	ret := _m.Called()
@@ -125,6 +153,34 @@ func (_m *ProverMock) IsIdle() bool {
 	return r0
 }
 
+// Name provides a mock function with given fields:
+func (_m *ProverMock) Name() string {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// Prefers provides a mock function with given fields: capability
+func (_m *ProverMock) Prefers(capability string) bool {
+	ret := _m.Called(capability)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(capability)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // WaitFinalProof provides a mock function with given fields: ctx, proofID
 func (_m *ProverMock) WaitFinalProof(ctx context.Context, proofID string) (*pb.FinalProof, error) {
 	ret := _m.Called(ctx, proofID)
@@ -5,6 +5,10 @@ package mocks
 import (
 	context "context"
 
+	common "github.com/ethereum/go-ethereum/common"
+
+	time "time"
+
 	pgx "github.com/jackc/pgx/v4"
 	mock "github.com/stretchr/testify/mock"
 
@@ -30,6 +34,41 @@ func (_m *StateMock) AddGeneratedProof(ctx context.Context, proof *state.Proof,
 	return r0
 }
 
+// AddProofRegenerations provides a mock function with given fields: ctx, batchNumber, batchNumberFinal, dbTx
+func (_m *StateMock) AddProofRegenerations(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (uint64, error) {
+	ret := _m.Called(ctx, batchNumber, batchNumberFinal, dbTx)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) uint64); ok {
+		r0 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+		r1 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AddProofVerification provides a mock function with given fields: ctx, verification, dbTx
+func (_m *StateMock) AddProofVerification(ctx context.Context, verification *state.ProofVerification, dbTx pgx.Tx) error {
+	ret := _m.Called(ctx, verification, dbTx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *state.ProofVerification, pgx.Tx) error); ok {
+		r0 = rf(ctx, verification, dbTx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // BeginStateTransaction provides a mock function with given fields: ctx
 func (_m *StateMock) BeginStateTransaction(ctx context.Context) (pgx.Tx, error) {
 	ret := _m.Called(ctx)
@@ -74,27 +113,76 @@ func (_m *StateMock) CheckProofContainsCompleteSequences(ctx context.Context, pr
 	return r0, r1
 }
 
-// DeleteGeneratedProofs provides a mock function with given fields: ctx, batchNumber, batchNumberFinal, dbTx
-func (_m *StateMock) DeleteGeneratedProofs(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+// CheckBatchesInSameSequence provides a mock function with given fields: ctx, batchNumber, batchNumberFinal, dbTx
+func (_m *StateMock) CheckBatchesInSameSequence(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error) {
 	ret := _m.Called(ctx, batchNumber, batchNumberFinal, dbTx)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) bool); ok {
 		r0 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(bool)
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+		r1 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// DeleteUngeneratedProofs provides a mock function with given fields: ctx, dbTx
-func (_m *StateMock) DeleteUngeneratedProofs(ctx context.Context, dbTx pgx.Tx) error {
-	ret := _m.Called(ctx, dbTx)
+// CheckProofPendingVerification provides a mock function with given fields: ctx, batchNumber, batchNumberFinal, dbTx
+func (_m *StateMock) CheckProofPendingVerification(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error) {
+	ret := _m.Called(ctx, batchNumber, batchNumberFinal, dbTx)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) bool); ok {
+		r0 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+		r1 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountProofRegenerations provides a mock function with given fields: ctx, lastVerfiedBatchNumber, dbTx
+func (_m *StateMock) CountProofRegenerations(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (uint64, error) {
+	ret := _m.Called(ctx, lastVerfiedBatchNumber, dbTx)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, pgx.Tx) uint64); ok {
+		r0 = rf(ctx, lastVerfiedBatchNumber, dbTx)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, pgx.Tx) error); ok {
+		r1 = rf(ctx, lastVerfiedBatchNumber, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteGeneratedProofs provides a mock function with given fields: ctx, batchNumber, batchNumberFinal, dbTx
+func (_m *StateMock) DeleteGeneratedProofs(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	ret := _m.Called(ctx, batchNumber, batchNumberFinal, dbTx)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, pgx.Tx) error); ok {
-		r0 = rf(ctx, dbTx)
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+		r0 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -125,6 +213,52 @@ func (_m *StateMock) GetBatchByNumber(ctx context.Context, batchNumber uint64, d
 	return r0, r1
 }
 
+// GetBatchToRegenerate provides a mock function with given fields: ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx
+func (_m *StateMock) GetBatchToRegenerate(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*state.Batch, error) {
+	ret := _m.Called(ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx)
+
+	var r0 *state.Batch
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, []uint64, pgx.Tx) *state.Batch); ok {
+		r0 = rf(ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*state.Batch)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, []uint64, pgx.Tx) error); ok {
+		r1 = rf(ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExitRootByGlobalExitRoot provides a mock function with given fields: ctx, ger, dbTx
+func (_m *StateMock) GetExitRootByGlobalExitRoot(ctx context.Context, ger common.Hash, dbTx pgx.Tx) (*state.GlobalExitRoot, error) {
+	ret := _m.Called(ctx, ger, dbTx)
+
+	var r0 *state.GlobalExitRoot
+	if rf, ok := ret.Get(0).(func(context.Context, common.Hash, pgx.Tx) *state.GlobalExitRoot); ok {
+		r0 = rf(ctx, ger, dbTx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*state.GlobalExitRoot)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, common.Hash, pgx.Tx) error); ok {
+		r1 = rf(ctx, ger, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetLastVerifiedBatch provides a mock function with given fields: ctx, dbTx
 func (_m *StateMock) GetLastVerifiedBatch(ctx context.Context, dbTx pgx.Tx) (*state.VerifiedBatch, error) {
 	ret := _m.Called(ctx, dbTx)
@@ -148,13 +282,34 @@ func (_m *StateMock) GetLastVerifiedBatch(ctx context.Context, dbTx pgx.Tx) (*st
 	return r0, r1
 }
 
-// GetProofReadyToVerify provides a mock function with given fields: ctx, lastVerfiedBatchNumber, dbTx
-func (_m *StateMock) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (*state.Proof, error) {
-	ret := _m.Called(ctx, lastVerfiedBatchNumber, dbTx)
+// GetLastVerifyProofTime provides a mock function with given fields: ctx, dbTx
+func (_m *StateMock) GetLastVerifyProofTime(ctx context.Context, dbTx pgx.Tx) (time.Time, error) {
+	ret := _m.Called(ctx, dbTx)
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func(context.Context, pgx.Tx) time.Time); ok {
+		r0 = rf(ctx, dbTx)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, pgx.Tx) error); ok {
+		r1 = rf(ctx, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProofReadyToVerify provides a mock function with given fields: ctx, lastVerfiedBatchNumber, preference, dbTx
+func (_m *StateMock) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, preference state.ProofPreference, dbTx pgx.Tx) (*state.Proof, error) {
+	ret := _m.Called(ctx, lastVerfiedBatchNumber, preference, dbTx)
 
 	var r0 *state.Proof
-	if rf, ok := ret.Get(0).(func(context.Context, uint64, pgx.Tx) *state.Proof); ok {
-		r0 = rf(ctx, lastVerfiedBatchNumber, dbTx)
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, state.ProofPreference, pgx.Tx) *state.Proof); ok {
+		r0 = rf(ctx, lastVerfiedBatchNumber, preference, dbTx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*state.Proof)
@@ -162,8 +317,8 @@ func (_m *StateMock) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatch
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, uint64, pgx.Tx) error); ok {
-		r1 = rf(ctx, lastVerfiedBatchNumber, dbTx)
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, state.ProofPreference, pgx.Tx) error); ok {
+		r1 = rf(ctx, lastVerfiedBatchNumber, preference, dbTx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -171,13 +326,36 @@ func (_m *StateMock) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatch
 	return r0, r1
 }
 
-// GetProofsToAggregate provides a mock function with given fields: ctx, dbTx
-func (_m *StateMock) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx) (*state.Proof, *state.Proof, error) {
-	ret := _m.Called(ctx, dbTx)
+// GetProofVerification provides a mock function with given fields: ctx, txHash, dbTx
+func (_m *StateMock) GetProofVerification(ctx context.Context, txHash common.Hash, dbTx pgx.Tx) (*state.ProofVerification, error) {
+	ret := _m.Called(ctx, txHash, dbTx)
+
+	var r0 *state.ProofVerification
+	if rf, ok := ret.Get(0).(func(context.Context, common.Hash, pgx.Tx) *state.ProofVerification); ok {
+		r0 = rf(ctx, txHash, dbTx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*state.ProofVerification)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, common.Hash, pgx.Tx) error); ok {
+		r1 = rf(ctx, txHash, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProofsToAggregate provides a mock function with given fields: ctx, maxDepth, maxBatches, dbTx
+func (_m *StateMock) GetProofsToAggregate(ctx context.Context, maxDepth uint64, maxBatches uint64, dbTx pgx.Tx) (*state.Proof, *state.Proof, error) {
+	ret := _m.Called(ctx, maxDepth, maxBatches, dbTx)
 
 	var r0 *state.Proof
-	if rf, ok := ret.Get(0).(func(context.Context, pgx.Tx) *state.Proof); ok {
-		r0 = rf(ctx, dbTx)
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) *state.Proof); ok {
+		r0 = rf(ctx, maxDepth, maxBatches, dbTx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*state.Proof)
@@ -185,8 +363,8 @@ func (_m *StateMock) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx) (*st
 	}
 
 	var r1 *state.Proof
-	if rf, ok := ret.Get(1).(func(context.Context, pgx.Tx) *state.Proof); ok {
-		r1 = rf(ctx, dbTx)
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64, pgx.Tx) *state.Proof); ok {
+		r1 = rf(ctx, maxDepth, maxBatches, dbTx)
 	} else {
 		if ret.Get(1) != nil {
 			r1 = ret.Get(1).(*state.Proof)
@@ -194,8 +372,8 @@ func (_m *StateMock) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx) (*st
 	}
 
 	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, pgx.Tx) error); ok {
-		r2 = rf(ctx, dbTx)
+	if rf, ok := ret.Get(2).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+		r2 = rf(ctx, maxDepth, maxBatches, dbTx)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -203,13 +381,36 @@ func (_m *StateMock) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx) (*st
 	return r0, r1, r2
 }
 
-// GetVirtualBatchToProve provides a mock function with given fields: ctx, lastVerfiedBatchNumber, dbTx
-func (_m *StateMock) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (*state.Batch, error) {
-	ret := _m.Called(ctx, lastVerfiedBatchNumber, dbTx)
+// GetVerifiedProofs provides a mock function with given fields: ctx, dbTx
+func (_m *StateMock) GetVerifiedProofs(ctx context.Context, dbTx pgx.Tx) ([]*state.Proof, error) {
+	ret := _m.Called(ctx, dbTx)
+
+	var r0 []*state.Proof
+	if rf, ok := ret.Get(0).(func(context.Context, pgx.Tx) []*state.Proof); ok {
+		r0 = rf(ctx, dbTx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*state.Proof)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, pgx.Tx) error); ok {
+		r1 = rf(ctx, dbTx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetVirtualBatchToProve provides a mock function with given fields: ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx
+func (_m *StateMock) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*state.Batch, error) {
+	ret := _m.Called(ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx)
 
 	var r0 *state.Batch
-	if rf, ok := ret.Get(0).(func(context.Context, uint64, pgx.Tx) *state.Batch); ok {
-		r0 = rf(ctx, lastVerfiedBatchNumber, dbTx)
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, []uint64, pgx.Tx) *state.Batch); ok {
+		r0 = rf(ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*state.Batch)
@@ -217,8 +418,8 @@ func (_m *StateMock) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatc
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, uint64, pgx.Tx) error); ok {
-		r1 = rf(ctx, lastVerfiedBatchNumber, dbTx)
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, []uint64, pgx.Tx) error); ok {
+		r1 = rf(ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -226,6 +427,109 @@ func (_m *StateMock) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatc
 	return r0, r1
 }
 
+// MarkProofVerified provides a mock function with given fields: ctx, batchNumber, batchNumberFinal, dbTx
+func (_m *StateMock) MarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	ret := _m.Called(ctx, batchNumber, batchNumberFinal, dbTx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+		r0 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Ping provides a mock function with given fields: ctx
+func (_m *StateMock) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecoverGeneratingProofs provides a mock function with given fields: ctx, dbTx
+func (_m *StateMock) RecoverGeneratingProofs(ctx context.Context, dbTx pgx.Tx) (uint64, uint64, error) {
+	ret := _m.Called(ctx, dbTx)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context, pgx.Tx) uint64); ok {
+		r0 = rf(ctx, dbTx)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 uint64
+	if rf, ok := ret.Get(1).(func(context.Context, pgx.Tx) uint64); ok {
+		r1 = rf(ctx, dbTx)
+	} else {
+		r1 = ret.Get(1).(uint64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(context.Context, pgx.Tx) error); ok {
+		r2 = rf(ctx, dbTx)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ReplaceRegeneratedProof provides a mock function with given fields: ctx, proof, dbTx
+func (_m *StateMock) ReplaceRegeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	ret := _m.Called(ctx, proof, dbTx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *state.Proof, pgx.Tx) error); ok {
+		r0 = rf(ctx, proof, dbTx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetIdleConnections provides a mock function with given fields: ctx
+func (_m *StateMock) ResetIdleConnections(ctx context.Context) {
+	_m.Called(ctx)
+}
+
+// SetLastVerifyProofTime provides a mock function with given fields: ctx, lastVerifyProofTime, dbTx
+func (_m *StateMock) SetLastVerifyProofTime(ctx context.Context, lastVerifyProofTime time.Time, dbTx pgx.Tx) error {
+	ret := _m.Called(ctx, lastVerifyProofTime, dbTx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, pgx.Tx) error); ok {
+		r0 = rf(ctx, lastVerifyProofTime, dbTx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnmarkProofVerified provides a mock function with given fields: ctx, batchNumber, batchNumberFinal, dbTx
+func (_m *StateMock) UnmarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	ret := _m.Called(ctx, batchNumber, batchNumberFinal, dbTx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64, pgx.Tx) error); ok {
+		r0 = rf(ctx, batchNumber, batchNumberFinal, dbTx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateGeneratedProof provides a mock function with given fields: ctx, proof, dbTx
 func (_m *StateMock) UpdateGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
 	ret := _m.Called(ctx, proof, dbTx)
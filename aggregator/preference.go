@@ -0,0 +1,155 @@
+package aggregator
+
+import (
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/prover"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// operationCapabilities maps each channel operation to the prover capability
+// required to perform it.
+var operationCapabilities = map[ChannelOperation]string{
+	ChannelOperationBuildFinalProof:    prover.CapabilityFinalProof,
+	ChannelOperationAggregateProofs:    prover.CapabilityAggregatedProof,
+	ChannelOperationGenerateBatchProof: prover.CapabilityBatchProof,
+	ChannelOperationRegenerateProof:    prover.CapabilityBatchProof,
+}
+
+// prefersOperation returns whether the prover advertised a preference for the
+// operation.
+func prefersOperation(p proverInterface, op ChannelOperation) bool {
+	return p.Prefers(operationCapabilities[op])
+}
+
+// canPerformOperation returns whether the prover advertised the capability
+// required to perform the operation.  Provers advertising no capabilities are
+// assumed capable of every operation.
+func canPerformOperation(p proverInterface, op ChannelOperation) bool {
+	return p.HasCapability(operationCapabilities[op])
+}
+
+// unableToPerform returns whether the operation must not be asked to the
+// prover because, with the operations routed by the prover capabilities, the
+// prover didn't advertise it can perform it.
+func (a *Aggregator) unableToPerform(p proverInterface, op ChannelOperation) bool {
+	if !a.cfg.FilterProofsByProverCapabilities || canPerformOperation(p, op) {
+		return false
+	}
+	log.Debugf("Prover { ID [%s], name [%s] } can't perform operation %s", p.ID(), p.Name(), op)
+	return true
+}
+
+// operationPreferences tracks the operations preferred by each connected
+// prover.
+type operationPreferences struct {
+	mu          sync.RWMutex
+	preferences map[string][]ChannelOperation
+}
+
+func newOperationPreferences() *operationPreferences {
+	return &operationPreferences{
+		preferences: make(map[string][]ChannelOperation),
+	}
+}
+
+// register stores the operations preferred by the prover, if any.
+func (o *operationPreferences) register(p proverInterface) {
+	if o == nil {
+		return
+	}
+	var ops []ChannelOperation
+	for _, op := range defaultChannelOperationsOrder {
+		if prefersOperation(p, op) {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) == 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.preferences[p.ID()] = ops
+}
+
+// forget removes the preferences of the prover, once disconnected.
+func (o *operationPreferences) forget(proverID string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.preferences, proverID)
+}
+
+// preferring returns the ids of the connected provers preferring the
+// operation.
+func (o *operationPreferences) preferring(op ChannelOperation) []string {
+	if o == nil {
+		return nil
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	var proverIDs []string
+	for proverID, ops := range o.preferences {
+		for _, preferred := range ops {
+			if preferred == op {
+				proverIDs = append(proverIDs, proverID)
+				break
+			}
+		}
+	}
+	return proverIDs
+}
+
+// channelOperationsOrder returns the operations to perform with the prover,
+// moving the ones it prefers to the front while keeping the configured order
+// otherwise.
+func (a *Aggregator) channelOperationsOrder(p proverInterface) []ChannelOperation {
+	order := a.operationsOrder()
+	if !a.cfg.PreferredOperationRouting {
+		return order
+	}
+	ops := make([]ChannelOperation, 0, len(order))
+	for _, op := range order {
+		if prefersOperation(p, op) {
+			ops = append(ops, op)
+		}
+	}
+	for _, op := range order {
+		if !prefersOperation(p, op) {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// deferToPreferringProver returns whether the operation must be left for a
+// prover preferring it. The preference is soft: the operation is only left
+// while any of the connected provers preferring it is not busy.
+func (a *Aggregator) deferToPreferringProver(p proverInterface, op ChannelOperation) bool {
+	if !a.cfg.PreferredOperationRouting || prefersOperation(p, op) {
+		return false
+	}
+	for _, proverID := range a.preferences.preferring(op) {
+		if proverID != p.ID() && !a.assignments.isAssigned(proverID) {
+			log.Debugf("Operation %s left for prover [%s] which prefers it, prover { ID [%s], name [%s] } not used",
+				op, proverID, p.ID(), p.Name())
+			return true
+		}
+	}
+	return false
+}
+
+// recordRouting reports whether the operation performed by the prover was
+// routed to a prover preferring it.
+func (a *Aggregator) recordRouting(p proverInterface, op ChannelOperation) {
+	if !a.cfg.PreferredOperationRouting {
+		return
+	}
+	metrics.OperationRouted(string(op), prefersOperation(p, op))
+}
@@ -0,0 +1,83 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/prover"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPreferredOperationRouting(t *testing.T) {
+	aggregating := mocks.NewProverMock(t)
+	aggregating.On("ID").Return("prover-1").Maybe()
+	aggregating.On("Addr").Return("addr-1").Maybe()
+	aggregating.On("Name").Return("addr-1").Maybe()
+	aggregating.On("Prefers", prover.CapabilityAggregatedProof).Return(true).Maybe()
+	aggregating.On("Prefers", prover.CapabilityBatchProof).Return(false).Maybe()
+	aggregating.On("Prefers", prover.CapabilityFinalProof).Return(false).Maybe()
+	generic := mocks.NewProverMock(t)
+	generic.On("ID").Return("prover-2").Maybe()
+	generic.On("Addr").Return("addr-2").Maybe()
+	generic.On("Name").Return("addr-2").Maybe()
+	generic.On("Prefers", mock.Anything).Return(false).Maybe()
+
+	a := Aggregator{
+		cfg: Config{
+			PreferredOperationRouting: true,
+			ChannelOperationsOrder:    defaultChannelOperationsOrder,
+		},
+		assignments: newProverAssignments(),
+		preferences: newOperationPreferences(),
+	}
+	a.preferences.register(aggregating)
+	a.preferences.register(generic)
+
+	// the preferred operations are tried first
+	assert.Equal(t, []ChannelOperation{ChannelOperationAggregateProofs, ChannelOperationBuildFinalProof, ChannelOperationGenerateBatchProof},
+		a.channelOperationsOrder(aggregating))
+	assert.Equal(t, defaultChannelOperationsOrder, a.channelOperationsOrder(generic))
+
+	// aggregations are left for prover-1 while it is idle
+	assert.True(t, a.deferToPreferringProver(generic, ChannelOperationAggregateProofs))
+	assert.False(t, a.deferToPreferringProver(generic, ChannelOperationGenerateBatchProof))
+	assert.False(t, a.deferToPreferringProver(aggregating, ChannelOperationAggregateProofs))
+	assert.False(t, a.deferToPreferringProver(aggregating, ChannelOperationGenerateBatchProof))
+
+	// falls back when prover-1 is busy
+	a.assignments.assign(aggregating, ChannelOperationAggregateProofs, 1, 4)
+	assert.False(t, a.deferToPreferringProver(generic, ChannelOperationAggregateProofs))
+	a.assignments.clear(aggregating)
+
+	// falls back when prover-1 disconnects
+	a.preferences.forget("prover-1")
+	assert.False(t, a.deferToPreferringProver(generic, ChannelOperationAggregateProofs))
+
+	// disabled
+	a.preferences.register(aggregating)
+	a.cfg.PreferredOperationRouting = false
+	assert.False(t, a.deferToPreferringProver(generic, ChannelOperationAggregateProofs))
+	assert.Equal(t, defaultChannelOperationsOrder, a.channelOperationsOrder(aggregating))
+}
+
+func TestUnableToPerform(t *testing.T) {
+	batchOnly := mocks.NewProverMock(t)
+	batchOnly.On("ID").Return("prover-1").Maybe()
+	batchOnly.On("Addr").Return("addr-1").Maybe()
+	batchOnly.On("Name").Return("addr-1").Maybe()
+	batchOnly.On("HasCapability", prover.CapabilityBatchProof).Return(true).Maybe()
+	batchOnly.On("HasCapability", mock.Anything).Return(false).Maybe()
+
+	a := Aggregator{cfg: Config{FilterProofsByProverCapabilities: true}}
+
+	assert.False(t, a.unableToPerform(batchOnly, ChannelOperationGenerateBatchProof))
+	assert.True(t, a.unableToPerform(batchOnly, ChannelOperationAggregateProofs))
+	assert.True(t, a.unableToPerform(batchOnly, ChannelOperationBuildFinalProof))
+
+	// disabled
+	a.cfg.FilterProofsByProverCapabilities = false
+	for _, op := range defaultChannelOperationsOrder {
+		assert.False(t, a.unableToPerform(batchOnly, op))
+	}
+}
@@ -3,7 +3,10 @@ package aggregator
 import (
 	"context"
 	"math/big"
+	"sync"
 	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
 )
 
 // TxProfitabilityCheckerType checks profitability of batch validation
@@ -16,19 +19,22 @@ const (
 	ProfitabilityAcceptAll = "acceptall"
 )
 
-// TxProfitabilityCheckerBase checks matic collateral with min reward
+// TxProfitabilityCheckerBase checks matic collateral with min reward. Its
+// parameters can be reloaded with SetParams, and are read with Params.
 type TxProfitabilityCheckerBase struct {
-	State                             stateInterface
-	IntervalAfterWhichBatchSentAnyway time.Duration
-	MinReward                         *big.Int
+	State stateInterface
+
+	mu                                sync.RWMutex
+	intervalAfterWhichBatchSentAnyway time.Duration
+	minReward                         *big.Int
 }
 
 // NewTxProfitabilityCheckerBase init base tx profitability checker
 func NewTxProfitabilityCheckerBase(state stateInterface, interval time.Duration, minReward *big.Int) *TxProfitabilityCheckerBase {
 	return &TxProfitabilityCheckerBase{
 		State:                             state,
-		IntervalAfterWhichBatchSentAnyway: interval,
-		MinReward:                         minReward,
+		intervalAfterWhichBatchSentAnyway: interval,
+		minReward:                         minReward,
 	}
 }
 
@@ -44,7 +50,31 @@ func (pc *TxProfitabilityCheckerBase) IsProfitable(ctx context.Context, maticCol
 	//	}
 	//}
 
-	return maticCollateral.Cmp(pc.MinReward) >= 0, nil
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	metrics.ProfitabilityReward(maticCollateral)
+	metrics.ProfitabilityMargin(maticCollateral, pc.minReward)
+
+	return maticCollateral.Cmp(pc.minReward) >= 0, nil
+}
+
+// SetParams updates the checker parameters, it is safe to call it while the
+// checker is in use.
+func (pc *TxProfitabilityCheckerBase) SetParams(interval time.Duration, minReward *big.Int) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.intervalAfterWhichBatchSentAnyway = interval
+	pc.minReward = minReward
+}
+
+// Params returns the parameters currently in use by the checker.
+func (pc *TxProfitabilityCheckerBase) Params() (time.Duration, *big.Int) {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+
+	return pc.intervalAfterWhichBatchSentAnyway, pc.minReward
 }
 
 // TxProfitabilityCheckerAcceptAll validate batch anyway and don't check anything
@@ -73,6 +103,10 @@ func (pc *TxProfitabilityCheckerAcceptAll) IsProfitable(ctx context.Context, mat
 	//	}
 	//}
 
+	// there is no min reward, the whole collateral is the margin
+	metrics.ProfitabilityReward(maticCollateral)
+	metrics.ProfitabilityMargin(maticCollateral, big.NewInt(0))
+
 	return true, nil
 }
 
@@ -0,0 +1,28 @@
+package aggregator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxProfitabilityCheckerBaseSetParams(t *testing.T) {
+	ctx := context.Background()
+	pc := NewTxProfitabilityCheckerBase(nil, time.Minute, big.NewInt(100))
+
+	isProfitable, err := pc.IsProfitable(ctx, big.NewInt(50))
+	require.NoError(t, err)
+	require.False(t, isProfitable)
+
+	pc.SetParams(time.Hour, big.NewInt(10))
+
+	isProfitable, err = pc.IsProfitable(ctx, big.NewInt(50))
+	require.NoError(t, err)
+	require.True(t, isProfitable)
+	interval, minReward := pc.Params()
+	require.Equal(t, time.Hour, interval)
+	require.Equal(t, big.NewInt(10), minReward)
+}
@@ -0,0 +1,230 @@
+package aggregator
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/jackc/pgx/v4"
+)
+
+// batchRange identifies a proof by the range of batches it covers.
+type batchRange struct {
+	batchNumber      uint64
+	batchNumberFinal uint64
+}
+
+func (r batchRange) overlaps(batchNumber, batchNumberFinal uint64) bool {
+	return r.batchNumber <= batchNumberFinal && batchNumber <= r.batchNumberFinal
+}
+
+// readyToVerifyKey identifies a GetProofReadyToVerify query.
+type readyToVerifyKey struct {
+	lastVerifiedBatchNumber uint64
+	preference              state.ProofPreference
+}
+
+// proofCache is a stateInterface wrapper that keeps an in-memory LRU cache of
+// the proofs returned by GetProofReadyToVerify and GetProofsToAggregate, so
+// the polling loops don't need to fetch the same proof blobs from the DB on
+// every iteration. Any mutation of a proof through the wrapper invalidates the
+// cached entries overlapping its batch range once written, and the proofs read
+// concurrently with the write are not cached. Only positive results are
+// cached, as the absence of eligible proofs also depends on the synchronized
+// sequences.
+type proofCache struct {
+	stateInterface
+
+	size int
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[batchRange]*list.Element
+	// readyToVerify maps the last verified batch number and the preference
+	// used in the query to the range of the proof returned
+	readyToVerify map[readyToVerifyKey]batchRange
+	// toAggregate holds the ranges of the last pair of proofs returned
+	toAggregate *[2]batchRange
+	// generation is increased on every invalidation, so the proofs read
+	// before it are not cached as they may predate the write
+	generation uint64
+}
+
+func newProofCache(st stateInterface, size int) *proofCache {
+	return &proofCache{
+		stateInterface: st,
+		size:           size,
+		ll:             list.New(),
+		entries:        make(map[batchRange]*list.Element),
+		readyToVerify:  make(map[readyToVerifyKey]batchRange),
+	}
+}
+
+// GetProofReadyToVerify implements stateInterface.
+func (c *proofCache) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, preference state.ProofPreference, dbTx pgx.Tx) (*state.Proof, error) {
+	key := readyToVerifyKey{lastVerifiedBatchNumber: lastVerfiedBatchNumber, preference: preference}
+	c.mu.Lock()
+	if r, ok := c.readyToVerify[key]; ok {
+		if proof, ok := c.get(r); ok {
+			c.mu.Unlock()
+			return proof, nil
+		}
+		delete(c.readyToVerify, key)
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	proof, err := c.stateInterface.GetProofReadyToVerify(ctx, lastVerfiedBatchNumber, preference, dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.readyToVerify[key] = c.add(proof)
+	}
+	return proof, nil
+}
+
+// GetProofsToAggregate implements stateInterface.
+func (c *proofCache) GetProofsToAggregate(ctx context.Context, maxDepth, maxBatches uint64, dbTx pgx.Tx) (*state.Proof, *state.Proof, error) {
+	c.mu.Lock()
+	if c.toAggregate != nil {
+		proof1, ok1 := c.get(c.toAggregate[0])
+		proof2, ok2 := c.get(c.toAggregate[1])
+		if ok1 && ok2 && belowDepth(proof1, maxDepth) && belowDepth(proof2, maxDepth) && withinBatches(proof1, proof2, maxBatches) {
+			c.mu.Unlock()
+			return proof1, proof2, nil
+		}
+		c.toAggregate = nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	proof1, proof2, err := c.stateInterface.GetProofsToAggregate(ctx, maxDepth, maxBatches, dbTx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.toAggregate = &[2]batchRange{c.add(proof1), c.add(proof2)}
+	}
+	return proof1, proof2, nil
+}
+
+// belowDepth returns whether the proof can still be aggregated with the given
+// max depth, 0 means no limit.
+func belowDepth(proof *state.Proof, maxDepth uint64) bool {
+	return maxDepth == 0 || proof.Depth < maxDepth
+}
+
+// withinBatches returns whether the proof aggregating the given ones spans at
+// most the given number of batches, 0 means no limit.
+func withinBatches(proof1, proof2 *state.Proof, maxBatches uint64) bool {
+	return maxBatches == 0 || proof2.BatchNumberFinal-proof1.BatchNumber+1 <= maxBatches
+}
+
+// AddGeneratedProof implements stateInterface.
+func (c *proofCache) AddGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	err := c.stateInterface.AddGeneratedProof(ctx, proof, dbTx)
+	c.invalidate(proof.BatchNumber, proof.BatchNumberFinal)
+	return err
+}
+
+// UpdateGeneratedProof implements stateInterface.
+func (c *proofCache) UpdateGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	err := c.stateInterface.UpdateGeneratedProof(ctx, proof, dbTx)
+	c.invalidate(proof.BatchNumber, proof.BatchNumberFinal)
+	return err
+}
+
+// DeleteGeneratedProofs implements stateInterface.
+func (c *proofCache) DeleteGeneratedProofs(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	err := c.stateInterface.DeleteGeneratedProofs(ctx, batchNumber, batchNumberFinal, dbTx)
+	c.invalidate(batchNumber, batchNumberFinal)
+	return err
+}
+
+// MarkProofVerified implements stateInterface.
+func (c *proofCache) MarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	err := c.stateInterface.MarkProofVerified(ctx, batchNumber, batchNumberFinal, dbTx)
+	c.invalidate(batchNumber, batchNumberFinal)
+	return err
+}
+
+// UnmarkProofVerified implements stateInterface.
+func (c *proofCache) UnmarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	err := c.stateInterface.UnmarkProofVerified(ctx, batchNumber, batchNumberFinal, dbTx)
+	c.invalidate(batchNumber, batchNumberFinal)
+	return err
+}
+
+// RecoverGeneratingProofs implements stateInterface.
+func (c *proofCache) RecoverGeneratingProofs(ctx context.Context, dbTx pgx.Tx) (uint64, uint64, error) {
+	recovered, deleted, err := c.stateInterface.RecoverGeneratingProofs(ctx, dbTx)
+	c.mu.Lock()
+	c.ll.Init()
+	c.entries = make(map[batchRange]*list.Element)
+	c.readyToVerify = make(map[readyToVerifyKey]batchRange)
+	c.toAggregate = nil
+	c.generation++
+	c.mu.Unlock()
+	return recovered, deleted, err
+}
+
+// ReplaceRegeneratedProof implements stateInterface.
+func (c *proofCache) ReplaceRegeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	err := c.stateInterface.ReplaceRegeneratedProof(ctx, proof, dbTx)
+	c.invalidate(proof.BatchNumber, proof.BatchNumberFinal)
+	return err
+}
+
+// get returns a copy of the cached proof for the given range, it must be
+// called with the lock held.
+func (c *proofCache) get(r batchRange) (*state.Proof, bool) {
+	e, ok := c.entries[r]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	proof := *e.Value.(*state.Proof)
+	return &proof, true
+}
+
+// add stores a copy of the proof evicting the least recently used entry if
+// the cache is full, it must be called with the lock held.
+func (c *proofCache) add(proof *state.Proof) batchRange {
+	r := batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}
+	p := *proof
+	if e, ok := c.entries[r]; ok {
+		e.Value = &p
+		c.ll.MoveToFront(e)
+		return r
+	}
+	c.entries[r] = c.ll.PushFront(&p)
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		oldestProof := oldest.Value.(*state.Proof)
+		c.ll.Remove(oldest)
+		delete(c.entries, batchRange{batchNumber: oldestProof.BatchNumber, batchNumberFinal: oldestProof.BatchNumberFinal})
+	}
+	return r
+}
+
+// invalidate removes all the cached proofs overlapping the given range.
+func (c *proofCache) invalidate(batchNumber, batchNumberFinal uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for r, e := range c.entries {
+		if r.overlaps(batchNumber, batchNumberFinal) {
+			c.ll.Remove(e)
+			delete(c.entries, r)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofCacheInvalidation(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	c := newProofCache(st, 2)
+	ctx := context.Background()
+	proof := &state.Proof{BatchNumber: 5, BatchNumberFinal: 8, Proof: "proof"}
+
+	st.On("GetProofReadyToVerify", ctx, uint64(4), state.ProofPreferenceLargest, nil).Return(proof, nil).Once()
+
+	p, err := c.GetProofReadyToVerify(ctx, 4, state.ProofPreferenceLargest, nil)
+	require.NoError(t, err)
+	require.Equal(t, proof, p)
+
+	// served from the cache
+	p, err = c.GetProofReadyToVerify(ctx, 4, state.ProofPreferenceLargest, nil)
+	require.NoError(t, err)
+	require.Equal(t, proof, p)
+
+	// a mutation of an overlapping range invalidates the entry
+	st.On("UpdateGeneratedProof", ctx, proof, nil).Return(nil).Once()
+	require.NoError(t, c.UpdateGeneratedProof(ctx, proof, nil))
+
+	st.On("GetProofReadyToVerify", ctx, uint64(4), state.ProofPreferenceLargest, nil).Return(nil, state.ErrNotFound).Once()
+	_, err = c.GetProofReadyToVerify(ctx, 4, state.ProofPreferenceLargest, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestProofCachePreference(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	c := newProofCache(st, 4)
+	ctx := context.Background()
+	largest := &state.Proof{BatchNumber: 5, BatchNumberFinal: 8, Proof: "largest"}
+	smallest := &state.Proof{BatchNumber: 5, BatchNumberFinal: 6, Proof: "smallest"}
+
+	st.On("GetProofReadyToVerify", ctx, uint64(4), state.ProofPreferenceLargest, nil).Return(largest, nil).Once()
+	st.On("GetProofReadyToVerify", ctx, uint64(4), state.ProofPreferenceSmallest, nil).Return(smallest, nil).Once()
+
+	// each preference is cached apart
+	for i := 0; i < 2; i++ {
+		p, err := c.GetProofReadyToVerify(ctx, 4, state.ProofPreferenceLargest, nil)
+		require.NoError(t, err)
+		require.Equal(t, largest, p)
+		p, err = c.GetProofReadyToVerify(ctx, 4, state.ProofPreferenceSmallest, nil)
+		require.NoError(t, err)
+		require.Equal(t, smallest, p)
+	}
+}
+
+func TestProofCacheConcurrentWrite(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	c := newProofCache(st, 2)
+	ctx := context.Background()
+	staleProof := &state.Proof{BatchNumber: 5, BatchNumberFinal: 8, Proof: "proof", Generating: false}
+	lockedProof := &state.Proof{BatchNumber: 5, BatchNumberFinal: 8, Proof: "proof", Generating: true}
+
+	// the proof is locked while it's being read, so the row read may
+	// predate the write and it's not cached
+	st.On("UpdateGeneratedProof", ctx, lockedProof, nil).Return(nil).Once()
+	st.On("GetProofReadyToVerify", ctx, uint64(4), state.ProofPreferenceLargest, nil).Return(staleProof, nil).Once().
+		Run(func(mock.Arguments) {
+			require.NoError(t, c.UpdateGeneratedProof(ctx, lockedProof, nil))
+		})
+	p, err := c.GetProofReadyToVerify(ctx, 4, state.ProofPreferenceLargest, nil)
+	require.NoError(t, err)
+	require.Equal(t, staleProof, p)
+
+	st.On("GetProofReadyToVerify", ctx, uint64(4), state.ProofPreferenceLargest, nil).Return(nil, state.ErrNotFound).Once()
+	_, err = c.GetProofReadyToVerify(ctx, 4, state.ProofPreferenceLargest, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestProofCacheEviction(t *testing.T) {
+	c := newProofCache(nil, 2)
+	c.add(&state.Proof{BatchNumber: 1, BatchNumberFinal: 1})
+	c.add(&state.Proof{BatchNumber: 2, BatchNumberFinal: 2})
+	c.add(&state.Proof{BatchNumber: 3, BatchNumberFinal: 3})
+
+	_, ok := c.get(batchRange{batchNumber: 1, batchNumberFinal: 1})
+	require.False(t, ok)
+	_, ok = c.get(batchRange{batchNumber: 3, batchNumberFinal: 3})
+	require.True(t, ok)
+	require.Equal(t, 2, c.ll.Len())
+}
+
+func TestProofCacheMaxAggregationDepth(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	c := newProofCache(st, 4)
+	ctx := context.Background()
+	proof1 := &state.Proof{BatchNumber: 1, BatchNumberFinal: 4, Depth: 2}
+	proof2 := &state.Proof{BatchNumber: 5, BatchNumberFinal: 5}
+
+	st.On("GetProofsToAggregate", ctx, uint64(0), uint64(0), nil).Return(proof1, proof2, nil).Once()
+	_, _, err := c.GetProofsToAggregate(ctx, 0, 0, nil)
+	require.NoError(t, err)
+
+	// the cached pair exceeds the max depth, the state is queried again
+	st.On("GetProofsToAggregate", ctx, uint64(2), uint64(0), nil).Return(nil, nil, state.ErrNotFound).Once()
+	_, _, err = c.GetProofsToAggregate(ctx, 2, 0, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestProofCacheMaxBatchesPerAggregatedProof(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	c := newProofCache(st, 4)
+	ctx := context.Background()
+	proof1 := &state.Proof{BatchNumber: 1, BatchNumberFinal: 4}
+	proof2 := &state.Proof{BatchNumber: 5, BatchNumberFinal: 5}
+
+	st.On("GetProofsToAggregate", ctx, uint64(0), uint64(0), nil).Return(proof1, proof2, nil).Once()
+	_, _, err := c.GetProofsToAggregate(ctx, 0, 0, nil)
+	require.NoError(t, err)
+
+	// the cached pair fits the max span, it's served from the cache
+	_, _, err = c.GetProofsToAggregate(ctx, 0, 5, nil)
+	require.NoError(t, err)
+
+	// the cached pair exceeds the max span, the state is queried again
+	st.On("GetProofsToAggregate", ctx, uint64(0), uint64(4), nil).Return(nil, nil, state.ErrNotFound).Once()
+	_, _, err = c.GetProofsToAggregate(ctx, 0, 4, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+}
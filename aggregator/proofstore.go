@@ -0,0 +1,473 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/jackc/pgx/v4"
+)
+
+// proofStoreConnectTimeout is the max time to wait for the proof store to
+// respond at startup.
+const proofStoreConnectTimeout = 10 * time.Second
+
+// newProofStore returns the state with its proof store methods served by the
+// configured backend, after validating the backend is reachable.
+func newProofStore(backend ProofStoreBackend, st stateInterface) (stateInterface, error) {
+	switch backend {
+	case ProofStorePostgres, "":
+		ctx, cancel := context.WithTimeout(context.Background(), proofStoreConnectTimeout)
+		defer cancel()
+		if err := st.Ping(ctx); err != nil {
+			return nil, fmt.Errorf("Failed to connect to the postgres proof store, %w", err)
+		}
+		return st, nil
+	case ProofStoreMemory:
+		log.Warn("Proofs are kept in memory, they will be lost on restart")
+		return newMemoryProofStore(st), nil
+	default:
+		return nil, fmt.Errorf("Unsupported proof store backend %q", backend)
+	}
+}
+
+// memoryProof is a proof kept by the memory proof store.
+type memoryProof struct {
+	proof    state.Proof
+	verified bool
+}
+
+// memoryProofStore is a stateInterface wrapper that keeps the proofs in
+// memory instead of the state database, for tests and ephemeral nodes. The
+// sequences and batches are still read from the state. It behaves as the
+// postgres proof store, except that the proofs are lost on restart. The
+// changes made within a dbTx begun by the store are undone if the dbTx is
+// rolled back, but they are seen outside the dbTx before it's committed.
+type memoryProofStore struct {
+	stateInterface
+
+	mu            sync.RWMutex
+	proofs        map[batchRange]*memoryProof
+	regenerations map[uint64]struct{}
+	// undo are the functions restoring the proofs and the regenerations
+	// changed within each dbTx, in the order they were changed
+	undo map[*memoryProofStoreTx][]func()
+}
+
+func newMemoryProofStore(st stateInterface) *memoryProofStore {
+	return &memoryProofStore{
+		stateInterface: st,
+		proofs:         make(map[batchRange]*memoryProof),
+		regenerations:  make(map[uint64]struct{}),
+		undo:           make(map[*memoryProofStoreTx][]func()),
+	}
+}
+
+// memoryProofStoreTx is a state dbTx that undoes the changes made to the
+// memory proof store within it if it's rolled back.
+type memoryProofStoreTx struct {
+	pgx.Tx
+	store *memoryProofStore
+}
+
+// BeginStateTransaction implements stateInterface.
+func (s *memoryProofStore) BeginStateTransaction(ctx context.Context) (pgx.Tx, error) {
+	dbTx, err := s.stateInterface.BeginStateTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryProofStoreTx{Tx: dbTx, store: s}, nil
+}
+
+// Commit implements pgx.Tx, the changes are undone if the commit fails.
+func (tx *memoryProofStoreTx) Commit(ctx context.Context) error {
+	if err := tx.Tx.Commit(ctx); err != nil {
+		tx.store.rollback(tx)
+		return err
+	}
+	tx.store.commit(tx)
+	return nil
+}
+
+// Rollback implements pgx.Tx.
+func (tx *memoryProofStoreTx) Rollback(ctx context.Context) error {
+	tx.store.rollback(tx)
+	return tx.Tx.Rollback(ctx)
+}
+
+// commit forgets how to undo the changes made within the dbTx.
+func (s *memoryProofStore) commit(tx *memoryProofStoreTx) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.undo, tx)
+}
+
+// rollback undoes the changes made within the dbTx, latest first.
+func (s *memoryProofStore) rollback(tx *memoryProofStoreTx) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	undo := s.undo[tx]
+	for i := len(undo) - 1; i >= 0; i-- {
+		undo[i]()
+	}
+	delete(s.undo, tx)
+}
+
+// saveProof records how to restore the proof of the range, as it is before
+// being changed, if the dbTx is rolled back. s.mu must be held.
+func (s *memoryProofStore) saveProof(dbTx pgx.Tx, r batchRange) {
+	tx, ok := dbTx.(*memoryProofStoreTx)
+	if !ok || tx.store != s {
+		return
+	}
+	p, found := s.proofs[r]
+	var saved memoryProof
+	if found {
+		saved = *p
+	}
+	s.undo[tx] = append(s.undo[tx], func() {
+		if !found {
+			delete(s.proofs, r)
+			return
+		}
+		restored := saved
+		s.proofs[r] = &restored
+	})
+}
+
+// saveRegeneration records how to restore whether the batch is queued to be
+// regenerated, as it is before being changed, if the dbTx is rolled back.
+// s.mu must be held.
+func (s *memoryProofStore) saveRegeneration(dbTx pgx.Tx, batchNumber uint64) {
+	tx, ok := dbTx.(*memoryProofStoreTx)
+	if !ok || tx.store != s {
+		return
+	}
+	_, queued := s.regenerations[batchNumber]
+	s.undo[tx] = append(s.undo[tx], func() {
+		if queued {
+			s.regenerations[batchNumber] = struct{}{}
+		} else {
+			delete(s.regenerations, batchNumber)
+		}
+	})
+}
+
+// sorted returns the stored proofs matching the filter, sorted by their batch
+// range.
+func (s *memoryProofStore) sorted(filter func(p *memoryProof) bool) []*memoryProof {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	proofs := make([]*memoryProof, 0, len(s.proofs))
+	for _, p := range s.proofs {
+		if filter(p) {
+			copied := *p
+			proofs = append(proofs, &copied)
+		}
+	}
+	sort.Slice(proofs, func(i, j int) bool {
+		if proofs[i].proof.BatchNumber != proofs[j].proof.BatchNumber {
+			return proofs[i].proof.BatchNumber < proofs[j].proof.BatchNumber
+		}
+		return proofs[i].proof.BatchNumberFinal < proofs[j].proof.BatchNumberFinal
+	})
+	return proofs
+}
+
+// CheckProofPendingVerification implements stateInterface.
+func (s *memoryProofStore) CheckProofPendingVerification(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.proofs[batchRange{batchNumber: batchNumber, batchNumberFinal: batchNumberFinal}]
+	return ok && !p.verified, nil
+}
+
+// GetProofReadyToVerify implements stateInterface.
+func (s *memoryProofStore) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, preference state.ProofPreference, dbTx pgx.Tx) (*state.Proof, error) {
+	candidates := s.sorted(func(p *memoryProof) bool {
+		return p.proof.BatchNumber == lastVerfiedBatchNumber+1 && !p.proof.Generating && !p.verified
+	})
+	if preference != state.ProofPreferenceSmallest {
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	}
+	for _, p := range candidates {
+		complete, err := s.stateInterface.CheckProofContainsCompleteSequences(ctx, &p.proof, dbTx)
+		if err != nil {
+			return nil, err
+		}
+		if complete {
+			return &p.proof, nil
+		}
+	}
+	return nil, state.ErrNotFound
+}
+
+// GetProofsToAggregate implements stateInterface.
+func (s *memoryProofStore) GetProofsToAggregate(ctx context.Context, maxDepth, maxBatches uint64, dbTx pgx.Tx) (*state.Proof, *state.Proof, error) {
+	candidates := s.sorted(func(p *memoryProof) bool {
+		return !p.proof.Generating && !p.verified && (maxDepth == 0 || p.proof.Depth < maxDepth)
+	})
+	for _, p1 := range candidates {
+		for _, p2 := range candidates {
+			if p2.proof.BatchNumber != p1.proof.BatchNumberFinal+1 || !withinBatches(&p1.proof, &p2.proof, maxBatches) {
+				continue
+			}
+			ok, err := s.canAggregate(ctx, &p1.proof, &p2.proof, dbTx)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				return &p1.proof, &p2.proof, nil
+			}
+		}
+	}
+	return nil, nil, state.ErrNotFound
+}
+
+// canAggregate returns whether the proofs belong to the same sequence or both
+// contain complete sequences.
+func (s *memoryProofStore) canAggregate(ctx context.Context, proof1, proof2 *state.Proof, dbTx pgx.Tx) (bool, error) {
+	sameSequence, err := s.stateInterface.CheckBatchesInSameSequence(ctx, proof1.BatchNumber, proof2.BatchNumberFinal, dbTx)
+	if err != nil || sameSequence {
+		return sameSequence, err
+	}
+	for _, proof := range []*state.Proof{proof1, proof2} {
+		complete, err := s.stateInterface.CheckProofContainsCompleteSequences(ctx, proof, dbTx)
+		if err != nil || !complete {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// GetVirtualBatchToProve implements stateInterface.
+func (s *memoryProofStore) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*state.Batch, error) {
+	for {
+		batch, err := s.stateInterface.GetVirtualBatchToProve(ctx, lastVerfiedBatchNumber, excludedBatchNumbers, dbTx)
+		if err != nil {
+			return nil, err
+		}
+		covering := s.covering(batch.BatchNumber)
+		if covering == nil {
+			return batch, nil
+		}
+		lastVerfiedBatchNumber = covering.batchNumberFinal
+	}
+}
+
+// covering returns the range of a stored proof covering the batch, nil if
+// there is none.
+func (s *memoryProofStore) covering(batchNumber uint64) *batchRange {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for r := range s.proofs {
+		if r.overlaps(batchNumber, batchNumber) {
+			covering := r
+			return &covering
+		}
+	}
+	return nil
+}
+
+// AddGeneratedProof implements stateInterface.
+func (s *memoryProofStore) AddGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}
+	if _, ok := s.proofs[r]; ok {
+		return fmt.Errorf("proof for batches [%d-%d] already stored", proof.BatchNumber, proof.BatchNumberFinal)
+	}
+	s.saveProof(dbTx, r)
+	s.proofs[r] = &memoryProof{proof: *proof}
+	return nil
+}
+
+// UpdateGeneratedProof implements stateInterface.
+func (s *memoryProofStore) UpdateGeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}
+	if p, ok := s.proofs[r]; ok {
+		s.saveProof(dbTx, r)
+		p.proof = *proof
+	}
+	return nil
+}
+
+// DeleteGeneratedProofs implements stateInterface.
+func (s *memoryProofStore) DeleteGeneratedProofs(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for r := range s.proofs {
+		if r.batchNumber >= batchNumber && r.batchNumberFinal <= batchNumberFinal {
+			s.saveProof(dbTx, r)
+			delete(s.proofs, r)
+		}
+	}
+	return nil
+}
+
+// RecoverGeneratingProofs implements stateInterface.
+func (s *memoryProofStore) RecoverGeneratingProofs(ctx context.Context, dbTx pgx.Tx) (uint64, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recovered, deleted uint64
+	for r, p := range s.proofs {
+		if !p.proof.Generating {
+			continue
+		}
+		s.saveProof(dbTx, r)
+		if p.proof.Proof != "" {
+			p.proof.Generating = false
+			recovered++
+			continue
+		}
+		delete(s.proofs, r)
+		deleted++
+	}
+	return recovered, deleted, nil
+}
+
+// MarkProofVerified implements stateInterface.
+func (s *memoryProofStore) MarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := batchRange{batchNumber: batchNumber, batchNumberFinal: batchNumberFinal}
+	if p, ok := s.proofs[r]; ok {
+		s.saveProof(dbTx, r)
+		p.verified = true
+		p.proof.Generating = false
+	}
+	return nil
+}
+
+// UnmarkProofVerified implements stateInterface.
+func (s *memoryProofStore) UnmarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := batchRange{batchNumber: batchNumber, batchNumberFinal: batchNumberFinal}
+	if p, ok := s.proofs[r]; ok {
+		s.saveProof(dbTx, r)
+		p.verified = false
+	}
+	return nil
+}
+
+// GetVerifiedProofs implements stateInterface.
+func (s *memoryProofStore) GetVerifiedProofs(ctx context.Context, dbTx pgx.Tx) ([]*state.Proof, error) {
+	verified := s.sorted(func(p *memoryProof) bool { return p.verified })
+	proofs := make([]*state.Proof, 0, len(verified))
+	for _, p := range verified {
+		proofs = append(proofs, &state.Proof{
+			BatchNumber:      p.proof.BatchNumber,
+			BatchNumberFinal: p.proof.BatchNumberFinal,
+			ProofID:          p.proof.ProofID,
+			Prover:           p.proof.Prover,
+		})
+	}
+	return proofs, nil
+}
+
+// AddProofRegenerations implements stateInterface.
+func (s *memoryProofStore) AddProofRegenerations(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (uint64, error) {
+	var added uint64
+	for n := batchNumber; n <= batchNumberFinal; n++ {
+		_, err := s.stateInterface.GetBatchByNumber(ctx, n, dbTx)
+		if errors.Is(err, state.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return added, err
+		}
+		s.mu.Lock()
+		if _, ok := s.regenerations[n]; !ok {
+			s.saveRegeneration(dbTx, n)
+			s.regenerations[n] = struct{}{}
+			added++
+		}
+		s.mu.Unlock()
+	}
+	return added, nil
+}
+
+// GetBatchToRegenerate implements stateInterface.
+func (s *memoryProofStore) GetBatchToRegenerate(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*state.Batch, error) {
+	excluded := make(map[uint64]bool, len(excludedBatchNumbers))
+	for _, n := range excludedBatchNumbers {
+		excluded[n] = true
+	}
+
+	s.mu.RLock()
+	found := false
+	var batchNumber uint64
+	for n := range s.regenerations {
+		if n > lastVerfiedBatchNumber && !excluded[n] && (!found || n < batchNumber) {
+			batchNumber = n
+			found = true
+		}
+	}
+	s.mu.RUnlock()
+
+	if !found {
+		return nil, state.ErrNotFound
+	}
+	return s.stateInterface.GetBatchByNumber(ctx, batchNumber, dbTx)
+}
+
+// CountProofRegenerations implements stateInterface.
+func (s *memoryProofStore) CountProofRegenerations(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count uint64
+	for n := range s.regenerations {
+		if n > lastVerfiedBatchNumber {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ReplaceRegeneratedProof implements stateInterface.
+func (s *memoryProofStore) ReplaceRegeneratedProof(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for r, p := range s.proofs {
+		if r.overlaps(proof.BatchNumber, proof.BatchNumberFinal) && p.proof.Generating {
+			return state.ErrProofGenerating
+		}
+	}
+	for r := range s.proofs {
+		if r.overlaps(proof.BatchNumber, proof.BatchNumberFinal) {
+			s.saveProof(dbTx, r)
+			delete(s.proofs, r)
+		}
+	}
+	r := batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}
+	s.saveProof(dbTx, r)
+	s.proofs[r] = &memoryProof{proof: *proof}
+	for n := proof.BatchNumber; n <= proof.BatchNumberFinal; n++ {
+		s.saveRegeneration(dbTx, n)
+		delete(s.regenerations, n)
+	}
+	return nil
+}
@@ -0,0 +1,320 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/db"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/0xPolygonHermez/zkevm-node/test/dbutils"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// proofStoreSequences are the sequences the proof store suite runs against.
+var proofStoreSequences = []state.Sequence{
+	{FromBatchNumber: 1, ToBatchNumber: 2},
+	{FromBatchNumber: 3, ToBatchNumber: 3},
+	{FromBatchNumber: 4, ToBatchNumber: 4},
+}
+
+// testProofStore is the suite shared by the proof store backends, proving
+// they behave the same.
+func testProofStore(t *testing.T, store proofStore) {
+	ctx := context.Background()
+	requireAggregable := func(maxDepth uint64, batchNumber1, batchNumber2 uint64) {
+		t.Helper()
+		proof1, proof2, err := store.GetProofsToAggregate(ctx, maxDepth, 0, nil)
+		require.NoError(t, err)
+		assert.Equal(t, batchNumber1, proof1.BatchNumber)
+		assert.Equal(t, batchNumber2, proof2.BatchNumber)
+	}
+
+	_, err := store.GetProofReadyToVerify(ctx, 0, state.ProofPreferenceLargest, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+	_, _, err = store.GetProofsToAggregate(ctx, 0, 0, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 1, Proof: "proof1"}, nil))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 2, BatchNumberFinal: 2, Proof: "proof2"}, nil))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 3, Proof: "proof3"}, nil))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4, Generating: true}, nil))
+	require.Error(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 1, Proof: "proof1"}, nil))
+
+	// proofs of the same sequence are aggregated, but not verified
+	requireAggregable(0, 1, 2)
+	_, err = store.GetProofReadyToVerify(ctx, 0, state.ProofPreferenceLargest, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	require.NoError(t, store.DeleteGeneratedProofs(ctx, 1, 2, nil))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 2, Proof: "proof12", Depth: 1}, nil))
+
+	// proofs of complete sequences are verified and aggregated
+	proof, err := store.GetProofReadyToVerify(ctx, 0, state.ProofPreferenceLargest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), proof.BatchNumberFinal)
+	assert.Equal(t, "proof12", proof.Proof)
+	assert.Equal(t, uint64(1), proof.Depth)
+	requireAggregable(0, 1, 3)
+	_, _, err = store.GetProofsToAggregate(ctx, 1, 0, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	// proofs whose aggregation would span more than the max batches are not
+	// aggregated
+	_, _, err = store.GetProofsToAggregate(ctx, 0, 2, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+	proof1, proof2, err := store.GetProofsToAggregate(ctx, 0, 3, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), proof1.BatchNumber)
+	assert.Equal(t, uint64(3), proof2.BatchNumberFinal)
+
+	// verified proofs are kept, but not verified nor aggregated again
+	pending, err := store.CheckProofPendingVerification(ctx, 1, 2, nil)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	require.NoError(t, store.MarkProofVerified(ctx, 1, 2, nil))
+	pending, err = store.CheckProofPendingVerification(ctx, 1, 2, nil)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	_, err = store.GetProofReadyToVerify(ctx, 0, state.ProofPreferenceLargest, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+	verified, err := store.GetVerifiedProofs(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, verified, 1)
+	assert.Equal(t, uint64(1), verified[0].BatchNumber)
+	assert.Equal(t, uint64(2), verified[0].BatchNumberFinal)
+
+	// a proof whose verification is reorged out is pending verification again
+	require.NoError(t, store.UnmarkProofVerified(ctx, 1, 2, nil))
+	pending, err = store.CheckProofPendingVerification(ctx, 1, 2, nil)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	require.NoError(t, store.MarkProofVerified(ctx, 1, 2, nil))
+
+	// generating proofs are not aggregated until generated
+	_, _, err = store.GetProofsToAggregate(ctx, 0, 0, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+	require.NoError(t, store.UpdateGeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4, Proof: "proof4"}, nil))
+	requireAggregable(0, 3, 4)
+
+	// on boot-up after a crash, the proofs locked once generated are
+	// recovered, the ones never generated are deleted and the verified ones
+	// are kept
+	require.NoError(t, store.UpdateGeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4, Proof: "proof4", Generating: true}, nil))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 5, BatchNumberFinal: 5, InputProver: "input5", Generating: true}, nil))
+	_, _, err = store.GetProofsToAggregate(ctx, 0, 0, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+	recovered, deleted, err := store.RecoverGeneratingProofs(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), recovered)
+	assert.Equal(t, uint64(1), deleted)
+	requireAggregable(0, 3, 4)
+	pending, err = store.CheckProofPendingVerification(ctx, 5, 5, nil)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	verified, err = store.GetVerifiedProofs(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, verified, 1)
+
+	// among the proofs ready to verify the preferred one is returned
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 4, Proof: "proof34", Depth: 1}, nil))
+	proof, err = store.GetProofReadyToVerify(ctx, 2, state.ProofPreferenceLargest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), proof.BatchNumberFinal)
+	proof, err = store.GetProofReadyToVerify(ctx, 2, state.ProofPreferenceSmallest, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), proof.BatchNumberFinal)
+}
+
+// sequencesStateStub serves the sequences and virtual batches to the memory
+// proof store.
+type sequencesStateStub struct {
+	stateInterface
+	sequences      []state.Sequence
+	virtualBatches []uint64
+}
+
+func (s *sequencesStateStub) CheckProofContainsCompleteSequences(ctx context.Context, proof *state.Proof, dbTx pgx.Tx) (bool, error) {
+	var from, to bool
+	for _, sequence := range s.sequences {
+		from = from || sequence.FromBatchNumber == proof.BatchNumber
+		to = to || sequence.ToBatchNumber == proof.BatchNumberFinal
+	}
+	return from && to, nil
+}
+
+func (s *sequencesStateStub) CheckBatchesInSameSequence(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error) {
+	for _, sequence := range s.sequences {
+		if sequence.FromBatchNumber <= batchNumber && sequence.ToBatchNumber >= batchNumberFinal {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *sequencesStateStub) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*state.Batch, error) {
+next:
+	for _, batchNumber := range s.virtualBatches {
+		if batchNumber <= lastVerfiedBatchNumber {
+			continue
+		}
+		for _, excluded := range excludedBatchNumbers {
+			if batchNumber == excluded {
+				continue next
+			}
+		}
+		return &state.Batch{BatchNumber: batchNumber}, nil
+	}
+	return nil, state.ErrNotFound
+}
+
+func (s *sequencesStateStub) GetBatchByNumber(ctx context.Context, batchNumber uint64, dbTx pgx.Tx) (*state.Batch, error) {
+	for _, n := range s.virtualBatches {
+		if n == batchNumber {
+			return &state.Batch{BatchNumber: batchNumber}, nil
+		}
+	}
+	return nil, state.ErrNotFound
+}
+
+func TestMemoryProofStore(t *testing.T) {
+	testProofStore(t, newMemoryProofStore(&sequencesStateStub{sequences: proofStoreSequences}))
+}
+
+func TestPostgresProofStore(t *testing.T) {
+	stateDBCfg := dbutils.NewStateConfigFromEnv()
+	require.NoError(t, dbutils.InitOrResetState(stateDBCfg))
+	sqlDB, err := db.NewSQLDB(stateDBCfg)
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	ctx := context.Background()
+	storage := state.NewPostgresStorage(sqlDB)
+	_, err = storage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1), (2), (3), (4), (5)")
+	require.NoError(t, err)
+	for _, sequence := range proofStoreSequences {
+		require.NoError(t, storage.AddSequence(ctx, sequence, nil))
+	}
+
+	testProofStore(t, storage)
+}
+
+func TestMemoryProofStoreVirtualBatchToProve(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryProofStore(&sequencesStateStub{virtualBatches: []uint64{1, 2, 3, 4}})
+
+	batch, err := store.GetVirtualBatchToProve(ctx, 0, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), batch.BatchNumber)
+
+	// the batches covered by a stored proof are skipped
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 2, Generating: true}, nil))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 3}, nil))
+	batch, err = store.GetVirtualBatchToProve(ctx, 0, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), batch.BatchNumber)
+
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4}, nil))
+	_, err = store.GetVirtualBatchToProve(ctx, 0, nil, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestMemoryProofStoreRegenerations(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryProofStore(&sequencesStateStub{virtualBatches: []uint64{1, 2, 3, 4}})
+
+	// only the existing batches are queued, once
+	queued, err := store.AddProofRegenerations(ctx, 2, 6, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), queued)
+	queued, err = store.AddProofRegenerations(ctx, 1, 3, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), queued)
+	count, err := store.CountProofRegenerations(ctx, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), count)
+
+	batch, err := store.GetBatchToRegenerate(ctx, 1, []uint64{2}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), batch.BatchNumber)
+
+	// the regenerated proof replaces the overlapping ones
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 2, BatchNumberFinal: 3, Proof: "proof23"}, nil))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4, Generating: true}, nil))
+	require.NoError(t, store.ReplaceRegeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 3, Proof: "proof3"}, nil))
+	pending, err := store.CheckProofPendingVerification(ctx, 2, 3, nil)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	pending, err = store.CheckProofPendingVerification(ctx, 3, 3, nil)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	count, err = store.CountProofRegenerations(ctx, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), count)
+
+	// the batch is kept queued while an overlapping proof is locked
+	require.ErrorIs(t, store.ReplaceRegeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4, Proof: "proof4"}, nil), state.ErrProofGenerating)
+	batch, err = store.GetBatchToRegenerate(ctx, 1, []uint64{2}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), batch.BatchNumber)
+
+	// the verified batches are not regenerated
+	_, err = store.GetBatchToRegenerate(ctx, 4, nil, nil)
+	require.ErrorIs(t, err, state.ErrNotFound)
+}
+
+func TestMemoryProofStoreRollback(t *testing.T) {
+	ctx := context.Background()
+	st := mocks.NewStateMock(t)
+	dbTx := mocks.NewDbTxMock(t)
+	store := newMemoryProofStore(st)
+	proof1 := batchRange{batchNumber: 1, batchNumberFinal: 1}
+	proof2 := batchRange{batchNumber: 2, batchNumberFinal: 2}
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 1, Proof: "proof1"}, nil))
+
+	st.On("BeginStateTransaction", ctx).Return(dbTx, nil).Twice()
+	dbTx.On("Rollback", ctx).Return(nil).Twice()
+	dbTx.On("Commit", ctx).Return(nil).Once()
+
+	// the changes of a rolled back dbTx are undone
+	tx, err := store.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateGeneratedProof(ctx, &state.Proof{BatchNumber: 1, BatchNumberFinal: 1, Proof: "proof1", Generating: true}, tx))
+	require.NoError(t, store.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 2, BatchNumberFinal: 2, Generating: true}, tx))
+	require.NoError(t, tx.Rollback(ctx))
+	assert.False(t, store.proofs[proof1].proof.Generating)
+	assert.NotContains(t, store.proofs, proof2)
+
+	// the changes of a committed dbTx are kept
+	tx, err = store.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.MarkProofVerified(ctx, 1, 1, tx))
+	require.NoError(t, tx.Commit(ctx))
+	require.NoError(t, tx.Rollback(ctx))
+	assert.True(t, store.proofs[proof1].verified)
+	assert.Empty(t, store.undo)
+}
+
+func TestNewProofStore(t *testing.T) {
+	st := mocks.NewStateMock(t)
+
+	st.On("Ping", mock.Anything).Return(nil).Once()
+	store, err := newProofStore(ProofStorePostgres, st)
+	require.NoError(t, err)
+	assert.Equal(t, st, store)
+
+	st.On("Ping", mock.Anything).Return(errors.New("connection refused")).Once()
+	_, err = newProofStore(ProofStorePostgres, st)
+	require.Error(t, err)
+
+	store, err = newProofStore(ProofStoreMemory, st)
+	require.NoError(t, err)
+	assert.IsType(t, &memoryProofStore{}, store)
+
+	_, err = newProofStore("redis", st)
+	require.Error(t, err)
+}
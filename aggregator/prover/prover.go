@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
@@ -14,41 +16,104 @@ import (
 )
 
 var (
-	ErrBadProverResponse    = errors.New("Prover returned wrong type for response")  //nolint:revive
-	ErrProverInternalError  = errors.New("Prover returned INTERNAL_ERROR response")  //nolint:revive
-	ErrProverCompletedError = errors.New("Prover returned COMPLETED_ERROR response") //nolint:revive
-	ErrBadRequest           = errors.New("Prover returned ERROR for a bad request")  //nolint:revive
-	ErrUnspecified          = errors.New("Prover returned an UNSPECIFIED response")  //nolint:revive
-	ErrUnknown              = errors.New("Prover returned an unknown response")      //nolint:revive
-	ErrProofCanceled        = errors.New("Proof has been canceled")                  //nolint:revive
+	ErrBadProverResponse    = errors.New("Prover returned wrong type for response")            //nolint:revive
+	ErrProverInternalError  = errors.New("Prover returned INTERNAL_ERROR response")            //nolint:revive
+	ErrProverCompletedError = errors.New("Prover returned COMPLETED_ERROR response")           //nolint:revive
+	ErrBadRequest           = errors.New("Prover returned ERROR for a bad request")            //nolint:revive
+	ErrUnspecified          = errors.New("Prover returned an UNSPECIFIED response")            //nolint:revive
+	ErrUnknown              = errors.New("Prover returned an unknown response")                //nolint:revive
+	ErrProofCanceled        = errors.New("Proof has been canceled")                            //nolint:revive
+	ErrDeadConnection       = errors.New("Prover didn't respond before the call was given up") //nolint:revive
 )
 
+// Capabilities advertised by the provers.
+const (
+	CapabilityBatchProof      = "batch_proof"      //nolint:revive
+	CapabilityAggregatedProof = "aggregated_proof" //nolint:revive
+	CapabilityFinalProof      = "final_proof"      //nolint:revive
+)
+
+// preferencePrefix is the prefix of the capabilities advertised by a prover
+// to state its preference for an operation, e.g. prefer_aggregated_proof.
+const preferencePrefix = "prefer_"
+
 // Prover abstraction of the grpc prover client.
 type Prover struct {
 	id                        string
+	name                      string
+	version                   string
+	forkID                    uint64
+	capabilities              map[string]bool
+	preferences               map[string]bool
 	address                   net.Addr
 	proofStatePollingInterval types.Duration
+	proofTTL                  types.Duration
+	keepaliveInterval         types.Duration
+	keepaliveTimeout          types.Duration
 	stream                    pb.AggregatorService_ChannelServer
+
+	// dead is set once the prover didn't respond within the keepalive
+	// timeout or before the wait was given up, a receive may still be
+	// pending on the stream
+	dead int32
 }
 
-// New returns a new Prover instance.
-func New(stream pb.AggregatorService_ChannelServer, addr net.Addr, proofStatePollingInterval types.Duration) (*Prover, error) {
+// New returns a new Prover instance. While waiting for a proof, the prover is
+// pinged every keepalive interval, and the connection is considered dead once
+// a message isn't responded within the keepalive timeout. A zero keepalive
+// interval disables the keepalive.
+func New(stream pb.AggregatorService_ChannelServer, addr net.Addr, proofStatePollingInterval types.Duration, proofTTL types.Duration, keepaliveInterval types.Duration, keepaliveTimeout types.Duration) (*Prover, error) {
 	p := &Prover{
 		stream:                    stream,
 		address:                   addr,
 		proofStatePollingInterval: proofStatePollingInterval,
+		proofTTL:                  proofTTL,
+		keepaliveInterval:         keepaliveInterval,
+		keepaliveTimeout:          keepaliveTimeout,
 	}
 	status, err := p.Status()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve prover id %w", err)
 	}
 	p.id = status.ProverId
+	p.name = status.ProverName
+	p.version = status.VersionServer
+	p.forkID = status.ForkId
+	p.capabilities = make(map[string]bool, len(status.Capabilities))
+	p.preferences = make(map[string]bool)
+	for _, capability := range status.Capabilities {
+		if strings.HasPrefix(capability, preferencePrefix) {
+			p.preferences[strings.TrimPrefix(capability, preferencePrefix)] = true
+			continue
+		}
+		p.capabilities[capability] = true
+	}
 	return p, nil
 }
 
 // ID returns the Prover ID.
 func (p *Prover) ID() string { return p.id }
 
+// Version returns the server version reported by the prover.
+func (p *Prover) Version() string { return p.version }
+
+// ForkID returns the fork id supported by the prover, 0 means that the prover
+// didn't report any.
+func (p *Prover) ForkID() uint64 { return p.forkID }
+
+// HasCapability returns whether the prover supports the given capability. A
+// prover that didn't advertise any capability is assumed to support all of
+// them.
+func (p *Prover) HasCapability(capability string) bool {
+	return len(p.capabilities) == 0 || p.capabilities[capability]
+}
+
+// Prefers returns whether the prover advertised a preference for the given
+// capability. The preferences don't restrict the capabilities of the prover.
+func (p *Prover) Prefers(capability string) bool {
+	return p.preferences[capability]
+}
+
 // Addr returns the prover IP address.
 func (p *Prover) Addr() string {
 	if p.address == nil {
@@ -57,14 +122,29 @@ func (p *Prover) Addr() string {
 	return p.address.String()
 }
 
+// Name returns the name reported by the prover, like its hostname, or its
+// address if it didn't report any. Along with the ID it tells apart the
+// provers connecting through the same proxy.
+func (p *Prover) Name() string {
+	if p.name == "" {
+		return p.Addr()
+	}
+	return p.name
+}
+
 // Status gets the prover status.
 func (p *Prover) Status() (*pb.GetStatusResponse, error) {
+	return p.status(context.Background())
+}
+
+// status gets the prover status, giving up once the context is done.
+func (p *Prover) status(ctx context.Context) (*pb.GetStatusResponse, error) {
 	req := &pb.AggregatorMessage{
 		Request: &pb.AggregatorMessage_GetStatusRequest{
 			GetStatusRequest: &pb.GetStatusRequest{},
 		},
 	}
-	res, err := p.call(req)
+	res, err := p.call(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -76,12 +156,33 @@ func (p *Prover) Status() (*pb.GetStatusResponse, error) {
 
 // IsIdle returns true if the prover is idling.
 func (p *Prover) IsIdle() bool {
+	idle, _ := p.IdleLoad()
+	return idle
+}
+
+// IdleLoad returns true if the prover is idling, along with its load: the
+// fraction of its memory in use. A prover not reporting its memory is
+// considered fully loaded.
+func (p *Prover) IdleLoad() (bool, float64) {
 	status, err := p.Status()
 	if err != nil {
 		log.Warnf("Error asking status for prover ID %s: %w", p.ID(), err)
-		return false
+		return false, 1
+	}
+	load := 1.0
+	if status.TotalMemory > 0 && status.FreeMemory <= status.TotalMemory {
+		load = 1 - float64(status.FreeMemory)/float64(status.TotalMemory)
+	}
+	return status.Status == pb.GetStatusResponse_IDLE, load
+}
+
+// ttl returns the seconds after which the prover abandons a requested proof,
+// rounded up so a TTL under a second is not taken as no TTL.
+func (p *Prover) ttl() uint64 {
+	if p.proofTTL.Duration <= 0 {
+		return 0
 	}
-	return status.Status == pb.GetStatusResponse_IDLE
+	return uint64((p.proofTTL.Duration + time.Second - 1) / time.Second)
 }
 
 // BatchProof instructs the prover to generate a batch proof for the provided
@@ -91,10 +192,10 @@ func (p *Prover) BatchProof(input *pb.InputProver) (*string, error) {
 
 	req := &pb.AggregatorMessage{
 		Request: &pb.AggregatorMessage_GenBatchProofRequest{
-			GenBatchProofRequest: &pb.GenBatchProofRequest{Input: input},
+			GenBatchProofRequest: &pb.GenBatchProofRequest{Input: input, Ttl: p.ttl()},
 		},
 	}
-	res, err := p.call(req)
+	res, err := p.call(context.Background(), req)
 	if err != nil {
 		return nil, err
 	}
@@ -127,10 +228,11 @@ func (p *Prover) AggregatedProof(inputProof1, inputProof2 string) (*string, erro
 			GenAggregatedProofRequest: &pb.GenAggregatedProofRequest{
 				RecursiveProof_1: inputProof1,
 				RecursiveProof_2: inputProof2,
+				Ttl:              p.ttl(),
 			},
 		},
 	}
-	res, err := p.call(req)
+	res, err := p.call(context.Background(), req)
 	if err != nil {
 		return nil, err
 	}
@@ -167,10 +269,11 @@ func (p *Prover) FinalProof(inputProof string, aggregatorAddr string) (*string,
 			GenFinalProofRequest: &pb.GenFinalProofRequest{
 				RecursiveProof: inputProof,
 				AggregatorAddr: aggregatorAddr,
+				Ttl:            p.ttl(),
 			},
 		},
 	}
-	res, err := p.call(req)
+	res, err := p.call(context.Background(), req)
 	if err != nil {
 		return nil, err
 	}
@@ -204,7 +307,7 @@ func (p *Prover) CancelProofRequest(proofID string) error {
 			CancelRequest: &pb.CancelRequest{Id: proofID},
 		},
 	}
-	res, err := p.call(req)
+	res, err := p.call(context.Background(), req)
 	if err != nil {
 		return err
 	}
@@ -270,14 +373,16 @@ func (p *Prover) waitProof(ctx context.Context, proofID string) (*pb.GetProofRes
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
-			res, err := p.call(req)
+			res, err := p.call(ctx, req)
 			if err != nil {
 				return nil, err
 			}
 			if msg, ok := res.Response.(*pb.ProverMessage_GetProofResponse); ok {
 				switch msg.GetProofResponse.Result {
 				case pb.GetProofResponse_PENDING:
-					time.Sleep(p.proofStatePollingInterval.Duration)
+					if err := p.pause(ctx, p.proofStatePollingInterval.Duration); err != nil {
+						return nil, err
+					}
 					continue
 				case pb.GetProofResponse_UNSPECIFIED:
 					return nil, fmt.Errorf("Failed to get proof ID: %s, %w, prover response: %s",
@@ -306,15 +411,87 @@ func (p *Prover) waitProof(ctx context.Context, proofID string) (*pb.GetProofRes
 	}
 }
 
+// pause waits for the given time, pinging the prover every keepalive interval
+// meanwhile so the stream isn't idle long enough to be dropped by the
+// intermediaries. The pings are status requests, which every prover answers.
+// It returns the context error if the context is done meanwhile.
+func (p *Prover) pause(ctx context.Context, d time.Duration) error {
+	interval := p.keepaliveInterval.Duration
+	for interval > 0 && d > interval {
+		if err := sleep(ctx, interval); err != nil {
+			return err
+		}
+		d -= interval
+		if _, err := p.status(ctx); err != nil {
+			return fmt.Errorf("Failed to ping prover ID %s, %w", p.ID(), err)
+		}
+	}
+	return sleep(ctx, d)
+}
+
+// sleep waits for the given time, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Err returns ErrDeadConnection once the prover didn't respond within the
+// keepalive timeout or before a wait was given up, the stream can't be used
+// anymore.
+func (p *Prover) Err() error {
+	if atomic.LoadInt32(&p.dead) != 0 {
+		return ErrDeadConnection
+	}
+	return nil
+}
+
 // call sends a message to the prover and waits to receive the response over
-// the connection stream.
-func (p *Prover) call(req *pb.AggregatorMessage) (*pb.ProverMessage, error) {
-	if err := p.stream.Send(req); err != nil {
+// the connection stream, up to the keepalive timeout if the keepalive is
+// enabled, and until the context is done. A prover that didn't respond is
+// considered dead, as its response may still arrive in place of the next one.
+func (p *Prover) call(ctx context.Context, req *pb.AggregatorMessage) (*pb.ProverMessage, error) {
+	if err := p.Err(); err != nil {
 		return nil, err
 	}
-	res, err := p.stream.Recv()
-	if err != nil {
+	if err := p.stream.Send(req); err != nil {
 		return nil, err
 	}
-	return res, nil
+	keepalive := p.keepaliveInterval.Duration > 0 && p.keepaliveTimeout.Duration > 0
+	if !keepalive && ctx.Done() == nil {
+		return p.stream.Recv()
+	}
+
+	type response struct {
+		res *pb.ProverMessage
+		err error
+	}
+	// the receive is left pending if the prover doesn't respond, it returns
+	// once the stream is closed
+	resCh := make(chan response, 1)
+	go func() {
+		res, err := p.stream.Recv()
+		resCh <- response{res: res, err: err}
+	}()
+	var timeout <-chan time.Time
+	if keepalive {
+		timer := time.NewTimer(p.keepaliveTimeout.Duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-resCh:
+		return r.res, r.err
+	case <-timeout:
+		atomic.StoreInt32(&p.dead, 1)
+		return nil, ErrDeadConnection
+	case <-ctx.Done():
+		atomic.StoreInt32(&p.dead, 1)
+		return nil, ctx.Err()
+	}
 }
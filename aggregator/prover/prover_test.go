@@ -0,0 +1,243 @@
+package prover
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// ttlProverStream is a prover honoring the TTL of the proof requests: it
+// keeps the proofs pending and abandons them once their TTL expires.
+type ttlProverStream struct {
+	grpc.ServerStream
+
+	ttls     []uint64
+	deadline time.Time
+	pending  *pb.ProverMessage
+}
+
+func (s *ttlProverStream) Send(msg *pb.AggregatorMessage) error {
+	switch req := msg.Request.(type) {
+	case *pb.AggregatorMessage_GetStatusRequest:
+		s.pending = &pb.ProverMessage{Response: &pb.ProverMessage_GetStatusResponse{
+			GetStatusResponse: &pb.GetStatusResponse{ProverId: "prover", VersionServer: "v1.3.0"},
+		}}
+	case *pb.AggregatorMessage_GenBatchProofRequest:
+		ttl := req.GenBatchProofRequest.Ttl
+		s.ttls = append(s.ttls, ttl)
+		s.deadline = time.Time{}
+		if ttl > 0 {
+			s.deadline = time.Now().Add(time.Duration(ttl) * time.Second)
+		}
+		s.pending = &pb.ProverMessage{Response: &pb.ProverMessage_GenBatchProofResponse{
+			GenBatchProofResponse: &pb.GenBatchProofResponse{Id: "proof", Result: pb.Result_OK},
+		}}
+	case *pb.AggregatorMessage_GetProofRequest:
+		result := pb.GetProofResponse_PENDING
+		if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+			result = pb.GetProofResponse_CANCEL
+		}
+		s.pending = &pb.ProverMessage{Response: &pb.ProverMessage_GetProofResponse{
+			GetProofResponse: &pb.GetProofResponse{Id: req.GetProofRequest.Id, Result: result},
+		}}
+	default:
+		return errors.New("unexpected request")
+	}
+	return nil
+}
+
+func (s *ttlProverStream) Recv() (*pb.ProverMessage, error) {
+	return s.pending, nil
+}
+
+func TestProofTTL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		proofTTL time.Duration
+		ttl      uint64
+	}{
+		{name: "no TTL", proofTTL: 0, ttl: 0},
+		{name: "whole seconds", proofTTL: time.Second, ttl: 1},
+		{name: "rounded up", proofTTL: 1500 * time.Millisecond, ttl: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stream := &ttlProverStream{}
+			p, err := New(stream, nil, types.NewDuration(10*time.Millisecond), types.NewDuration(tc.proofTTL), types.Duration{}, types.Duration{})
+			require.NoError(t, err)
+
+			_, err = p.BatchProof(&pb.InputProver{})
+			require.NoError(t, err)
+			assert.Equal(t, []uint64{tc.ttl}, stream.ttls)
+		})
+	}
+}
+
+func TestProofAbandonedOnTTL(t *testing.T) {
+	stream := &ttlProverStream{}
+	p, err := New(stream, nil, types.NewDuration(10*time.Millisecond), types.NewDuration(time.Second), types.Duration{}, types.Duration{})
+	require.NoError(t, err)
+
+	proofID, err := p.BatchProof(&pb.InputProver{})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = p.WaitRecursiveProof(context.Background(), *proofID)
+	require.ErrorIs(t, err, ErrProofCanceled)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+}
+
+func TestVersion(t *testing.T) {
+	p, err := New(&ttlProverStream{}, nil, types.NewDuration(10*time.Millisecond), types.Duration{}, types.Duration{}, types.Duration{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "prover", p.ID())
+	assert.Equal(t, "v1.3.0", p.Version())
+}
+
+// statusProverStream is a prover replying to the status requests only.
+type statusProverStream struct {
+	grpc.ServerStream
+
+	status *pb.GetStatusResponse
+}
+
+func (s *statusProverStream) Send(msg *pb.AggregatorMessage) error {
+	if _, ok := msg.Request.(*pb.AggregatorMessage_GetStatusRequest); !ok {
+		return errors.New("unexpected request")
+	}
+	return nil
+}
+
+func (s *statusProverStream) Recv() (*pb.ProverMessage, error) {
+	return &pb.ProverMessage{Response: &pb.ProverMessage_GetStatusResponse{GetStatusResponse: s.status}}, nil
+}
+
+func TestName(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 50071}
+
+	stream := &statusProverStream{status: &pb.GetStatusResponse{ProverId: "prover", ProverName: "prover-host-1"}}
+	p, err := New(stream, addr, types.NewDuration(10*time.Millisecond), types.Duration{}, types.Duration{}, types.Duration{})
+	require.NoError(t, err)
+	assert.Equal(t, "prover-host-1", p.Name())
+
+	// falls back to the address if the prover doesn't report its name
+	stream = &statusProverStream{status: &pb.GetStatusResponse{ProverId: "prover"}}
+	p, err = New(stream, addr, types.NewDuration(10*time.Millisecond), types.Duration{}, types.Duration{}, types.Duration{})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:50071", p.Name())
+}
+
+func TestIdleLoad(t *testing.T) {
+	stream := &statusProverStream{status: &pb.GetStatusResponse{ProverId: "prover"}}
+	p, err := New(stream, nil, types.NewDuration(10*time.Millisecond), types.Duration{}, types.Duration{}, types.Duration{})
+	require.NoError(t, err)
+
+	stream.status = &pb.GetStatusResponse{Status: pb.GetStatusResponse_IDLE, TotalMemory: 64, FreeMemory: 48}
+	idle, load := p.IdleLoad()
+	assert.True(t, idle)
+	assert.Equal(t, 0.25, load)
+
+	stream.status = &pb.GetStatusResponse{Status: pb.GetStatusResponse_COMPUTING, TotalMemory: 64, FreeMemory: 16}
+	idle, load = p.IdleLoad()
+	assert.False(t, idle)
+	assert.Equal(t, 0.75, load)
+
+	// the memory is not reported
+	stream.status = &pb.GetStatusResponse{Status: pb.GetStatusResponse_IDLE}
+	idle, load = p.IdleLoad()
+	assert.True(t, idle)
+	assert.Equal(t, 1.0, load)
+}
+
+// keepaliveProverStream is a prover keeping its proof pending, counting the
+// pings, that stops responding once unresponsive is set.
+type keepaliveProverStream struct {
+	ttlProverStream
+
+	pings        int
+	unresponsive bool
+	closed       chan struct{}
+}
+
+func (s *keepaliveProverStream) Send(msg *pb.AggregatorMessage) error {
+	if _, ok := msg.Request.(*pb.AggregatorMessage_GetStatusRequest); ok {
+		s.pings++
+	}
+	return s.ttlProverStream.Send(msg)
+}
+
+func (s *keepaliveProverStream) Recv() (*pb.ProverMessage, error) {
+	if s.unresponsive {
+		<-s.closed
+		return nil, context.Canceled
+	}
+	return s.ttlProverStream.Recv()
+}
+
+func TestKeepalive(t *testing.T) {
+	stream := &keepaliveProverStream{closed: make(chan struct{})}
+	defer close(stream.closed)
+	p, err := New(stream, nil, types.NewDuration(100*time.Millisecond), types.Duration{}, types.NewDuration(20*time.Millisecond), types.NewDuration(50*time.Millisecond))
+	require.NoError(t, err)
+	proofID, err := p.BatchProof(&pb.InputProver{})
+	require.NoError(t, err)
+
+	// the prover is pinged while the proof is pending
+	stream.pings = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	_, err = p.WaitRecursiveProof(ctx, *proofID)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, stream.pings, 4)
+	require.NoError(t, p.Err())
+
+	// a prover not responding is detected as dead
+	stream.unresponsive = true
+	start := time.Now()
+	_, err = p.WaitRecursiveProof(context.Background(), *proofID)
+	require.ErrorIs(t, err, ErrDeadConnection)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, p.Err(), ErrDeadConnection)
+
+	// the stream isn't used anymore
+	_, err = p.BatchProof(&pb.InputProver{})
+	assert.ErrorIs(t, err, ErrDeadConnection)
+}
+
+func TestWaitProofContextDone(t *testing.T) {
+	// the keepalive is disabled, the wait is only bounded by the context
+	stream := &keepaliveProverStream{closed: make(chan struct{})}
+	defer close(stream.closed)
+	p, err := New(stream, nil, types.NewDuration(time.Hour), types.Duration{}, types.Duration{}, types.Duration{})
+	require.NoError(t, err)
+	proofID, err := p.BatchProof(&pb.InputProver{})
+	require.NoError(t, err)
+
+	// the context is done while pausing between polls
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = p.WaitRecursiveProof(ctx, *proofID)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	require.NoError(t, p.Err())
+
+	// the context is done while the prover never responds
+	stream.unresponsive = true
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = p.WaitRecursiveProof(ctx, *proofID)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, p.Err(), ErrDeadConnection)
+}
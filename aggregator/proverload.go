@@ -0,0 +1,127 @@
+package aggregator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// proverLoadMaxAgeRetries is the number of retry times after which the load
+// reported by an idle prover is not taken into account anymore, in case its
+// loop is stuck.
+const proverLoadMaxAgeRetries = 3
+
+// proverLoad is the load reported by an idle prover.
+type proverLoad struct {
+	prover     proverInterface
+	load       float64
+	reportedAt time.Time
+}
+
+// proverLoads tracks the load of the idle provers, so new work is assigned to
+// the least loaded one able to perform it.
+type proverLoads struct {
+	mu    sync.RWMutex
+	loads map[string]proverLoad
+}
+
+func newProverLoads() *proverLoads {
+	return &proverLoads{
+		loads: make(map[string]proverLoad),
+	}
+}
+
+// report records the prover as idle with the given load. It's safe to call
+// it on a nil tracker.
+func (l *proverLoads) report(p proverInterface, load float64, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.loads[p.ID()] = proverLoad{prover: p, load: load, reportedAt: now}
+}
+
+// forget removes the prover, once busy or disconnected.
+func (l *proverLoads) forget(proverID string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.loads, proverID)
+}
+
+// idle returns the loads of the idle provers reported since the given time.
+func (l *proverLoads) idle(since time.Time) []proverLoad {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	loads := make([]proverLoad, 0, len(l.loads))
+	for _, load := range l.loads {
+		if !load.reportedAt.Before(since) {
+			loads = append(loads, load)
+		}
+	}
+	return loads
+}
+
+// lessLoaded returns whether the first load is lower than the second one,
+// the ties broken by prover id so a single prover is the least loaded.
+func lessLoaded(l1, l2 proverLoad) bool {
+	if l1.load != l2.load {
+		return l1.load < l2.load
+	}
+	return l1.prover.ID() < l2.prover.ID()
+}
+
+// reportProverLoad records the load of the idle prover when the provers are
+// selected by their load.
+func (a *Aggregator) reportProverLoad(p proverInterface, load float64) {
+	if a.cfg.ProverSelection != ProverSelectionLeastLoaded {
+		return
+	}
+	a.proverLoads.report(p, load, time.Now())
+}
+
+// deferToLessLoadedProver returns whether the operation must be left for an
+// idle prover less loaded than the given one and able to perform it.
+func (a *Aggregator) deferToLessLoadedProver(p proverInterface, op ChannelOperation) bool {
+	if a.cfg.ProverSelection != ProverSelectionLeastLoaded {
+		return false
+	}
+
+	loads := a.proverLoads.idle(time.Now().Add(-proverLoadMaxAgeRetries * a.cfg.RetryTime.Duration))
+	var own *proverLoad
+	for i := range loads {
+		if loads[i].prover.ID() == p.ID() {
+			own = &loads[i]
+			break
+		}
+	}
+	if own == nil {
+		return false
+	}
+
+	capability := operationCapabilities[op]
+	for _, other := range loads {
+		if other.prover.ID() == p.ID() ||
+			!other.prover.HasCapability(capability) ||
+			other.prover.ForkID() != p.ForkID() ||
+			a.assignments.isAssigned(other.prover.ID()) {
+			continue
+		}
+		if lessLoaded(other, *own) {
+			log.Debugf("Operation %s left for prover [%s] with load %.2f, prover { ID [%s], name [%s] } with load %.2f not used",
+				op, other.prover.ID(), other.load, p.ID(), p.Name(), own.load)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/prover"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newLoadedProver(t *testing.T, id string, capabilities ...string) *mocks.ProverMock {
+	p := mocks.NewProverMock(t)
+	p.On("ID").Return(id).Maybe()
+	p.On("Addr").Return("addr").Maybe()
+	p.On("Name").Return("addr").Maybe()
+	p.On("ForkID").Return(uint64(0)).Maybe()
+	if len(capabilities) == 0 {
+		p.On("HasCapability", mock.Anything).Return(true).Maybe()
+	}
+	for _, capability := range capabilities {
+		p.On("HasCapability", capability).Return(true).Maybe()
+	}
+	p.On("HasCapability", mock.Anything).Return(false).Maybe()
+	return p
+}
+
+func TestDeferToLessLoadedProver(t *testing.T) {
+	a := Aggregator{
+		cfg: Config{
+			RetryTime:       types.NewDuration(time.Second),
+			ProverSelection: ProverSelectionLeastLoaded,
+		},
+		assignments: newProverAssignments(),
+		proverLoads: newProverLoads(),
+	}
+	light := newLoadedProver(t, "prover-1", prover.CapabilityBatchProof)
+	medium := newLoadedProver(t, "prover-2")
+	heavy := newLoadedProver(t, "prover-3")
+	a.reportProverLoad(light, 0.1)
+	a.reportProverLoad(medium, 0.5)
+	a.reportProverLoad(heavy, 0.9)
+
+	// the batches are proven by the least loaded prover
+	assert.False(t, a.deferToLessLoadedProver(light, ChannelOperationGenerateBatchProof))
+	assert.True(t, a.deferToLessLoadedProver(medium, ChannelOperationGenerateBatchProof))
+	assert.True(t, a.deferToLessLoadedProver(heavy, ChannelOperationGenerateBatchProof))
+
+	// the aggregations by the least loaded prover able to aggregate
+	assert.False(t, a.deferToLessLoadedProver(medium, ChannelOperationAggregateProofs))
+	assert.True(t, a.deferToLessLoadedProver(heavy, ChannelOperationAggregateProofs))
+
+	// falls back when the least loaded prover is busy
+	a.assignments.assign(light, ChannelOperationGenerateBatchProof, 1, 1)
+	assert.False(t, a.deferToLessLoadedProver(medium, ChannelOperationGenerateBatchProof))
+	assert.True(t, a.deferToLessLoadedProver(heavy, ChannelOperationGenerateBatchProof))
+	a.assignments.clear(light)
+
+	// and when it disconnects
+	a.proverLoads.forget("prover-1")
+	assert.False(t, a.deferToLessLoadedProver(medium, ChannelOperationGenerateBatchProof))
+
+	// ties are broken by prover id
+	a.reportProverLoad(heavy, 0.5)
+	assert.False(t, a.deferToLessLoadedProver(medium, ChannelOperationGenerateBatchProof))
+	assert.True(t, a.deferToLessLoadedProver(heavy, ChannelOperationGenerateBatchProof))
+
+	// disabled
+	a.cfg.ProverSelection = ProverSelectionFirstCome
+	assert.False(t, a.deferToLessLoadedProver(heavy, ChannelOperationGenerateBatchProof))
+}
+
+func TestDeferToLessLoadedProverStaleLoad(t *testing.T) {
+	a := Aggregator{
+		cfg: Config{
+			RetryTime:       types.NewDuration(time.Second),
+			ProverSelection: ProverSelectionLeastLoaded,
+		},
+		assignments: newProverAssignments(),
+		proverLoads: newProverLoads(),
+	}
+	light := newLoadedProver(t, "prover-1")
+	heavy := newLoadedProver(t, "prover-2")
+
+	// the light prover hasn't reported its load for a while
+	a.proverLoads.report(light, 0.1, time.Now().Add(-proverLoadMaxAgeRetries*time.Second-time.Second))
+	a.reportProverLoad(heavy, 0.9)
+	assert.False(t, a.deferToLessLoadedProver(heavy, ChannelOperationGenerateBatchProof))
+
+	a.reportProverLoad(light, 0.1)
+	assert.True(t, a.deferToLessLoadedProver(heavy, ChannelOperationGenerateBatchProof))
+}
+
+func TestNewProverSelection(t *testing.T) {
+	a, err := New(Config{ProofStore: ProofStoreMemory}, mocks.NewStateMock(t), mocks.NewEthTxManager(t), mocks.NewEtherman(t), nil)
+	require.NoError(t, err)
+	assert.Equal(t, ProverSelectionFirstCome, a.cfg.ProverSelection)
+
+	_, err = New(Config{ProofStore: ProofStoreMemory, ProverSelection: "random"}, mocks.NewStateMock(t), mocks.NewEthTxManager(t), mocks.NewEtherman(t), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package aggregator
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// quietPeriodElapsed returns whether the node has been synced for the startup
+// quiet period, so the proofs can be verified.
+func (a *Aggregator) quietPeriodElapsed() bool {
+	return a.cfg.StartupQuietPeriod.Duration <= 0 || atomic.LoadInt32(&a.quietPeriodOver) == 1
+}
+
+// runQuietPeriod checks every retry time whether the node is synced, until it
+// has been synced without interruption for the startup quiet period.
+func (a *Aggregator) runQuietPeriod(ctx context.Context, synced func(ctx context.Context) bool) {
+	period := a.cfg.StartupQuietPeriod.Duration
+	log.Infof("Holding the verification of proofs until synced for %v", period)
+
+	var syncedSince time.Time
+	for {
+		if synced(ctx) {
+			if syncedSince.IsZero() {
+				syncedSince = time.Now()
+			}
+			if time.Since(syncedSince) >= period {
+				atomic.StoreInt32(&a.quietPeriodOver, 1)
+				log.Info("Startup quiet period elapsed, proofs can be verified")
+				return
+			}
+		} else {
+			// the node must be synced for the whole period
+			syncedSince = time.Time{}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.cfg.RetryTime.Duration):
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunQuietPeriod(t *testing.T) {
+	a := Aggregator{
+		cfg: Config{
+			RetryTime:          types.NewDuration(time.Millisecond),
+			StartupQuietPeriod: types.NewDuration(20 * time.Millisecond),
+		},
+	}
+	assert.False(t, a.quietPeriodElapsed())
+
+	// the node loses sync after a while, so the period starts over
+	checks := 0
+	var resyncedAt time.Time
+	synced := func(ctx context.Context) bool {
+		checks++
+		if checks == 5 {
+			resyncedAt = time.Now()
+			return false
+		}
+		return true
+	}
+
+	a.runQuietPeriod(context.Background(), synced)
+	assert.True(t, a.quietPeriodElapsed())
+	assert.GreaterOrEqual(t, time.Since(resyncedAt), 20*time.Millisecond)
+
+	a.cfg.StartupQuietPeriod = types.NewDuration(0)
+	a.quietPeriodOver = 0
+	assert.True(t, a.quietPeriodElapsed())
+}
@@ -0,0 +1,152 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"golang.org/x/time/rate"
+)
+
+// l1RateLimiter is a token bucket shared by all the L1 calls of the
+// aggregator.
+type l1RateLimiter struct {
+	limiter *rate.Limiter
+	maxWait time.Duration
+}
+
+func newL1RateLimiter(cfg L1RateLimitConfig) *l1RateLimiter {
+	burst := cfg.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return &l1RateLimiter{
+		limiter: rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), burst),
+		maxWait: cfg.MaxWait.Duration,
+	}
+}
+
+// wait blocks until the call is allowed by the rate limit, failing if it
+// can't be allowed before the max wait time.
+func (l *l1RateLimiter) wait(ctx context.Context) error {
+	if l.maxWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.maxWait)
+		defer cancel()
+	}
+	start := time.Now()
+	err := l.limiter.Wait(ctx)
+	metrics.L1RateLimiterWait(time.Since(start))
+	if err != nil {
+		return fmt.Errorf("Failed to wait for the L1 rate limiter, %w", err)
+	}
+	return nil
+}
+
+// rateLimitedEtherman is an etherman wrapper that rate limits the calls to L1.
+type rateLimitedEtherman struct {
+	etherman
+	limiter *l1RateLimiter
+}
+
+// CheckReorg implements etherman.
+func (e *rateLimitedEtherman) CheckReorg(batchNumber uint64, stateRoot common.Hash) (bool, error) {
+	if err := e.limiter.wait(context.Background()); err != nil {
+		return false, err
+	}
+	return e.etherman.CheckReorg(batchNumber, stateRoot)
+}
+
+// GetAggregatorReward implements etherman.
+func (e *rateLimitedEtherman) GetAggregatorReward() (*big.Int, error) {
+	if err := e.limiter.wait(context.Background()); err != nil {
+		return nil, err
+	}
+	return e.etherman.GetAggregatorReward()
+}
+
+// GetL1ChainID implements etherman.
+func (e *rateLimitedEtherman) GetL1ChainID(ctx context.Context) (uint64, error) {
+	if err := e.limiter.wait(ctx); err != nil {
+		return 0, err
+	}
+	return e.etherman.GetL1ChainID(ctx)
+}
+
+// GetL2ChainID implements etherman.
+func (e *rateLimitedEtherman) GetL2ChainID() (uint64, error) {
+	if err := e.limiter.wait(context.Background()); err != nil {
+		return 0, err
+	}
+	return e.etherman.GetL2ChainID()
+}
+
+// GetLatestBlockNumber implements etherman.
+func (e *rateLimitedEtherman) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	if err := e.limiter.wait(ctx); err != nil {
+		return 0, err
+	}
+	return e.etherman.GetLatestBlockNumber(ctx)
+}
+
+// GetLatestVerifiedBatchNum implements etherman.
+func (e *rateLimitedEtherman) GetLatestVerifiedBatchNum() (uint64, error) {
+	if err := e.limiter.wait(context.Background()); err != nil {
+		return 0, err
+	}
+	return e.etherman.GetLatestVerifiedBatchNum()
+}
+
+// GetTxReceipt implements etherman.
+func (e *rateLimitedEtherman) GetTxReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if err := e.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return e.etherman.GetTxReceipt(ctx, txHash)
+}
+
+// GetVerifiedBatchStateRoot implements etherman.
+func (e *rateLimitedEtherman) GetVerifiedBatchStateRoot(batchNumber uint64) (common.Hash, error) {
+	if err := e.limiter.wait(context.Background()); err != nil {
+		return common.Hash{}, err
+	}
+	return e.etherman.GetVerifiedBatchStateRoot(batchNumber)
+}
+
+// GetPublicAddress is not rate limited as the address is known locally.
+
+// rateLimitedEthTxManager is an ethTxManager wrapper that rate limits the
+// submissions to L1.
+type rateLimitedEthTxManager struct {
+	ethTxManager
+	limiter *l1RateLimiter
+}
+
+// VerifyBatches implements ethTxManager.
+func (m *rateLimitedEthTxManager) VerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error) {
+	if err := m.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.ethTxManager.VerifyBatches(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
+}
+
+// EstimateVerifyBatches implements ethTxManager.
+func (m *rateLimitedEthTxManager) EstimateVerifyBatches(ctx context.Context, lastVerifiedBatch uint64, batchNum uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error) {
+	if err := m.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.ethTxManager.EstimateVerifyBatches(ctx, lastVerifiedBatch, batchNum, inputs, gasPrice)
+}
+
+// CancelTx implements ethTxManager.
+func (m *rateLimitedEthTxManager) CancelTx(ctx context.Context, txHash common.Hash) (*types.Transaction, error) {
+	if err := m.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	return m.ethTxManager.CancelTx(ctx, txHash)
+}
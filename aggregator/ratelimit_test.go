@@ -0,0 +1,51 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedEtherman(t *testing.T) {
+	eth := mocks.NewEtherman(t)
+	e := &rateLimitedEtherman{
+		etherman: eth,
+		limiter: newL1RateLimiter(L1RateLimitConfig{
+			RequestsPerSecond: 10,
+			Burst:             1,
+			MaxWait:           types.NewDuration(time.Second),
+		}),
+	}
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(1), nil).Twice()
+
+	start := time.Now()
+	_, err := e.GetLatestVerifiedBatchNum()
+	require.NoError(t, err)
+	// the second call waits for the next token
+	_, err = e.GetLatestVerifiedBatchNum()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestRateLimitedEthermanMaxWait(t *testing.T) {
+	eth := mocks.NewEtherman(t)
+	e := &rateLimitedEtherman{
+		etherman: eth,
+		limiter: newL1RateLimiter(L1RateLimitConfig{
+			RequestsPerSecond: 0.1,
+			Burst:             1,
+			MaxWait:           types.NewDuration(10 * time.Millisecond),
+		}),
+	}
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(1), nil).Once()
+
+	_, err := e.GetLatestVerifiedBatchNum()
+	require.NoError(t, err)
+	// the next token is not available before the max wait
+	_, err = e.GetLatestVerifiedBatchNum()
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/events"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// ErrInvalidRegenerationRange is returned when the range of batches to
+// regenerate is empty or reversed.
+var ErrInvalidRegenerationRange = errors.New("invalid range of batches to regenerate")
+
+// operationsOrder returns the configured channel operations order with, if
+// enabled, the proof regeneration placed before the batch proof generation
+// when its priority is high, and after it otherwise.
+func (a *Aggregator) operationsOrder() []ChannelOperation {
+	if !a.cfg.ProofRegeneration.Enabled {
+		return a.cfg.ChannelOperationsOrder
+	}
+	high := a.cfg.ProofRegeneration.Priority == ProofRegenerationPriorityHigh
+	ops := make([]ChannelOperation, 0, len(a.cfg.ChannelOperationsOrder)+1)
+	for _, op := range a.cfg.ChannelOperationsOrder {
+		if op == ChannelOperationGenerateBatchProof && high {
+			ops = append(ops, ChannelOperationRegenerateProof)
+		}
+		ops = append(ops, op)
+		if op == ChannelOperationGenerateBatchProof && !high {
+			ops = append(ops, ChannelOperationRegenerateProof)
+		}
+	}
+	return ops
+}
+
+// EnqueueProofRegeneration queues the batches in the range for their proofs
+// to be regenerated, skipping the ones already verified. It returns the
+// number of batches queued, the ones already queued are not counted.
+func (a *Aggregator) EnqueueProofRegeneration(ctx context.Context, batchNumber, batchNumberFinal uint64) (uint64, error) {
+	if batchNumber == 0 || batchNumber > batchNumberFinal {
+		return 0, fmt.Errorf("%w [%d-%d]", ErrInvalidRegenerationRange, batchNumber, batchNumberFinal)
+	}
+
+	lastVerifiedBatch, err := a.State.GetLastVerifiedBatch(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get last verified batch, %w", err)
+	}
+	if batchNumber <= lastVerifiedBatch.BatchNumber {
+		batchNumber = lastVerifiedBatch.BatchNumber + 1
+	}
+	if batchNumber > batchNumberFinal {
+		log.Infof("Batches up to %d already verified, no proof queued for regeneration", lastVerifiedBatch.BatchNumber)
+		return 0, nil
+	}
+
+	queued, err := a.State.AddProofRegenerations(ctx, batchNumber, batchNumberFinal, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to queue the proofs of batches [%d-%d] for regeneration, %w", batchNumber, batchNumberFinal, err)
+	}
+	log.Infof("%d batches in [%d-%d] queued for their proofs to be regenerated", queued, batchNumber, batchNumberFinal)
+
+	a.reportRegenerationQueueDepth(ctx)
+	return queued, nil
+}
+
+// regenerationQueueDepth returns the number of batches above the last
+// verified batch queued for their proofs to be regenerated.
+func (a *Aggregator) regenerationQueueDepth(ctx context.Context) (uint64, error) {
+	lastVerifiedBatch, err := a.State.GetLastVerifiedBatch(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get last verified batch, %w", err)
+	}
+	return a.State.CountProofRegenerations(ctx, lastVerifiedBatch.BatchNumber, nil)
+}
+
+// reportRegenerationQueueDepth sets the regeneration queue depth metric.
+func (a *Aggregator) reportRegenerationQueueDepth(ctx context.Context) {
+	depth, err := a.regenerationQueueDepth(ctx)
+	if err != nil {
+		log.Errorf("Failed to get the proof regeneration queue depth, err: %v", err)
+		return
+	}
+	metrics.ProofRegenerationQueueDepth(depth)
+}
+
+// getAndClaimBatchToRegenerate returns the next queued batch whose proof must
+// be regenerated, claimed for the prover so no other prover proves it
+// meanwhile.
+func (a *Aggregator) getAndClaimBatchToRegenerate(ctx context.Context, prover proverInterface) (*state.Batch, error) {
+	if a.batchClaims.full() {
+		log.Debugf("Max number of batches being proven reached, prover { ID [%s], name [%s] } not used", prover.ID(), prover.Name())
+		return nil, state.ErrNotFound
+	}
+
+	lastVerifiedBatch, err := a.State.GetLastVerifiedBatch(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	a.StateDBMutex.Lock()
+	batch, err := a.State.GetBatchToRegenerate(ctx, lastVerifiedBatch.BatchNumber, a.batchClaims.excluded(), nil)
+	if err == nil && !a.batchClaims.claim(batch.BatchNumber, prover.ID()) {
+		// the max number of batches being proven was reached meanwhile
+		err = state.ErrNotFound
+	}
+	a.StateDBMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	forkID := a.forkIDForBatch(batch.BatchNumber)
+	if prover.ForkID() != 0 && prover.ForkID() != forkID {
+		// leave the batch for a prover supporting its fork id
+		log.Infof("Prover { ID [%s], name [%s] } supports fork id %d, batch %d requires fork id %d",
+			prover.ID(), prover.Name(), prover.ForkID(), batch.BatchNumber, forkID)
+		a.batchClaims.release(batch.BatchNumber)
+		return nil, state.ErrNotFound
+	}
+	return batch, nil
+}
+
+// tryRegenerateProof regenerates the proof of the next queued batch with the
+// prover. The regenerated proof replaces the stored proofs overlapping the
+// batch, and the batch is removed from the queue, in the same db transaction.
+// The batch is kept queued if the proof can't be regenerated or any of the
+// overlapping proofs is locked once regenerated. The batches of the replaced
+// aggregated proofs outside the queue are proven again as new batches.
+func (a *Aggregator) tryRegenerateProof(ctx context.Context, prover proverInterface) (bool, error) {
+	log.Debugf("tryRegenerateProof start prover { ID [%s], name [%s] }", prover.ID(), prover.Name())
+
+	batchToProve, err := a.getAndClaimBatchToRegenerate(ctx, prover)
+	if errors.Is(err, state.ErrNotFound) {
+		log.Debug("Nothing to regenerate")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer a.batchClaims.release(batchToProve.BatchNumber)
+
+	log.Infof("Prover { ID [%s], name [%s] } is going to be used to regenerate the proof of batch [%d]", prover.ID(), prover.Name(), batchToProve.BatchNumber)
+	a.assignments.assign(prover, ChannelOperationRegenerateProof, batchToProve.BatchNumber, batchToProve.BatchNumber)
+
+	proverID := prover.ID()
+	proof := &state.Proof{
+		BatchNumber:      batchToProve.BatchNumber,
+		BatchNumberFinal: batchToProve.BatchNumber,
+		Prover:           &proverID,
+	}
+
+	inputProver, err := a.buildInputProver(ctx, batchToProve)
+	if err != nil {
+		return false, fmt.Errorf("Failed to build input prover, %w", err)
+	}
+
+	proof.InputProver, err = a.serializeInputProver(ctx, inputProver)
+	if err != nil {
+		return false, fmt.Errorf("Failed to serialize input prover, %w", err)
+	}
+
+	if a.cfg.ProofCommitments {
+		proof.Commitment = batchCommitment(batchToProve)
+	}
+
+	proof.ProofID, err = prover.BatchProof(inputProver)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get batch proof id %w", err)
+	}
+	log.Infof("Proof ID for the regeneration of batch %d: %v", proof.BatchNumber, *proof.ProofID)
+	a.publishEvent(events.EventProofStarted, proof.BatchNumber, proof.BatchNumberFinal, prover.ID())
+
+	waitCtx, cancel := proofTimeoutContext(ctx, a.proofTimeout(a.cfg.BatchProofTimeout.Duration))
+	defer cancel()
+	start := time.Now()
+	proof.Proof, err = prover.WaitRecursiveProof(waitCtx, *proof.ProofID)
+	metrics.ProofWaited(metrics.ProofTypeBatch, proverIdentity(prover), time.Since(start), err == nil && !isEmptyRecursiveProof(proof.Proof))
+	if err != nil {
+		return false, fmt.Errorf("Failed to get proof from prover %w", checkProofTimeout(ctx, waitCtx, err))
+	}
+	if isEmptyRecursiveProof(proof.Proof) {
+		return false, fmt.Errorf("Failed to get proof %s from prover, %w", *proof.ProofID, ErrEmptyProof)
+	}
+
+	// the prover is done, store its result even if the prover disconnects
+	err = a.replaceRegeneratedProof(a.serverContext(), proof)
+	if errors.Is(err, state.ErrProofGenerating) {
+		log.Infof("Proof of batch %d not replaced, an overlapping proof is locked, the batch is kept queued", proof.BatchNumber)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	log.Infof("Proof of batch %d regenerated", proof.BatchNumber)
+	a.publishEvent(events.EventProofGenerated, proof.BatchNumber, proof.BatchNumberFinal, prover.ID())
+	a.reportRegenerationQueueDepth(ctx)
+	return true, nil
+}
+
+// replaceRegeneratedProof stores the regenerated proof replacing the ones
+// overlapping it atomically.
+func (a *Aggregator) replaceRegeneratedProof(ctx context.Context, proof *state.Proof) error {
+	a.StateDBMutex.Lock()
+	defer a.StateDBMutex.Unlock()
+
+	dbTx, err := a.State.BeginStateTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction to replace the regenerated proof, %w", err)
+	}
+	err = a.State.ReplaceRegeneratedProof(ctx, proof, dbTx)
+	if err != nil {
+		dbTx.Rollback(ctx) //nolint:errcheck
+		return fmt.Errorf("Failed to replace the regenerated proof of batches [%d-%d], %w", proof.BatchNumber, proof.BatchNumberFinal, err)
+	}
+	if err := dbTx.Commit(ctx); err != nil {
+		return fmt.Errorf("Failed to commit the regenerated proof of batches [%d-%d], %w", proof.BatchNumber, proof.BatchNumberFinal, err)
+	}
+	return nil
+}
+
+// proofRegenerationRequest is the range of batches to regenerate posted to
+// the admin endpoint.
+type proofRegenerationRequest struct {
+	BatchNumber      uint64 `json:"batchNumber"`
+	BatchNumberFinal uint64 `json:"batchNumberFinal"`
+}
+
+// proofRegenerationStatus is the response of the admin endpoint.
+type proofRegenerationStatus struct {
+	Queued uint64 `json:"queued"`
+	Depth  uint64 `json:"depth"`
+}
+
+// handleProofRegenerations returns the depth of the regeneration queue, and
+// queues the posted range of batches for their proofs to be regenerated.
+func (a *Aggregator) handleProofRegenerations(w http.ResponseWriter, r *http.Request) {
+	var status proofRegenerationStatus
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req proofRegenerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request, %v", err), http.StatusBadRequest)
+			return
+		}
+		queued, err := a.EnqueueProofRegeneration(r.Context(), req.BatchNumber, req.BatchNumberFinal)
+		if errors.Is(err, ErrInvalidRegenerationRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Errorf("Failed to queue proof regeneration, err: %v", err)
+			http.Error(w, "failed to queue proof regeneration", http.StatusInternalServerError)
+			return
+		}
+		status.Queued = queued
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	depth, err := a.regenerationQueueDepth(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get the proof regeneration queue depth, err: %v", err)
+		http.Error(w, "failed to get the proof regeneration queue depth", http.StatusInternalServerError)
+		return
+	}
+	status.Depth = depth
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Errorf("Failed to encode proof regeneration status, err: %v", err)
+	}
+}
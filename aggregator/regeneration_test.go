@@ -0,0 +1,158 @@
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationsOrder(t *testing.T) {
+	a := Aggregator{cfg: Config{ChannelOperationsOrder: defaultChannelOperationsOrder}}
+	assert.Equal(t, defaultChannelOperationsOrder, a.operationsOrder())
+
+	a.cfg.ProofRegeneration = ProofRegenerationConfig{Enabled: true, Priority: ProofRegenerationPriorityLow}
+	assert.Equal(t, []ChannelOperation{
+		ChannelOperationBuildFinalProof,
+		ChannelOperationAggregateProofs,
+		ChannelOperationGenerateBatchProof,
+		ChannelOperationRegenerateProof,
+	}, a.operationsOrder())
+
+	a.cfg.ProofRegeneration.Priority = ProofRegenerationPriorityHigh
+	assert.Equal(t, []ChannelOperation{
+		ChannelOperationBuildFinalProof,
+		ChannelOperationAggregateProofs,
+		ChannelOperationRegenerateProof,
+		ChannelOperationGenerateBatchProof,
+	}, a.operationsOrder())
+}
+
+func TestEnqueueProofRegeneration(t *testing.T) {
+	ctx := context.Background()
+	st := mocks.NewStateMock(t)
+	a := Aggregator{State: st}
+
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{BatchNumber: 3}, nil)
+	// the verified batches are skipped
+	st.On("AddProofRegenerations", mock.Anything, uint64(4), uint64(6), nil).Return(uint64(3), nil).Once()
+	st.On("CountProofRegenerations", mock.Anything, uint64(3), nil).Return(uint64(3), nil).Once()
+
+	queued, err := a.EnqueueProofRegeneration(ctx, 1, 6)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), queued)
+
+	// nothing left to regenerate
+	queued, err = a.EnqueueProofRegeneration(ctx, 2, 3)
+	require.NoError(t, err)
+	assert.Zero(t, queued)
+
+	_, err = a.EnqueueProofRegeneration(ctx, 6, 4)
+	assert.ErrorIs(t, err, ErrInvalidRegenerationRange)
+	_, err = a.EnqueueProofRegeneration(ctx, 0, 4)
+	assert.ErrorIs(t, err, ErrInvalidRegenerationRange)
+}
+
+func expectProofRegeneration(st *mocks.StateMock, eth *mocks.Etherman, prover *mocks.ProverMock, proofID *string) {
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	prover.On("ForkID").Return(uint64(0))
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{BatchNumber: 1}, nil)
+	st.On("GetBatchToRegenerate", mock.Anything, uint64(1), []uint64(nil), nil).Return(&state.Batch{BatchNumber: 2}, nil)
+	st.On("GetBatchByNumber", mock.Anything, uint64(1), nil).Return(&state.Batch{BatchNumber: 1}, nil)
+	eth.On("GetPublicAddress").Return(common.Address{}, nil)
+	prover.On("BatchProof", mock.Anything).Return(proofID, nil)
+	prover.On("WaitRecursiveProof", mock.Anything, *proofID).Return("proof", nil)
+}
+
+func TestTryRegenerateProof(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	prover := mocks.NewProverMock(t)
+	dbTx := mocks.NewDbTxMock(t)
+	a := newDisconnectTestAggregator(st, eth, nil, time.Hour)
+	a.batchClaims = newBatchProofClaims(0)
+
+	proofID := "proofID"
+	expectProofRegeneration(st, eth, prover, &proofID)
+	st.On("BeginStateTransaction", mock.Anything).Return(dbTx, nil)
+	st.On("ReplaceRegeneratedProof", mock.Anything, mock.MatchedBy(func(proof *state.Proof) bool {
+		return proof.BatchNumber == 2 && proof.BatchNumberFinal == 2 && proof.Proof == "proof" && !proof.Generating
+	}), dbTx).Return(nil).Once()
+	dbTx.On("Commit", mock.Anything).Return(nil).Once()
+	st.On("CountProofRegenerations", mock.Anything, uint64(1), nil).Return(uint64(0), nil).Once()
+
+	regenerated, err := a.tryRegenerateProof(context.Background(), prover)
+	require.NoError(t, err)
+	assert.True(t, regenerated)
+	assert.Empty(t, a.batchClaims.excluded())
+
+	// the batch is kept queued if an overlapping proof is locked
+	st.On("ReplaceRegeneratedProof", mock.Anything, mock.Anything, dbTx).Return(state.ErrProofGenerating).Once()
+	dbTx.On("Rollback", mock.Anything).Return(nil).Once()
+
+	regenerated, err = a.tryRegenerateProof(context.Background(), prover)
+	require.NoError(t, err)
+	assert.False(t, regenerated)
+	assert.Empty(t, a.batchClaims.excluded())
+}
+
+func TestTryRegenerateProofNothingQueued(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	prover := mocks.NewProverMock(t)
+	a := Aggregator{State: st, StateDBMutex: &sync.Mutex{}}
+
+	prover.On("ID").Return("prover-1")
+	prover.On("Name").Return("addr")
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{BatchNumber: 1}, nil)
+	st.On("GetBatchToRegenerate", mock.Anything, uint64(1), []uint64(nil), nil).Return(nil, state.ErrNotFound)
+
+	regenerated, err := a.tryRegenerateProof(context.Background(), prover)
+	require.NoError(t, err)
+	assert.False(t, regenerated)
+}
+
+func TestHandleProofRegenerations(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	a := Aggregator{State: st}
+
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{BatchNumber: 3}, nil)
+	st.On("AddProofRegenerations", mock.Anything, uint64(4), uint64(8), nil).Return(uint64(5), nil).Once()
+	st.On("CountProofRegenerations", mock.Anything, uint64(3), nil).Return(uint64(5), nil)
+
+	body, err := json.Marshal(proofRegenerationRequest{BatchNumber: 4, BatchNumberFinal: 8})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	a.handleProofRegenerations(rec, httptest.NewRequest(http.MethodPost, "/admin/regenerations", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var status proofRegenerationStatus
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, proofRegenerationStatus{Queued: 5, Depth: 5}, status)
+
+	rec = httptest.NewRecorder()
+	a.handleProofRegenerations(rec, httptest.NewRequest(http.MethodGet, "/admin/regenerations", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, proofRegenerationStatus{Depth: 5}, status)
+
+	body, err = json.Marshal(proofRegenerationRequest{BatchNumber: 8, BatchNumberFinal: 4})
+	require.NoError(t, err)
+	rec = httptest.NewRecorder()
+	a.handleProofRegenerations(rec, httptest.NewRequest(http.MethodPost, "/admin/regenerations", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	a.handleProofRegenerations(rec, httptest.NewRequest(http.MethodDelete, "/admin/regenerations", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
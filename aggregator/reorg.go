@@ -0,0 +1,119 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// verifiedBatchReorg tracks the fork point of an L1 reorg of the verified
+// batches, the last batch still verified on L1, until the state is synced
+// back with L1. Meanwhile the batches after the fork point are proved and
+// verified again.
+type verifiedBatchReorg struct {
+	mu        sync.Mutex
+	detected  bool
+	forkPoint uint64
+}
+
+func newVerifiedBatchReorg() *verifiedBatchReorg {
+	return &verifiedBatchReorg{}
+}
+
+// set records the reorg fork point, it returns false if the reorg was
+// already recorded.
+func (r *verifiedBatchReorg) set(forkPoint uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.detected && r.forkPoint == forkPoint {
+		return false
+	}
+	r.detected = true
+	r.forkPoint = forkPoint
+	return true
+}
+
+// clear forgets the reorg once the state is synced back with L1, it returns
+// whether there was one.
+func (r *verifiedBatchReorg) clear() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	detected := r.detected
+	r.detected = false
+	return detected
+}
+
+// cap returns the last verified batch number capped to the reorg fork point.
+// It's safe to call it on a nil tracker.
+func (r *verifiedBatchReorg) cap(lastVerifiedBatchNum uint64) uint64 {
+	if r == nil {
+		return lastVerifiedBatchNum
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.detected && r.forkPoint < lastVerifiedBatchNum {
+		return r.forkPoint
+	}
+	return lastVerifiedBatchNum
+}
+
+// checkVerifiedBatchReorg checks whether the last verified batch of the state
+// is still verified on L1 with the same state root. On an L1 reorg it rolls
+// the verified batches back to the last one verified on L1 and returns true,
+// so the batches after it are proved and verified again. It's always false if
+// DetectVerifiedBatchReorgs is disabled.
+func (a *Aggregator) checkVerifiedBatchReorg(ctx context.Context, lastVerifiedBatch *state.VerifiedBatch, lastVerifiedEthBatchNum uint64) (bool, error) {
+	if a.reorg == nil {
+		return false, nil
+	}
+
+	reorged, err := a.Ethman.CheckReorg(lastVerifiedBatch.BatchNumber, lastVerifiedBatch.StateRoot)
+	if err != nil {
+		return false, fmt.Errorf("Failed to check the verification of batch %d on L1, %w", lastVerifiedBatch.BatchNumber, err)
+	}
+	if !reorged {
+		if a.reorg.clear() {
+			log.Infof("State synced back with L1 after a reorg, last verified batch %d", lastVerifiedBatch.BatchNumber)
+		}
+		return false, nil
+	}
+
+	forkPoint := lastVerifiedEthBatchNum
+	if forkPoint >= lastVerifiedBatch.BatchNumber && lastVerifiedBatch.BatchNumber > 0 {
+		forkPoint = lastVerifiedBatch.BatchNumber - 1
+	}
+	if a.reorg.set(forkPoint) {
+		log.Warnf("L1 reorg detected, batch %d no longer verified on L1, rolling the verified batches back to %d", lastVerifiedBatch.BatchNumber, forkPoint)
+		a.rollbackVerifiedBatches(ctx, forkPoint)
+	}
+	return true, nil
+}
+
+// rollbackVerifiedBatches makes the proofs of the batches after the fork
+// point pending verification again, so they are sent again.
+func (a *Aggregator) rollbackVerifiedBatches(ctx context.Context, forkPoint uint64) {
+	a.finalProofBuilds.forgetAfter(forkPoint)
+
+	proofs, err := a.State.GetVerifiedProofs(ctx, nil)
+	if err != nil {
+		log.Errorf("Failed to get the verified proofs to roll back, err: %v", err)
+		return
+	}
+	for _, proof := range proofs {
+		if proof.BatchNumberFinal <= forkPoint {
+			continue
+		}
+		err := a.State.UnmarkProofVerified(ctx, proof.BatchNumber, proof.BatchNumberFinal, nil)
+		if err != nil {
+			log.Errorf("Failed to unmark proof for batches [%d-%d] as verified, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+			continue
+		}
+		log.Infof("Proof for batches [%d-%d] rolled back to be verified again", proof.BatchNumber, proof.BatchNumberFinal)
+	}
+}
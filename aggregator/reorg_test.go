@@ -0,0 +1,66 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifiedBatchReorgRollback(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	a := Aggregator{
+		cfg:              Config{NotSyncedWhenAheadOfL1: true},
+		State:            st,
+		Ethman:           eth,
+		finalProofBuilds: newFinalProofBuilds(),
+		reorg:            newVerifiedBatchReorg(),
+	}
+	ctx := context.Background()
+	stateRoot := common.HexToHash("0x10")
+
+	for _, proof := range []*state.Proof{{BatchNumber: 1, BatchNumberFinal: 6}, {BatchNumber: 7, BatchNumberFinal: 10}} {
+		require.NoError(t, a.startFinalProof(proof))
+		a.finishFinalProof(proof, true)
+	}
+
+	// the verification of batches 7-10 is reorged out of L1
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10, StateRoot: stateRoot}, nil).Twice()
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(6), nil).Times(3)
+	eth.On("CheckReorg", uint64(10), stateRoot).Return(true, nil).Twice()
+	st.On("GetVerifiedProofs", ctx, nil).Return([]*state.Proof{
+		{BatchNumber: 1, BatchNumberFinal: 6},
+		{BatchNumber: 7, BatchNumberFinal: 10},
+	}, nil).Once()
+	st.On("UnmarkProofVerified", ctx, uint64(7), uint64(10), nil).Return(nil).Once()
+
+	// synced up to the fork point, the verified batches are rolled back once
+	assert.True(t, a.isSynced(ctx))
+	assert.True(t, a.isSynced(ctx))
+	assert.Equal(t, uint64(6), a.reorg.cap(10))
+	assert.Equal(t, uint64(5), a.reorg.cap(5))
+	assert.NoError(t, a.startFinalProof(&state.Proof{BatchNumber: 7, BatchNumberFinal: 10}))
+	assert.ErrorIs(t, a.startFinalProof(&state.Proof{BatchNumber: 1, BatchNumberFinal: 6}), ErrFinalProofDeduplicated)
+
+	// the state is synced back with L1
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 6, StateRoot: common.HexToHash("0x6")}, nil).Once()
+	eth.On("CheckReorg", uint64(6), common.HexToHash("0x6")).Return(false, nil).Once()
+
+	assert.True(t, a.isSynced(ctx))
+	assert.Equal(t, uint64(10), a.reorg.cap(10))
+}
+
+func TestVerifiedBatchReorgDisabled(t *testing.T) {
+	var reorg *verifiedBatchReorg
+	assert.Equal(t, uint64(10), reorg.cap(10))
+
+	a := Aggregator{}
+	reorged, err := a.checkVerifiedBatchReorg(context.Background(), &state.VerifiedBatch{BatchNumber: 10}, 6)
+	require.NoError(t, err)
+	assert.False(t, reorged)
+}
@@ -0,0 +1,25 @@
+package aggregator
+
+import (
+	"context"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/jackc/pgx/v4"
+)
+
+// verifiedProofUnmarker removes the checkpoint of the verification of the
+// proofs on L1.
+type verifiedProofUnmarker interface {
+	UnmarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error
+}
+
+// unmarkProofVerified removes the checkpoint of the verification of the
+// proof once reorged out, so the proof is sent again instead of being
+// cleaned up on restart.
+func unmarkProofVerified(ctx context.Context, store verifiedProofUnmarker, proof *state.Proof) {
+	err := store.UnmarkProofVerified(ctx, proof.BatchNumber, proof.BatchNumberFinal, nil)
+	if err != nil {
+		log.Errorf("Failed to unmark proof for batches [%d-%d] as verified, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+	}
+}
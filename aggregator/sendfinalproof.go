@@ -0,0 +1,235 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/events"
+	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
+	"github.com/0xPolygonHermez/zkevm-node/ethtxmanager"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// gateResult is the outcome of a gate of the submission of a final proof.
+type gateResult int
+
+const (
+	// gatePass lets the final proof through to the next gate.
+	gatePass gateResult = iota
+	// gateSkip stops the submission of the final proof, the gate having
+	// already released or kept it, and waits for the next one.
+	gateSkip
+	// gateStop stops the submission of the final proofs, the aggregator is
+	// stopping.
+	gateStop
+)
+
+// unlockFinalProof unlocks the proof of a final proof not sent, so it can be
+// sent again, and enables the proof verification.
+func (a *Aggregator) unlockFinalProof(ctx context.Context, proof *state.Proof) {
+	// unlock the underlying proof (generating=false)
+	proof.Generating = false
+	err := a.State.UpdateGeneratedProof(ctx, proof, nil)
+	if err != nil {
+		log.Errorf("Rollback failed updating proof state (false) for proof ID [%v], err: %v", proof.ProofID, err)
+	}
+	a.enableProofVerification()
+}
+
+// dropFinalProof gives up a final proof not sent, unlocking its proof so it
+// can be sent again.
+func (a *Aggregator) dropFinalProof(ctx context.Context, proof *state.Proof) {
+	a.unlockFinalProof(ctx, proof)
+	a.finishFinalProof(proof, false)
+}
+
+// This function waits to receive a final proof from a prover. Once it receives
+// the proof, it performs these steps in order:
+// - send the final proof to L1
+// - wait for the synchronizer to catch up
+// - clean up the cache of recursive proofs
+func (a *Aggregator) sendFinalProof() {
+	defer a.drain.end()
+	for {
+		a.drain.end()
+		select {
+		case <-a.ctx.Done():
+			return
+		case msg := <-a.finalProof:
+			if a.submitFinalProof(msg) == gateStop {
+				return
+			}
+		}
+	}
+}
+
+// submitFinalProof passes the final proof through the gates of its
+// submission, each one in its own file: the leadership, the dry run, the
+// submission cool-down and re-check, the checkpoint of the verification, the
+// wait for the synchronizer, the state root check, the confirmations and the
+// chunked clean up of the proofs.
+func (a *Aggregator) submitFinalProof(msg finalProofMsg) gateResult {
+	ctx := a.ctx
+	proof := msg.recursiveProof
+
+	if !a.drain.begin(proof) {
+		log.Infof("Aggregator stopping, final proof for batches [%d-%d] not sent", proof.BatchNumber, proof.BatchNumberFinal)
+		a.rollbackFinalProof(proof)
+		a.finishFinalProof(proof, false)
+		return gateStop
+	}
+
+	sendCtx, ok := a.leaderSendContext(ctx, proof)
+	if !ok {
+		return gateSkip
+	}
+
+	log.Infof("Verifying final proof with ethereum smart contract, batches %d-%d", proof.BatchNumber, proof.BatchNumberFinal)
+
+	inputs, ok := a.finalProofInputs(ctx, msg)
+	if !ok {
+		return gateSkip
+	}
+
+	gasPrice := a.gasPrices.get(proof.BatchNumber, proof.BatchNumberFinal)
+	if gasPrice != nil {
+		log.Infof("Gas price override %d applied to the verification of batches [%d-%d]", gasPrice, proof.BatchNumber, proof.BatchNumberFinal)
+	}
+
+	if a.dryRun.enabled() {
+		a.dryRunFinalProof(ctx, proof, inputs, gasPrice)
+		return gateSkip
+	}
+
+	if !a.cooldown.wait(ctx) {
+		return gateStop
+	}
+
+	if result := a.recheckFinalProof(ctx, proof); result != gatePass {
+		return result
+	}
+
+	a.cooldown.start(time.Now())
+	tx, err := a.EthTxManager.VerifyBatches(sendCtx, proof.BatchNumber-1, proof.BatchNumberFinal, inputs, gasPrice)
+	if err != nil && a.leadershipLostWhileSending(ctx, sendCtx, proof, err) {
+		return gateSkip
+	}
+	if err != nil {
+		err = newLifecycleError(ErrL1SubmissionFailed, err)
+		a.releaseSubmission(proof)
+		log.Errorf("Error verifiying final proof for batches [%d-%d], err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		a.recordOutcome(ctx, false, err)
+		if errors.Is(err, ethtxmanager.ErrTxNotMined) {
+			log.Warnf("Final proof tx for batches [%d-%d] was not mined in time, the proof is unlocked to be sent again", proof.BatchNumber, proof.BatchNumberFinal)
+		}
+		var syncErr *ethtxmanager.SyncTimeoutError
+		if errors.As(err, &syncErr) {
+			log.Warnf("Final proof tx %s for batches [%d-%d] was mined but not synced after %s, the last verified batch synced is %d",
+				syncErr.TxHash, proof.BatchNumber, proof.BatchNumberFinal, syncErr.Progress.Elapsed, syncErr.Progress.LastSyncedBatchNumber)
+		}
+		a.dropFinalProof(ctx, proof)
+		return gateSkip
+	}
+
+	log.Infof("Final proof for batches [%d-%d] verified in transaction [%v]", proof.BatchNumber, proof.BatchNumberFinal, tx.Hash())
+	a.recordOutcome(ctx, true, nil)
+	a.publishEvent(events.EventFinalProofSubmitted, proof.BatchNumber, proof.BatchNumberFinal, msg.proverID)
+
+	verification := Verification{
+		TxHash:           tx.Hash(),
+		BatchNumber:      proof.BatchNumber,
+		BatchNumberFinal: proof.BatchNumberFinal,
+	}
+	if proof.ProofID != nil {
+		verification.ProofID = *proof.ProofID
+	}
+	a.recordVerification(ctx, verification)
+	a.verificationWindow.reset()
+	a.gasPrices.remove(proof.BatchNumber, proof.BatchNumberFinal)
+
+	markProofVerified(ctx, a.State, proof)
+	a.releaseSubmission(proof)
+	a.finishFinalProof(proof, true)
+	// the clean up is resumed on restart, no need to wait for it
+	a.drain.end()
+
+	if result := a.syncVerifiedFinalProof(ctx, proof); result != gatePass {
+		return result
+	}
+
+	a.publishEvent(events.EventVerified, proof.BatchNumber, proof.BatchNumberFinal, msg.proverID)
+	if a.cfg.ProofRegeneration.Enabled {
+		// the batches verified are out of the regeneration queue
+		a.reportRegenerationQueueDepth(ctx)
+	}
+
+	a.stateRootCheck.check(proof.BatchNumberFinal, inputs.NewStateRoot)
+
+	if result := a.confirmVerifiedFinalProof(ctx, proof, tx.Hash()); result != gatePass {
+		return result
+	}
+
+	a.resetVerifyProofTime()
+
+	// network is synced with the final proof, we can safely delete the recursive proofs
+	err = a.deleteVerifiedProofs(ctx, proof.BatchNumber, proof.BatchNumberFinal)
+	if err != nil {
+		log.Errorf("Failed to store proof aggregation result, err: %v", err)
+	}
+	return gatePass
+}
+
+// finalProofInputs returns the inputs to verify the final proof on L1, once
+// the proof and its last batch are checked. The proof is given up if they
+// can't be read or checked, and dropped to be proved again if its commitment
+// doesn't match.
+func (a *Aggregator) finalProofInputs(ctx context.Context, msg finalProofMsg) (*ethmanTypes.FinalProofInputs, bool) {
+	proof := msg.recursiveProof
+
+	finalBatch, err := a.getFinalBatch(ctx, proof.BatchNumberFinal)
+	if err != nil {
+		log.Errorf("Failed to retrieve batch with number [%d], dropping the final proof, err: %v", proof.BatchNumberFinal, err)
+		a.dropFinalProof(ctx, proof)
+		return nil, false
+	}
+
+	if a.cfg.ProofCommitments {
+		err = a.checkCommitment(ctx, proof)
+		if err != nil {
+			log.Errorf("Failed to validate the commitment of the final proof for batches [%d-%d], not sending it, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+			if errors.Is(err, ErrCommitmentMismatch) {
+				// the proof can't be trusted, drop it so its batches are proved again
+				err = a.State.DeleteGeneratedProofs(ctx, proof.BatchNumber, proof.BatchNumberFinal, nil)
+			} else {
+				// unlock the underlying proof (generating=false)
+				proof.Generating = false
+				err = a.State.UpdateGeneratedProof(ctx, proof, nil)
+			}
+			if err != nil {
+				log.Errorf("Rollback failed releasing proof ID [%v], err: %v", proof.ProofID, err)
+			}
+			a.finishFinalProof(proof, false)
+			a.enableProofVerification()
+			return nil, false
+		}
+	}
+
+	if a.cfg.CheckFinalProofInputs {
+		err = a.checkFinalProofInputs(proof, msg.finalProof, finalBatch)
+		if err != nil {
+			log.Errorf("Final proof for batches [%d-%d] not sent, it would revert on L1, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+			a.dropFinalProof(ctx, proof)
+			return nil, false
+		}
+	}
+
+	inputs := &ethmanTypes.FinalProofInputs{
+		FinalProof:       msg.finalProof,
+		NewLocalExitRoot: finalBatch.LocalExitRoot.Bytes(),
+		NewStateRoot:     finalBatch.StateRoot.Bytes(),
+	}
+	log.Infof("Final proof inputs: NewLocalExitRoot [%#x], NewStateRoot [%#x]", inputs.NewLocalExitRoot, inputs.NewStateRoot)
+	return inputs, true
+}
@@ -0,0 +1,131 @@
+package aggregator
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendFinalProofAllGates(t *testing.T) {
+	stateRoot := common.HexToHash("0x1")
+	tx := ethTypes.NewTransaction(1, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	receipt := &ethTypes.Receipt{TxHash: tx.Hash(), BlockNumber: big.NewInt(100)}
+
+	testCases := []struct {
+		name   string
+		dryRun bool
+		// reorged reports the verification tx reorged out while waiting for
+		// its confirmations
+		reorged bool
+	}{
+		{name: "sent"},
+		{name: "dry run", dryRun: true},
+		{name: "reorged", reorged: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := mocks.NewStateMock(t)
+			eth := mocks.NewEtherman(t)
+			ethTxMan := mocks.NewEthTxManager(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			a := Aggregator{
+				cfg: Config{
+					RetryTime:                      types.NewDuration(time.Millisecond),
+					VerifyProofInterval:            types.NewDuration(time.Hour),
+					ProofDeletionConfirmations:     2,
+					VerifiedProofsCleanupChunkSize: 1,
+				},
+				State:                   st,
+				Ethman:                  eth,
+				EthTxManager:            ethTxMan,
+				TimeSendFinalProofMutex: &sync.RWMutex{},
+				finalProof:              make(chan finalProofMsg),
+				verifications:           newVerificationHistory(10),
+				leaderLock:              &leaderLockStub{},
+				leadership:              &leadership{},
+				cooldown:                newSubmissionCooldown(time.Hour),
+				submissions:             newFinalProofSubmissions(),
+				stateRootCheck:          newVerifiedStateRootCheck(eth),
+				ctx:                     ctx,
+				exit:                    cancel,
+			}
+			if tc.dryRun {
+				a.dryRun = newDryRun(ethTxMan)
+			}
+			a.setLeader(true)
+			proofID := "proofID"
+			proof := &state.Proof{BatchNumber: 11, BatchNumberFinal: 12, ProofID: &proofID, Generating: true}
+			done := make(chan struct{})
+			signal := func(mock.Arguments) { close(done) }
+
+			st.On("GetBatchByNumber", ctx, uint64(12), nil).Return(&state.Batch{BatchNumber: 12, StateRoot: stateRoot}, nil).Once()
+			if tc.dryRun {
+				// the proof is estimated and unlocked, none of the following
+				// gates is reached
+				ethTxMan.On("EstimateVerifyBatches", ctx, uint64(10), uint64(12), mock.Anything, (*big.Int)(nil)).Return(tx, nil).Once()
+				st.On("UpdateGeneratedProof", ctx, mock.MatchedBy(func(p *state.Proof) bool { return !p.Generating }), nil).Return(nil).Once().Run(signal)
+			} else {
+				// re-checked right before sending
+				eth.On("GetLatestVerifiedBatchNum").Return(uint64(10), nil).Once()
+				st.On("CheckProofPendingVerification", ctx, uint64(11), uint64(12), nil).Return(true, nil).Once()
+				ethTxMan.On("VerifyBatches", mock.Anything, uint64(10), uint64(12), mock.Anything, (*big.Int)(nil)).Return(tx, nil).Once()
+				st.On("AddProofVerification", ctx, mock.Anything, nil).Return(nil).Once()
+				// checkpointed, then synced
+				st.On("MarkProofVerified", ctx, uint64(11), uint64(12), nil).Return(nil).Once()
+				st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 12}, nil).Once()
+				eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil).Once()
+				eth.On("GetVerifiedBatchStateRoot", uint64(12)).Return(stateRoot, nil).Once()
+				st.On("SetLastVerifyProofTime", ctx, mock.Anything, nil).Return(nil).Once()
+				if tc.reorged {
+					// kept to be sent again
+					eth.On("GetTxReceipt", ctx, tx.Hash()).Return(nil, ethereum.NotFound).Times(3)
+					st.On("UnmarkProofVerified", ctx, uint64(11), uint64(12), nil).Return(nil).Once().Run(signal)
+				} else {
+					// confirmed, then deleted in chunks, the verified proof
+					// last
+					eth.On("GetTxReceipt", ctx, tx.Hash()).Return(receipt, nil).Once()
+					eth.On("GetLatestBlockNumber", ctx).Return(uint64(101), nil).Once()
+					st.On("DeleteGeneratedProofs", ctx, uint64(12), uint64(12), nil).Return(nil).Once()
+					st.On("DeleteGeneratedProofs", ctx, uint64(11), uint64(12), nil).Return(nil).Once().Run(signal)
+				}
+			}
+
+			stopped := make(chan struct{})
+			go func() {
+				a.sendFinalProof()
+				close(stopped)
+			}()
+			a.finalProof <- finalProofMsg{proverID: "prover", recursiveProof: proof, finalProof: &pb.FinalProof{}}
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				require.Fail(t, "final proof not handled")
+			}
+			cancel()
+			<-stopped
+
+			if tc.dryRun {
+				assert.Equal(t, time.Duration(0), a.cooldown.remaining(time.Now()))
+				return
+			}
+			// the next submission is held by the cool-down, and the claim on
+			// the batches released
+			assert.Greater(t, a.cooldown.remaining(time.Now()), time.Duration(0))
+			assert.True(t, a.submissions.claim(batchRange{batchNumber: 11, batchNumberFinal: 12}))
+		})
+	}
+}
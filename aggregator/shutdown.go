@@ -0,0 +1,121 @@
+package aggregator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// finalProofDrain tracks the final proof being sent to L1, so the aggregator
+// lets it finish before stopping.
+type finalProofDrain struct {
+	mu       sync.Mutex
+	proof    *state.Proof
+	done     chan struct{}
+	draining bool
+}
+
+func newFinalProofDrain() *finalProofDrain {
+	return &finalProofDrain{}
+}
+
+// begin records the proof whose final proof is being sent. It returns false
+// if the aggregator is stopping, in which case the final proof must not be
+// sent. It's safe to call it on a nil tracker.
+func (d *finalProofDrain) begin(proof *state.Proof) bool {
+	if d == nil {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.draining {
+		return false
+	}
+	// keep a copy to roll it back without racing with the sender
+	p := *proof
+	d.proof = &p
+	d.done = make(chan struct{})
+	return true
+}
+
+// end records that the final proof being sent, if any, is done with.
+func (d *finalProofDrain) end() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.proof != nil {
+		d.proof = nil
+		close(d.done)
+	}
+}
+
+// drain stops accepting final proofs to send and waits up to the timeout for
+// the one being sent to be done with. It returns the proof still being sent
+// on timeout.
+func (d *finalProofDrain) drain(timeout time.Duration) *state.Proof {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	d.draining = true
+	if d.proof == nil {
+		d.mu.Unlock()
+		return nil
+	}
+	done := d.done
+	d.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.proof
+}
+
+// rollbackFinalProof unlocks the proof whose final proof was not sent, so it
+// can be verified again. The aggregator context is not used as it's canceled
+// when stopping.
+func (a *Aggregator) rollbackFinalProof(proof *state.Proof) {
+	proof.Generating = false
+	err := a.State.UpdateGeneratedProof(context.Background(), proof, nil)
+	if err != nil {
+		log.Errorf("Rollback failed updating proof state (false) for proof ID [%v], err: %v", proof.ProofID, err)
+	}
+}
+
+// stopServer stops the gRPC server, letting the pending RPCs finish for up to
+// the timeout before closing them.
+func (a *Aggregator) stopServer(timeout time.Duration) {
+	if timeout <= 0 {
+		a.srv.Stop()
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		a.srv.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		log.Warnf("Server not stopped gracefully after %v, closing the pending RPCs", timeout)
+		a.srv.Stop()
+	}
+}
@@ -0,0 +1,173 @@
+package aggregator
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/pb"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum/common"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// newStoppingAggregator returns an aggregator sending the final proofs,
+// along with the channel closed once it stops sending them.
+func newStoppingAggregator(t *testing.T, shutdownTimeout time.Duration) (*Aggregator, *mocks.StateMock, *mocks.EthTxManager, chan struct{}) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	ethTxMan := mocks.NewEthTxManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Aggregator{
+		cfg: Config{
+			RetryTime:       types.NewDuration(10 * time.Millisecond),
+			ShutdownTimeout: types.NewDuration(shutdownTimeout),
+		},
+		State:                   st,
+		Ethman:                  eth,
+		EthTxManager:            ethTxMan,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+		finalProof:              make(chan finalProofMsg),
+		verifications:           newVerificationHistory(10),
+		drain:                   newFinalProofDrain(),
+		srv:                     grpc.NewServer(),
+		ctx:                     ctx,
+		exit:                    cancel,
+	}
+	st.On("GetBatchByNumber", mock.Anything, uint64(12), nil).Return(&state.Batch{BatchNumber: 12}, nil).Maybe()
+	st.On("GetLastVerifiedBatch", mock.Anything, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil).Maybe()
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil).Maybe()
+
+	done := make(chan struct{})
+	go func() {
+		a.sendFinalProof()
+		close(done)
+	}()
+	return a, st, ethTxMan, done
+}
+
+func newFinalProofMsg() finalProofMsg {
+	proofID := "proofID"
+	return finalProofMsg{
+		proverID:       "prover",
+		recursiveProof: &state.Proof{BatchNumber: 11, BatchNumberFinal: 12, ProofID: &proofID, Generating: true},
+		finalProof:     &pb.FinalProof{},
+	}
+}
+
+func isRolledBack(proof *state.Proof) bool {
+	return proof.BatchNumber == 11 && proof.BatchNumberFinal == 12 && !proof.Generating
+}
+
+func TestStopDrainsFinalProof(t *testing.T) {
+	a, st, ethTxMan, done := newStoppingAggregator(t, time.Second)
+
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	tx := ethTypes.NewTransaction(1, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	ethTxMan.On("VerifyBatches", mock.Anything, uint64(10), uint64(12), mock.Anything, (*big.Int)(nil)).Return(tx, nil).Once().
+		Run(func(mock.Arguments) {
+			close(sending)
+			<-release
+		})
+	st.On("AddProofVerification", mock.Anything, mock.Anything, nil).Return(nil).Once()
+	st.On("MarkProofVerified", mock.Anything, uint64(11), uint64(12), nil).Return(nil).Once()
+
+	a.finalProof <- newFinalProofMsg()
+	<-sending
+
+	stopped := make(chan struct{})
+	go func() {
+		a.Stop()
+		close(stopped)
+	}()
+
+	// the final proof being sent is waited for
+	select {
+	case <-stopped:
+		require.Fail(t, "stopped while sending the final proof")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.NoError(t, a.ctx.Err())
+
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		require.Fail(t, "not stopped once the final proof was sent")
+	}
+	<-done
+}
+
+func TestStopRollsBackFinalProofOnTimeout(t *testing.T) {
+	a, st, ethTxMan, done := newStoppingAggregator(t, 20*time.Millisecond)
+
+	sending := make(chan struct{})
+	ethTxMan.On("VerifyBatches", mock.Anything, uint64(10), uint64(12), mock.Anything, (*big.Int)(nil)).Return(nil, context.Canceled).Once().
+		Run(func(args mock.Arguments) {
+			close(sending)
+			<-args.Get(0).(context.Context).Done()
+		})
+	// the sender fails to unlock the proof as its context is canceled
+	st.On("UpdateGeneratedProof", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() != nil }), mock.MatchedBy(isRolledBack), nil).
+		Return(context.Canceled).Maybe()
+	rolledBack := make(chan struct{})
+	st.On("UpdateGeneratedProof", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil }), mock.MatchedBy(isRolledBack), nil).
+		Return(nil).Once().Run(func(mock.Arguments) { close(rolledBack) })
+
+	a.finalProof <- newFinalProofMsg()
+	<-sending
+
+	a.Stop()
+	select {
+	case <-rolledBack:
+	case <-time.After(time.Second):
+		require.Fail(t, "final proof not rolled back")
+	}
+	<-done
+}
+
+func TestStopRejectsFinalProofWhileDraining(t *testing.T) {
+	a, st, _, done := newStoppingAggregator(t, time.Second)
+
+	// nothing in flight, the drain returns right away
+	assert.Nil(t, a.drain.drain(time.Second))
+
+	// a final proof handed off afterwards is not sent
+	rolledBack := make(chan struct{})
+	st.On("UpdateGeneratedProof", mock.Anything, mock.MatchedBy(isRolledBack), nil).Return(nil).Once().
+		Run(func(mock.Arguments) { close(rolledBack) })
+	a.finalProof <- newFinalProofMsg()
+	<-rolledBack
+	<-done
+
+	a.Stop()
+}
+
+func TestFinalProofDrainNil(t *testing.T) {
+	var d *finalProofDrain
+
+	assert.True(t, d.begin(&state.Proof{}))
+	d.end()
+	assert.Nil(t, d.drain(time.Second))
+}
+
+func TestStopWithoutShutdownTimeout(t *testing.T) {
+	a := &Aggregator{drain: newFinalProofDrain(), srv: grpc.NewServer()}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.ctx, a.exit = ctx, cancel
+
+	require.True(t, a.drain.begin(&state.Proof{}))
+	start := time.Now()
+	a.Stop()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, a.ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,43 @@
+package aggregator
+
+import (
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// verifiedStateRootReader reads the state root of the batches verified on L1.
+type verifiedStateRootReader interface {
+	GetVerifiedBatchStateRoot(batchNumber uint64) (common.Hash, error)
+}
+
+// verifiedStateRootCheck compares the state root stored in L1 for the
+// verified batches with the one submitted with the final proof.
+type verifiedStateRootCheck struct {
+	l1 verifiedStateRootReader
+}
+
+func newVerifiedStateRootCheck(l1 verifiedStateRootReader) *verifiedStateRootCheck {
+	return &verifiedStateRootCheck{l1: l1}
+}
+
+// check reads the state root stored in L1 for the verified batch and compares
+// it with the submitted one. Any discrepancy is logged and reported through
+// metrics. It's safe to call it on a nil check.
+func (c *verifiedStateRootCheck) check(batchNumber uint64, submittedStateRoot []byte) {
+	if c == nil {
+		return
+	}
+	onChainStateRoot, err := c.l1.GetVerifiedBatchStateRoot(batchNumber)
+	if err != nil {
+		log.Errorf("Failed to get verified state root from L1 for batch [%d], err: %v", batchNumber, err)
+		return
+	}
+	if onChainStateRoot != common.BytesToHash(submittedStateRoot) {
+		metrics.StateRootMismatch()
+		log.Errorf("CRITICAL: verified state root on L1 for batch [%d] does not match the submitted one: L1 [%s], submitted [%#x]",
+			batchNumber, onChainStateRoot, submittedStateRoot)
+		return
+	}
+	log.Debugf("Verified state root on L1 for batch [%d] matches the submitted one", batchNumber)
+}
@@ -0,0 +1,25 @@
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestVerifiedStateRootCheck(t *testing.T) {
+	stateRoot := common.HexToHash("0x1")
+
+	// disabled
+	var disabled *verifiedStateRootCheck
+	disabled.check(10, stateRoot.Bytes())
+
+	// the state root stored in L1 is read once per check, a mismatch being
+	// only reported
+	eth := mocks.NewEtherman(t)
+	c := newVerifiedStateRootCheck(eth)
+	eth.On("GetVerifiedBatchStateRoot", uint64(10)).Return(stateRoot, nil).Once()
+	c.check(10, stateRoot.Bytes())
+	eth.On("GetVerifiedBatchStateRoot", uint64(11)).Return(common.HexToHash("0x2"), nil).Once()
+	c.check(11, stateRoot.Bytes())
+}
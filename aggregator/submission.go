@@ -0,0 +1,153 @@
+package aggregator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+var (
+	// ErrSubmissionInProgress is returned when a final proof overlapping the
+	// one to send is already being sent by another path.
+	ErrSubmissionInProgress = errors.New("final proof submission already in progress")
+	// ErrVerifiedConcurrently is returned when the batches of the final
+	// proof to send have already been verified by another path.
+	ErrVerifiedConcurrently = errors.New("batches already verified")
+)
+
+// finalProofSubmissions tracks the ranges of batches whose final proof is
+// being sent to L1, so the same batches are never submitted twice at once.
+type finalProofSubmissions struct {
+	mu     sync.Mutex
+	ranges map[batchRange]struct{}
+}
+
+func newFinalProofSubmissions() *finalProofSubmissions {
+	return &finalProofSubmissions{
+		ranges: make(map[batchRange]struct{}),
+	}
+}
+
+// claim records the range as being submitted, it returns false if an
+// overlapping range is already being submitted. It's safe to call it on a nil
+// tracker.
+func (s *finalProofSubmissions) claim(r batchRange) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for claimed := range s.ranges {
+		if claimed.overlaps(r.batchNumber, r.batchNumberFinal) {
+			return false
+		}
+	}
+	s.ranges[r] = struct{}{}
+	return true
+}
+
+// enabled returns whether the final proofs are re-checked and claimed before
+// being sent, it's safe to call it on a nil tracker.
+func (s *finalProofSubmissions) enabled() bool {
+	return s != nil
+}
+
+// release removes the range, once submitted or given up.
+func (s *finalProofSubmissions) release(r batchRange) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.ranges, r)
+}
+
+// claimSubmission re-checks, right before sending the final proof to L1, that
+// its batches are not being nor have been verified by another path, and
+// claims them. The claim must be released once the proof is submitted or
+// given up.
+func (a *Aggregator) claimSubmission(ctx context.Context, proof *state.Proof) (err error) {
+	r := batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal}
+	if !a.submissions.claim(r) {
+		return fmt.Errorf("%w: batches [%d-%d]", ErrSubmissionInProgress, proof.BatchNumber, proof.BatchNumberFinal)
+	}
+	defer func() {
+		if err != nil {
+			a.submissions.release(r)
+		}
+	}()
+
+	lastVerifiedEthBatchNum, err := a.Ethman.GetLatestVerifiedBatchNum()
+	if err != nil {
+		return fmt.Errorf("Failed to get last verified batch from L1, %w", err)
+	}
+	if lastVerifiedEthBatchNum >= proof.BatchNumber {
+		return fmt.Errorf("%w: batches [%d-%d], last verified batch on L1 %d", ErrVerifiedConcurrently,
+			proof.BatchNumber, proof.BatchNumberFinal, lastVerifiedEthBatchNum)
+	}
+
+	pending, err := a.State.CheckProofPendingVerification(ctx, proof.BatchNumber, proof.BatchNumberFinal, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to check if proof is pending verification, %w", err)
+	}
+	if !pending {
+		return fmt.Errorf("%w: batches [%d-%d], proof no longer pending verification", ErrVerifiedConcurrently,
+			proof.BatchNumber, proof.BatchNumberFinal)
+	}
+	return nil
+}
+
+// releaseSubmission releases the claim on the batches of the final proof.
+func (a *Aggregator) releaseSubmission(proof *state.Proof) {
+	a.submissions.release(batchRange{batchNumber: proof.BatchNumber, batchNumberFinal: proof.BatchNumberFinal})
+}
+
+// recheckFinalProof claims the batches of the final proof right before sending
+// it, if RecheckBeforeSendingFinalProof is set. The proof is given up if its
+// batches are being sent by another path, and cleaned up once synced if they
+// have already been verified.
+func (a *Aggregator) recheckFinalProof(ctx context.Context, proof *state.Proof) gateResult {
+	if !a.submissions.enabled() {
+		return gatePass
+	}
+	err := a.claimSubmission(ctx, proof)
+	if errors.Is(err, ErrSubmissionInProgress) {
+		// the path sending the same batches resets the verification
+		log.Warnf("Final proof for batches [%d-%d] not sent, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		a.finishFinalProof(proof, false)
+		return gateSkip
+	}
+	if errors.Is(err, ErrVerifiedConcurrently) {
+		log.Warnf("Final proof for batches [%d-%d] not sent, a concurrent verification won the race, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		err = a.waitForSync(ctx)
+		if ctx.Err() != nil {
+			return gateStop
+		}
+		if err != nil {
+			log.Warnf("Proof for batches [%d-%d] verified concurrently not cleaned up, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+			// unlock the underlying proof (generating=false)
+			proof.Generating = false
+			err = a.State.UpdateGeneratedProof(ctx, proof, nil)
+		} else {
+			err = a.deleteVerifiedProofs(ctx, proof.BatchNumber, proof.BatchNumberFinal)
+		}
+		if err != nil {
+			log.Errorf("Failed to delete proof for batches [%d-%d] verified concurrently, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		}
+		a.finishFinalProof(proof, false)
+		a.resetVerifyProofTime()
+		return gateSkip
+	}
+	if err != nil {
+		log.Errorf("Failed to check final proof for batches [%d-%d] before sending it, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		a.dropFinalProof(ctx, proof)
+		return gateSkip
+	}
+	return gatePass
+}
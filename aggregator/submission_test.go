@@ -0,0 +1,67 @@
+package aggregator
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimSubmissionConcurrently(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	a := Aggregator{
+		State:       st,
+		Ethman:      eth,
+		submissions: newFinalProofSubmissions(),
+	}
+	ctx := context.Background()
+	proof := &state.Proof{BatchNumber: 11, BatchNumberFinal: 20}
+
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(10), nil).Once()
+	st.On("CheckProofPendingVerification", ctx, uint64(11), uint64(20), nil).Return(true, nil).Once()
+
+	// the aggregate->finalize path and the channel loop race to send the
+	// same batches, only one of them wins
+	const paths = 8
+	errs := make([]error, paths)
+	var wg sync.WaitGroup
+	for i := 0; i < paths; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = a.claimSubmission(ctx, &state.Proof{BatchNumber: proof.BatchNumber, BatchNumberFinal: proof.BatchNumberFinal})
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		if err == nil {
+			won++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrSubmissionInProgress)
+	}
+	require.Equal(t, 1, won)
+
+	// overlapping ranges are rejected as well
+	assert.ErrorIs(t, a.claimSubmission(ctx, &state.Proof{BatchNumber: 15, BatchNumberFinal: 25}), ErrSubmissionInProgress)
+
+	// once the winner has verified the proof, the loser finds it verified
+	a.releaseSubmission(proof)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(10), nil).Once()
+	st.On("CheckProofPendingVerification", ctx, uint64(11), uint64(20), nil).Return(false, nil).Once()
+	assert.ErrorIs(t, a.claimSubmission(ctx, proof), ErrVerifiedConcurrently)
+
+	// or verified on L1
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(20), nil).Once()
+	assert.ErrorIs(t, a.claimSubmission(ctx, proof), ErrVerifiedConcurrently)
+
+	// the failed claims are released
+	assert.True(t, a.submissions.claim(batchRange{batchNumber: 11, batchNumberFinal: 20}))
+}
@@ -0,0 +1,64 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// syncChecker tells whether the synchronizer caught up with the batches
+// verified on L1.
+type syncChecker interface {
+	isSynced(ctx context.Context) bool
+}
+
+// waitSynced polls the checker every interval until synced, for maxWait at
+// most if set. It returns the context error if the context is done before,
+// or ErrSyncWaitTimeout if the maximum wait elapses.
+func waitSynced(ctx context.Context, checker syncChecker, interval, maxWait time.Duration) error {
+	var deadline <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for !checker.isSynced(ctx) {
+		log.Info("Waiting for synchronizer to sync...")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("%w after %s", ErrSyncWaitTimeout, maxWait)
+		case <-time.After(interval):
+		}
+	}
+	return nil
+}
+
+// waitForSync waits for the synchronizer to catch up with the batches
+// verified on L1, for MaxSyncWaitTime at most if set.
+func (a *Aggregator) waitForSync(ctx context.Context) error {
+	return waitSynced(ctx, a, a.cfg.RetryTime.Duration, a.cfg.MaxSyncWaitTime.Duration)
+}
+
+// syncVerifiedFinalProof waits for the synchronizer to catch up with the
+// batches of the final proof just verified. The proof is kept, to be cleaned
+// up on restart, if the synchronizer doesn't catch up in time.
+func (a *Aggregator) syncVerifiedFinalProof(ctx context.Context, proof *state.Proof) gateResult {
+	log.Debug("A final proof has been sent, waiting for the network to be synced")
+	err := a.waitForSync(ctx)
+	if ctx.Err() != nil {
+		return gateStop
+	}
+	if err != nil {
+		// the clean up is resumed on restart
+		log.Warnf("Proofs for batches [%d-%d] verified but not cleaned up, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+		a.resetVerifyProofTime()
+		return gateSkip
+	}
+	return gatePass
+}
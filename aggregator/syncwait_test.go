@@ -0,0 +1,56 @@
+package aggregator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForSyncContextDone(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	eth := mocks.NewEtherman(t)
+	a := Aggregator{
+		cfg:    Config{RetryTime: types.NewDuration(time.Hour)},
+		State:  st,
+		Ethman: eth,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	st.On("GetLastVerifiedBatch", ctx, nil).Return(&state.VerifiedBatch{BatchNumber: 10}, nil)
+	eth.On("GetLatestVerifiedBatchNum").Return(uint64(12), nil)
+
+	// the wait is interrupted without waiting for the retry time
+	err := a.waitForSync(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// syncCheckerStub is synced once checked the given number of times.
+type syncCheckerStub struct {
+	checks     int
+	syncedFrom int
+}
+
+func (s *syncCheckerStub) isSynced(ctx context.Context) bool {
+	s.checks++
+	return s.syncedFrom > 0 && s.checks >= s.syncedFrom
+}
+
+func TestWaitSynced(t *testing.T) {
+	ctx := context.Background()
+
+	checker := &syncCheckerStub{syncedFrom: 3}
+	require.NoError(t, waitSynced(ctx, checker, time.Millisecond, 0))
+	assert.Equal(t, 3, checker.checks)
+
+	// never synced
+	checker = &syncCheckerStub{}
+	err := waitSynced(ctx, checker, time.Millisecond, 20*time.Millisecond)
+	assert.ErrorIs(t, err, ErrSyncWaitTimeout)
+}
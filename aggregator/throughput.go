@@ -0,0 +1,136 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/events"
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// throughputMetricsInterval is the interval to refresh the throughput metrics,
+// so they decay even if no events happen.
+const throughputMetricsInterval = time.Minute
+
+// Throughput is the throughput of the proof pipeline over the rolling window.
+type Throughput struct {
+	Window                 string  `json:"window"`
+	BatchesVerifiedPerHour float64 `json:"batchesVerifiedPerHour"`
+	ProofsGeneratedPerHour float64 `json:"proofsGeneratedPerHour"`
+	// WindowComplete is false until the aggregator has been running for the
+	// whole window, the rates are computed over the whole window anyway so
+	// they ramp up after a restart instead of spiking
+	WindowComplete bool `json:"windowComplete"`
+}
+
+type throughputSample struct {
+	at    time.Time
+	count uint64
+}
+
+// throughputTracker keeps rolling counters of the batches verified and the
+// proofs generated, derived from the proof lifecycle events.
+type throughputTracker struct {
+	window  time.Duration
+	started time.Time
+
+	mu       sync.Mutex
+	verified []throughputSample
+	proofs   []throughputSample
+}
+
+func newThroughputTracker(window time.Duration) *throughputTracker {
+	return &throughputTracker{
+		window:  window,
+		started: time.Now(),
+	}
+}
+
+// record counts the event if it's relevant for the throughput, it's safe to
+// call it on a nil tracker.
+func (t *throughputTracker) record(eventType events.EventType, batchNumber, batchNumberFinal uint64, at time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch eventType {
+	case events.EventVerified:
+		t.verified = append(t.verified, throughputSample{at: at, count: batchNumberFinal - batchNumber + 1})
+	case events.EventProofGenerated, events.EventAggregationDone:
+		t.proofs = append(t.proofs, throughputSample{at: at, count: 1})
+	}
+}
+
+// get returns the throughput over the window ending at the given time.
+func (t *throughputTracker) get(now time.Time) Throughput {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := now.Add(-t.window)
+	t.verified = pruneThroughputSamples(t.verified, since)
+	t.proofs = pruneThroughputSamples(t.proofs, since)
+
+	hours := t.window.Hours()
+	return Throughput{
+		Window:                 t.window.String(),
+		BatchesVerifiedPerHour: float64(sumThroughputSamples(t.verified)) / hours,
+		ProofsGeneratedPerHour: float64(sumThroughputSamples(t.proofs)) / hours,
+		WindowComplete:         !t.started.After(since),
+	}
+}
+
+func pruneThroughputSamples(samples []throughputSample, since time.Time) []throughputSample {
+	i := 0
+	for i < len(samples) && samples[i].at.Before(since) {
+		i++
+	}
+	return samples[i:]
+}
+
+func sumThroughputSamples(samples []throughputSample) uint64 {
+	var sum uint64
+	for _, s := range samples {
+		sum += s.count
+	}
+	return sum
+}
+
+// Throughput returns the throughput of the proof pipeline over the configured
+// window.
+func (a *Aggregator) Throughput() Throughput {
+	if a.throughput == nil {
+		return Throughput{}
+	}
+	return a.throughput.get(time.Now())
+}
+
+// handleThroughput serves the throughput of the proof pipeline as JSON.
+func (a *Aggregator) handleThroughput(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.Throughput()); err != nil {
+		log.Errorf("Failed to encode throughput, err: %v", err)
+	}
+}
+
+// runThroughputMetrics refreshes the throughput metrics until the context is
+// done.
+func (a *Aggregator) runThroughputMetrics(ctx context.Context) {
+	ticker := time.NewTicker(throughputMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		throughput := a.Throughput()
+		metrics.Throughput(throughput.BatchesVerifiedPerHour, throughput.ProofsGeneratedPerHour)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThroughputTracker(t *testing.T) {
+	tracker := newThroughputTracker(time.Hour)
+	start := tracker.started
+
+	tracker.record(events.EventProofStarted, 1, 1, start)
+	tracker.record(events.EventProofGenerated, 1, 1, start.Add(time.Minute))
+	tracker.record(events.EventProofGenerated, 2, 2, start.Add(2*time.Minute))
+	tracker.record(events.EventAggregationDone, 1, 2, start.Add(3*time.Minute))
+	tracker.record(events.EventVerified, 1, 2, start.Add(4*time.Minute))
+
+	// rates are computed over the whole window right after starting
+	throughput := tracker.get(start.Add(5 * time.Minute))
+	assert.Equal(t, float64(2), throughput.BatchesVerifiedPerHour)
+	assert.Equal(t, float64(3), throughput.ProofsGeneratedPerHour)
+	assert.False(t, throughput.WindowComplete)
+
+	tracker.record(events.EventVerified, 3, 5, start.Add(90*time.Minute))
+
+	// the first events are out of the window
+	throughput = tracker.get(start.Add(100 * time.Minute))
+	assert.Equal(t, float64(3), throughput.BatchesVerifiedPerHour)
+	assert.Equal(t, float64(0), throughput.ProofsGeneratedPerHour)
+	assert.True(t, throughput.WindowComplete)
+}
+
+func TestHandleThroughput(t *testing.T) {
+	a := Aggregator{throughput: newThroughputTracker(2 * time.Hour)}
+	a.throughput.record(events.EventVerified, 1, 4, time.Now())
+
+	rec := httptest.NewRecorder()
+	a.handleThroughput(rec, httptest.NewRequest(http.MethodGet, "/status/throughput", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var throughput Throughput
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&throughput))
+	assert.Equal(t, "2h0m0s", throughput.Window)
+	assert.Equal(t, float64(2), throughput.BatchesVerifiedPerHour)
+}
@@ -0,0 +1,85 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+)
+
+// VerificationTimer is the state of the timer gating the verification of the
+// final proofs.
+type VerificationTimer struct {
+	// TimeSendFinalProof is the time from which a final proof can be built
+	TimeSendFinalProof time.Time `json:"timeSendFinalProof"`
+	// VerifyingProof is true while a final proof is being built or sent, no
+	// other final proof is built meanwhile
+	VerifyingProof bool `json:"verifyingProof"`
+	// UntilNextVerification is the time left to reach TimeSendFinalProof, 0
+	// if already reached
+	UntilNextVerification string `json:"untilNextVerification"`
+	// QuietPeriodElapsed is false while the startup quiet period holds the
+	// verification
+	QuietPeriodElapsed bool `json:"quietPeriodElapsed"`
+	// UntilNextSubmission is the time left for the submission cool-down to
+	// allow the next final proof to be sent to L1, 0 if already allowed
+	UntilNextSubmission string `json:"untilNextSubmission"`
+}
+
+// VerificationTimer returns the state of the timer gating the verification of
+// the final proofs.
+func (a *Aggregator) VerificationTimer() VerificationTimer {
+	a.TimeSendFinalProofMutex.RLock()
+	defer a.TimeSendFinalProofMutex.RUnlock()
+
+	until := time.Until(a.TimeSendFinalProof)
+	if until < 0 {
+		until = 0
+	}
+	untilSubmission := a.cooldown.remaining(time.Now())
+	return VerificationTimer{
+		TimeSendFinalProof:    a.TimeSendFinalProof,
+		VerifyingProof:        a.verifyingProof,
+		UntilNextVerification: until.Round(time.Second).String(),
+		QuietPeriodElapsed:    a.quietPeriodElapsed(),
+		UntilNextSubmission:   untilSubmission.Round(time.Second).String(),
+	}
+}
+
+// ResetVerificationTimer re-arms the timer gating the verification of the
+// final proofs, so a final proof can be built once the verify proof interval
+// elapses. It also clears the flag of a final proof being verified, so it
+// must only be used when the verification is known to be stuck.
+func (a *Aggregator) ResetVerificationTimer() VerificationTimer {
+	timer := a.VerificationTimer()
+	if timer.VerifyingProof {
+		log.Warn("Resetting the verification timer while a final proof is being verified")
+	}
+	a.resetVerifyProofTime()
+	log.Infof("Verification timer reset, previous state: %+v", timer)
+	return a.VerificationTimer()
+}
+
+// handleVerificationTimer serves the state of the verification timer as JSON.
+func (a *Aggregator) handleVerificationTimer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.VerificationTimer()); err != nil {
+		log.Errorf("Failed to encode verification timer, err: %v", err)
+	}
+}
+
+// handleResetVerificationTimer resets the verification timer and serves its
+// new state as JSON.
+func (a *Aggregator) handleResetVerificationTimer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	timer := a.ResetVerificationTimer()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(timer); err != nil {
+		log.Errorf("Failed to encode verification timer, err: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package aggregator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/aggregator/mocks"
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerificationTimer(t *testing.T) {
+	st := mocks.NewStateMock(t)
+	a := Aggregator{
+		cfg:                     Config{VerifyProofInterval: types.NewDuration(time.Hour)},
+		State:                   st,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+	}
+	st.On("SetLastVerifyProofTime", mock.Anything, mock.Anything, nil).Return(nil).Once()
+
+	// the interval has elapsed and a final proof is being verified
+	a.TimeSendFinalProof = time.Now().Add(-time.Minute)
+	require.True(t, a.canVerifyProof())
+
+	timer := a.VerificationTimer()
+	assert.True(t, timer.VerifyingProof)
+	assert.Equal(t, "0s", timer.UntilNextVerification)
+	assert.True(t, timer.QuietPeriodElapsed)
+
+	// reset by an operator on the admin server
+	rec := httptest.NewRecorder()
+	a.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/verification/timer/reset", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&timer))
+	assert.False(t, timer.VerifyingProof)
+	assert.Equal(t, "1h0m0s", timer.UntilNextVerification)
+	assert.False(t, a.canVerifyProof())
+
+	rec = httptest.NewRecorder()
+	a.handleVerificationTimer(rec, httptest.NewRequest(http.MethodGet, "/status/verification/timer", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got VerificationTimer
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	assert.False(t, got.VerifyingProof)
+	assert.WithinDuration(t, a.TimeSendFinalProof, got.TimeSendFinalProof, time.Millisecond)
+}
+
+func TestResumeVerifyProofTime(t *testing.T) {
+	ctx := context.Background()
+	st := mocks.NewStateMock(t)
+	a := Aggregator{
+		cfg:                     Config{VerifyProofInterval: types.NewDuration(time.Hour)},
+		State:                   st,
+		TimeSendFinalProofMutex: &sync.RWMutex{},
+	}
+
+	// the interval is counted from the last reset before the restart
+	lastVerifyProofTime := time.Now().Add(-40 * time.Minute)
+	st.On("GetLastVerifyProofTime", ctx, nil).Return(lastVerifyProofTime, nil).Once()
+	a.resumeVerifyProofTime(ctx)
+	assert.Equal(t, lastVerifyProofTime.Add(time.Hour), a.VerificationTimer().TimeSendFinalProof)
+
+	// the interval is never held longer on a clock skew
+	st.On("GetLastVerifyProofTime", ctx, nil).Return(time.Now().Add(time.Hour), nil).Once()
+	a.resumeVerifyProofTime(ctx)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), a.VerificationTimer().TimeSendFinalProof, time.Second)
+
+	// the timer is reset, and persisted, if no time was persisted
+	st.On("GetLastVerifyProofTime", ctx, nil).Return(time.Time{}, state.ErrNotFound).Once()
+	st.On("SetLastVerifyProofTime", mock.Anything, mock.Anything, nil).Return(nil).Once()
+	a.resumeVerifyProofTime(ctx)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), a.VerificationTimer().TimeSendFinalProof, time.Second)
+
+	// the timer is reset if the persisted time can't be read
+	st.On("GetLastVerifyProofTime", ctx, nil).Return(time.Time{}, errors.New("connection refused")).Once()
+	st.On("SetLastVerifyProofTime", mock.Anything, mock.Anything, nil).Return(errors.New("connection refused")).Once()
+	a.resumeVerifyProofTime(ctx)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), a.VerificationTimer().TimeSendFinalProof, time.Second)
+}
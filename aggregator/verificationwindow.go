@@ -0,0 +1,119 @@
+package aggregator
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+)
+
+// VerificationAccumulation is the range of batches accumulated to be verified
+// in a single L1 tx.
+type VerificationAccumulation struct {
+	Accumulating     bool   `json:"accumulating"`
+	BatchNumber      uint64 `json:"batchNumber"`
+	BatchNumberFinal uint64 `json:"batchNumberFinal"`
+	WindowRemaining  string `json:"windowRemaining"`
+}
+
+// verificationWindow delays the verification of the eligible proofs to let
+// the contiguous ones be aggregated, so a bigger range of batches is verified
+// in a single L1 tx. The window starts when a proof starting at the next batch
+// to verify is first eligible.
+type verificationWindow struct {
+	window   time.Duration
+	maxRange uint64
+
+	mu               sync.Mutex
+	accumulating     bool
+	batchNumber      uint64
+	batchNumberFinal uint64
+	since            time.Time
+}
+
+func newVerificationWindow(cfg VerificationWindowConfig) *verificationWindow {
+	return &verificationWindow{
+		window:   cfg.Window.Duration,
+		maxRange: cfg.MaxRange,
+	}
+}
+
+// ready accumulates the eligible proof and returns whether it must be verified
+// now, because the window has elapsed or its range has reached the max range.
+// It's safe to call it on a nil window.
+func (v *verificationWindow) ready(proof *state.Proof, now time.Time) bool {
+	if v == nil {
+		return true
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.accumulating || v.batchNumber != proof.BatchNumber {
+		v.accumulating = true
+		v.batchNumber = proof.BatchNumber
+		v.since = now
+	}
+	v.batchNumberFinal = proof.BatchNumberFinal
+
+	if v.maxRange > 0 && v.batchNumberFinal-v.batchNumber+1 >= v.maxRange {
+		return true
+	}
+	if now.Sub(v.since) >= v.window {
+		return true
+	}
+	log.Infof("Accumulating batches %d-%d to be verified, %v remaining",
+		v.batchNumber, v.batchNumberFinal, v.window-now.Sub(v.since))
+	return false
+}
+
+// reset starts a new accumulation once the accumulated range is verified.
+func (v *verificationWindow) reset() {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.accumulating = false
+}
+
+// status returns the current accumulation.
+func (v *verificationWindow) status(now time.Time) VerificationAccumulation {
+	if v == nil {
+		return VerificationAccumulation{}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.accumulating {
+		return VerificationAccumulation{}
+	}
+	remaining := v.window - now.Sub(v.since)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return VerificationAccumulation{
+		Accumulating:     true,
+		BatchNumber:      v.batchNumber,
+		BatchNumberFinal: v.batchNumberFinal,
+		WindowRemaining:  remaining.Round(time.Second).String(),
+	}
+}
+
+// VerificationAccumulation returns the range of batches currently accumulated
+// to be verified.
+func (a *Aggregator) VerificationAccumulation() VerificationAccumulation {
+	return a.verificationWindow.status(time.Now())
+}
+
+// handleVerificationAccumulation serves the range of batches currently
+// accumulated to be verified as JSON.
+func (a *Aggregator) handleVerificationAccumulation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.VerificationAccumulation()); err != nil {
+		log.Errorf("Failed to encode verification accumulation, err: %v", err)
+	}
+}
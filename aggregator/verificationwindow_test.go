@@ -0,0 +1,45 @@
+package aggregator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationWindow(t *testing.T) {
+	v := newVerificationWindow(VerificationWindowConfig{
+		Window:   types.NewDuration(10 * time.Minute),
+		MaxRange: 10,
+	})
+	now := time.Now()
+
+	assert.False(t, v.ready(&state.Proof{BatchNumber: 1, BatchNumberFinal: 2}, now))
+	assert.Equal(t, VerificationAccumulation{
+		Accumulating:     true,
+		BatchNumber:      1,
+		BatchNumberFinal: 2,
+		WindowRemaining:  "10m0s",
+	}, v.status(now))
+
+	// the range grows within the window
+	assert.False(t, v.ready(&state.Proof{BatchNumber: 1, BatchNumberFinal: 5}, now.Add(4*time.Minute)))
+	assert.Equal(t, "6m0s", v.status(now.Add(4*time.Minute)).WindowRemaining)
+	assert.Equal(t, uint64(5), v.status(now).BatchNumberFinal)
+
+	// the window elapsed
+	assert.True(t, v.ready(&state.Proof{BatchNumber: 1, BatchNumberFinal: 5}, now.Add(10*time.Minute)))
+
+	// a new window starts once verified
+	v.reset()
+	assert.False(t, v.status(now).Accumulating)
+	assert.False(t, v.ready(&state.Proof{BatchNumber: 6, BatchNumberFinal: 8}, now.Add(11*time.Minute)))
+
+	// the max range is reached
+	assert.True(t, v.ready(&state.Proof{BatchNumber: 6, BatchNumberFinal: 15}, now.Add(12*time.Minute)))
+
+	var disabled *verificationWindow
+	assert.True(t, disabled.ready(&state.Proof{BatchNumber: 1, BatchNumberFinal: 1}, now))
+}
@@ -0,0 +1,25 @@
+package aggregator
+
+import (
+	"context"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/jackc/pgx/v4"
+)
+
+// verifiedProofMarker checkpoints the verification of the proofs on L1.
+type verifiedProofMarker interface {
+	MarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error
+}
+
+// markProofVerified checkpoints the verification of the proof, so its clean
+// up is resumed if the aggregator restarts while waiting for the
+// synchronizer. A failure is only logged, the proof is then cleaned up once
+// synced unless the aggregator restarts before.
+func markProofVerified(ctx context.Context, store verifiedProofMarker, proof *state.Proof) {
+	err := store.MarkProofVerified(ctx, proof.BatchNumber, proof.BatchNumberFinal, nil)
+	if err != nil {
+		log.Errorf("Failed to mark proof for batches [%d-%d] as verified, err: %v", proof.BatchNumber, proof.BatchNumberFinal, err)
+	}
+}
@@ -66,26 +66,39 @@ func start(cliCtx *cli.Context) error {
 	if err != nil {
 		log.Fatal(err)
 	}
+	verifyBatchesEtherman, err := newVerifyBatchesEtherman(*c, etherman)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// READ CHAIN ID FROM POE SC
 	l2ChainID, err := etherman.GetL2ChainID()
 	if err != nil {
 		log.Fatal(err)
 	}
-	c.Aggregator.ChainID = l2ChainID
+	if c.Aggregator.ChainID == 0 {
+		c.Aggregator.ChainID = l2ChainID
+	}
+	c.Aggregator.L1ChainID = c.Etherman.L1ChainID
 	c.RPC.ChainID = l2ChainID
 	log.Infof("Chain ID read from POE SC = %v", l2ChainID)
 
 	ctx := context.Background()
-	st := newState(ctx, c, l2ChainID, stateSqlDB)
+	st, err := newState(ctx, c, l2ChainID, stateSqlDB)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	ethTxManager := ethtxmanager.New(c.EthTxManager, etherman, st)
+	ethTxManager, err := ethtxmanager.NewWithVerifyBatchesEtherman(c.EthTxManager, etherman, verifyBatchesEtherman, st)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	for _, item := range cliCtx.StringSlice(config.FlagComponents) {
 		switch item {
 		case AGGREGATOR:
 			log.Info("Running aggregator")
-			go runAggregator(ctx, c.Aggregator, etherman, ethTxManager, st)
+			go runAggregator(ctx, c, verifyBatchesEtherman, ethTxManager, st, stateSqlDB)
 		case SEQUENCER:
 			log.Info("Running sequencer")
 			poolInstance := createPool(c.PoolDB, c.NetworkConfig.L2BridgeAddr, l2ChainID, st)
@@ -150,6 +163,29 @@ func newEtherman(c config.Config) (*etherman.Client, error) {
 	return etherman, nil
 }
 
+// newVerifyBatchesEtherman returns the etherman to send the verify batches txs,
+// it's the default one unless a different key is configured for them.
+func newVerifyBatchesEtherman(c config.Config, defaultEtherman *etherman.Client) (*etherman.Client, error) {
+	if c.Etherman.VerifyBatchesPrivateKeyPath == "" && c.Etherman.VerifyBatchesPrivateKeyPassword == "" {
+		return defaultEtherman, nil
+	}
+	auth, err := newAuthFromKeystore(c.Etherman.VerifyBatchesPrivateKeyPath, c.Etherman.VerifyBatchesPrivateKeyPassword, c.Etherman.L1ChainID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the verify batches key, err: %w", err)
+	}
+	if defaultEtherman.IsReadOnly() {
+		return nil, fmt.Errorf("verify batches key configured without the sequences key in PrivateKeyPath")
+	}
+	defaultAddr, err := defaultEtherman.GetPublicAddress()
+	if err != nil {
+		return nil, err
+	}
+	if defaultAddr == auth.From {
+		return nil, fmt.Errorf("verify batches key must be different from the sequences key, both are %s", auth.From)
+	}
+	return etherman.NewClient(c.Etherman, auth)
+}
+
 func runSynchronizer(cfg config.Config, etherman *etherman.Client, st *state.State) {
 	sy, err := synchronizer.NewSynchronizer(cfg.IsTrustedSequencer, etherman, st, cfg.NetworkConfig.Genesis, cfg.Synchronizer)
 	if err != nil {
@@ -183,11 +219,24 @@ func createSequencer(c config.Config, pool *pool.Pool, state *state.State, ether
 	return seq
 }
 
-func runAggregator(ctx context.Context, c aggregator.Config, ethman *etherman.Client, ethTxManager *ethtxmanager.Client, state *state.State) {
-	agg, err := aggregator.New(c, state, ethTxManager, ethman)
+func runAggregator(ctx context.Context, c *config.Config, ethman *etherman.Client, ethTxManager *ethtxmanager.Client, state *state.State, sqlDB *pgxpool.Pool) {
+	var leaderLock *aggregator.PGLeaderLock
+	if c.Aggregator.LeaderElection.Enabled {
+		switch c.Aggregator.LeaderElection.Backend {
+		case aggregator.LeaderLockPostgres:
+			leaderLock = aggregator.NewPGLeaderLock(sqlDB, c.Aggregator.LeaderElection.LockID)
+		default:
+			log.Fatalf("unsupported aggregator leader lock backend %q", c.Aggregator.LeaderElection.Backend)
+		}
+	}
+	agg, err := aggregator.New(c.Aggregator, state, ethTxManager, ethman, leaderLock)
 	if err != nil {
 		log.Fatal(err)
 	}
+	config.Watch(c, func(newCfg *config.Config) {
+		agg.ReloadProfitabilityParams(newCfg.Aggregator)
+		agg.ReloadBatchFilter(newCfg.Aggregator)
+	})
 	err = agg.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
@@ -271,10 +320,13 @@ func newAuthFromKeystore(path, password string, chainID uint64) (*bind.TransactO
 	return auth, nil
 }
 
-func newState(ctx context.Context, c *config.Config, l2ChainID uint64, sqlDB *pgxpool.Pool) *state.State {
+func newState(ctx context.Context, c *config.Config, l2ChainID uint64, sqlDB *pgxpool.Pool) (*state.State, error) {
 	stateDb := state.NewPostgresStorage(sqlDB)
 	executorClient, _, _ := executor.NewExecutorClient(ctx, c.Executor)
-	stateDBClient, _, _ := merkletree.NewMTDBServiceClient(ctx, c.MTClient)
+	stateDBClient, _, _, err := merkletree.NewMTDBServiceClient(ctx, c.MTClient)
+	if err != nil {
+		return nil, err
+	}
 	stateTree := merkletree.NewStateTree(stateDBClient)
 
 	stateCfg := state.Config{
@@ -283,7 +335,7 @@ func newState(ctx context.Context, c *config.Config, l2ChainID uint64, sqlDB *pg
 	}
 
 	st := state.NewState(stateCfg, stateDb, executorClient, stateTree)
-	return st
+	return st, nil
 }
 
 func createPool(poolDBConfig db.Config, l2BridgeAddr common.Address, l2ChainID uint64, st *state.State) *pool.Pool {
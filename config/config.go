@@ -19,6 +19,7 @@ import (
 	"github.com/0xPolygonHermez/zkevm-node/sequencer/broadcast"
 	"github.com/0xPolygonHermez/zkevm-node/state/runtime/executor"
 	"github.com/0xPolygonHermez/zkevm-node/synchronizer"
+	"github.com/fsnotify/fsnotify"
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 	"github.com/urfave/cli/v2"
@@ -125,3 +126,19 @@ func Load(ctx *cli.Context) (*Config, error) {
 	*/
 	return cfg, nil
 }
+
+// Watch watches the config file loaded by Load and calls onChange with a copy
+// of cfg updated with the new file contents every time the file changes.
+func Watch(cfg *Config, onChange func(*Config)) {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		log.Infof("config file changed: %s", e.Name)
+		newCfg := *cfg
+		err := viper.Unmarshal(&newCfg, viper.DecodeHook(mapstructure.TextUnmarshallerHookFunc()))
+		if err != nil {
+			log.Errorf("error unmarshaling reloaded config: %v", err)
+			return
+		}
+		onChange(&newCfg)
+	})
+	viper.WatchConfig()
+}
@@ -148,6 +148,14 @@ func Test_Defaults(t *testing.T) {
 			path:          "Etherman.PrivateKeyPassword",
 			expectedValue: "",
 		},
+		{
+			path:          "Etherman.VerifyBatchesPrivateKeyPath",
+			expectedValue: "",
+		},
+		{
+			path:          "Etherman.VerifyBatchesPrivateKeyPassword",
+			expectedValue: "",
+		},
 		{
 			path:          "Etherman.PoEAddr",
 			expectedValue: common.HexToAddress("0x2279B7A0a67DB372996a5FaB50D91eAA73d2eBe6"),
@@ -188,6 +196,14 @@ func Test_Defaults(t *testing.T) {
 			path:          "EthTxManager.WaitTxToBeSynced",
 			expectedValue: types.NewDuration(10 * time.Second),
 		},
+		{
+			path:          "EthTxManager.ConfirmationsToWait",
+			expectedValue: uint64(1),
+		},
+		{
+			path:          "EthTxManager.VerifyBatchTxMiningWindow",
+			expectedValue: types.NewDuration(0),
+		},
 		{
 			path:          "EthTxManager.PercentageToIncreaseGasPrice",
 			expectedValue: uint64(10),
@@ -196,6 +212,22 @@ func Test_Defaults(t *testing.T) {
 			path:          "EthTxManager.PercentageToIncreaseGasLimit",
 			expectedValue: uint64(10),
 		},
+		{
+			path:          "EthTxManager.MaxGasPriceWei",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "EthTxManager.MinGasPriceWei",
+			expectedValue: uint64(0),
+		},
+		{
+			path:          "EthTxManager.FillNonceGaps",
+			expectedValue: false,
+		},
+		{
+			path:          "EthTxManager.MaxNonceGapFillers",
+			expectedValue: uint64(5),
+		},
 		{
 			path:          "PriceGetter.Type",
 			expectedValue: pricegetter.DefaultType,
@@ -212,6 +244,26 @@ func Test_Defaults(t *testing.T) {
 			path:          "MTClient.URI",
 			expectedValue: "127.0.0.1:50061",
 		},
+		{
+			path:          "MTClient.FallbackURI",
+			expectedValue: "",
+		},
+		{
+			path:          "MTClient.LoadBalancingPolicy",
+			expectedValue: "",
+		},
+		{
+			path:          "MTClient.MaxConnectionRetries",
+			expectedValue: 5,
+		},
+		{
+			path:          "MTClient.RetryInterval",
+			expectedValue: types.NewDuration(2 * time.Second),
+		},
+		{
+			path:          "MTClient.TLS.Enabled",
+			expectedValue: false,
+		},
 		{
 			path:          "StateDB.User",
 			expectedValue: "state_user",
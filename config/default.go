@@ -44,8 +44,21 @@ FrequencyForResendingFailedSendBatches = "1s"
 FrequencyForResendingFailedVerifyBatch = "1s"
 WaitTxToBeMined = "2m"
 WaitTxToBeSynced = "10s"
+WaitTxToBeSyncedRetries = 3
+WaitTxToBeSyncedBackoff = "5s"
+ConfirmationsToWait = 1
+WaitTxToBeConfirmed = "0s"
+VerifyBatchTxMiningWindow = "0s"
 PercentageToIncreaseGasPrice = 10
 PercentageToIncreaseGasLimit = 10
+MaxGasPriceWei = 0
+MinGasPriceWei = 0
+FillNonceGaps = false
+MaxNonceGapFillers = 5
+ManageNonces = false
+EscalateAfterResubmissions = 0
+EscalationAction = "alert"
+EscalationPercentageToIncreaseGasPrice = 50
 
 [RPC]
 Host = "0.0.0.0"
@@ -98,11 +111,112 @@ DefaultPrice = "2000"
 [Aggregator]
 Host = "0.0.0.0"
 Port = 50081
+MaxRecvMsgSize = 0
+MaxSendMsgSize = 0
+StatusPort = 0
+AdminHost = "127.0.0.1"
+AdminPort = 0
+AdminToken = ""
 RetryTime = "5s"
+MaxSyncWaitTime = "0s"
 VerifyProofInterval = "90s"
+FinalProofSubmissionCooldown = "0s"
 TxProfitabilityCheckerType = "acceptall"
 TxProfitabilityMinReward = "1.1"
+MinBatchesToVerify = 0
 ProofStatePollingInterval = "5s"
+BatchProofTimeout = "30m"
+AggregatedProofTimeout = "30m"
+FinalProofTimeout = "30m"
+ProofGenerationTimeout = "0s"
+ProofTTL = "0s"
+CheckGlobalExitRoot = false
+FailOnStaleGlobalExitRoot = false
+CheckBatchL2Data = false
+TransientDisconnectHold = "0s"
+CheckVerifiedStateRoot = false
+ProofStore = "postgres"
+ProofCacheSize = 8
+MaxConcurrentSerializations = 4
+MaxConcurrentBatchProofs = 0
+ChannelOperationsOrder = ["buildfinalproof", "aggregateproofs", "generatebatchproof"]
+VerificationHistorySize = 1000
+NotSyncedWhenAheadOfL1 = false
+DetectVerifiedBatchReorgs = false
+FilterProofsByProverCapabilities = false
+ThroughputWindow = "1h"
+ShutdownTimeout = "1m"
+BatchAffinityWait = "0s"
+PreferredOperationRouting = false
+ProverSelection = "firstcome"
+ProofReadyToVerifyPreference = "largest"
+BatchL2DataCompression = "none"
+StartupQuietPeriod = "0s"
+FinalBatchRetries = 5
+FinalBatchRetryInterval = "1s"
+AggregatedProofUnlockRetries = 3
+AggregatedProofUnlockRetryInterval = "1s"
+CompleteSequencesCheckFailOpen = false
+ProofCommitments = false
+MaxAggregationDepth = 0
+MaxBatchesPerAggregatedProof = 0
+VerifiedProofsCleanupChunkSize = 0
+ProofDeletionConfirmations = 0
+RecheckBeforeSendingFinalProof = true
+CheckFinalProofInputs = true
+DryRun = false
+UseMockProverValues = false
+DeduplicateFinalProofs = false
+DBHealthCheckInterval = "10s"
+	[Aggregator.HealthCheck]
+	NoProversGracePeriod = "0s"
+	L1 = false
+	WatchInterval = "1s"
+	[Aggregator.ChainIDCheck]
+	Enabled = true
+	WarnOnly = false
+	[Aggregator.LeaderElection]
+	Enabled = false
+	Backend = "postgres"
+	LockID = 1
+	RetryInterval = "5s"
+	[Aggregator.L1RateLimit]
+	RequestsPerSecond = 0
+	Burst = 1
+	MaxWait = "1m"
+	[Aggregator.VerificationWindow]
+	Window = "0s"
+	MaxRange = 0
+	[Aggregator.LoadShedding]
+	Enabled = false
+	MaxHeapMB = 0
+	MaxGoroutines = 0
+	CheckInterval = "5s"
+	[Aggregator.GRPCKeepalive]
+	Time = "0s"
+	Timeout = "0s"
+	MinTime = "0s"
+	PermitWithoutStream = false
+	[Aggregator.ProverKeepalive]
+	Interval = "0s"
+	Timeout = "1m"
+	[Aggregator.FailureCircuit]
+	Enabled = false
+	Window = "30m"
+	Threshold = 0.5
+	MinOperations = 10
+	AutoResume = true
+	[Aggregator.ProofRegeneration]
+	Enabled = false
+	Priority = "low"
+	[Aggregator.Events]
+	Enabled = false
+	URL = "nats://127.0.0.1:4222"
+	Subject = "aggregator.proofs"
+	BufferSize = 100
+	TLSCAFile = ""
+	TLSCertFile = ""
+	TLSKeyFile = ""
 
 [GasPriceEstimator]
 Type = "default"
@@ -118,6 +232,15 @@ StoreBackend = "PostgreSQL"
 
 [MTClient]
 URI = "127.0.0.1:50061"
+FallbackURI = ""
+LoadBalancingPolicy = ""
+MaxConnectionRetries = 5
+RetryInterval = "2s"
+	[MTClient.TLS]
+	Enabled = false
+	CACert = ""
+	ClientCert = ""
+	ClientKey = ""
 
 [Executor]
 URI = "127.0.0.1:50071"
@@ -17,6 +17,11 @@ func (d *Duration) UnmarshalText(data []byte) error {
 	return nil
 }
 
+// MarshalText marshalls time duration to text, the way it's unmarshalled.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
 // NewDuration returns Duration wrapper
 func NewDuration(duration time.Duration) Duration {
 	return Duration{time.Duration(duration)}
@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE state.proof_verification
+(
+    tx_hash         VARCHAR NOT NULL PRIMARY KEY,
+    batch_num       BIGINT NOT NULL,
+    batch_num_final BIGINT NOT NULL,
+    proof_id        VARCHAR,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS state.proof_verification;
@@ -0,0 +1,28 @@
+-- +migrate Up
+CREATE TABLE state.proof_data
+(
+    batch_num  BIGINT NOT NULL,
+    batch_num_final BIGINT NOT NULL,
+    proof VARCHAR,
+    input_prover VARCHAR,
+    PRIMARY KEY (batch_num, batch_num_final),
+    FOREIGN KEY (batch_num, batch_num_final) REFERENCES state.proof (batch_num, batch_num_final) ON DELETE CASCADE
+);
+
+INSERT INTO state.proof_data (batch_num, batch_num_final, proof, input_prover)
+SELECT batch_num, batch_num_final, proof, input_prover FROM state.proof;
+
+ALTER TABLE state.proof
+DROP COLUMN IF EXISTS proof,
+DROP COLUMN IF EXISTS input_prover;
+
+-- +migrate Down
+ALTER TABLE state.proof
+ADD COLUMN proof VARCHAR,
+ADD COLUMN input_prover VARCHAR;
+
+UPDATE state.proof p SET proof = d.proof, input_prover = d.input_prover
+FROM state.proof_data d
+WHERE p.batch_num = d.batch_num AND p.batch_num_final = d.batch_num_final;
+
+DROP TABLE state.proof_data;
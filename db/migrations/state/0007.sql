@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE state.proof
+ADD COLUMN commitment VARCHAR;
+
+-- +migrate Down
+ALTER TABLE state.proof
+DROP COLUMN IF EXISTS commitment;
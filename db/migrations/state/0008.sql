@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE state.proof
+ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +migrate Down
+ALTER TABLE state.proof
+DROP COLUMN IF EXISTS verified;
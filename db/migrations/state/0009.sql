@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE state.proof
+ADD COLUMN depth BIGINT NOT NULL DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE state.proof
+DROP COLUMN IF EXISTS depth;
@@ -0,0 +1,9 @@
+-- +migrate Up
+CREATE TABLE state.proof_regeneration
+(
+    batch_num  BIGINT NOT NULL PRIMARY KEY REFERENCES state.batch (batch_num) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS state.proof_regeneration;
@@ -0,0 +1,11 @@
+-- +migrate Up
+CREATE TABLE state.aggregator_info
+(
+    last_verify_proof_time TIMESTAMP WITH TIME ZONE
+);
+
+-- Insert default values into aggregator_info table
+INSERT INTO state.aggregator_info (last_verify_proof_time) VALUES (NULL);
+
+-- +migrate Down
+DROP TABLE IF EXISTS state.aggregator_info;
@@ -0,0 +1,9 @@
+-- +migrate Up
+CREATE TABLE state.account_nonce
+(
+    address    VARCHAR NOT NULL PRIMARY KEY,
+    next_nonce BIGINT NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS state.account_nonce;
@@ -17,6 +17,12 @@ type Config struct {
 	PrivateKeyPath     string `mapstructure:"PrivateKeyPath"`
 	PrivateKeyPassword string `mapstructure:"PrivateKeyPassword"`
 
+	// VerifyBatchesPrivateKeyPath and VerifyBatchesPrivateKeyPassword are the
+	// key used to sign the verify batches txs. If not set, they are signed by
+	// the key in PrivateKeyPath as the sequence txs
+	VerifyBatchesPrivateKeyPath     string `mapstructure:"VerifyBatchesPrivateKeyPath"`
+	VerifyBatchesPrivateKeyPassword string `mapstructure:"VerifyBatchesPrivateKeyPassword"`
+
 	MultiGasProvider bool `mapstructure:"MultiGasProvider"`
 	Etherscan        etherscan.Config
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -52,6 +53,8 @@ var (
 
 	// ErrNotFound is used when the object is not found
 	ErrNotFound = errors.New("Not found")
+	// ErrChainIDNotSupported is used when the L1 client can't report its chain id
+	ErrChainIDNotSupported = errors.New("L1 client doesn't support reading the chain id")
 	// ErrIsReadOnlyMode is used when the EtherMan client is in read-only mode.
 	ErrIsReadOnlyMode = errors.New("Etherman client in read-only mode: no account configured to send transactions to L1. " +
 		"Please check the [Etherman] PrivateKeyPath and PrivateKeyPassword configuration.")
@@ -86,6 +89,8 @@ type ethClienter interface {
 	ethereum.ContractCaller
 	ethereum.GasPricer
 	bind.DeployBackend
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
 }
 
 type externalGasProviders struct {
@@ -370,6 +375,23 @@ func (etherMan *Client) EstimateGasForTrustedVerifyBatches(lastVerifiedBatch, ne
 	return tx.Gas(), nil
 }
 
+// EstimateTrustedVerifyBatches builds the trusted verify batches tx with its
+// gas estimated, without sending it. The tx is built with the given gas price,
+// or the suggested one if nil.
+func (etherMan *Client) EstimateTrustedVerifyBatches(ctx context.Context, lastVerifiedBatch, newVerifiedBatch uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error) {
+	if etherMan.IsReadOnly() {
+		return nil, ErrIsReadOnlyMode
+	}
+	verifyBatchOpts := *etherMan.auth
+	verifyBatchOpts.NoSend = true
+	if gasPrice != nil {
+		verifyBatchOpts.GasPrice = gasPrice
+	} else if etherMan.GasProviders.MultiGasProvider {
+		verifyBatchOpts.GasPrice = etherMan.getGasPrice(ctx)
+	}
+	return etherMan.trustedVerifyBatches(&verifyBatchOpts, lastVerifiedBatch, newVerifiedBatch, inputs)
+}
+
 // TrustedVerifyBatches function allows the aggregator send the final proof to L1.
 func (etherMan *Client) TrustedVerifyBatches(ctx context.Context, lastVerifiedBatch, newVerifiedBatch uint64, inputs *ethmanTypes.FinalProofInputs, gasLimit uint64, gasPrice, nonce *big.Int) (*types.Transaction, error) {
 	if etherMan.IsReadOnly() {
@@ -802,6 +824,26 @@ func (etherMan *Client) GetLatestVerifiedBatchNum() (uint64, error) {
 	return etherMan.PoE.LastVerifiedBatch(&bind.CallOpts{Pending: false})
 }
 
+// GetVerifiedBatchStateRoot gets the state root stored in the smc for the
+// provided verified batch
+func (etherMan *Client) GetVerifiedBatchStateRoot(batchNumber uint64) (common.Hash, error) {
+	stateRoot, err := etherMan.PoE.BatchNumToStateRoot(&bind.CallOpts{Pending: false}, batchNumber)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.Hash(stateRoot), nil
+}
+
+// CheckReorg returns whether the batch is no longer verified on L1 with the
+// state root provided, as after an L1 reorg of its verification
+func (etherMan *Client) CheckReorg(batchNumber uint64, stateRoot common.Hash) (bool, error) {
+	verifiedStateRoot, err := etherMan.GetVerifiedBatchStateRoot(batchNumber)
+	if err != nil {
+		return false, err
+	}
+	return verifiedStateRoot != stateRoot, nil
+}
+
 // GetTx function get ethereum tx
 func (etherMan *Client) GetTx(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
 	return etherMan.EtherClient.TransactionByHash(ctx, txHash)
@@ -832,6 +874,57 @@ func (etherMan *Client) ApproveMatic(ctx context.Context, maticAmount *big.Int,
 	return tx, nil
 }
 
+// PendingNonce returns the next nonce of the account after the txs that can be
+// executed in the pool.
+func (etherMan *Client) PendingNonce(ctx context.Context) (uint64, error) {
+	if etherMan.IsReadOnly() {
+		return 0, ErrIsReadOnlyMode
+	}
+	return etherMan.EtherClient.PendingNonceAt(ctx, etherMan.auth.From)
+}
+
+// SendNonceFillerTx sends a zero value transfer to the account itself with the
+// given nonce, to fill a gap in the nonces of the account.
+func (etherMan *Client) SendNonceFillerTx(ctx context.Context, nonce uint64) (*types.Transaction, error) {
+	if etherMan.IsReadOnly() {
+		return nil, ErrIsReadOnlyMode
+	}
+	var gasPrice *big.Int
+	if etherMan.GasProviders.MultiGasProvider {
+		gasPrice = etherMan.getGasPrice(ctx)
+	} else {
+		var err error
+		gasPrice, err = etherMan.EtherClient.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting gas price. Error: %w", err)
+		}
+	}
+	return etherMan.sendSelfTransfer(ctx, nonce, gasPrice, "nonce filler")
+}
+
+// SendCancelTx sends a zero value transfer to the account itself with the
+// nonce of a stuck tx and the given gas price, which must be high enough to
+// replace the stuck tx, freeing its nonce.
+func (etherMan *Client) SendCancelTx(ctx context.Context, nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	if etherMan.IsReadOnly() {
+		return nil, ErrIsReadOnlyMode
+	}
+	return etherMan.sendSelfTransfer(ctx, nonce, gasPrice, "cancel")
+}
+
+func (etherMan *Client) sendSelfTransfer(ctx context.Context, nonce uint64, gasPrice *big.Int, kind string) (*types.Transaction, error) {
+	tx := types.NewTransaction(nonce, etherMan.auth.From, big.NewInt(0), params.TxGas, gasPrice, nil)
+	signedTx, err := etherMan.auth.Signer(etherMan.auth.From, tx)
+	if err != nil {
+		return nil, fmt.Errorf("error signing %s tx. Error: %w", kind, err)
+	}
+	err = etherMan.EtherClient.SendTransaction(ctx, signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("error sending %s tx. Error: %w", kind, err)
+	}
+	return signedTx, nil
+}
+
 // GetTrustedSequencerURL Gets the trusted sequencer url from rollup smc
 func (etherMan *Client) GetTrustedSequencerURL() (string, error) {
 	return etherMan.PoE.TrustedSequencerURL(&bind.CallOpts{Pending: false})
@@ -845,11 +938,38 @@ func (etherMan *Client) GetPublicAddress() (common.Address, error) {
 	return etherMan.auth.From, nil
 }
 
+// GetAggregatorReward returns the reward in matic the aggregator currently
+// gets for each batch verified.
+func (etherMan *Client) GetAggregatorReward() (*big.Int, error) {
+	return etherMan.PoE.CalculateRewardPerBatch(&bind.CallOpts{Pending: false})
+}
+
+// GetL1ChainID returns the chain id of the L1 the client is connected to.
+func (etherMan *Client) GetL1ChainID(ctx context.Context) (uint64, error) {
+	client, ok := etherMan.EtherClient.(interface {
+		ChainID(ctx context.Context) (*big.Int, error)
+	})
+	if !ok {
+		return 0, ErrChainIDNotSupported
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return chainID.Uint64(), nil
+}
+
 // GetL2ChainID returns L2 Chain ID
 func (etherMan *Client) GetL2ChainID() (uint64, error) {
 	return etherMan.PoE.ChainID(&bind.CallOpts{Pending: false})
 }
 
+// SuggestedGasPrice returns the gas price the txs are sent with when no gas
+// price is given.
+func (etherMan *Client) SuggestedGasPrice(ctx context.Context) *big.Int {
+	return etherMan.getGasPrice(ctx)
+}
+
 func (etherMan *Client) getGasPrice(ctx context.Context) *big.Int {
 	// Get gasPrice from providers
 	gasPrice := big.NewInt(0)
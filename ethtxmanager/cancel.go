@@ -0,0 +1,157 @@
+package ethtxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrTxCanceled tx replaced by a cancel tx error.
+var ErrTxCanceled = errors.New("Tx canceled")
+
+// ErrTxAlreadyMined tx already mined, so it can't be canceled, error.
+var ErrTxAlreadyMined = errors.New("Tx already mined")
+
+// ErrUnknownSender tx not sent by any of the accounts of the manager error.
+var ErrUnknownSender = errors.New("Tx not sent by an account of the eth tx manager")
+
+// accountNonce identifies the txs sent by an account with a nonce, which
+// replace each other.
+type accountNonce struct {
+	from  common.Address
+	nonce uint64
+}
+
+// CancelTx frees the nonce of a stuck tx, sent by the account of the sequence
+// txs or of the verify batches txs, replacing it with a zero value transfer to
+// the account itself with the same nonce and the gas price of the stuck tx
+// increased by PercentageToIncreaseGasPrice. The SequenceBatches or
+// VerifyBatches call waiting for the stuck tx fails with ErrTxCanceled
+// instead of resubmitting it once it reaches WaitTxToBeMined. A mined tx is
+// not canceled. The gas price is capped by MaxGasPriceWei.
+func (c *Client) CancelTx(ctx context.Context, txHash common.Hash) (*types.Transaction, error) {
+	c.pruneCanceled(ctx)
+	tx, isPending, err := c.ethMan.GetTx(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tx %s to cancel, err: %w", txHash, err)
+	}
+	if !isPending {
+		return nil, fmt.Errorf("failed to cancel tx %s, err: %w", txHash, ErrTxAlreadyMined)
+	}
+	return c.cancelTx(ctx, tx)
+}
+
+// cancelTx replaces the tx with a zero value transfer of its sender to
+// itself with the same nonce, as CancelTx does, once the tx is known not to
+// be mined.
+func (c *Client) cancelTx(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	txHash := tx.Hash()
+	from, err := txSender(tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the sender of tx %s to cancel, err: %w", txHash, err)
+	}
+	ethMan, err := c.ethManOf(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel tx %s, err: %w", txHash, err)
+	}
+
+	c.markCanceled(from, tx.Nonce(), common.Hash{})
+	gasPrice := c.capGasPrice(c.floorGasPrice(increaseGasPrice(tx.GasPrice(), c.cfg.PercentageToIncreaseGasPrice)))
+	cancelTx, err := ethMan.SendCancelTx(ctx, tx.Nonce(), gasPrice)
+	if err != nil {
+		c.unmarkCanceled(from, tx.Nonce())
+		return nil, fmt.Errorf("failed to cancel tx %s, err: %w", txHash, err)
+	}
+	c.markCanceled(from, tx.Nonce(), cancelTx.Hash())
+	log.Warnf("tx %s canceled by tx %s with nonce %d and gas price %d", txHash, cancelTx.Hash(), cancelTx.Nonce(), gasPrice)
+	return cancelTx, nil
+}
+
+// ethManOf returns the etherman of the account.
+func (c *Client) ethManOf(from common.Address) (etherman, error) {
+	for _, ethMan := range []etherman{c.ethMan, c.verifyBatchesEthMan} {
+		address, err := ethMan.GetPublicAddress()
+		if err == nil && address == from {
+			return ethMan, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSender, from)
+}
+
+// markCanceled records the nonce as freed by the cancel tx, whose hash is
+// zero until it is sent.
+func (c *Client) markCanceled(from common.Address, nonce uint64, cancelTxHash common.Hash) {
+	c.canceledMu.Lock()
+	defer c.canceledMu.Unlock()
+	c.canceled[accountNonce{from: from, nonce: nonce}] = cancelTxHash
+}
+
+func (c *Client) unmarkCanceled(from common.Address, nonce uint64) {
+	c.canceledMu.Lock()
+	defer c.canceledMu.Unlock()
+	delete(c.canceled, accountNonce{from: from, nonce: nonce})
+}
+
+// forgetCanceled forgets the cancellation of the nonce of the tx, once its
+// nonce is consumed or no call waits for it anymore.
+func (c *Client) forgetCanceled(tx *types.Transaction) {
+	from, err := txSender(tx)
+	if err != nil {
+		return
+	}
+	c.unmarkCanceled(from, tx.Nonce())
+}
+
+// pruneCanceled forgets the cancellations whose nonce was consumed, by the
+// cancel tx or by the stuck tx mined anyway, which replaced the cancel tx,
+// when no SequenceBatches or VerifyBatches call waited for the stuck tx.
+func (c *Client) pruneCanceled(ctx context.Context) {
+	c.canceledMu.Lock()
+	canceled := make(map[accountNonce]common.Hash, len(c.canceled))
+	for key, cancelTxHash := range c.canceled {
+		canceled[key] = cancelTxHash
+	}
+	c.canceledMu.Unlock()
+
+	for key, cancelTxHash := range canceled {
+		if cancelTxHash == (common.Hash{}) {
+			continue
+		}
+		_, isPending, err := c.ethMan.GetTx(ctx, cancelTxHash)
+		if err == nil && isPending {
+			continue
+		}
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			log.Warnf("failed to get cancel tx %s, err: %v", cancelTxHash, err)
+			continue
+		}
+		c.unmarkCanceled(key.from, key.nonce)
+	}
+}
+
+// checkCanceled returns ErrTxCanceled if the nonce of the tx was freed by
+// CancelTx, forgetting the cancellation as the tx is not resubmitted anymore.
+func (c *Client) checkCanceled(tx *types.Transaction) error {
+	from, err := txSender(tx)
+	if err != nil {
+		return nil
+	}
+	c.canceledMu.Lock()
+	defer c.canceledMu.Unlock()
+	key := accountNonce{from: from, nonce: tx.Nonce()}
+	if _, ok := c.canceled[key]; !ok {
+		return nil
+	}
+	delete(c.canceled, key)
+	return fmt.Errorf("tx %s failed, err: %w", tx.Hash(), ErrTxCanceled)
+}
+
+// txSender returns the account that signed the tx.
+func txSender(tx *types.Transaction) (common.Address, error) {
+	return types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+}
@@ -0,0 +1,149 @@
+package ethtxmanager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
+	"github.com/0xPolygonHermez/zkevm-node/test/operations"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cancelEthermanStub signs the txs with its own key and keeps them pending
+// unless they are mined.
+type cancelEthermanStub struct {
+	etherman
+	t        *testing.T
+	key      *ecdsa.PrivateKey
+	txs      map[common.Hash]*types.Transaction
+	mined    map[common.Hash]bool
+	sent     int
+	canceled []*types.Transaction
+}
+
+func newCancelEthermanStub(t *testing.T) *cancelEthermanStub {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	return &cancelEthermanStub{t: t, key: key, txs: make(map[common.Hash]*types.Transaction), mined: make(map[common.Hash]bool)}
+}
+
+func (e *cancelEthermanStub) sign(nonce uint64, gasPrice *big.Int) *types.Transaction {
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 0, gasPrice, nil), types.NewEIP155Signer(big.NewInt(1337)), e.key)
+	require.NoError(e.t, err)
+	e.txs[tx.Hash()] = tx
+	return tx
+}
+
+func (e *cancelEthermanStub) GetPublicAddress() (common.Address, error) {
+	return crypto.PubkeyToAddress(e.key.PublicKey), nil
+}
+
+func (e *cancelEthermanStub) GetTx(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	return e.txs[txHash], !e.mined[txHash], nil
+}
+
+func (e *cancelEthermanStub) SendCancelTx(ctx context.Context, nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	tx := e.sign(nonce, gasPrice)
+	e.canceled = append(e.canceled, tx)
+	return tx, nil
+}
+
+func (e *cancelEthermanStub) TrustedVerifyBatches(ctx context.Context, lastVerifiedBatch, newVerifiedBatch uint64, inputs *ethmanTypes.FinalProofInputs, gasLimit uint64, gasPrice, nonce *big.Int) (*types.Transaction, error) {
+	e.sent++
+	return e.sign(7, big.NewInt(100)), nil
+}
+
+func (e *cancelEthermanStub) WaitTxToBeMined(ctx context.Context, tx *types.Transaction, timeout time.Duration) error {
+	return operations.ErrTimeoutReached
+}
+
+func TestCancelTx(t *testing.T) {
+	ethMan := newCancelEthermanStub(t)
+	txMan, err := New(Config{
+		MaxVerifyBatchTxRetries:      2,
+		WaitTxToBeMined:              cfgTypes.NewDuration(time.Millisecond),
+		PercentageToIncreaseGasPrice: 10,
+	}, ethMan, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// the stuck tx is replaced by a self transfer with the same nonce and a
+	// bumped gas price
+	stuckTx := ethMan.sign(7, big.NewInt(100))
+	cancelTx, err := txMan.CancelTx(ctx, stuckTx.Hash())
+	require.NoError(t, err)
+	require.Len(t, ethMan.canceled, 1)
+	assert.Equal(t, cancelTx, ethMan.canceled[0])
+	assert.Equal(t, uint64(7), cancelTx.Nonce())
+	assert.Equal(t, big.NewInt(110), cancelTx.GasPrice())
+
+	// the verification waiting for the stuck tx fails instead of resubmitting
+	// it with the freed nonce
+	_, err = txMan.VerifyBatches(ctx, 41, 42, nil, nil)
+	assert.ErrorIs(t, err, ErrTxCanceled)
+	assert.Equal(t, 1, ethMan.sent)
+	assert.Empty(t, txMan.canceled)
+
+	// a mined tx is not canceled
+	minedTx := ethMan.sign(8, big.NewInt(100))
+	ethMan.mined[minedTx.Hash()] = true
+	_, err = txMan.CancelTx(ctx, minedTx.Hash())
+	assert.ErrorIs(t, err, ErrTxAlreadyMined)
+	assert.Len(t, ethMan.canceled, 1)
+
+	// nor a tx sent by another account
+	otherEthMan := newCancelEthermanStub(t)
+	otherTx := otherEthMan.sign(9, big.NewInt(100))
+	ethMan.txs[otherTx.Hash()] = otherTx
+	_, err = txMan.CancelTx(ctx, otherTx.Hash())
+	assert.ErrorIs(t, err, ErrUnknownSender)
+	assert.Len(t, ethMan.canceled, 1)
+}
+
+func TestCancelTxCapsGasPrice(t *testing.T) {
+	ethMan := newCancelEthermanStub(t)
+	txMan, err := New(Config{
+		PercentageToIncreaseGasPrice: 10,
+		MaxGasPriceWei:               105,
+	}, ethMan, nil)
+	require.NoError(t, err)
+
+	stuckTx := ethMan.sign(7, big.NewInt(100))
+	cancelTx, err := txMan.CancelTx(context.Background(), stuckTx.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(105), cancelTx.GasPrice())
+}
+
+func TestCancelTxPrunesConsumedNonces(t *testing.T) {
+	ethMan := newCancelEthermanStub(t)
+	txMan, err := New(Config{PercentageToIncreaseGasPrice: 10}, ethMan, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	from, err := ethMan.GetPublicAddress()
+	require.NoError(t, err)
+
+	// no call waits for the stuck txs, so only the pruning forgets them
+	stuckTx := ethMan.sign(7, big.NewInt(100))
+	cancelTx, err := txMan.CancelTx(ctx, stuckTx.Hash())
+	require.NoError(t, err)
+	otherStuckTx := ethMan.sign(8, big.NewInt(100))
+	_, err = txMan.CancelTx(ctx, otherStuckTx.Hash())
+	require.NoError(t, err)
+	require.Len(t, txMan.canceled, 2)
+
+	// the nonce of a mined cancel tx is consumed, the pending one is kept
+	ethMan.mined[cancelTx.Hash()] = true
+	thirdStuckTx := ethMan.sign(9, big.NewInt(100))
+	_, err = txMan.CancelTx(ctx, thirdStuckTx.Hash())
+	require.NoError(t, err)
+	assert.Len(t, txMan.canceled, 2)
+	assert.NotContains(t, txMan.canceled, accountNonce{from: from, nonce: 7})
+}
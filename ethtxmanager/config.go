@@ -17,8 +17,63 @@ type Config struct {
 	WaitTxToBeMined types.Duration `mapstructure:"WaitTxToBeMined"`
 	// WaitTxToBeSynced time to wait after transaction was sent to the ethereum to get into the state
 	WaitTxToBeSynced types.Duration `mapstructure:"WaitTxToBeSynced"`
+	// WaitTxToBeSyncedRetries amount of times to wait again for a sequencing
+	// tx to be synced when WaitTxToBeSynced is reached, since the sync lag is
+	// often transient. 0 means no retries
+	WaitTxToBeSyncedRetries uint32 `mapstructure:"WaitTxToBeSyncedRetries"`
+	// WaitTxToBeSyncedBackoff time to wait before the first retry to wait for
+	// a sequencing tx to be synced, doubled on every retry
+	WaitTxToBeSyncedBackoff types.Duration `mapstructure:"WaitTxToBeSyncedBackoff"`
+	// ConfirmationsToWait is the number of L1 confirmations, as counted by
+	// Confirmations, of a synced tx before it is considered synced,
+	// so a tx reorged out of L1 soon after is not taken as final. Waited
+	// within WaitTxToBeConfirmed, 1 means no wait after the tx is synced
+	ConfirmationsToWait uint64 `mapstructure:"ConfirmationsToWait"`
+	// WaitTxToBeConfirmed max time to wait for ConfirmationsToWait once the
+	// tx is synced. 0 derives it from the confirmations, allowing 30s each
+	WaitTxToBeConfirmed types.Duration `mapstructure:"WaitTxToBeConfirmed"`
+	// VerifyBatchTxMiningWindow max time to get a verify batches tx mined
+	// since it is first sent, increasing the gas price every time
+	// WaitTxToBeMined is reached. Once exceeded, the verification is aborted
+	// so the proof can be sent again, 0 means no limit
+	VerifyBatchTxMiningWindow types.Duration `mapstructure:"VerifyBatchTxMiningWindow"`
 	// PercentageToIncreaseGasPrice when tx is failed by timeout increase gas price by this percentage
 	PercentageToIncreaseGasPrice uint64 `mapstructure:"PercentageToIncreaseGasPrice"`
 	// PercentageToIncreaseGasLimit when tx is failed by timeout increase gas price by this percentage
 	PercentageToIncreaseGasLimit uint64 `mapstructure:"PercentageToIncreaseGasLimit"`
+	// MaxGasPriceWei max gas price of the sequence and verify batches txs,
+	// including the increases and the gas price set by the aggregator, 0
+	// means no limit
+	MaxGasPriceWei uint64 `mapstructure:"MaxGasPriceWei"`
+	// MinGasPriceWei min gas price of the txs, applied to the suggested gas
+	// price the txs are first sent with and to the increased ones, so a zero
+	// or too low estimate doesn't get the txs stuck. 0 means no floor
+	MinGasPriceWei uint64 `mapstructure:"MinGasPriceWei"`
+
+	// EscalateAfterResubmissions is the number of resubmissions of a tx that
+	// reached WaitTxToBeMined after which the tx is escalated, as an early
+	// warning of a stuck tx. 0 means no escalation
+	EscalateAfterResubmissions uint32 `mapstructure:"EscalateAfterResubmissions"`
+	// EscalationAction is the action taken on an escalated tx: alert only,
+	// cancel it or bump its gas price more aggressively
+	EscalationAction EscalationAction `mapstructure:"EscalationAction"`
+	// EscalationPercentageToIncreaseGasPrice is the percentage the gas price
+	// of an escalated tx is increased by on every resubmission, when the
+	// escalation action is bump
+	EscalationPercentageToIncreaseGasPrice uint64 `mapstructure:"EscalationPercentageToIncreaseGasPrice"`
+
+	// FillNonceGaps enables sending self transfers to fill the nonces missing
+	// in the pool before a tx that reached the timeout to be mined
+	FillNonceGaps bool `mapstructure:"FillNonceGaps"`
+	// MaxNonceGapFillers is the max number of filler txs sent to fill a gap,
+	// bigger gaps are not filled
+	MaxNonceGapFillers uint64 `mapstructure:"MaxNonceGapFillers"`
+
+	// ManageNonces makes the manager hand out the nonces of the txs instead
+	// of taking the pending nonce of the account, tracking the next nonce of
+	// each account and persisting it in the state once sent, so the sequence
+	// txs and the verify batches txs sent concurrently by the same account
+	// never collide. The nonces not in the pool are handed out again once
+	// every nonce handed out was sent and their txs are no longer found
+	ManageNonces bool `mapstructure:"ManageNonces"`
 }
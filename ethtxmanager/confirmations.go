@@ -0,0 +1,116 @@
+package ethtxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// confirmationTime is the time allowed for each confirmation when
+// WaitTxToBeConfirmed is not set, well above the L1 block time.
+const confirmationTime = 30 * time.Second
+
+// reorgedPolls is the number of consecutive polls the receipt of a tx must be
+// missing for the tx to be considered reorged out, so a lagging or load
+// balanced L1 node that didn't index the receipt yet isn't taken as a reorg.
+const reorgedPolls = 3
+
+// ErrTxReorged tx reorged out of L1 before reaching the confirmations to wait
+// error.
+var ErrTxReorged = errors.New("Tx reorged out of L1")
+
+// L1Reader reads the receipt of a tx and the head of L1, to count the
+// confirmations of the tx.
+type L1Reader interface {
+	GetTxReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	GetLatestBlockNumber(ctx context.Context) (uint64, error)
+}
+
+// Confirmations returns the confirmations of a tx mined in the block, given
+// the L1 head. The block including the tx counts as the first confirmation,
+// so a tx mined in the head has 1 confirmation, and a tx in a block above the
+// head has none.
+func Confirmations(head, block uint64) uint64 {
+	if head < block {
+		return 0
+	}
+	return head - block + 1
+}
+
+// WaitConfirmations waits for the tx to get the confirmations, as counted by
+// Confirmations, polling the receipt of the tx and the L1 head every
+// interval. The failures to read L1 are logged and retried. ErrTxReorged is
+// returned if the tx is no longer found on L1 for reorgedPolls consecutive
+// polls, and the context error if the context is done before. 1 confirmation or less means the tx is already
+// confirmed once mined.
+func WaitConfirmations(ctx context.Context, l1 L1Reader, txHash common.Hash, confirmations uint64, interval time.Duration) error {
+	if confirmations <= 1 {
+		return nil
+	}
+
+	notFound := 0
+	for {
+		receipt, err := l1.GetTxReceipt(ctx, txHash)
+		if errors.Is(err, ethereum.NotFound) {
+			notFound++
+			if notFound >= reorgedPolls {
+				return fmt.Errorf("%w: tx %s", ErrTxReorged, txHash)
+			}
+			log.Warnf("receipt of tx %s not found while waiting for its confirmations, %d/%d polls before considering it reorged out", txHash, notFound, reorgedPolls)
+		} else {
+			notFound = 0
+		}
+		if err != nil && !errors.Is(err, ethereum.NotFound) && ctx.Err() == nil {
+			log.Errorf("failed to get the receipt of tx %s to wait for its confirmations, err: %v", txHash, err)
+		}
+		if err == nil {
+			head, err := l1.GetLatestBlockNumber(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Errorf("failed to get the L1 head to wait for the confirmations of tx %s, err: %v", txHash, err)
+			}
+			if err == nil {
+				got := Confirmations(head, receipt.BlockNumber.Uint64())
+				if got >= confirmations {
+					log.Debugf("tx %s confirmed by %d blocks", txHash, got)
+					return nil
+				}
+				log.Debugf("tx %s confirmed by %d blocks, waiting for %d", txHash, got, confirmations)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// waitConfirmations waits for the tx to get ConfirmationsToWait
+// confirmations, polling L1 every second. context.DeadlineExceeded is
+// returned if the confirmations are not reached within WaitTxToBeConfirmed.
+func (c *Client) waitConfirmations(ctx context.Context, ethMan etherman, tx *types.Transaction) error {
+	confirmations := c.cfg.ConfirmationsToWait
+	if confirmations <= 1 {
+		// the tx is already mined
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.confirmationsTimeout())
+	defer cancel()
+	return WaitConfirmations(ctx, ethMan, tx.Hash(), confirmations, time.Second)
+}
+
+// confirmationsTimeout returns the max time to wait for the confirmations,
+// WaitTxToBeConfirmed or the time derived from the confirmations if not set.
+func (c *Client) confirmationsTimeout() time.Duration {
+	if c.cfg.WaitTxToBeConfirmed.Duration > 0 {
+		return c.cfg.WaitTxToBeConfirmed.Duration
+	}
+	return time.Duration(c.cfg.ConfirmationsToWait) * confirmationTime
+}
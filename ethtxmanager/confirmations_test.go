@@ -0,0 +1,110 @@
+package ethtxmanager
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// confirmationsEthermanStub includes the tx in block 100 and mines a block
+// every time the head is queried, until maxHead. The receipt of the tx is not
+// found for the first notIndexed polls.
+type confirmationsEthermanStub struct {
+	etherman
+	reorged    bool
+	notIndexed int
+	receipts   int
+	head       uint64
+	maxHead    uint64
+}
+
+func (e *confirmationsEthermanStub) GetTxReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	e.receipts++
+	if e.reorged || e.receipts <= e.notIndexed {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{TxHash: txHash, BlockNumber: big.NewInt(100)}, nil
+}
+
+func (e *confirmationsEthermanStub) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	head := e.head
+	if e.head < e.maxHead {
+		e.head++
+	}
+	return head, nil
+}
+
+func TestConfirmations(t *testing.T) {
+	assert.Equal(t, uint64(0), Confirmations(99, 100))
+	assert.Equal(t, uint64(1), Confirmations(100, 100))
+	assert.Equal(t, uint64(3), Confirmations(102, 100))
+}
+
+func TestWaitConfirmations(t *testing.T) {
+	tx := types.NewTransaction(1, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+
+	testCases := []struct {
+		name          string
+		confirmations uint64
+		ethMan        *confirmationsEthermanStub
+		expectedErr   error
+		expectedHead  uint64
+	}{
+		{name: "single confirmation", confirmations: 1, ethMan: &confirmationsEthermanStub{reorged: true}},
+		{name: "already confirmed", confirmations: 3, ethMan: &confirmationsEthermanStub{head: 105, maxHead: 105}, expectedHead: 105},
+		{name: "confirmed after a new block", confirmations: 3, ethMan: &confirmationsEthermanStub{head: 101, maxHead: 110}, expectedHead: 103},
+		{name: "not confirmed in time", confirmations: 10, ethMan: &confirmationsEthermanStub{head: 100, maxHead: 100}, expectedErr: context.DeadlineExceeded, expectedHead: 100},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(Config{
+				ConfirmationsToWait: tc.confirmations,
+				WaitTxToBeConfirmed: cfgTypes.NewDuration(1500 * time.Millisecond),
+			}, tc.ethMan, nil)
+			require.NoError(t, err)
+
+			err = c.waitConfirmations(context.Background(), tc.ethMan, tx)
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr), "unexpected err: %v", err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedHead, tc.ethMan.head)
+		})
+	}
+}
+
+func TestWaitConfirmationsNotFound(t *testing.T) {
+	txHash := common.HexToHash("0x1")
+
+	// a receipt not indexed yet by the L1 node isn't taken as a reorg
+	ethMan := &confirmationsEthermanStub{notIndexed: reorgedPolls - 1, head: 102, maxHead: 102}
+	require.NoError(t, WaitConfirmations(context.Background(), ethMan, txHash, 3, time.Millisecond))
+	assert.Equal(t, reorgedPolls, ethMan.receipts)
+
+	// a receipt missing for reorgedPolls polls is a reorg
+	ethMan = &confirmationsEthermanStub{reorged: true}
+	err := WaitConfirmations(context.Background(), ethMan, txHash, 3, time.Millisecond)
+	assert.ErrorIs(t, err, ErrTxReorged)
+	assert.Equal(t, reorgedPolls, ethMan.receipts)
+}
+
+func TestConfirmationsTimeout(t *testing.T) {
+	// not bounded by the time to wait for the tx to be synced
+	c, err := New(Config{ConfirmationsToWait: 12, WaitTxToBeSynced: cfgTypes.NewDuration(10 * time.Second)}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 12*confirmationTime, c.confirmationsTimeout())
+
+	c, err = New(Config{ConfirmationsToWait: 12, WaitTxToBeConfirmed: cfgTypes.NewDuration(time.Minute)}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, c.confirmationsTimeout())
+}
@@ -0,0 +1,77 @@
+package ethtxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xPolygonHermez/zkevm-node/ethtxmanager/metrics"
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// EscalationAction is the action taken on a tx resubmitted too many times
+// without being mined.
+type EscalationAction string
+
+const (
+	// EscalationActionAlert only alerts about the tx, which keeps being
+	// resubmitted as usual
+	EscalationActionAlert EscalationAction = "alert"
+	// EscalationActionCancel replaces the tx with a cancel tx with the same
+	// nonce, as CancelTx does, and aborts it, so it can be sent again
+	EscalationActionCancel EscalationAction = "cancel"
+	// EscalationActionBump keeps resubmitting the tx increasing its gas
+	// price by EscalationPercentageToIncreaseGasPrice
+	EscalationActionBump EscalationAction = "bump"
+)
+
+// ErrTxEscalated tx canceled after too many resubmissions error.
+var ErrTxEscalated = errors.New("Tx canceled after too many resubmissions")
+
+// validateEscalationAction returns an error if the action is unknown. No
+// action means alert.
+func validateEscalationAction(action EscalationAction) error {
+	switch action {
+	case "", EscalationActionAlert, EscalationActionCancel, EscalationActionBump:
+		return nil
+	default:
+		return fmt.Errorf("invalid escalation action %q", action)
+	}
+}
+
+// gasPriceIncrease returns the percentage to increase the gas price of the tx
+// for its next resubmission, escalating the tx once it was resubmitted
+// EscalateAfterResubmissions times without being mined. A tx canceled on
+// escalation is replaced by a cancel tx, freeing its nonce, before failing
+// with ErrTxEscalated.
+func (c *Client) gasPriceIncrease(ctx context.Context, tx *types.Transaction, resubmissions uint32) (uint64, error) {
+	threshold := c.cfg.EscalateAfterResubmissions
+	if threshold == 0 || resubmissions < threshold {
+		return c.cfg.PercentageToIncreaseGasPrice, nil
+	}
+
+	action := c.cfg.EscalationAction
+	if action == "" {
+		action = EscalationActionAlert
+	}
+	if resubmissions == threshold {
+		log.Errorf("tx %s resubmitted %d times without being mined, escalating it: %s", tx.Hash(), resubmissions, action)
+		metrics.Escalation(string(action))
+	}
+
+	switch action {
+	case EscalationActionCancel:
+		cancelTx, err := c.cancelTx(ctx, tx)
+		if err != nil {
+			return 0, fmt.Errorf("tx %s failed, escalated but not canceled, err: %w", tx.Hash(), err)
+		}
+		// no call waits for the tx anymore
+		c.forgetCanceled(tx)
+		return 0, fmt.Errorf("tx %s failed, canceled by tx %s, err: %w", tx.Hash(), cancelTx.Hash(), ErrTxEscalated)
+	case EscalationActionBump:
+		return c.cfg.EscalationPercentageToIncreaseGasPrice, nil
+	default:
+		return c.cfg.PercentageToIncreaseGasPrice, nil
+	}
+}
@@ -9,43 +9,114 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
+	"github.com/0xPolygonHermez/zkevm-node/ethtxmanager/metrics"
 	"github.com/0xPolygonHermez/zkevm-node/log"
+	st "github.com/0xPolygonHermez/zkevm-node/state"
 	"github.com/0xPolygonHermez/zkevm-node/state/runtime"
 	"github.com/0xPolygonHermez/zkevm-node/test/operations"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
 // ErrMaxRetriesExceeded max retries exceeded error.
 var ErrMaxRetriesExceeded = errors.New("Maximum number of retries exceeded")
 
+// ErrTxNotMined tx not mined within the configured window error.
+var ErrTxNotMined = errors.New("Tx not mined within the configured window")
+
+// ErrTxNotSynced tx not synced within the configured retries error.
+var ErrTxNotSynced = errors.New("Tx not synced within the configured retries")
+
+// SyncTimeoutError is returned when a tx mined in L1 is not synced into the
+// state in time. It reports how far the synchronizer progressed, so the caller
+// can decide whether to wait longer or to send the tx again. It matches
+// ErrTxNotSynced.
+type SyncTimeoutError struct {
+	TxHash   common.Hash
+	Progress st.SyncProgress
+	// Err is the last error waiting for the tx to be synced
+	Err error
+}
+
+func (e *SyncTimeoutError) Error() string {
+	return fmt.Sprintf("%v: tx %s, waited %s, last synced batch %d, last err: %v",
+		ErrTxNotSynced, e.TxHash, e.Progress.Elapsed, e.Progress.LastSyncedBatchNumber, e.Err)
+}
+
+// Is matches ErrTxNotSynced.
+func (e *SyncTimeoutError) Is(target error) bool {
+	return target == ErrTxNotSynced
+}
+
+// Unwrap returns the last error waiting for the tx to be synced.
+func (e *SyncTimeoutError) Unwrap() error {
+	return e.Err
+}
+
 // Client for eth tx manager
 type Client struct {
 	cfg    Config
 	ethMan etherman
-	state  state
+	// verifyBatchesEthMan signs and sends the verify batches txs, it can
+	// hold a different key than ethMan, which sends the sequence txs
+	verifyBatchesEthMan etherman
+	state               state
+
+	// canceled are the nonces freed by CancelTx, whose txs must not be
+	// resubmitted, with the hash of their cancel tx
+	canceled   map[accountNonce]common.Hash
+	canceledMu sync.Mutex
+
+	// nonces hands out the nonces of the txs if ManageNonces is enabled
+	nonces *NonceManager
 }
 
 // New creates new eth tx manager
-func New(cfg Config, ethMan etherman, state state) *Client {
-	return &Client{
-		cfg:    cfg,
-		ethMan: ethMan,
-		state:  state,
+func New(cfg Config, ethMan etherman, state state) (*Client, error) {
+	return NewWithVerifyBatchesEtherman(cfg, ethMan, ethMan, state)
+}
+
+// NewWithVerifyBatchesEtherman creates new eth tx manager that sends the
+// sequence txs and the verify batches txs with different ethermans, so they
+// are signed by different keys and their nonces are tracked separately
+func NewWithVerifyBatchesEtherman(cfg Config, ethMan etherman, verifyBatchesEthMan etherman, state state) (*Client, error) {
+	if err := validateEscalationAction(cfg.EscalationAction); err != nil {
+		return nil, err
+	}
+
+	metrics.Register()
+
+	c := &Client{
+		cfg:                 cfg,
+		ethMan:              ethMan,
+		verifyBatchesEthMan: verifyBatchesEthMan,
+		state:               state,
+		canceled:            make(map[accountNonce]common.Hash),
+	}
+	if cfg.ManageNonces {
+		c.nonces = NewNonceManager(state)
 	}
+	return c, nil
 }
 
 // SequenceBatches send sequences to the channel
 func (c *Client) SequenceBatches(ctx context.Context, sequences []ethmanTypes.Sequence) error {
 	var (
-		attempts uint32
-		gas      uint64
-		gasPrice *big.Int
-		nonce    = big.NewInt(0)
+		attempts      uint32
+		resubmissions uint32
+		gas           uint64
+		sent          bool
 	)
 	log.Info("sending sequence to L1")
+	nonce, err := c.acquireNonce(ctx, c.ethMan)
+	if err != nil {
+		return fmt.Errorf("failed to sequence batches, err: %w", err)
+	}
+	gasPrice := c.initialGasPrice(ctx, c.ethMan)
 	for attempts < c.cfg.MaxSendBatchTxRetries {
 		var (
 			tx  *types.Transaction
@@ -67,9 +138,14 @@ func (c *Client) SequenceBatches(ctx context.Context, sequences []ethmanTypes.Se
 			}
 		}
 		if err != nil {
+			if !sent {
+				c.releaseNonce(ctx, c.ethMan, nonce)
+			}
 			log.Errorf("failed to sequence batches, maximum attempts exceeded, err: %w", err)
 			return fmt.Errorf("failed to sequence batches, maximum attempts exceeded, err: %w", err)
 		}
+		sent = true
+		c.sentNonce(ctx, c.ethMan, tx)
 		// Wait for tx to be mined
 		log.Infof("waiting for tx to be mined. Tx hash: %s, nonce: %d, gasPrice: %d", tx.Hash(), tx.Nonce(), tx.GasPrice().Int64())
 		err = c.ethMan.WaitTxToBeMined(ctx, tx, c.cfg.WaitTxToBeMined.Duration)
@@ -78,84 +154,283 @@ func (c *Client) SequenceBatches(ctx context.Context, sequences []ethmanTypes.Se
 			if errors.Is(err, runtime.ErrOutOfGas) {
 				gas = increaseGasLimit(tx.Gas(), c.cfg.PercentageToIncreaseGasLimit)
 				log.Infof("out of gas with %d, retrying with %d", tx.Gas(), gas)
+				// the reverted tx consumed its nonce
+				nonce, err = c.acquireNonce(ctx, c.ethMan)
+				if err != nil {
+					return fmt.Errorf("tx %s failed, err: %w", tx.Hash(), err)
+				}
+				sent = false
 				continue
 			} else if errors.Is(err, operations.ErrTimeoutReached) {
-				nonce = new(big.Int).SetUint64(tx.Nonce())
-				gasPrice = increaseGasPrice(tx.GasPrice(), c.cfg.PercentageToIncreaseGasPrice)
+				if err := c.checkCanceled(tx); err != nil {
+					log.Error(err)
+					return err
+				}
+				resubmissions++
+				increase, err := c.gasPriceIncrease(ctx, tx, resubmissions)
+				if err != nil {
+					log.Error(err)
+					return err
+				}
+				c.fillNonceGap(ctx, c.ethMan, tx.Nonce())
+				nonce, err = c.renewNonce(ctx, c.ethMan, tx)
+				if err != nil {
+					return fmt.Errorf("tx %s failed, err: %w", tx.Hash(), err)
+				}
+				sent = nonce.Uint64() == tx.Nonce()
+				gasPrice = c.capGasPrice(c.floorGasPrice(increaseGasPrice(tx.GasPrice(), increase)))
 				log.Infof("tx %s reached timeout, retrying with gas price = %d", tx.Hash(), gasPrice)
 				continue
 			}
+			c.forgetCanceled(tx)
 			log.Errorf("tx %s failed, err: %w", tx.Hash(), err)
 			return fmt.Errorf("tx %s failed, err: %w", tx.Hash(), err)
 		}
+		c.forgetCanceled(tx)
 
 		log.Infof("sequence sent to L1 successfully. Tx hash: %s", tx.Hash())
-		return c.state.WaitSequencingTxToBeSynced(ctx, tx, c.cfg.WaitTxToBeSynced.Duration)
+		err = c.waitSequencingTxToBeSynced(ctx, tx)
+		if err != nil {
+			return err
+		}
+		err = c.waitConfirmations(ctx, c.ethMan, tx)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return &SyncTimeoutError{TxHash: tx.Hash(), Err: err}
+		}
+		return err
 	}
 	return ErrMaxRetriesExceeded
 }
 
 // VerifyBatches sends the VerifyBatches request to Ethereum. It is also
 // responsible for retrying up to MaxVerifyBatchTxRetries times, increasing the
-// Gas price or Gas limit, depending on the error returned by Ethereum.
-func (c *Client) VerifyBatches(ctx context.Context, lastVerifiedBatch uint64, finalBatchNum uint64, inputs *ethmanTypes.FinalProofInputs) (*types.Transaction, error) {
+// Gas price or Gas limit, depending on the error returned by Ethereum. The tx
+// is first sent with the given gas price, or the suggested one if nil.
+func (c *Client) VerifyBatches(ctx context.Context, lastVerifiedBatch uint64, finalBatchNum uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error) {
 	var (
-		attempts uint32
-		gas      uint64
-		gasPrice *big.Int
-		nonce    = big.NewInt(0)
-		tx       *types.Transaction
-		err      error
+		attempts      uint32
+		resubmissions uint32
+		gas           uint64
+		sent          bool
+		tx            *types.Transaction
+		start         = time.Now()
 	)
 
 	log.Infof("sending verification to L1 for batches %d-%d", lastVerifiedBatch+1, finalBatchNum)
+	nonce, err := c.acquireNonce(ctx, c.verifyBatchesEthMan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send batch verification, err: %w", err)
+	}
+	if gasPrice == nil {
+		gasPrice = c.initialGasPrice(ctx, c.verifyBatchesEthMan)
+	}
+	if gasPrice != nil {
+		gasPrice = c.capGasPrice(c.floorGasPrice(gasPrice))
+		log.Infof("sending verification with gas price = %d", gasPrice)
+	}
 
 	for attempts < c.cfg.MaxVerifyBatchTxRetries {
 		if nonce.Uint64() > 0 {
-			tx, err = c.ethMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nonce)
+			tx, err = c.verifyBatchesEthMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nonce)
 		} else {
-			tx, err = c.ethMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nil)
+			tx, err = c.verifyBatchesEthMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nil)
 		}
 		for err != nil && attempts < c.cfg.MaxVerifyBatchTxRetries {
 			log.Errorf("failed to send batch verification, trying once again, retry #%d, err: %w", attempts, err)
 			time.Sleep(c.cfg.FrequencyForResendingFailedVerifyBatch.Duration)
 
 			if nonce.Uint64() > 0 {
-				tx, err = c.ethMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nonce)
+				tx, err = c.verifyBatchesEthMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nonce)
 			} else {
-				tx, err = c.ethMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nil)
+				tx, err = c.verifyBatchesEthMan.TrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gas, gasPrice, nil)
 			}
 
 			attempts++
 		}
 		if err != nil {
+			if !sent {
+				c.releaseNonce(ctx, c.verifyBatchesEthMan, nonce)
+			}
 			log.Errorf("failed to send batch verification, maximum attempts exceeded, err: %w", err)
 			return nil, fmt.Errorf("failed to send batch verification, maximum attempts exceeded, err: %w", err)
 		}
+		sent = true
+		c.sentNonce(ctx, c.verifyBatchesEthMan, tx)
 		// Wait for tx to be mined
 		log.Infof("waiting for tx to be mined. Tx hash: %s, nonce: %d, gasPrice: %d", tx.Hash(), tx.Nonce(), tx.GasPrice().Int64())
-		err = c.ethMan.WaitTxToBeMined(ctx, tx, c.cfg.WaitTxToBeMined.Duration)
+		err = c.verifyBatchesEthMan.WaitTxToBeMined(ctx, tx, c.cfg.WaitTxToBeMined.Duration)
 		if err != nil {
 			if errors.Is(err, runtime.ErrOutOfGas) {
 				gas = increaseGasLimit(tx.Gas(), c.cfg.PercentageToIncreaseGasLimit)
 				log.Infof("out of gas with %d, retrying with %d", tx.Gas(), gas)
+				// the reverted tx consumed its nonce
+				nonce, err = c.acquireNonce(ctx, c.verifyBatchesEthMan)
+				if err != nil {
+					return nil, fmt.Errorf("tx %s failed, err: %w", tx.Hash(), err)
+				}
+				sent = false
 				continue
 			} else if errors.Is(err, operations.ErrTimeoutReached) {
-				nonce = new(big.Int).SetUint64(tx.Nonce())
-				gasPrice = increaseGasPrice(tx.GasPrice(), c.cfg.PercentageToIncreaseGasPrice)
+				window := c.cfg.VerifyBatchTxMiningWindow.Duration
+				if window > 0 && time.Since(start) >= window {
+					log.Errorf("tx %s not mined within %v, aborting the verification", tx.Hash(), window)
+					return nil, fmt.Errorf("tx %s failed, err: %w", tx.Hash(), ErrTxNotMined)
+				}
+				if err := c.checkCanceled(tx); err != nil {
+					log.Error(err)
+					return nil, err
+				}
+				resubmissions++
+				increase, err := c.gasPriceIncrease(ctx, tx, resubmissions)
+				if err != nil {
+					log.Error(err)
+					return nil, err
+				}
+				c.fillNonceGap(ctx, c.verifyBatchesEthMan, tx.Nonce())
+				nonce, err = c.renewNonce(ctx, c.verifyBatchesEthMan, tx)
+				if err != nil {
+					return nil, fmt.Errorf("tx %s failed, err: %w", tx.Hash(), err)
+				}
+				sent = nonce.Uint64() == tx.Nonce()
+				gasPrice = c.capGasPrice(c.floorGasPrice(increaseGasPrice(tx.GasPrice(), increase)))
 				log.Infof("tx %s reached timeout, retrying with gas price = %d", tx.Hash(), gasPrice)
 				continue
 			}
+			c.forgetCanceled(tx)
 			log.Errorf("tx %s failed, err: %w", tx.Hash(), err)
 			return nil, fmt.Errorf("tx %s failed, err: %w", tx.Hash(), err)
 		}
+		c.forgetCanceled(tx)
 
 		log.Infof("batch verification sent to L1 successfully. Tx hash: %s", tx.Hash())
-		return tx, c.state.WaitVerifiedBatchToBeSynced(ctx, finalBatchNum, c.cfg.WaitTxToBeSynced.Duration)
+		progress, err := c.state.WaitVerifiedBatchToBeSynced(ctx, finalBatchNum, c.cfg.WaitTxToBeSynced.Duration)
+		if err == nil {
+			err = c.waitConfirmations(ctx, c.verifyBatchesEthMan, tx)
+		}
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return tx, &SyncTimeoutError{TxHash: tx.Hash(), Progress: progress, Err: err}
+		}
+		return tx, err
 	}
 	return nil, ErrMaxRetriesExceeded
 }
 
+// EstimateVerifyBatches builds the VerifyBatches tx with its gas estimated,
+// without sending it, to simulate VerifyBatches. The gas price is the one the
+// tx would be first sent with by VerifyBatches.
+func (c *Client) EstimateVerifyBatches(ctx context.Context, lastVerifiedBatch uint64, finalBatchNum uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error) {
+	if gasPrice == nil {
+		gasPrice = c.initialGasPrice(ctx, c.verifyBatchesEthMan)
+	}
+	if gasPrice != nil {
+		gasPrice = c.capGasPrice(c.floorGasPrice(gasPrice))
+	}
+	tx, err := c.verifyBatchesEthMan.EstimateTrustedVerifyBatches(ctx, lastVerifiedBatch, finalBatchNum, inputs, gasPrice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate batch verification, err: %w", err)
+	}
+	return tx, nil
+}
+
+// waitSequencingTxToBeSynced waits for the sequencing tx to be synced into the
+// state, waiting again up to WaitTxToBeSyncedRetries times with an increasing
+// backoff every time WaitTxToBeSynced is reached. Each retry checks again if
+// the tx was synced in the meantime. A SyncTimeoutError is returned once the
+// retries are exhausted.
+func (c *Client) waitSequencingTxToBeSynced(ctx context.Context, tx *types.Transaction) error {
+	var (
+		backoff = c.cfg.WaitTxToBeSyncedBackoff.Duration
+		elapsed time.Duration
+	)
+	for retry := uint32(0); ; retry++ {
+		progress, err := c.state.WaitSequencingTxToBeSynced(ctx, tx, c.cfg.WaitTxToBeSynced.Duration)
+		if err == nil || !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return err
+		}
+		elapsed += progress.Elapsed
+		if retry >= c.cfg.WaitTxToBeSyncedRetries {
+			progress.Elapsed = elapsed
+			return &SyncTimeoutError{TxHash: tx.Hash(), Progress: progress, Err: err}
+		}
+
+		log.Warnf("tx %s not synced yet, last virtual batch %d, retry #%d in %s", tx.Hash(), progress.LastSyncedBatchNumber, retry+1, backoff)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// fillNonceGap sends filler txs for the nonces missing in the pool of the
+// account of ethMan before the given one, as the tx can't be mined until the
+// gap is filled.
+func (c *Client) fillNonceGap(ctx context.Context, ethMan etherman, nonce uint64) {
+	if !c.cfg.FillNonceGaps {
+		return
+	}
+	pendingNonce, err := ethMan.PendingNonce(ctx)
+	if err != nil {
+		log.Errorf("failed to get pending nonce to check nonce gaps, err: %v", err)
+		return
+	}
+	if pendingNonce >= nonce {
+		// no gap
+		return
+	}
+	gap := nonce - pendingNonce
+	if gap > c.cfg.MaxNonceGapFillers {
+		log.Errorf("nonce gap of %d txs before nonce %d exceeds the max number of fillers %d", gap, nonce, c.cfg.MaxNonceGapFillers)
+		return
+	}
+	log.Warnf("nonce gap detected, filling nonces %d-%d", pendingNonce, nonce-1)
+	for n := pendingNonce; n < nonce; n++ {
+		tx, err := ethMan.SendNonceFillerTx(ctx, n)
+		if err != nil {
+			log.Errorf("failed to send nonce filler tx for nonce %d, err: %v", n, err)
+			return
+		}
+		log.Infof("nonce filler tx sent for nonce %d. Tx hash: %s", n, tx.Hash())
+	}
+}
+
+// capGasPrice limits the gas price to the configured max gas price.
+func (c *Client) capGasPrice(gasPrice *big.Int) *big.Int {
+	if c.cfg.MaxGasPriceWei == 0 || gasPrice == nil {
+		return gasPrice
+	}
+	maxGasPrice := new(big.Int).SetUint64(c.cfg.MaxGasPriceWei)
+	if gasPrice.Cmp(maxGasPrice) > 0 {
+		log.Warnf("gas price %d capped to the max gas price %d", gasPrice, maxGasPrice)
+		return maxGasPrice
+	}
+	return gasPrice
+}
+
+// initialGasPrice returns the gas price a tx is first sent with: nil, so the
+// etherman suggests it, unless a min or a max gas price is configured, in
+// which case the suggested gas price is resolved here to apply them to it.
+func (c *Client) initialGasPrice(ctx context.Context, ethMan etherman) *big.Int {
+	if c.cfg.MinGasPriceWei == 0 && c.cfg.MaxGasPriceWei == 0 {
+		return nil
+	}
+	return c.capGasPrice(c.floorGasPrice(ethMan.SuggestedGasPrice(ctx)))
+}
+
+// floorGasPrice raises the gas price to the configured min gas price.
+func (c *Client) floorGasPrice(gasPrice *big.Int) *big.Int {
+	if c.cfg.MinGasPriceWei == 0 {
+		return gasPrice
+	}
+	minGasPrice := new(big.Int).SetUint64(c.cfg.MinGasPriceWei)
+	if gasPrice == nil || gasPrice.Cmp(minGasPrice) < 0 {
+		log.Warnf("gas price %d raised to the min gas price %d", gasPrice, minGasPrice)
+		return minGasPrice
+	}
+	return gasPrice
+}
+
 func increaseGasPrice(currentGasPrice *big.Int, percentageIncrease uint64) *big.Int {
 	gasPrice := big.NewInt(0).Mul(currentGasPrice, new(big.Int).SetUint64(uint64(100)+percentageIncrease)) //nolint:gomnd
 	return gasPrice.Div(gasPrice, big.NewInt(100))                                                         //nolint:gomnd
@@ -2,12 +2,20 @@ package ethtxmanager
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"testing"
+	"time"
 
+	cfgTypes "github.com/0xPolygonHermez/zkevm-node/config/types"
 	ethman "github.com/0xPolygonHermez/zkevm-node/etherman"
 	ethmanTypes "github.com/0xPolygonHermez/zkevm-node/etherman/types"
+	st "github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/0xPolygonHermez/zkevm-node/test/operations"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIncreaseGasLimit(t *testing.T) {
@@ -22,18 +30,389 @@ func TestIncreaseGasPrice(t *testing.T) {
 
 func TestSequenceBatchesWithROEthman(t *testing.T) {
 	ethManRO, _, _, _, _ := ethman.NewSimulatedEtherman(ethman.Config{}, nil)
-	txMan := New(Config{MaxSendBatchTxRetries: 2}, ethManRO, nil) // 3 executions in total
+	txMan, err := New(Config{MaxSendBatchTxRetries: 2}, ethManRO, nil) // 3 executions in total
+	require.NoError(t, err)
 
-	err := txMan.SequenceBatches(context.Background(), []ethmanTypes.Sequence{})
+	err = txMan.SequenceBatches(context.Background(), []ethmanTypes.Sequence{})
 
 	assert.ErrorIs(t, err, ethman.ErrIsReadOnlyMode)
 }
 
 func TestVerifyBatchesWithROEthman(t *testing.T) {
 	ethManRO, _, _, _, _ := ethman.NewSimulatedEtherman(ethman.Config{}, nil)
-	txMan := New(Config{MaxVerifyBatchTxRetries: 2}, ethManRO, nil) // 3 executions in total
+	txMan, err := New(Config{MaxVerifyBatchTxRetries: 2}, ethManRO, nil) // 3 executions in total
+	require.NoError(t, err)
 
-	_, err := txMan.VerifyBatches(context.Background(), 41, 42, nil)
+	_, err = txMan.VerifyBatches(context.Background(), 41, 42, nil, nil)
 
 	assert.ErrorIs(t, err, ethman.ErrIsReadOnlyMode)
 }
+
+type nonceGapEthermanStub struct {
+	etherman
+	pendingNonce uint64
+	filled       []uint64
+}
+
+func (e *nonceGapEthermanStub) PendingNonce(ctx context.Context) (uint64, error) {
+	return e.pendingNonce, nil
+}
+
+func (e *nonceGapEthermanStub) SendNonceFillerTx(ctx context.Context, nonce uint64) (*types.Transaction, error) {
+	e.filled = append(e.filled, nonce)
+	return types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil), nil
+}
+
+func TestFillNonceGap(t *testing.T) {
+	testCases := []struct {
+		name           string
+		cfg            Config
+		pendingNonce   uint64
+		nonce          uint64
+		expectedFilled []uint64
+	}{
+		{
+			name:         "disabled",
+			cfg:          Config{FillNonceGaps: false, MaxNonceGapFillers: 5},
+			pendingNonce: 3,
+			nonce:        5,
+		},
+		{
+			name:         "no gap",
+			cfg:          Config{FillNonceGaps: true, MaxNonceGapFillers: 5},
+			pendingNonce: 5,
+			nonce:        5,
+		},
+		{
+			name:           "gap filled",
+			cfg:            Config{FillNonceGaps: true, MaxNonceGapFillers: 5},
+			pendingNonce:   3,
+			nonce:          5,
+			expectedFilled: []uint64{3, 4},
+		},
+		{
+			name:         "gap bigger than max fillers",
+			cfg:          Config{FillNonceGaps: true, MaxNonceGapFillers: 1},
+			pendingNonce: 3,
+			nonce:        5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ethMan := &nonceGapEthermanStub{pendingNonce: tc.pendingNonce}
+			txMan, err := New(tc.cfg, ethMan, nil)
+			require.NoError(t, err)
+
+			txMan.fillNonceGap(context.Background(), ethMan, tc.nonce)
+
+			assert.Equal(t, tc.expectedFilled, ethMan.filled)
+		})
+	}
+}
+
+type notMinedEthermanStub struct {
+	etherman
+	sent []*big.Int
+}
+
+func (e *notMinedEthermanStub) TrustedVerifyBatches(ctx context.Context, lastVerifiedBatch, newVerifiedBatch uint64, inputs *ethmanTypes.FinalProofInputs, gasLimit uint64, gasPrice, nonce *big.Int) (*types.Transaction, error) {
+	e.sent = append(e.sent, gasPrice)
+	if gasPrice == nil {
+		gasPrice = big.NewInt(100)
+	}
+	return types.NewTransaction(1, common.Address{}, big.NewInt(0), 0, gasPrice, nil), nil
+}
+
+func (e *notMinedEthermanStub) SequenceBatches(ctx context.Context, sequences []ethmanTypes.Sequence, gasLimit uint64, gasPrice, nonce *big.Int) (*types.Transaction, error) {
+	e.sent = append(e.sent, gasPrice)
+	return types.NewTransaction(1, common.Address{}, big.NewInt(0), 0, gasPrice, nil), nil
+}
+
+func (e *notMinedEthermanStub) SuggestedGasPrice(ctx context.Context) *big.Int {
+	return big.NewInt(100)
+}
+
+func (e *notMinedEthermanStub) WaitTxToBeMined(ctx context.Context, tx *types.Transaction, timeout time.Duration) error {
+	time.Sleep(timeout)
+	return operations.ErrTimeoutReached
+}
+
+func TestVerifyBatchesNotMinedWithinWindow(t *testing.T) {
+	ethMan := &notMinedEthermanStub{}
+	txMan, err := New(Config{
+		MaxVerifyBatchTxRetries:      2,
+		WaitTxToBeMined:              cfgTypes.NewDuration(10 * time.Millisecond),
+		VerifyBatchTxMiningWindow:    cfgTypes.NewDuration(25 * time.Millisecond),
+		PercentageToIncreaseGasPrice: 10,
+	}, ethMan, nil)
+	require.NoError(t, err)
+
+	_, err = txMan.VerifyBatches(context.Background(), 41, 42, nil, nil)
+
+	assert.ErrorIs(t, err, ErrTxNotMined)
+	// the tx was resubmitted with a bumped gas price until the window expired
+	assert.GreaterOrEqual(t, len(ethMan.sent), 2)
+	assert.Nil(t, ethMan.sent[0])
+	assert.Equal(t, big.NewInt(110), ethMan.sent[1])
+}
+
+func TestVerifyBatchesWithVerifyBatchesEtherman(t *testing.T) {
+	ethManRO, _, _, _, _ := ethman.NewSimulatedEtherman(ethman.Config{}, nil)
+	verifyEthMan := &notMinedEthermanStub{}
+	txMan, err := NewWithVerifyBatchesEtherman(Config{
+		MaxSendBatchTxRetries:     1,
+		MaxVerifyBatchTxRetries:   2,
+		WaitTxToBeMined:           cfgTypes.NewDuration(time.Millisecond),
+		VerifyBatchTxMiningWindow: cfgTypes.NewDuration(time.Millisecond),
+	}, ethManRO, verifyEthMan, nil)
+	require.NoError(t, err)
+
+	// the verify batches tx is sent by its own etherman
+	_, err = txMan.VerifyBatches(context.Background(), 41, 42, nil, nil)
+	assert.ErrorIs(t, err, ErrTxNotMined)
+	assert.NotEmpty(t, verifyEthMan.sent)
+
+	// the sequences are still sent by the default one
+	err = txMan.SequenceBatches(context.Background(), []ethmanTypes.Sequence{})
+	assert.ErrorIs(t, err, ethman.ErrIsReadOnlyMode)
+}
+
+func TestVerifyBatchesGasPriceOverride(t *testing.T) {
+	ethMan := &notMinedEthermanStub{}
+	txMan, err := New(Config{
+		MaxVerifyBatchTxRetries:      2,
+		WaitTxToBeMined:              cfgTypes.NewDuration(time.Millisecond),
+		VerifyBatchTxMiningWindow:    cfgTypes.NewDuration(time.Millisecond),
+		PercentageToIncreaseGasPrice: 10,
+		MaxGasPriceWei:               150,
+	}, ethMan, nil)
+	require.NoError(t, err)
+
+	_, err = txMan.VerifyBatches(context.Background(), 41, 42, nil, big.NewInt(1000))
+
+	assert.ErrorIs(t, err, ErrTxNotMined)
+	// the override is capped to the max gas price
+	assert.Equal(t, big.NewInt(150), ethMan.sent[0])
+}
+
+func TestSequenceBatchesMaxGasPrice(t *testing.T) {
+	ethMan := &notMinedEthermanStub{}
+	txMan, err := New(Config{
+		MaxSendBatchTxRetries:        3,
+		WaitTxToBeMined:              cfgTypes.NewDuration(time.Millisecond),
+		PercentageToIncreaseGasPrice: 10,
+		MaxGasPriceWei:               115,
+	}, ethMan, nil)
+	require.NoError(t, err)
+
+	err = txMan.SequenceBatches(context.Background(), []ethmanTypes.Sequence{})
+
+	assert.ErrorIs(t, err, ErrMaxRetriesExceeded)
+	// the suggested gas price is increased on every resubmission, up to the
+	// max gas price shared with the verify batches txs
+	assert.Equal(t, []*big.Int{big.NewInt(100), big.NewInt(110), big.NewInt(115)}, ethMan.sent)
+}
+
+// zeroGasPriceEthermanStub suggests a zero gas price.
+type zeroGasPriceEthermanStub struct {
+	notMinedEthermanStub
+}
+
+func (e *zeroGasPriceEthermanStub) SuggestedGasPrice(ctx context.Context) *big.Int {
+	return big.NewInt(0)
+}
+
+func TestVerifyBatchesMinGasPrice(t *testing.T) {
+	ethMan := &zeroGasPriceEthermanStub{}
+	txMan, err := New(Config{
+		MaxVerifyBatchTxRetries:      2,
+		WaitTxToBeMined:              cfgTypes.NewDuration(time.Millisecond),
+		VerifyBatchTxMiningWindow:    cfgTypes.NewDuration(5 * time.Millisecond),
+		PercentageToIncreaseGasPrice: 10,
+		MinGasPriceWei:               50,
+	}, ethMan, nil)
+	require.NoError(t, err)
+
+	_, err = txMan.VerifyBatches(context.Background(), 41, 42, nil, nil)
+
+	assert.ErrorIs(t, err, ErrTxNotMined)
+	require.GreaterOrEqual(t, len(ethMan.sent), 2)
+	// the zero estimate is raised to the min gas price, then increased
+	assert.Equal(t, big.NewInt(50), ethMan.sent[0])
+	assert.Equal(t, big.NewInt(55), ethMan.sent[1])
+
+	// a zero gas price is never increased without a floor
+	assert.Zero(t, increaseGasPrice(big.NewInt(0), 10).Sign())
+	assert.Equal(t, big.NewInt(50), txMan.floorGasPrice(increaseGasPrice(big.NewInt(0), 10)))
+
+	// no floor keeps the gas price suggested by the etherman
+	txMan, err = New(Config{}, ethMan, nil)
+	require.NoError(t, err)
+	assert.Nil(t, txMan.initialGasPrice(context.Background(), ethMan))
+	assert.Equal(t, big.NewInt(0), txMan.floorGasPrice(big.NewInt(0)))
+}
+
+// estimateEthermanStub records the gas price of the verify batches txs
+// estimated.
+type estimateEthermanStub struct {
+	etherman
+	estimated []*big.Int
+}
+
+func (e *estimateEthermanStub) SuggestedGasPrice(ctx context.Context) *big.Int {
+	return big.NewInt(100)
+}
+
+func (e *estimateEthermanStub) EstimateTrustedVerifyBatches(ctx context.Context, lastVerifiedBatch, newVerifiedBatch uint64, inputs *ethmanTypes.FinalProofInputs, gasPrice *big.Int) (*types.Transaction, error) {
+	e.estimated = append(e.estimated, gasPrice)
+	return types.NewTransaction(1, common.Address{}, big.NewInt(0), 0, gasPrice, nil), nil
+}
+
+func TestEstimateVerifyBatches(t *testing.T) {
+	ethMan := &estimateEthermanStub{}
+	txMan, err := New(Config{MaxGasPriceWei: 150}, ethMan, nil)
+	require.NoError(t, err)
+
+	// the gas price is the one VerifyBatches would send the tx with
+	_, err = txMan.EstimateVerifyBatches(context.Background(), 41, 42, nil, big.NewInt(1000))
+	require.NoError(t, err)
+	_, err = txMan.EstimateVerifyBatches(context.Background(), 41, 42, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*big.Int{big.NewInt(150), big.NewInt(100)}, ethMan.estimated)
+}
+
+type notSyncedStateStub struct {
+	state
+	notSyncedCalls int
+	calls          int
+}
+
+func (s *notSyncedStateStub) WaitSequencingTxToBeSynced(parentCtx context.Context, tx *types.Transaction, timeout time.Duration) (st.SyncProgress, error) {
+	s.calls++
+	progress := st.SyncProgress{LastSyncedBatchNumber: uint64(s.calls), Elapsed: timeout}
+	if s.calls <= s.notSyncedCalls {
+		return progress, context.DeadlineExceeded
+	}
+	return progress, nil
+}
+
+func TestWaitSequencingTxToBeSyncedRetries(t *testing.T) {
+	tx := types.NewTransaction(1, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	cfg := Config{
+		WaitTxToBeSynced:        cfgTypes.NewDuration(time.Millisecond),
+		WaitTxToBeSyncedRetries: 2,
+		WaitTxToBeSyncedBackoff: cfgTypes.NewDuration(time.Millisecond),
+	}
+
+	// synced on the second retry
+	st := &notSyncedStateStub{notSyncedCalls: 2}
+	txMan, err := New(cfg, nil, st)
+	require.NoError(t, err)
+	require.NoError(t, txMan.waitSequencingTxToBeSynced(context.Background(), tx))
+	assert.Equal(t, 3, st.calls)
+
+	// not synced within the retries
+	st = &notSyncedStateStub{notSyncedCalls: 3}
+	txMan, err = New(cfg, nil, st)
+	require.NoError(t, err)
+	err = txMan.waitSequencingTxToBeSynced(context.Background(), tx)
+	assert.ErrorIs(t, err, ErrTxNotSynced)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 3, st.calls)
+
+	// the progress of the synchronizer is reported
+	var syncErr *SyncTimeoutError
+	require.True(t, errors.As(err, &syncErr))
+	assert.Equal(t, tx.Hash(), syncErr.TxHash)
+	assert.Equal(t, uint64(3), syncErr.Progress.LastSyncedBatchNumber)
+	assert.Equal(t, 3*time.Millisecond, syncErr.Progress.Elapsed)
+}
+
+// resubmittedEthermanStub keeps the verify batches tx not mined until it was
+// sent maxSent times, then fails it.
+type resubmittedEthermanStub struct {
+	notMinedEthermanStub
+	maxSent int
+}
+
+var errStopResubmissions = errors.New("stop resubmissions")
+
+func (e *resubmittedEthermanStub) WaitTxToBeMined(ctx context.Context, tx *types.Transaction, timeout time.Duration) error {
+	if len(e.sent) >= e.maxSent {
+		return errStopResubmissions
+	}
+	return operations.ErrTimeoutReached
+}
+
+func TestVerifyBatchesEscalation(t *testing.T) {
+	testCases := []struct {
+		name         string
+		cfg          Config
+		expectedErr  error
+		expectedSent []*big.Int
+	}{
+		{
+			name:         "disabled",
+			cfg:          Config{EscalationAction: EscalationActionCancel},
+			expectedErr:  errStopResubmissions,
+			expectedSent: []*big.Int{nil, big.NewInt(110), big.NewInt(121), big.NewInt(133), big.NewInt(146), big.NewInt(160)},
+		},
+		{
+			name:         "alert",
+			cfg:          Config{EscalateAfterResubmissions: 2, EscalationAction: EscalationActionAlert},
+			expectedErr:  errStopResubmissions,
+			expectedSent: []*big.Int{nil, big.NewInt(110), big.NewInt(121), big.NewInt(133), big.NewInt(146), big.NewInt(160)},
+		},
+		{
+			name:         "bump",
+			cfg:          Config{EscalateAfterResubmissions: 2, EscalationAction: EscalationActionBump, EscalationPercentageToIncreaseGasPrice: 50},
+			expectedErr:  errStopResubmissions,
+			expectedSent: []*big.Int{nil, big.NewInt(110), big.NewInt(165), big.NewInt(247), big.NewInt(370), big.NewInt(555)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ethMan := &resubmittedEthermanStub{maxSent: 6}
+			cfg := tc.cfg
+			cfg.MaxVerifyBatchTxRetries = 1
+			cfg.PercentageToIncreaseGasPrice = 10
+			txMan, err := New(cfg, ethMan, nil)
+			require.NoError(t, err)
+
+			_, err = txMan.VerifyBatches(context.Background(), 41, 42, nil, nil)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expectedSent, ethMan.sent)
+		})
+	}
+}
+
+func TestVerifyBatchesEscalationCancel(t *testing.T) {
+	ethMan := newCancelEthermanStub(t)
+	txMan, err := New(Config{
+		MaxVerifyBatchTxRetries:      1,
+		PercentageToIncreaseGasPrice: 10,
+		EscalateAfterResubmissions:   2,
+		EscalationAction:             EscalationActionCancel,
+	}, ethMan, nil)
+	require.NoError(t, err)
+
+	_, err = txMan.VerifyBatches(context.Background(), 41, 42, nil, nil)
+	require.ErrorIs(t, err, ErrTxEscalated)
+	assert.Equal(t, 2, ethMan.sent)
+	require.Len(t, ethMan.canceled, 1)
+	assert.Equal(t, uint64(7), ethMan.canceled[0].Nonce())
+	assert.Equal(t, big.NewInt(110), ethMan.canceled[0].GasPrice())
+	assert.Empty(t, txMan.canceled)
+}
+
+func TestNewInvalidEscalationAction(t *testing.T) {
+	_, err := New(Config{EscalationAction: "ignore"}, nil, nil)
+	require.Error(t, err)
+
+	for _, action := range []EscalationAction{"", EscalationActionAlert, EscalationActionCancel, EscalationActionBump} {
+		_, err := New(Config{EscalationAction: action}, nil, nil)
+		require.NoError(t, err)
+	}
+}
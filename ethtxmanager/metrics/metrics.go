@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"github.com/0xPolygonHermez/zkevm-node/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	prefix          = "ethtxmanager_"
+	escalationsName = prefix + "escalations"
+
+	actionLabelName = "action"
+)
+
+// Register the metrics for the ethtxmanager package.
+func Register() {
+	counterVecs := []metrics.CounterVecOpts{
+		{
+			CounterOpts: prometheus.CounterOpts{
+				Name: escalationsName,
+				Help: "[ETHTXMANAGER] number of txs escalated after too many resubmissions without being mined",
+			},
+			Labels: []string{actionLabelName},
+		},
+	}
+
+	metrics.RegisterCounterVecs(counterVecs...)
+}
+
+// Escalation increments the counter of escalated txs for the given action.
+func Escalation(action string) {
+	metrics.CounterVecInc(escalationsName, action)
+}
@@ -0,0 +1,229 @@
+package ethtxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/0xPolygonHermez/zkevm-node/log"
+	st "github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jackc/pgx/v4"
+)
+
+// NonceStore persists the next nonce after the last one sent for the txs of
+// each account.
+type NonceStore interface {
+	GetNextNonce(ctx context.Context, from common.Address, dbTx pgx.Tx) (uint64, error)
+	SetNextNonce(ctx context.Context, from common.Address, nextNonce uint64, dbTx pgx.Tx) error
+}
+
+// TxReader reads the txs sent to L1, to tell the txs dropped from the pool
+// apart from the ones a lagging L1 node doesn't report yet.
+type TxReader interface {
+	GetTx(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+}
+
+// NonceManager hands out monotonically increasing nonces for the txs sent by
+// each account, before the previous txs are mined, so the sequence txs and the
+// verify batches txs sent concurrently by the same account never get the same
+// nonce. The nonces are reconciled with the pending nonce on L1 whenever no
+// nonce handed out is waiting to be sent, so the nonces of the txs never sent
+// or dropped from the pool are handed out again instead of leaving a gap. The
+// nonces of the txs sent since the start are only handed out again once their
+// txs are no longer found on L1, as the pending nonce reported by a lagging
+// L1 node can be behind them.
+type NonceManager struct {
+	store NonceStore
+
+	mu     sync.Mutex
+	next   map[common.Address]uint64
+	unsent map[common.Address]map[uint64]struct{}
+	// sent are the hashes of the last txs sent with the nonces not below the
+	// pending nonce
+	sent map[common.Address]map[uint64]common.Hash
+}
+
+// NewNonceManager returns a nonce manager persisting the next nonces in the
+// store. A nil store keeps them in memory only.
+func NewNonceManager(store NonceStore) *NonceManager {
+	return &NonceManager{
+		store:  store,
+		next:   make(map[common.Address]uint64),
+		unsent: make(map[common.Address]map[uint64]struct{}),
+		sent:   make(map[common.Address]map[uint64]common.Hash),
+	}
+}
+
+// Acquire hands out the next nonce of the account, never below its pending
+// nonce on L1, so the txs sent by other means are skipped. The txs sent are
+// looked up with l1 before handing their nonces out again. The nonce must be
+// either marked as sent or released.
+func (m *NonceManager) Acquire(ctx context.Context, from common.Address, pendingNonce uint64, l1 TxReader) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next, ok := m.next[from]
+	if !ok && m.store != nil {
+		stored, err := m.store.GetNextNonce(ctx, from, nil)
+		if err != nil && !errors.Is(err, st.ErrNotFound) {
+			return 0, fmt.Errorf("failed to get the next nonce of %s, err: %w", from, err)
+		}
+		next = stored
+	}
+	for nonce := range m.sent[from] {
+		if nonce < pendingNonce {
+			delete(m.sent[from], nonce)
+		}
+	}
+	if len(m.unsent[from]) == 0 && next > pendingNonce {
+		// every nonce handed out was sent, so the ones missing in the pool
+		// were dropped or never sent before a restart, unless the L1 node is
+		// lagging
+		if m.dropped(ctx, from, pendingNonce, next, l1) {
+			log.Warnf("nonces %d-%d of %s are not in the pool, handing them out again", pendingNonce, next-1, from)
+			next = pendingNonce
+		} else {
+			log.Warnf("pending nonce %d of %s is behind the nonces sent up to %d, the L1 node may be lagging", pendingNonce, from, next-1)
+		}
+	}
+	nonce := next
+	if pendingNonce > nonce {
+		nonce = pendingNonce
+	}
+	if m.unsent[from] == nil {
+		m.unsent[from] = make(map[uint64]struct{})
+	}
+	m.unsent[from][nonce] = struct{}{}
+	m.next[from] = nonce + 1
+	return nonce, nil
+}
+
+// dropped returns whether none of the txs sent with the nonces from
+// pendingNonce up to next is found on L1. The nonces with no tx sent since the
+// start are taken as not in the pool.
+func (m *NonceManager) dropped(ctx context.Context, from common.Address, pendingNonce, next uint64, l1 TxReader) bool {
+	for nonce, txHash := range m.sent[from] {
+		if nonce < pendingNonce || nonce >= next {
+			continue
+		}
+		_, _, err := l1.GetTx(ctx, txHash)
+		if !errors.Is(err, ethereum.NotFound) {
+			if err != nil {
+				log.Errorf("failed to check if tx %s with nonce %d was dropped, err: %v", txHash, nonce, err)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Sent marks the nonce as sent with the tx, persisting the next nonce after
+// it. A tx resubmitted with the same nonce replaces the previous one.
+func (m *NonceManager) Sent(ctx context.Context, from common.Address, nonce uint64, txHash common.Hash) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sent[from] == nil {
+		m.sent[from] = make(map[uint64]common.Hash)
+	}
+	m.sent[from][nonce] = txHash
+	if _, ok := m.unsent[from][nonce]; !ok {
+		return
+	}
+	delete(m.unsent[from], nonce)
+	if m.store != nil {
+		err := m.store.SetNextNonce(ctx, from, nonce+1, nil)
+		if err != nil {
+			log.Errorf("failed to store the next nonce of %s, err: %v", from, err)
+		}
+	}
+}
+
+// Release hands the nonce out again if it's the last one handed out for the
+// account, as no tx was sent with it.
+func (m *NonceManager) Release(ctx context.Context, from common.Address, nonce uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.unsent[from], nonce)
+	if next, ok := m.next[from]; ok && next == nonce+1 {
+		m.next[from] = nonce
+	}
+}
+
+// acquireNonce returns the nonce of the next tx of the account of ethMan, or
+// zero so ethMan picks the pending nonce if the nonces are not managed.
+func (c *Client) acquireNonce(ctx context.Context, ethMan etherman) (*big.Int, error) {
+	if c.nonces == nil {
+		return big.NewInt(0), nil
+	}
+	from, err := ethMan.GetPublicAddress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the sender address, err: %w", err)
+	}
+	pendingNonce, err := ethMan.PendingNonce(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the pending nonce of %s, err: %w", from, err)
+	}
+	nonce, err := c.nonces.Acquire(ctx, from, pendingNonce, ethMan)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(nonce), nil
+}
+
+// releaseNonce releases the nonce acquired for a tx that was never sent.
+func (c *Client) releaseNonce(ctx context.Context, ethMan etherman, nonce *big.Int) {
+	if c.nonces == nil {
+		return
+	}
+	from, err := ethMan.GetPublicAddress()
+	if err != nil {
+		log.Errorf("failed to get the sender address to release nonce %d, err: %v", nonce, err)
+		return
+	}
+	c.nonces.Release(ctx, from, nonce.Uint64())
+}
+
+// sentNonce marks the nonce of the tx sent, or resubmitted, as sent.
+func (c *Client) sentNonce(ctx context.Context, ethMan etherman, tx *types.Transaction) {
+	if c.nonces == nil {
+		return
+	}
+	from, err := ethMan.GetPublicAddress()
+	if err != nil {
+		log.Errorf("failed to get the sender address to mark nonce %d as sent, err: %v", tx.Nonce(), err)
+		return
+	}
+	c.nonces.Sent(ctx, from, tx.Nonce(), tx.Hash())
+}
+
+// renewNonce returns the nonce to resubmit the tx with: its own nonce, so the
+// resubmission replaces it, unless the nonces are managed and the tx was
+// dropped and its nonce consumed by a different tx, in which case a fresh
+// nonce is acquired.
+func (c *Client) renewNonce(ctx context.Context, ethMan etherman, tx *types.Transaction) (*big.Int, error) {
+	nonce := new(big.Int).SetUint64(tx.Nonce())
+	if c.nonces == nil {
+		return nonce, nil
+	}
+	_, _, err := ethMan.GetTx(ctx, tx.Hash())
+	if !errors.Is(err, ethereum.NotFound) {
+		return nonce, nil
+	}
+	pendingNonce, err := ethMan.PendingNonce(ctx)
+	if err != nil {
+		log.Errorf("failed to get pending nonce to check if nonce %d was consumed, err: %v", tx.Nonce(), err)
+		return nonce, nil
+	}
+	if pendingNonce <= tx.Nonce() {
+		return nonce, nil
+	}
+	log.Warnf("tx %s dropped and its nonce %d consumed by a different tx, acquiring a new nonce", tx.Hash(), tx.Nonce())
+	return c.acquireNonce(ctx, ethMan)
+}
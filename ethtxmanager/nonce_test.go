@@ -0,0 +1,261 @@
+package ethtxmanager
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	st "github.com/0xPolygonHermez/zkevm-node/state"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nonceStoreStub keeps the next nonces in memory.
+type nonceStoreStub struct {
+	state
+
+	mu   sync.Mutex
+	next map[common.Address]uint64
+}
+
+func newNonceStoreStub() *nonceStoreStub {
+	return &nonceStoreStub{next: make(map[common.Address]uint64)}
+}
+
+func (s *nonceStoreStub) GetNextNonce(ctx context.Context, from common.Address, dbTx pgx.Tx) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next, ok := s.next[from]
+	if !ok {
+		return 0, st.ErrNotFound
+	}
+	return next, nil
+}
+
+func (s *nonceStoreStub) SetNextNonce(ctx context.Context, from common.Address, nextNonce uint64, dbTx pgx.Tx) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next[from] = nextNonce
+	return nil
+}
+
+// pendingNonceEthermanStub is an account whose txs are not mined, so its
+// pending nonce doesn't move.
+type pendingNonceEthermanStub struct {
+	etherman
+	from         common.Address
+	pendingNonce uint64
+	txs          map[common.Hash]*types.Transaction
+}
+
+func (e *pendingNonceEthermanStub) GetPublicAddress() (common.Address, error) {
+	return e.from, nil
+}
+
+func (e *pendingNonceEthermanStub) PendingNonce(ctx context.Context) (uint64, error) {
+	return e.pendingNonce, nil
+}
+
+func (e *pendingNonceEthermanStub) GetTx(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	tx, ok := e.txs[txHash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, true, nil
+}
+
+func TestNonceManagerConcurrentAcquire(t *testing.T) {
+	store := newNonceStoreStub()
+	ethMan := &pendingNonceEthermanStub{from: common.HexToAddress("0x1"), pendingNonce: 7}
+	// the sequence txs and the verify batches txs are sent by the same account
+	c, err := NewWithVerifyBatchesEtherman(Config{ManageNonces: true}, ethMan, ethMan, store)
+	require.NoError(t, err)
+
+	const senders, txs = 4, 50
+	nonces := make(chan uint64, senders*txs)
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		ethMan := c.ethMan
+		if i%2 == 1 {
+			ethMan = c.verifyBatchesEthMan
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < txs; j++ {
+				nonce, err := c.acquireNonce(context.Background(), ethMan)
+				require.NoError(t, err)
+				nonces <- nonce.Uint64()
+			}
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+
+	// every tx got its own nonce, from the pending one on
+	seen := make(map[uint64]bool)
+	for nonce := range nonces {
+		assert.False(t, seen[nonce], "nonce %d handed out twice", nonce)
+		seen[nonce] = true
+	}
+	for nonce := uint64(7); nonce < 7+senders*txs; nonce++ {
+		assert.True(t, seen[nonce], "nonce %d not handed out", nonce)
+	}
+	// none was sent, so none was persisted
+	assert.Empty(t, store.next)
+}
+
+func TestNonceManagerPersisted(t *testing.T) {
+	ctx := context.Background()
+	store := newNonceStoreStub()
+	from := common.HexToAddress("0x1")
+	// none of the txs sent is found on L1
+	l1 := &pendingNonceEthermanStub{}
+
+	m := NewNonceManager(store)
+	nonce, err := m.Acquire(ctx, from, 3, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), nonce)
+	m.Sent(ctx, from, nonce, common.Hash{})
+	nonce, err = m.Acquire(ctx, from, 4, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), nonce)
+
+	// only the sent nonces are persisted
+	assert.Equal(t, uint64(4), store.next[from])
+
+	// the txs sent by other means are skipped
+	m.Sent(ctx, from, nonce, common.Hash{})
+	nonce, err = m.Acquire(ctx, from, 10, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), nonce)
+
+	// the nonces of each account are tracked apart
+	nonce, err = m.Acquire(ctx, common.HexToAddress("0x2"), 0, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), nonce)
+}
+
+func TestNonceManagerReconcile(t *testing.T) {
+	ctx := context.Background()
+	store := newNonceStoreStub()
+	from := common.HexToAddress("0x1")
+	// none of the txs sent is found on L1
+	l1 := &pendingNonceEthermanStub{}
+
+	m := NewNonceManager(store)
+	first, err := m.Acquire(ctx, from, 3, l1)
+	require.NoError(t, err)
+	second, err := m.Acquire(ctx, from, 3, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), second)
+
+	// a nonce handed out is not sent yet, so the next one is kept even if
+	// the pool doesn't contain the previous ones
+	m.Sent(ctx, from, second, common.Hash{})
+	nonce, err := m.Acquire(ctx, from, 3, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), nonce)
+
+	// once every nonce handed out was sent, the ones not in the pool, dropped
+	// or aborted, are handed out again instead of leaving a gap
+	m.Sent(ctx, from, first, common.Hash{})
+	m.Sent(ctx, from, nonce, common.Hash{})
+	nonce, err = m.Acquire(ctx, from, 4, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), nonce)
+
+	// after a restart, the nonces persisted but not in the pool, whose txs
+	// were never sent, are handed out again
+	store.next[from] = 9
+	m = NewNonceManager(store)
+	nonce, err = m.Acquire(ctx, from, 6, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), nonce)
+}
+
+func TestNonceManagerRelease(t *testing.T) {
+	ctx := context.Background()
+	from := common.HexToAddress("0x1")
+	l1 := &pendingNonceEthermanStub{}
+	m := NewNonceManager(nil)
+
+	first, err := m.Acquire(ctx, from, 0, l1)
+	require.NoError(t, err)
+	second, err := m.Acquire(ctx, from, 0, l1)
+	require.NoError(t, err)
+
+	// only the last nonce handed out can be handed out again
+	m.Release(ctx, from, first)
+	nonce, err := m.Acquire(ctx, from, 0, l1)
+	require.NoError(t, err)
+	assert.Equal(t, second+1, nonce)
+
+	m.Release(ctx, from, nonce)
+	nonce, err = m.Acquire(ctx, from, 0, l1)
+	require.NoError(t, err)
+	assert.Equal(t, second+1, nonce)
+}
+
+func TestNonceManagerLaggingPendingNonce(t *testing.T) {
+	ctx := context.Background()
+	from := common.HexToAddress("0x1")
+	tx := types.NewTransaction(5, common.Address{}, big.NewInt(0), 0, big.NewInt(100), nil)
+	l1 := &pendingNonceEthermanStub{txs: map[common.Hash]*types.Transaction{tx.Hash(): tx}}
+	m := NewNonceManager(nil)
+
+	nonce, err := m.Acquire(ctx, from, 5, l1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), nonce)
+	m.Sent(ctx, from, nonce, tx.Hash())
+
+	// the L1 node lags behind the tx just sent, its nonce isn't handed out
+	// again while the tx is found
+	nonce, err = m.Acquire(ctx, from, 5, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), nonce)
+	m.Sent(ctx, from, nonce, common.HexToHash("0x6"))
+
+	// once the tx is dropped, its nonce and the following ones are
+	delete(l1.txs, tx.Hash())
+	nonce, err = m.Acquire(ctx, from, 5, l1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), nonce)
+}
+
+func TestRenewNonce(t *testing.T) {
+	ctx := context.Background()
+	tx := types.NewTransaction(5, common.Address{}, big.NewInt(0), 0, big.NewInt(100), nil)
+
+	testCases := []struct {
+		name          string
+		manageNonces  bool
+		pending       bool
+		pendingNonce  uint64
+		expectedNonce uint64
+	}{
+		{name: "not managed", pendingNonce: 8, expectedNonce: 5},
+		{name: "tx pending", manageNonces: true, pending: true, pendingNonce: 8, expectedNonce: 5},
+		{name: "tx dropped, nonce free", manageNonces: true, pendingNonce: 5, expectedNonce: 5},
+		{name: "tx dropped, nonce consumed", manageNonces: true, pendingNonce: 8, expectedNonce: 8},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ethMan := &pendingNonceEthermanStub{from: common.HexToAddress("0x1"), pendingNonce: tc.pendingNonce, txs: make(map[common.Hash]*types.Transaction)}
+			if tc.pending {
+				ethMan.txs[tx.Hash()] = tx
+			}
+			c, err := New(Config{ManageNonces: tc.manageNonces}, ethMan, newNonceStoreStub())
+			require.NoError(t, err)
+
+			nonce, err := c.renewNonce(ctx, ethMan, tx)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedNonce, nonce.Uint64())
+		})
+	}
+}
@@ -82,7 +82,10 @@ func TestMain(m *testing.M) {
 
 	mtDBServerConfig := merkletree.Config{URI: fmt.Sprintf("%s:50061", zkProverURI)}
 	var mtDBCancel context.CancelFunc
-	mtDBServiceClient, mtDBClientConn, mtDBCancel = merkletree.NewMTDBServiceClient(ctx, mtDBServerConfig)
+	mtDBServiceClient, mtDBClientConn, mtDBCancel, err = merkletree.NewMTDBServiceClient(ctx, mtDBServerConfig)
+	if err != nil {
+		panic(err)
+	}
 	s = mtDBClientConn.GetState()
 	log.Infof("stateDbClientConn state: %s", s.String())
 	defer func() {
@@ -2,30 +2,148 @@ package merkletree
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/0xPolygonHermez/zkevm-node/log"
 	"github.com/0xPolygonHermez/zkevm-node/merkletree/pb"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
 )
 
-// NewMTDBServiceClient creates a new MTDB client.
-func NewMTDBServiceClient(ctx context.Context, c Config) (pb.StateDBServiceClient, *grpc.ClientConn, context.CancelFunc) {
+// NewMTDBServiceClient creates a new MTDB client. The connection is retried
+// with an exponential backoff up to the configured max connection retries, so
+// a server slow to start doesn't fail the client. When the configured pool
+// size is greater than one, the returned client load-balances Get requests
+// across that many connections and the returned cancel func closes them.
+func NewMTDBServiceClient(ctx context.Context, c Config) (pb.StateDBServiceClient, *grpc.ClientConn, context.CancelFunc, error) {
+	if err := validateTarget(c.URI); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid merkletree URI: %w", err)
+	}
+	creds, err := transportCredentials(c.TLS)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithBlock(),
 	}
-	const maxWaitSeconds = 120
-	ctx, cancel := context.WithTimeout(ctx, maxWaitSeconds*time.Second)
+	if c.LoadBalancingPolicy != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":%q}`, c.LoadBalancingPolicy)))
+	}
+	ctx, cancel := context.WithCancel(ctx)
 
-	log.Infof("trying to connect to merkletree: %v", c.URI)
-	mtDBConn, err := grpc.DialContext(ctx, c.URI, opts...)
+	target, mtDBConn, err := dialWithRetries(ctx, c, opts)
 	if err != nil {
-		log.Fatalf("fail to dial: %v", err)
+		cancel()
+		return nil, nil, nil, err
 	}
-	log.Infof("connected to merkletree")
+	log.Infof("connected to merkletree: %v", target)
 
 	mtDBClient := pb.NewStateDBServiceClient(mtDBConn)
-	return mtDBClient, mtDBConn, cancel
+	if c.PoolSize < 2 { //nolint:gomnd
+		return mtDBClient, mtDBConn, cancel, nil
+	}
+
+	conns := []*grpc.ClientConn{mtDBConn}
+	clients := []pb.StateDBServiceClient{mtDBClient}
+	closePool := func() {
+		cancel()
+		for _, conn := range conns {
+			if err := conn.Close(); err != nil {
+				log.Warnf("failed to close merkletree connection: %v", err)
+			}
+		}
+	}
+	for i := 1; i < c.PoolSize; i++ {
+		dialCtx, dialCancel := context.WithTimeout(ctx, dialTimeout)
+		conn, err := grpc.DialContext(dialCtx, target, opts...)
+		dialCancel()
+		if err != nil {
+			closePool()
+			return nil, nil, nil, fmt.Errorf("fail to dial pool connection %d: %w", i, err)
+		}
+		conns = append(conns, conn)
+		clients = append(clients, pb.NewStateDBServiceClient(conn))
+	}
+	log.Infof("merkletree connection pool of size %d ready", c.PoolSize)
+
+	return newPooledClient(clients), mtDBConn, closePool, nil
+}
+
+// dialTimeout is the time to wait for each connection attempt.
+var dialTimeout = 120 * time.Second
+
+// dialWithRetries connects to the configured URI, or to the fallback URI if
+// it can't, retrying with an exponential backoff starting at the configured
+// retry interval. It returns the target connected to.
+func dialWithRetries(ctx context.Context, c Config, opts []grpc.DialOption) (string, *grpc.ClientConn, error) {
+	interval := c.RetryInterval.Duration
+	for retries := 0; ; retries++ {
+		target, conn, err := dialOnce(ctx, c, opts)
+		if err == nil {
+			return target, conn, nil
+		}
+		if retries >= c.MaxConnectionRetries {
+			return "", nil, fmt.Errorf("fail to dial merkletree after %d retries: %w", retries, err)
+		}
+
+		log.Warnf("fail to dial merkletree, retry #%d in %v: %v", retries+1, interval, err)
+		select {
+		case <-ctx.Done():
+			return "", nil, fmt.Errorf("fail to dial merkletree: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// dialOnce connects to the configured URI, falling back to the fallback URI
+// if it can't.
+func dialOnce(ctx context.Context, c Config, opts []grpc.DialOption) (string, *grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	log.Infof("trying to connect to merkletree: %v", c.URI)
+	conn, err := dial(ctx, c.URI, c.FallbackURI != "", opts)
+	if err == nil || c.FallbackURI == "" {
+		return c.URI, conn, err
+	}
+	log.Warnf("fail to dial merkletree %v, falling back to %v: %v", c.URI, c.FallbackURI, err)
+	conn, err = dial(ctx, c.FallbackURI, false, opts)
+	return c.FallbackURI, conn, err
+}
+
+// fallbackDialTimeout is the time to wait for the connection through the
+// configured URI when there is a fallback URI.
+const fallbackDialTimeout = 30 * time.Second
+
+// dial connects to the target, waiting for the fallback dial timeout at most
+// if there is a fallback.
+func dial(ctx context.Context, target string, hasFallback bool, opts []grpc.DialOption) (*grpc.ClientConn, error) {
+	if hasFallback {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fallbackDialTimeout)
+		defer cancel()
+	}
+	return grpc.DialContext(ctx, target, opts...)
+}
+
+// validateTarget checks that the scheme of a name resolution target, like
+// dns:///statedb:50061, has a registered resolver. Targets without a scheme
+// are dialed directly.
+func validateTarget(target string) error {
+	if !strings.Contains(target, "://") {
+		return nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("failed to parse target %q: %w", target, err)
+	}
+	if resolver.Get(u.Scheme) == nil {
+		return fmt.Errorf("no resolver registered for scheme %q of target %q", u.Scheme, target)
+	}
+	return nil
 }
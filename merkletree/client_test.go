@@ -0,0 +1,68 @@
+package merkletree
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonHermez/zkevm-node/config/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestValidateTarget(t *testing.T) {
+	assert.NoError(t, validateTarget("127.0.0.1:50061"))
+	assert.NoError(t, validateTarget("statedb:50061"))
+	assert.NoError(t, validateTarget("dns:///statedb:50061"))
+	assert.NoError(t, validateTarget("passthrough:///statedb:50061"))
+	assert.Error(t, validateTarget("consul://statedb:50061"))
+}
+
+func TestNewMTDBServiceClientRetries(t *testing.T) {
+	defer func(timeout time.Duration) { dialTimeout = timeout }(dialTimeout)
+	dialTimeout = 50 * time.Millisecond
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	// nothing listening, the error is returned once the retries run out
+	start := time.Now()
+	_, _, _, err = NewMTDBServiceClient(context.Background(), Config{
+		URI:                  addr,
+		MaxConnectionRetries: 2,
+		RetryInterval:        types.NewDuration(10 * time.Millisecond),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "after 2 retries")
+	assert.GreaterOrEqual(t, time.Since(start), 3*dialTimeout+30*time.Millisecond)
+
+	// the server starts after the first attempt
+	go func() {
+		time.Sleep(2 * dialTimeout)
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		srv := grpc.NewServer()
+		t.Cleanup(srv.Stop)
+		_ = srv.Serve(lis)
+	}()
+	client, conn, cancel, err := NewMTDBServiceClient(context.Background(), Config{
+		URI:                  addr,
+		MaxConnectionRetries: 5,
+		RetryInterval:        types.NewDuration(10 * time.Millisecond),
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, client)
+	cancel()
+	require.NoError(t, conn.Close())
+}
+
+func TestNewMTDBServiceClientInvalidURI(t *testing.T) {
+	_, _, _, err := NewMTDBServiceClient(context.Background(), Config{URI: "consul://statedb:50061"})
+	assert.Error(t, err)
+}
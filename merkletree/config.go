@@ -1,7 +1,48 @@
 package merkletree
 
+import "github.com/0xPolygonHermez/zkevm-node/config/types"
+
 // Config represents the configuration of the merkletree server.
 type Config struct {
-	// URI is the server URI.
+	// URI is the server URI. It accepts a gRPC name resolution target like
+	// dns:///statedb:50061 to discover the servers dynamically.
 	URI string `mapstructure:"URI"`
+	// FallbackURI is the server URI used when the connection through URI
+	// can't be established, e.g. because its name can't be resolved. Empty
+	// means no fallback.
+	FallbackURI string `mapstructure:"FallbackURI"`
+	// LoadBalancingPolicy is the gRPC load balancing policy used across the
+	// servers resolved from URI, e.g. round_robin. Empty uses the gRPC
+	// default, which connects to the first server only.
+	LoadBalancingPolicy string `mapstructure:"LoadBalancingPolicy"`
+	// PoolSize is the number of connections opened against the server. Get
+	// requests are load-balanced across them, the rest of the operations
+	// always use the first one. Values lower than 2 disable the pool.
+	PoolSize int `mapstructure:"PoolSize"`
+	// MaxConnectionRetries is the number of times the connection to the
+	// server is retried when it can't be established, e.g. because the
+	// server is still starting. Zero means no retries
+	MaxConnectionRetries int `mapstructure:"MaxConnectionRetries"`
+	// RetryInterval is the time to wait before the first connection retry,
+	// it's doubled on each following retry
+	RetryInterval types.Duration `mapstructure:"RetryInterval"`
+	// TLS is the configuration of the encryption of the connection to the
+	// server
+	TLS TLSConfig `mapstructure:"TLS"`
+}
+
+// TLSConfig represents the configuration of the TLS connection to the
+// merkletree server.
+type TLSConfig struct {
+	// Enabled encrypts the connection to the server with TLS. If false, the
+	// connection is plaintext
+	Enabled bool `mapstructure:"Enabled"`
+	// CACert is the path of the PEM encoded CA certificate used to verify
+	// the server. Empty uses the system CAs
+	CACert string `mapstructure:"CACert"`
+	// ClientCert is the path of the PEM encoded certificate presented to the
+	// server when it requires the client to authenticate. Empty presents none
+	ClientCert string `mapstructure:"ClientCert"`
+	// ClientKey is the path of the PEM encoded key of the client certificate
+	ClientKey string `mapstructure:"ClientKey"`
 }
@@ -0,0 +1,54 @@
+package merkletree
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/0xPolygonHermez/zkevm-node/merkletree/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// pooledClient is a pb.StateDBServiceClient backed by several clients. Get
+// requests are distributed among them in a round-robin fashion, while the
+// operations that modify the tree are always sent through the first one to
+// keep them ordered.
+type pooledClient struct {
+	clients []pb.StateDBServiceClient
+	next    uint64
+}
+
+func newPooledClient(clients []pb.StateDBServiceClient) *pooledClient {
+	return &pooledClient{clients: clients}
+}
+
+// pick returns the next client in the pool.
+func (p *pooledClient) pick() pb.StateDBServiceClient {
+	n := atomic.AddUint64(&p.next, 1)
+	return p.clients[(n-1)%uint64(len(p.clients))]
+}
+
+// Set implements pb.StateDBServiceClient.
+func (p *pooledClient) Set(ctx context.Context, in *pb.SetRequest, opts ...grpc.CallOption) (*pb.SetResponse, error) {
+	return p.clients[0].Set(ctx, in, opts...)
+}
+
+// Get implements pb.StateDBServiceClient.
+func (p *pooledClient) Get(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.GetResponse, error) {
+	return p.pick().Get(ctx, in, opts...)
+}
+
+// SetProgram implements pb.StateDBServiceClient.
+func (p *pooledClient) SetProgram(ctx context.Context, in *pb.SetProgramRequest, opts ...grpc.CallOption) (*pb.SetProgramResponse, error) {
+	return p.clients[0].SetProgram(ctx, in, opts...)
+}
+
+// GetProgram implements pb.StateDBServiceClient.
+func (p *pooledClient) GetProgram(ctx context.Context, in *pb.GetProgramRequest, opts ...grpc.CallOption) (*pb.GetProgramResponse, error) {
+	return p.pick().GetProgram(ctx, in, opts...)
+}
+
+// Flush implements pb.StateDBServiceClient.
+func (p *pooledClient) Flush(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	return p.clients[0].Flush(ctx, in, opts...)
+}
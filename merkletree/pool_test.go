@@ -0,0 +1,45 @@
+package merkletree
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xPolygonHermez/zkevm-node/merkletree/pb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type countingClient struct {
+	pb.StateDBServiceClient
+	gets int
+	sets int
+}
+
+func (c *countingClient) Get(ctx context.Context, in *pb.GetRequest, opts ...grpc.CallOption) (*pb.GetResponse, error) {
+	c.gets++
+	return &pb.GetResponse{}, nil
+}
+
+func (c *countingClient) Set(ctx context.Context, in *pb.SetRequest, opts ...grpc.CallOption) (*pb.SetResponse, error) {
+	c.sets++
+	return &pb.SetResponse{}, nil
+}
+
+func TestPooledClient(t *testing.T) {
+	c1, c2, c3 := &countingClient{}, &countingClient{}, &countingClient{}
+	p := newPooledClient([]pb.StateDBServiceClient{c1, c2, c3})
+	ctx := context.Background()
+
+	for i := 0; i < 7; i++ {
+		_, err := p.Get(ctx, &pb.GetRequest{})
+		require.NoError(t, err)
+	}
+	_, err := p.Set(ctx, &pb.SetRequest{})
+	require.NoError(t, err)
+
+	require.Equal(t, 3, c1.gets)
+	require.Equal(t, 2, c2.gets)
+	require.Equal(t, 2, c3.gets)
+	require.Equal(t, 1, c1.sets)
+	require.Equal(t, 0, c2.sets+c3.sets)
+}
@@ -0,0 +1,157 @@
+package merkletree
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// testCert is a certificate signed by the test CA, along with its PEM
+// encoded certificate and key.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func newCertTemplate(serial int64, name string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+}
+
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestNewMTDBServiceClientTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	caTemplate := newCertTemplate(1, "test CA")
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	ca := newTestCert(t, caTemplate, nil)
+
+	serverTemplate := newCertTemplate(2, "statedb")
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	server := newTestCert(t, serverTemplate, ca)
+
+	clientTemplate := newCertTemplate(3, "node")
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client := newTestCert(t, clientTemplate, ca)
+
+	serverCert, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	tlsCfg := TLSConfig{
+		Enabled:    true,
+		CACert:     writeTestFile(t, dir, "ca.pem", ca.certPEM),
+		ClientCert: writeTestFile(t, dir, "client.pem", client.certPEM),
+		ClientKey:  writeTestFile(t, dir, "client.key", client.keyPEM),
+	}
+
+	mtDBClient, conn, cancel, err := NewMTDBServiceClient(context.Background(), Config{URI: lis.Addr().String(), TLS: tlsCfg})
+	require.NoError(t, err)
+	assert.NotNil(t, mtDBClient)
+	cancel()
+	require.NoError(t, conn.Close())
+
+	// the handshake fails without the client certificate, so the connection
+	// is never ready
+	defer func(timeout time.Duration) { dialTimeout = timeout }(dialTimeout)
+	dialTimeout = 200 * time.Millisecond
+	_, _, _, err = NewMTDBServiceClient(context.Background(), Config{
+		URI: lis.Addr().String(),
+		TLS: TLSConfig{Enabled: true, CACert: tlsCfg.CACert},
+	})
+	assert.Error(t, err)
+}
+
+func TestTransportCredentials(t *testing.T) {
+	dir := t.TempDir()
+	invalidPEM := writeTestFile(t, dir, "invalid.pem", []byte("not a certificate"))
+
+	creds, err := transportCredentials(TLSConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, "insecure", creds.Info().SecurityProtocol)
+
+	creds, err = transportCredentials(TLSConfig{Enabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, "tls", creds.Info().SecurityProtocol)
+
+	testCases := []struct {
+		name string
+		cfg  TLSConfig
+	}{
+		{name: "missing CA certificate", cfg: TLSConfig{Enabled: true, CACert: filepath.Join(dir, "missing.pem")}},
+		{name: "invalid CA certificate", cfg: TLSConfig{Enabled: true, CACert: invalidPEM}},
+		{name: "client certificate without key", cfg: TLSConfig{Enabled: true, ClientCert: invalidPEM}},
+		{name: "client key without certificate", cfg: TLSConfig{Enabled: true, ClientKey: invalidPEM}},
+		{name: "invalid client certificate", cfg: TLSConfig{Enabled: true, ClientCert: invalidPEM, ClientKey: invalidPEM}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := transportCredentials(tc.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
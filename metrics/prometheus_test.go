@@ -15,6 +15,11 @@ var (
 	gaugeName             = "gaugeName"
 	gaugeOpts             = prometheus.GaugeOpts{Name: gaugeName}
 	gauge                 prometheus.Gauge
+	gaugeVecName          = "gaugeVecName"
+	gaugeVecLabelName     = "gaugeVecLabelName"
+	gaugeVecLabelVal      = "gaugeVecLabelVal"
+	gaugeVecOpts          = GaugeVecOpts{prometheus.GaugeOpts{Name: gaugeVecName}, []string{gaugeVecLabelName}}
+	gaugeVec              *prometheus.GaugeVec
 	counterName           = "counterName"
 	counterOpts           = prometheus.CounterOpts{Name: counterName}
 	counter               prometheus.Counter
@@ -39,6 +44,7 @@ var (
 func setup() {
 	Init()
 	gauge = prometheus.NewGauge(gaugeOpts)
+	gaugeVec = prometheus.NewGaugeVec(gaugeVecOpts.GaugeOpts, gaugeVecOpts.Labels)
 	counter = prometheus.NewCounter(counterOpts)
 	counterVec = prometheus.NewCounterVec(counterVecOpts.CounterOpts, counterVecOpts.Labels)
 	histogram = prometheus.NewHistogram(histogramOpts)
@@ -186,6 +192,53 @@ func TestUnregisterCounters(t *testing.T) {
 	assert.Len(t, counters, 0)
 }
 
+func TestRegisterGaugeVecs(t *testing.T) {
+	setup()
+	defer cleanup()
+	gaugeVecsOpts := []GaugeVecOpts{gaugeVecOpts}
+
+	RegisterGaugeVecs(gaugeVecsOpts...)
+
+	assert.Len(t, gaugeVecs, 1)
+}
+
+func TestGaugeVec(t *testing.T) {
+	setup()
+	defer cleanup()
+	gaugeVecs[gaugeVecName] = gaugeVec
+
+	actual, exist := GaugeVec(gaugeVecName)
+
+	assert.True(t, exist)
+	assert.Equal(t, gaugeVec, actual)
+}
+
+func TestGaugeVecIncDec(t *testing.T) {
+	setup()
+	defer cleanup()
+	gaugeVecs[gaugeVecName] = gaugeVec
+	expected := float64(1)
+
+	GaugeVecInc(gaugeVecName, gaugeVecLabelVal)
+	GaugeVecInc(gaugeVecName, gaugeVecLabelVal)
+	GaugeVecDec(gaugeVecName, gaugeVecLabelVal)
+	currGaugeVec, err := gaugeVec.GetMetricWithLabelValues(gaugeVecLabelVal)
+	require.NoError(t, err)
+	actual := testutil.ToFloat64(currGaugeVec)
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnregisterGaugeVecs(t *testing.T) {
+	setup()
+	defer cleanup()
+	RegisterGaugeVecs(gaugeVecOpts)
+
+	UnregisterGaugeVecs(gaugeVecName)
+
+	assert.Len(t, gaugeVecs, 0)
+}
+
 func TestRegisterCounterVecs(t *testing.T) {
 	setup()
 	defer cleanup()
@@ -329,6 +382,23 @@ func TestHistogramVecObserve(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestHistogramVecObserveWithLabels(t *testing.T) {
+	setup()
+	defer cleanup()
+	histogramVec = prometheus.NewHistogramVec(histogramVecOpts.HistogramOpts, []string{histogramVecLabelName, "otherLabelName"})
+	histogramVecs[histogramVecName] = histogramVec
+	expected := float64(2)
+
+	HistogramVecObserveWithLabels(histogramVecName, expected, histogramVecLabelVal, "otherLabelVal")
+
+	currHistogramVec := histogramVec.WithLabelValues(histogramVecLabelVal, "otherLabelVal")
+	m := &dto.Metric{}
+	require.NoError(t, currHistogramVec.(prometheus.Histogram).Write(m))
+	h := m.GetHistogram()
+	actual := h.GetSampleSum()
+	assert.Equal(t, expected, actual)
+}
+
 func TestUnregisterHistogramVecs(t *testing.T) {
 	setup()
 	defer cleanup()
@@ -926,7 +926,10 @@ func newState(sqlDB *pgxpool.Pool) *state.State {
 	executorServerConfig := executor.Config{URI: fmt.Sprintf("%s:50071", zkProverURI)}
 	mtDBServerConfig := merkletree.Config{URI: fmt.Sprintf("%s:50061", zkProverURI)}
 	executorClient, _, _ := executor.NewExecutorClient(ctx, executorServerConfig)
-	stateDBClient, _, _ := merkletree.NewMTDBServiceClient(ctx, mtDBServerConfig)
+	stateDBClient, _, _, err := merkletree.NewMTDBServiceClient(ctx, mtDBServerConfig)
+	if err != nil {
+		panic(err)
+	}
 	stateTree := merkletree.NewStateTree(stateDBClient)
 	st := state.NewState(state.Config{MaxCumulativeGasUsed: 800000}, stateDb, executorClient, stateTree)
 	return st
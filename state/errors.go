@@ -46,6 +46,9 @@ var (
 	// ErrInsufficientFunds is returned if the total cost of executing a transaction
 	// is higher than the balance of the user's account.
 	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
+	// ErrProofGenerating indicates that a stored proof is locked while being
+	// generated or verified
+	ErrProofGenerating = errors.New("proof is being generated")
 )
 
 func constructErrorFromRevert(err error, returnValue []byte) error {
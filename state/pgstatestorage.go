@@ -93,6 +93,17 @@ func NewPostgresStorage(db *pgxpool.Pool) *PostgresStorage {
 	}
 }
 
+// ResetIdleConnections closes the idle connections of the pool, so they are
+// established again on the next use. It's meant to recover from a database
+// connection drop without waiting for the pool health check.
+func (p *PostgresStorage) ResetIdleConnections(ctx context.Context) {
+	for _, conn := range p.AcquireAllIdle(ctx) {
+		// a closed connection is destroyed when released
+		conn.Conn().Close(ctx) //nolint:errcheck
+		conn.Release()
+	}
+}
+
 // getExecQuerier determines which execQuerier to use, dbTx or the main pgxpool
 func (p *PostgresStorage) getExecQuerier(dbTx pgx.Tx) execQuerier {
 	if dbTx != nil {
@@ -1373,13 +1384,13 @@ func (p *PostgresStorage) GetLastVerifiedBatchNumberSeenOnEthereum(ctx context.C
 
 // GetLastVerifiedBatch gets last verified batch
 func (p *PostgresStorage) GetLastVerifiedBatch(ctx context.Context, dbTx pgx.Tx) (*VerifiedBatch, error) {
-	const query = "SELECT block_num, batch_num, tx_hash, aggregator FROM state.verified_batch ORDER BY batch_num DESC LIMIT 1"
+	const query = "SELECT block_num, batch_num, tx_hash, aggregator, state_root FROM state.verified_batch ORDER BY batch_num DESC LIMIT 1"
 	var (
-		verifiedBatch VerifiedBatch
-		txHash, agg   string
+		verifiedBatch   VerifiedBatch
+		txHash, agg, sr string
 	)
 	e := p.getExecQuerier(dbTx)
-	err := e.QueryRow(ctx, query).Scan(&verifiedBatch.BlockNumber, &verifiedBatch.BatchNumber, &txHash, &agg)
+	err := e.QueryRow(ctx, query).Scan(&verifiedBatch.BlockNumber, &verifiedBatch.BatchNumber, &txHash, &agg, &sr)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
 	} else if err != nil {
@@ -1387,6 +1398,7 @@ func (p *PostgresStorage) GetLastVerifiedBatch(ctx context.Context, dbTx pgx.Tx)
 	}
 	verifiedBatch.Aggregator = common.HexToAddress(agg)
 	verifiedBatch.TxHash = common.HexToHash(txHash)
+	verifiedBatch.StateRoot = common.HexToHash(sr)
 	return &verifiedBatch, nil
 }
 
@@ -1761,8 +1773,8 @@ func (p *PostgresStorage) GetSequences(ctx context.Context, lastVerifiedBatchNum
 }
 
 // GetVirtualBatchToProve return the next batch that is not proved, neither in
-// proved process.
-func (p *PostgresStorage) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (*Batch, error) {
+// proved process, skipping the excluded batch numbers.
+func (p *PostgresStorage) GetVirtualBatchToProve(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*Batch, error) {
 	const query = `
 		SELECT
 			b.batch_num,
@@ -1779,14 +1791,19 @@ func (p *PostgresStorage) GetVirtualBatchToProve(ctx context.Context, lastVerfie
 			state.virtual_batch v
 		WHERE
 			b.batch_num > $1 AND b.batch_num = v.batch_num AND
+			b.batch_num <> ALL($2) AND
 			NOT EXISTS (
 				SELECT p.batch_num FROM state.proof p 
 				WHERE v.batch_num >= p.batch_num AND v.batch_num <= p.batch_num_final
 			)
 		ORDER BY b.batch_num ASC LIMIT 1
 		`
+	if excludedBatchNumbers == nil {
+		// a NULL array would exclude every batch
+		excludedBatchNumbers = []uint64{}
+	}
 	e := p.getExecQuerier(dbTx)
-	row := e.QueryRow(ctx, query, lastVerfiedBatchNumber)
+	row := e.QueryRow(ctx, query, lastVerfiedBatchNumber, excludedBatchNumbers)
 	batch, err := scanBatch(row)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
@@ -1811,28 +1828,69 @@ func (p *PostgresStorage) CheckProofContainsCompleteSequences(ctx context.Contex
 	return exists, nil
 }
 
-// GetProofReadyToVerify return the proof that is ready to verify
-func (p *PostgresStorage) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (*Proof, error) {
-	const getProofReadyToVerifySQL = `
+// CheckBatchesInSameSequence checks if a range of batches belongs to a single
+// sequence
+func (p *PostgresStorage) CheckBatchesInSameSequence(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error) {
+	const checkBatchesInSameSequenceSQL = `
+		SELECT EXISTS (SELECT 1 FROM state.sequences WHERE from_batch_num <= $1 AND to_batch_num >= $2)
+		`
+	e := p.getExecQuerier(dbTx)
+	var exists bool
+	err := e.QueryRow(ctx, checkBatchesInSameSequenceSQL, batchNumber, batchNumberFinal).Scan(&exists)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return exists, err
+	}
+	return exists, nil
+}
+
+// CheckProofPendingVerification checks if a proof is stored and hasn't been
+// verified yet
+func (p *PostgresStorage) CheckProofPendingVerification(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (bool, error) {
+	const checkProofPendingVerificationSQL = `
+		SELECT EXISTS (SELECT 1 FROM state.proof WHERE batch_num = $1 AND batch_num_final = $2 AND verified = FALSE)
+		`
+	e := p.getExecQuerier(dbTx)
+	var pending bool
+	err := e.QueryRow(ctx, checkProofPendingVerificationSQL, batchNumber, batchNumberFinal).Scan(&pending)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return pending, err
+	}
+	return pending, nil
+}
+
+// GetProofReadyToVerify return the proof that is ready to verify, starting
+// right after the last verified batch and containing complete sequences. If
+// several are, the one with the largest or smallest final batch number is
+// returned according to the preference, the largest by default.
+func (p *PostgresStorage) GetProofReadyToVerify(ctx context.Context, lastVerfiedBatchNumber uint64, preference ProofPreference, dbTx pgx.Tx) (*Proof, error) {
+	order := "DESC"
+	if preference == ProofPreferenceSmallest {
+		order = "ASC"
+	}
+	getProofReadyToVerifySQL := `
 		SELECT 
 			p.batch_num, 
 			p.batch_num_final,
-			p.proof,
+			d.proof,
 			p.proof_id,
-			p.input_prover,
+			d.input_prover,
 			p.prover,
-			p.generating
-		FROM state.proof p
-		WHERE batch_num = $1 AND generating = FALSE AND
+			p.generating,
+			p.commitment,
+			p.depth
+		FROM state.proof p INNER JOIN state.proof_data d ON p.batch_num = d.batch_num AND p.batch_num_final = d.batch_num_final
+		WHERE p.batch_num = $1 AND p.generating = FALSE AND p.verified = FALSE AND
 			EXISTS (SELECT 1 FROM state.sequences s1 WHERE s1.from_batch_num = p.batch_num) AND
-			EXISTS (SELECT 1 FROM state.sequences s2 WHERE s2.to_batch_num = p.batch_num_final)		
+			EXISTS (SELECT 1 FROM state.sequences s2 WHERE s2.to_batch_num = p.batch_num_final)
+		ORDER BY p.batch_num_final ` + order + `
+		LIMIT 1
 		`
 
 	var proof *Proof = &Proof{}
 
 	e := p.getExecQuerier(dbTx)
 	row := e.QueryRow(ctx, getProofReadyToVerifySQL, lastVerfiedBatchNumber+1)
-	err := row.Scan(&proof.BatchNumber, &proof.BatchNumberFinal, &proof.Proof, &proof.ProofID, &proof.InputProver, &proof.Prover, &proof.Generating)
+	err := row.Scan(&proof.BatchNumber, &proof.BatchNumberFinal, &proof.Proof, &proof.ProofID, &proof.InputProver, &proof.Prover, &proof.Generating, &proof.Commitment, &proof.Depth)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNotFound
@@ -1843,8 +1901,12 @@ func (p *PostgresStorage) GetProofReadyToVerify(ctx context.Context, lastVerfied
 	return proof, err
 }
 
-// GetProofsToAggregate return the next to proof that it is possible to aggregate
-func (p *PostgresStorage) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx) (*Proof, *Proof, error) {
+// GetProofsToAggregate return the next to proof that it is possible to aggregate.
+// The proofs already at the max aggregation depth are not returned, unless
+// they don't contain complete sequences, as they could never be verified
+// otherwise, nor the ones whose aggregation would span more than the max
+// number of batches, 0 means no limit for both.
+func (p *PostgresStorage) GetProofsToAggregate(ctx context.Context, maxDepth, maxBatches uint64, dbTx pgx.Tx) (*Proof, *Proof, error) {
 	var (
 		proof1 *Proof = &Proof{}
 		proof2 *Proof = &Proof{}
@@ -1855,19 +1917,35 @@ func (p *PostgresStorage) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx)
 		SELECT 
 			p1.batch_num as p1_batch_num, 
 			p1.batch_num_final as p1_batch_num_final, 
-			p1.proof as p1_proof,	
+			d1.proof as p1_proof,	
 			p1.proof_id as p1_proof_id, 
-			p1.input_prover as p1_input_prover, 
+			d1.input_prover as p1_input_prover, 
 			p1.prover as p1_prover,
+			p1.commitment as p1_commitment,
+			p1.depth as p1_depth,
 			p2.batch_num as p2_batch_num, 
 			p2.batch_num_final as p2_batch_num_final, 
-			p2.proof as p2_proof,	
+			d2.proof as p2_proof,	
 			p2.proof_id as p2_proof_id, 
-			p2.input_prover as p2_input_prover, 
-			p2.prover as p2_prover
+			d2.input_prover as p2_input_prover, 
+			p2.prover as p2_prover,
+			p2.commitment as p2_commitment,
+			p2.depth as p2_depth
 		FROM state.proof p1 INNER JOIN state.proof p2 ON p1.batch_num_final = p2.batch_num - 1
+			INNER JOIN state.proof_data d1 ON p1.batch_num = d1.batch_num AND p1.batch_num_final = d1.batch_num_final
+			INNER JOIN state.proof_data d2 ON p2.batch_num = d2.batch_num AND p2.batch_num_final = d2.batch_num_final
 		WHERE p1.generating = FALSE AND p2.generating = FALSE AND 
-		 	  p1.proof IS NOT NULL AND p2.proof IS NOT NULL AND
+			  p1.verified = FALSE AND p2.verified = FALSE AND
+			  ($1 = 0 OR (
+					(p1.depth < $1 OR NOT (
+						EXISTS ( SELECT 1 FROM state.sequences s WHERE p1.batch_num = s.from_batch_num) AND
+						EXISTS ( SELECT 1 FROM state.sequences s WHERE p1.batch_num_final = s.to_batch_num))) AND
+					(p2.depth < $1 OR NOT (
+						EXISTS ( SELECT 1 FROM state.sequences s WHERE p2.batch_num = s.from_batch_num) AND
+						EXISTS ( SELECT 1 FROM state.sequences s WHERE p2.batch_num_final = s.to_batch_num)))
+				)) AND
+			  ($2 = 0 OR p2.batch_num_final - p1.batch_num + 1 <= $2) AND
+		 	  d1.proof IS NOT NULL AND d2.proof IS NOT NULL AND
 			  (
 					EXISTS (
 					SELECT 1 FROM state.sequences s
@@ -1889,10 +1967,10 @@ func (p *PostgresStorage) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx)
 		`
 
 	e := p.getExecQuerier(dbTx)
-	row := e.QueryRow(ctx, getProofsToAggregateSQL)
+	row := e.QueryRow(ctx, getProofsToAggregateSQL, maxDepth, maxBatches)
 	err := row.Scan(
-		&proof1.BatchNumber, &proof1.BatchNumberFinal, &proof1.Proof, &proof1.ProofID, &proof1.InputProver, &proof1.Prover,
-		&proof2.BatchNumber, &proof2.BatchNumberFinal, &proof2.Proof, &proof2.ProofID, &proof2.InputProver, &proof2.Prover)
+		&proof1.BatchNumber, &proof1.BatchNumberFinal, &proof1.Proof, &proof1.ProofID, &proof1.InputProver, &proof1.Prover, &proof1.Commitment, &proof1.Depth,
+		&proof2.BatchNumber, &proof2.BatchNumberFinal, &proof2.Proof, &proof2.ProofID, &proof2.InputProver, &proof2.Prover, &proof2.Commitment, &proof2.Depth)
 
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil, ErrNotFound
@@ -1903,19 +1981,34 @@ func (p *PostgresStorage) GetProofsToAggregate(ctx context.Context, dbTx pgx.Tx)
 	return proof1, proof2, err
 }
 
-// AddGeneratedProof adds a generated proof to the storage
+// AddGeneratedProof adds a generated proof to the storage. The proof and the
+// input prover are stored apart from the metadata of the proof.
 func (p *PostgresStorage) AddGeneratedProof(ctx context.Context, proof *Proof, dbTx pgx.Tx) error {
-	const addGeneratedProofSQL = "INSERT INTO state.proof (batch_num, batch_num_final, proof, proof_id, input_prover, prover, generating) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	const addGeneratedProofSQL = `
+		WITH p AS (
+			INSERT INTO state.proof (batch_num, batch_num_final, proof_id, prover, generating, commitment, depth) VALUES ($1, $2, $4, $6, $7, $8, $9)
+		)
+		INSERT INTO state.proof_data (batch_num, batch_num_final, proof, input_prover) VALUES ($1, $2, $3, $5)
+		`
 	e := p.getExecQuerier(dbTx)
-	_, err := e.Exec(ctx, addGeneratedProofSQL, proof.BatchNumber, proof.BatchNumberFinal, proof.Proof, proof.ProofID, proof.InputProver, proof.Prover, proof.Generating)
+	_, err := e.Exec(ctx, addGeneratedProofSQL, proof.BatchNumber, proof.BatchNumberFinal, proof.Proof, proof.ProofID, proof.InputProver, proof.Prover, proof.Generating, proof.Commitment, proof.Depth)
 	return err
 }
 
-// UpdateGeneratedProof updates a generated proof in the storage
+// UpdateGeneratedProof updates a generated proof in the storage. The proof and
+// the input prover are only rewritten if they changed, so locking and
+// unlocking a proof doesn't rewrite them.
 func (p *PostgresStorage) UpdateGeneratedProof(ctx context.Context, proof *Proof, dbTx pgx.Tx) error {
-	const addGeneratedProofSQL = "UPDATE state.proof SET proof = $3, proof_id = $4, input_prover = $5, prover = $6, generating = $7 WHERE batch_num = $1 AND batch_num_final = $2"
+	const updateGeneratedProofSQL = `
+		WITH d AS (
+			UPDATE state.proof_data SET proof = $3, input_prover = $5
+			WHERE batch_num = $1 AND batch_num_final = $2 AND
+				(proof IS DISTINCT FROM $3 OR input_prover IS DISTINCT FROM $5)
+		)
+		UPDATE state.proof SET proof_id = $4, prover = $6, generating = $7, commitment = $8, depth = $9 WHERE batch_num = $1 AND batch_num_final = $2
+		`
 	e := p.getExecQuerier(dbTx)
-	_, err := e.Exec(ctx, addGeneratedProofSQL, proof.BatchNumber, proof.BatchNumberFinal, proof.Proof, proof.ProofID, proof.InputProver, proof.Prover, proof.Generating)
+	_, err := e.Exec(ctx, updateGeneratedProofSQL, proof.BatchNumber, proof.BatchNumberFinal, proof.Proof, proof.ProofID, proof.InputProver, proof.Prover, proof.Generating, proof.Commitment, proof.Depth)
 	return err
 }
 
@@ -1928,6 +2021,53 @@ func (p *PostgresStorage) DeleteGeneratedProofs(ctx context.Context, batchNumber
 	return err
 }
 
+// MarkProofVerified marks the proof as verified on L1 and releases it. A
+// verified proof is not verified nor aggregated again, it's kept until deleted
+// once the state is synced with the verification.
+func (p *PostgresStorage) MarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	const markProofVerifiedSQL = "UPDATE state.proof SET verified = TRUE, generating = FALSE WHERE batch_num = $1 AND batch_num_final = $2"
+	e := p.getExecQuerier(dbTx)
+	_, err := e.Exec(ctx, markProofVerifiedSQL, batchNumber, batchNumberFinal)
+	return err
+}
+
+// UnmarkProofVerified marks back the proof as pending verification, so it's
+// verified again once its verification on L1 is reorged out.
+func (p *PostgresStorage) UnmarkProofVerified(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) error {
+	const unmarkProofVerifiedSQL = "UPDATE state.proof SET verified = FALSE WHERE batch_num = $1 AND batch_num_final = $2"
+	e := p.getExecQuerier(dbTx)
+	_, err := e.Exec(ctx, unmarkProofVerifiedSQL, batchNumber, batchNumberFinal)
+	return err
+}
+
+// GetVerifiedProofs returns the metadata of the proofs verified on L1 that
+// haven't been deleted yet.
+func (p *PostgresStorage) GetVerifiedProofs(ctx context.Context, dbTx pgx.Tx) ([]*Proof, error) {
+	const getVerifiedProofsSQL = `
+		SELECT batch_num, batch_num_final, proof_id, prover
+		FROM state.proof
+		WHERE verified = TRUE
+		ORDER BY batch_num ASC
+		`
+	e := p.getExecQuerier(dbTx)
+	rows, err := e.Query(ctx, getVerifiedProofsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	proofs := make([]*Proof, 0)
+	for rows.Next() {
+		proof := &Proof{}
+		err := rows.Scan(&proof.BatchNumber, &proof.BatchNumberFinal, &proof.ProofID, &proof.Prover)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, proof)
+	}
+	return proofs, rows.Err()
+}
+
 // DeleteUngeneratedProofs deletes ungenerated proofs.
 // This method is meant to be use during aggregator boot-up sequence
 func (p *PostgresStorage) DeleteUngeneratedProofs(ctx context.Context, dbTx pgx.Tx) error {
@@ -1937,6 +2077,222 @@ func (p *PostgresStorage) DeleteUngeneratedProofs(ctx context.Context, dbTx pgx.
 	return err
 }
 
+// RecoverGeneratingProofs unlocks the proofs left generating that have their
+// proof stored, and deletes the ones that never produced it. It returns the
+// number of proofs recovered and deleted.
+// This method is meant to be use during aggregator boot-up sequence
+func (p *PostgresStorage) RecoverGeneratingProofs(ctx context.Context, dbTx pgx.Tx) (uint64, uint64, error) {
+	const recoverGeneratingProofsSQL = `
+		WITH recovered AS (
+			UPDATE state.proof p SET generating = FALSE
+			FROM state.proof_data d
+			WHERE p.batch_num = d.batch_num AND p.batch_num_final = d.batch_num_final AND
+				p.generating IS TRUE AND d.proof IS NOT NULL AND d.proof <> ''
+			RETURNING p.batch_num
+		), deleted AS (
+			DELETE FROM state.proof p
+			WHERE p.generating IS TRUE AND NOT EXISTS (
+				SELECT 1 FROM state.proof_data d
+				WHERE d.batch_num = p.batch_num AND d.batch_num_final = p.batch_num_final AND
+					d.proof IS NOT NULL AND d.proof <> ''
+			)
+			RETURNING p.batch_num
+		)
+		SELECT (SELECT COUNT(*) FROM recovered), (SELECT COUNT(*) FROM deleted)
+		`
+	var recovered, deleted uint64
+	e := p.getExecQuerier(dbTx)
+	err := e.QueryRow(ctx, recoverGeneratingProofsSQL).Scan(&recovered, &deleted)
+	if err != nil {
+		return 0, 0, err
+	}
+	return recovered, deleted, nil
+}
+
+// GetLastVerifyProofTime returns the time the aggregator last reset the
+// interval to verify a proof, ErrNotFound if it was never reset.
+func (p *PostgresStorage) GetLastVerifyProofTime(ctx context.Context, dbTx pgx.Tx) (time.Time, error) {
+	const getLastVerifyProofTimeSQL = "SELECT last_verify_proof_time FROM state.aggregator_info LIMIT 1"
+	var lastVerifyProofTime *time.Time
+	e := p.getExecQuerier(dbTx)
+	err := e.QueryRow(ctx, getLastVerifyProofTimeSQL).Scan(&lastVerifyProofTime)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && lastVerifyProofTime == nil) {
+		return time.Time{}, ErrNotFound
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return *lastVerifyProofTime, nil
+}
+
+// SetLastVerifyProofTime stores the time the aggregator last reset the
+// interval to verify a proof.
+func (p *PostgresStorage) SetLastVerifyProofTime(ctx context.Context, lastVerifyProofTime time.Time, dbTx pgx.Tx) error {
+	const setLastVerifyProofTimeSQL = "UPDATE state.aggregator_info SET last_verify_proof_time = $1"
+	e := p.getExecQuerier(dbTx)
+	_, err := e.Exec(ctx, setLastVerifyProofTimeSQL, lastVerifyProofTime)
+	return err
+}
+
+// GetNextNonce returns the next nonce to hand out for the txs sent by the
+// account, ErrNotFound if none was handed out yet.
+func (p *PostgresStorage) GetNextNonce(ctx context.Context, from common.Address, dbTx pgx.Tx) (uint64, error) {
+	const getNextNonceSQL = "SELECT next_nonce FROM state.account_nonce WHERE address = $1"
+	var nextNonce uint64
+	e := p.getExecQuerier(dbTx)
+	err := e.QueryRow(ctx, getNextNonceSQL, from.String()).Scan(&nextNonce)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	return nextNonce, nil
+}
+
+// SetNextNonce stores the next nonce to hand out for the txs sent by the
+// account.
+func (p *PostgresStorage) SetNextNonce(ctx context.Context, from common.Address, nextNonce uint64, dbTx pgx.Tx) error {
+	const setNextNonceSQL = `
+		INSERT INTO state.account_nonce (address, next_nonce) VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET next_nonce = EXCLUDED.next_nonce
+		`
+	e := p.getExecQuerier(dbTx)
+	_, err := e.Exec(ctx, setNextNonceSQL, from.String(), nextNonce)
+	return err
+}
+
+// AddProofRegenerations queues the existing batches in the batch numbers range
+// for their proofs to be regenerated. It returns the number of batches queued,
+// the ones already queued are not counted.
+func (p *PostgresStorage) AddProofRegenerations(ctx context.Context, batchNumber uint64, batchNumberFinal uint64, dbTx pgx.Tx) (uint64, error) {
+	const addProofRegenerationsSQL = `
+		INSERT INTO state.proof_regeneration (batch_num)
+		SELECT batch_num FROM state.batch WHERE batch_num >= $1 AND batch_num <= $2
+		ON CONFLICT DO NOTHING
+		`
+	e := p.getExecQuerier(dbTx)
+	res, err := e.Exec(ctx, addProofRegenerationsSQL, batchNumber, batchNumberFinal)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(res.RowsAffected()), nil
+}
+
+// GetBatchToRegenerate returns the lowest queued batch above the last verified
+// batch whose proof must be regenerated, skipping the excluded batches.
+func (p *PostgresStorage) GetBatchToRegenerate(ctx context.Context, lastVerfiedBatchNumber uint64, excludedBatchNumbers []uint64, dbTx pgx.Tx) (*Batch, error) {
+	const query = `
+		SELECT
+			b.batch_num,
+			b.global_exit_root,
+			b.local_exit_root,
+			b.acc_input_hash,
+			b.state_root,
+			b.timestamp,
+			b.coinbase,
+			b.raw_txs_data,
+			b.forced_batch_num
+		FROM
+			state.batch b,
+			state.proof_regeneration r
+		WHERE
+			b.batch_num > $1 AND b.batch_num = r.batch_num AND
+			b.batch_num <> ALL($2)
+		ORDER BY b.batch_num ASC LIMIT 1
+		`
+	if excludedBatchNumbers == nil {
+		// a NULL array would exclude every batch
+		excludedBatchNumbers = []uint64{}
+	}
+	e := p.getExecQuerier(dbTx)
+	row := e.QueryRow(ctx, query, lastVerfiedBatchNumber, excludedBatchNumbers)
+	batch, err := scanBatch(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// CountProofRegenerations returns the number of queued batches above the last
+// verified batch whose proofs must be regenerated.
+func (p *PostgresStorage) CountProofRegenerations(ctx context.Context, lastVerfiedBatchNumber uint64, dbTx pgx.Tx) (uint64, error) {
+	const countProofRegenerationsSQL = "SELECT COUNT(*) FROM state.proof_regeneration WHERE batch_num > $1"
+	var count uint64
+	e := p.getExecQuerier(dbTx)
+	err := e.QueryRow(ctx, countProofRegenerationsSQL, lastVerfiedBatchNumber).Scan(&count)
+	return count, err
+}
+
+// ReplaceRegeneratedProof stores the regenerated proof replacing the proofs
+// overlapping its batch numbers range, and removes the range from the
+// regeneration queue. It fails with
+// ErrProofGenerating, leaving the storage untouched, if any of the
+// overlapping proofs is locked. The dbTx is required so the proofs are
+// replaced atomically.
+func (p *PostgresStorage) ReplaceRegeneratedProof(ctx context.Context, proof *Proof, dbTx pgx.Tx) error {
+	if dbTx == nil {
+		return ErrDBTxNil
+	}
+	const checkGeneratingSQL = `
+		SELECT EXISTS (
+			SELECT 1 FROM state.proof
+			WHERE batch_num <= $2 AND batch_num_final >= $1 AND generating IS TRUE
+		)
+		`
+	var generating bool
+	err := dbTx.QueryRow(ctx, checkGeneratingSQL, proof.BatchNumber, proof.BatchNumberFinal).Scan(&generating)
+	if err != nil {
+		return err
+	}
+	if generating {
+		return ErrProofGenerating
+	}
+
+	const deleteOverlappingProofsSQL = "DELETE FROM state.proof WHERE batch_num <= $2 AND batch_num_final >= $1"
+	if _, err := dbTx.Exec(ctx, deleteOverlappingProofsSQL, proof.BatchNumber, proof.BatchNumberFinal); err != nil {
+		return err
+	}
+	if err := p.AddGeneratedProof(ctx, proof, dbTx); err != nil {
+		return err
+	}
+	const deleteProofRegenerationsSQL = "DELETE FROM state.proof_regeneration WHERE batch_num >= $1 AND batch_num <= $2"
+	_, err = dbTx.Exec(ctx, deleteProofRegenerationsSQL, proof.BatchNumber, proof.BatchNumberFinal)
+	return err
+}
+
+// AddProofVerification stores the final proof verification sent to L1,
+// replacing the one stored for the same tx.
+func (p *PostgresStorage) AddProofVerification(ctx context.Context, verification *ProofVerification, dbTx pgx.Tx) error {
+	const addProofVerificationSQL = `
+		INSERT INTO state.proof_verification (tx_hash, batch_num, batch_num_final, proof_id) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tx_hash) DO UPDATE SET
+			batch_num = EXCLUDED.batch_num, batch_num_final = EXCLUDED.batch_num_final, proof_id = EXCLUDED.proof_id
+		`
+	e := p.getExecQuerier(dbTx)
+	_, err := e.Exec(ctx, addProofVerificationSQL, verification.TxHash.String(), verification.BatchNumber, verification.BatchNumberFinal, verification.ProofID)
+	return err
+}
+
+// GetProofVerification returns the final proof verification sent to L1 in
+// the given tx, ErrNotFound if there is none.
+func (p *PostgresStorage) GetProofVerification(ctx context.Context, txHash common.Hash, dbTx pgx.Tx) (*ProofVerification, error) {
+	const getProofVerificationSQL = "SELECT batch_num, batch_num_final, proof_id FROM state.proof_verification WHERE tx_hash = $1"
+	verification := &ProofVerification{TxHash: txHash}
+	var proofID *string
+	e := p.getExecQuerier(dbTx)
+	err := e.QueryRow(ctx, getProofVerificationSQL, txHash.String()).Scan(&verification.BatchNumber, &verification.BatchNumberFinal, &proofID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if proofID != nil {
+		verification.ProofID = *proofID
+	}
+	return verification, nil
+}
+
 // AddDebugInfo is used to store debug info useful during runtime
 func (p *PostgresStorage) AddDebugInfo(ctx context.Context, info *DebugInfo, dbTx pgx.Tx) error {
 	const insertDebugInfoSQL = "INSERT INTO state.debug (error_type, timestamp, payload) VALUES ($1, $2, $3)"
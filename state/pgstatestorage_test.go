@@ -2,8 +2,11 @@ package state_test
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"math/big"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -247,3 +250,506 @@ func TestVerifiedBatch(t *testing.T) {
 
 	require.NoError(t, dbTx.Commit(ctx))
 }
+
+func TestGetVirtualBatchToProveExcluded(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	block := &state.Block{BlockNumber: 1, ReceivedAt: time.Now()}
+	require.NoError(t, testState.AddBlock(ctx, block, dbTx))
+	_, err = dbTx.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1), (2), (3)")
+	require.NoError(t, err)
+	for batchNumber := uint64(1); batchNumber <= 3; batchNumber++ {
+		require.NoError(t, testState.AddVirtualBatch(ctx, &state.VirtualBatch{BlockNumber: 1, BatchNumber: batchNumber}, dbTx))
+	}
+
+	batch, err := testState.GetVirtualBatchToProve(ctx, 0, nil, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), batch.BatchNumber)
+
+	// the batches being proven are skipped
+	batch, err = testState.GetVirtualBatchToProve(ctx, 0, []uint64{1, 2}, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), batch.BatchNumber)
+
+	_, err = testState.GetVirtualBatchToProve(ctx, 0, []uint64{1, 2, 3}, dbTx)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestGetProofsToAggregateMaxDepth(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	_, err = testState.PostgresStorage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1), (2), (3)")
+	require.NoError(t, err)
+	require.NoError(t, testState.AddSequence(ctx, state.Sequence{FromBatchNumber: 1, ToBatchNumber: 2}, dbTx))
+	require.NoError(t, testState.AddSequence(ctx, state.Sequence{FromBatchNumber: 3, ToBatchNumber: 3}, dbTx))
+
+	deepProof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 2, Proof: "proof1", Depth: 2}
+	require.NoError(t, testState.AddGeneratedProof(ctx, deepProof, dbTx))
+	require.NoError(t, testState.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 3, Proof: "proof2"}, dbTx))
+
+	proof1, proof2, err := testState.GetProofsToAggregate(ctx, 0, 0, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), proof1.Depth)
+	assert.Equal(t, uint64(3), proof2.BatchNumber)
+
+	// the proof at the max depth is not aggregated, it's left to be verified
+	_, _, err = testState.GetProofsToAggregate(ctx, 2, 0, dbTx)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	proof, err := testState.GetProofReadyToVerify(ctx, 0, state.ProofPreferenceLargest, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), proof.BatchNumber)
+	assert.Equal(t, uint64(2), proof.Depth)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestGetProofsToAggregateMaxDepthWithinSequence(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	_, err = testState.PostgresStorage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1), (2), (3)")
+	require.NoError(t, err)
+	require.NoError(t, testState.AddSequence(ctx, state.Sequence{FromBatchNumber: 1, ToBatchNumber: 3}, dbTx))
+
+	deepProof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 2, Proof: "proof1", Depth: 2}
+	require.NoError(t, testState.AddGeneratedProof(ctx, deepProof, dbTx))
+	require.NoError(t, testState.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 3, Proof: "proof2"}, dbTx))
+
+	// the proof at the max depth doesn't end on a sequence boundary, so it's
+	// still aggregated to complete the sequence
+	proof1, proof2, err := testState.GetProofsToAggregate(ctx, 2, 0, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), proof1.Depth)
+	assert.Equal(t, uint64(3), proof2.BatchNumber)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestVerifiedProofs(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	_, err = testState.PostgresStorage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1), (2)")
+	require.NoError(t, err)
+
+	proofID := "proofID"
+	prover := "prover"
+	proof := &state.Proof{
+		BatchNumber:      1,
+		BatchNumberFinal: 2,
+		Proof:            "proof",
+		ProofID:          &proofID,
+		InputProver:      "inputProver",
+		Prover:           &prover,
+		Generating:       true,
+	}
+	err = testState.AddGeneratedProof(ctx, proof, dbTx)
+	require.NoError(t, err)
+
+	proofs, err := testState.GetVerifiedProofs(ctx, dbTx)
+	require.NoError(t, err)
+	assert.Empty(t, proofs)
+
+	pending, err := testState.CheckProofPendingVerification(ctx, 1, 2, dbTx)
+	require.NoError(t, err)
+	assert.True(t, pending)
+
+	err = testState.MarkProofVerified(ctx, 1, 2, dbTx)
+	require.NoError(t, err)
+
+	pending, err = testState.CheckProofPendingVerification(ctx, 1, 2, dbTx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+
+	// verified proofs are released and kept on boot-up
+	_, _, err = testState.RecoverGeneratingProofs(ctx, dbTx)
+	require.NoError(t, err)
+
+	proofs, err = testState.GetVerifiedProofs(ctx, dbTx)
+	require.NoError(t, err)
+	require.Len(t, proofs, 1)
+	assert.Equal(t, uint64(1), proofs[0].BatchNumber)
+	assert.Equal(t, uint64(2), proofs[0].BatchNumberFinal)
+	assert.Equal(t, proofID, *proofs[0].ProofID)
+	assert.False(t, proofs[0].Generating)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestRecoverGeneratingProofs(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	_, err = testState.PostgresStorage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1), (2), (3), (4)")
+	require.NoError(t, err)
+
+	// the aggregator crashed with a proof locked once generated, a proof
+	// still being generated and a proof not locked
+	proofID := "proofID"
+	prover := "prover"
+	proofs := []*state.Proof{
+		{BatchNumber: 1, BatchNumberFinal: 2, Proof: "proof12", ProofID: &proofID, InputProver: "input12", Prover: &prover, Generating: true},
+		{BatchNumber: 3, BatchNumberFinal: 3, ProofID: &proofID, InputProver: "input3", Prover: &prover, Generating: true},
+		{BatchNumber: 4, BatchNumberFinal: 4, Proof: "proof4", InputProver: "input4"},
+	}
+	for _, proof := range proofs {
+		require.NoError(t, testState.AddGeneratedProof(ctx, proof, dbTx))
+	}
+
+	recovered, deleted, err := testState.RecoverGeneratingProofs(ctx, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), recovered)
+	assert.Equal(t, uint64(1), deleted)
+
+	// the generated proof is kept with its content, unlocked
+	const getProofSQL = `SELECT p.generating, d.proof FROM state.proof p INNER JOIN state.proof_data d
+		ON p.batch_num = d.batch_num AND p.batch_num_final = d.batch_num_final
+		WHERE p.batch_num = $1 AND p.batch_num_final = $2`
+	var generating bool
+	var storedProof string
+	require.NoError(t, dbTx.QueryRow(ctx, getProofSQL, 1, 2).Scan(&generating, &storedProof))
+	assert.False(t, generating)
+	assert.Equal(t, "proof12", storedProof)
+	require.NoError(t, dbTx.QueryRow(ctx, getProofSQL, 4, 4).Scan(&generating, &storedProof))
+	assert.False(t, generating)
+	assert.Equal(t, "proof4", storedProof)
+
+	// the ungenerated one is deleted along with its data
+	var count int
+	require.NoError(t, dbTx.QueryRow(ctx, "SELECT COUNT(*) FROM state.proof_data WHERE batch_num = 3").Scan(&count))
+	assert.Equal(t, 0, count)
+	pending, err := testState.CheckProofPendingVerification(ctx, 3, 3, dbTx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+
+	// nothing left to recover
+	recovered, deleted, err = testState.RecoverGeneratingProofs(ctx, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), recovered)
+	assert.Equal(t, uint64(0), deleted)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestLastVerifyProofTime(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	_, err = testState.GetLastVerifyProofTime(ctx, dbTx)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	lastVerifyProofTime := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, testState.SetLastVerifyProofTime(ctx, lastVerifyProofTime, dbTx))
+	got, err := testState.GetLastVerifyProofTime(ctx, dbTx)
+	require.NoError(t, err)
+	assert.True(t, lastVerifyProofTime.Equal(got))
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestNextNonce(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	from := common.HexToAddress("0x1")
+	_, err = testState.GetNextNonce(ctx, from, dbTx)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	require.NoError(t, testState.SetNextNonce(ctx, from, 5, dbTx))
+	require.NoError(t, testState.SetNextNonce(ctx, from, 6, dbTx))
+	nextNonce, err := testState.GetNextNonce(ctx, from, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), nextNonce)
+
+	// the nonces of each account are tracked apart
+	_, err = testState.GetNextNonce(ctx, common.HexToAddress("0x2"), dbTx)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestProofRegenerations(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	_, err = testState.PostgresStorage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1), (2), (3), (4)")
+	require.NoError(t, err)
+
+	// only the existing batches are queued, once
+	queued, err := testState.AddProofRegenerations(ctx, 2, 6, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), queued)
+	queued, err = testState.AddProofRegenerations(ctx, 1, 3, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), queued)
+	count, err := testState.CountProofRegenerations(ctx, 1, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), count)
+
+	batch, err := testState.GetBatchToRegenerate(ctx, 1, []uint64{2}, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), batch.BatchNumber)
+
+	// the regenerated proof replaces the overlapping ones
+	require.NoError(t, testState.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 2, BatchNumberFinal: 3, Proof: "proof23"}, dbTx))
+	require.NoError(t, testState.AddGeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4, Generating: true}, dbTx))
+	require.NoError(t, testState.ReplaceRegeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 3, Proof: "proof3"}, dbTx))
+	pending, err := testState.CheckProofPendingVerification(ctx, 2, 3, dbTx)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	pending, err = testState.CheckProofPendingVerification(ctx, 3, 3, dbTx)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	count, err = testState.CountProofRegenerations(ctx, 1, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), count)
+
+	// the batch is kept queued while an overlapping proof is locked
+	err = testState.ReplaceRegeneratedProof(ctx, &state.Proof{BatchNumber: 4, BatchNumberFinal: 4, Proof: "proof4"}, dbTx)
+	require.ErrorIs(t, err, state.ErrProofGenerating)
+	batch, err = testState.GetBatchToRegenerate(ctx, 1, []uint64{2}, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), batch.BatchNumber)
+
+	// the verified batches are not regenerated
+	_, err = testState.GetBatchToRegenerate(ctx, 4, nil, dbTx)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	// the proofs are only replaced atomically
+	require.ErrorIs(t, testState.ReplaceRegeneratedProof(ctx, &state.Proof{BatchNumber: 3, BatchNumberFinal: 3}, nil), state.ErrDBTxNil)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+func TestUpdateGeneratedProofDoesNotRewriteProofData(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	_, err = testState.PostgresStorage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1)")
+	require.NoError(t, err)
+
+	proofID := "proofID"
+	prover := "prover"
+	proof := &state.Proof{
+		BatchNumber:      1,
+		BatchNumberFinal: 1,
+		Proof:            "proof",
+		ProofID:          &proofID,
+		InputProver:      "inputProver",
+		Prover:           &prover,
+	}
+	err = testState.AddGeneratedProof(ctx, proof, dbTx)
+	require.NoError(t, err)
+
+	// ctid changes every time a new version of the row is written
+	const getProofDataCtidSQL = "SELECT ctid::text FROM state.proof_data WHERE batch_num = 1 AND batch_num_final = 1"
+	var ctid, newCtid string
+	require.NoError(t, dbTx.QueryRow(ctx, getProofDataCtidSQL).Scan(&ctid))
+
+	// lock the proof
+	proof.Generating = true
+	err = testState.UpdateGeneratedProof(ctx, proof, dbTx)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.QueryRow(ctx, getProofDataCtidSQL).Scan(&newCtid))
+	assert.Equal(t, ctid, newCtid)
+
+	// update the proof
+	proof.Proof = "newProof"
+	err = testState.UpdateGeneratedProof(ctx, proof, dbTx)
+	require.NoError(t, err)
+	require.NoError(t, dbTx.QueryRow(ctx, getProofDataCtidSQL).Scan(&newCtid))
+	assert.NotEqual(t, ctid, newCtid)
+
+	var generating bool
+	var storedProof string
+	err = dbTx.QueryRow(ctx, "SELECT p.generating, d.proof FROM state.proof p INNER JOIN state.proof_data d ON p.batch_num = d.batch_num AND p.batch_num_final = d.batch_num_final").Scan(&generating, &storedProof)
+	require.NoError(t, err)
+	assert.True(t, generating)
+	assert.Equal(t, "newProof", storedProof)
+
+	// set the commitment
+	commitment := "0x01"
+	proof.Commitment = &commitment
+	err = testState.UpdateGeneratedProof(ctx, proof, dbTx)
+	require.NoError(t, err)
+	var storedCommitment *string
+	require.NoError(t, dbTx.QueryRow(ctx, "SELECT commitment FROM state.proof WHERE batch_num = 1 AND batch_num_final = 1").Scan(&storedCommitment))
+	require.NotNil(t, storedCommitment)
+	assert.Equal(t, commitment, *storedCommitment)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
+
+// proofBlobSizes are the sizes of the proof blobs the write amplification is
+// measured with, below and above the size postgres moves out of the row.
+var proofBlobSizes = []int{1 << 10, 1 << 20}
+
+// walBytesSince returns the bytes written to the WAL since the lsn.
+func walBytesSince(ctx context.Context, b *testing.B, lsn string) int64 {
+	var written int64
+	require.NoError(b, stateDb.QueryRow(ctx, "SELECT pg_wal_lsn_diff(pg_current_wal_insert_lsn(), $1::pg_lsn)::BIGINT", lsn).Scan(&written))
+	return written
+}
+
+// BenchmarkUpdateGeneratedProofWriteAmplification reports the WAL bytes
+// written to lock a proof, with the proof blobs in state.proof_data and
+// inline with the metadata, as they were stored before.
+func BenchmarkUpdateGeneratedProofWriteAmplification(b *testing.B) {
+	ctx := context.Background()
+	const inlineProofSQL = `CREATE TABLE state.proof_inline
+(
+    batch_num  BIGINT NOT NULL,
+    batch_num_final BIGINT NOT NULL,
+    proof VARCHAR,
+    proof_id VARCHAR,
+    input_prover VARCHAR,
+    prover VARCHAR,
+    generating BOOLEAN DEFAULT FALSE,
+    PRIMARY KEY (batch_num, batch_num_final)
+)`
+
+	for _, size := range proofBlobSizes {
+		blob := strings.Repeat("f", size)
+
+		b.Run(fmt.Sprintf("split %d bytes", size), func(b *testing.B) {
+			initOrResetDB()
+			_, err := testState.PostgresStorage.Exec(ctx, "INSERT INTO state.batch (batch_num) VALUES (1)")
+			require.NoError(b, err)
+			proof := &state.Proof{BatchNumber: 1, BatchNumberFinal: 1, Proof: blob, InputProver: blob}
+			require.NoError(b, testState.AddGeneratedProof(ctx, proof, nil))
+
+			var lsn string
+			require.NoError(b, stateDb.QueryRow(ctx, "SELECT pg_current_wal_insert_lsn()::TEXT").Scan(&lsn))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				proof.Generating = !proof.Generating
+				require.NoError(b, testState.UpdateGeneratedProof(ctx, proof, nil))
+			}
+			b.ReportMetric(float64(walBytesSince(ctx, b, lsn))/float64(b.N), "WAL-bytes/op")
+		})
+
+		b.Run(fmt.Sprintf("inline %d bytes", size), func(b *testing.B) {
+			initOrResetDB()
+			_, err := stateDb.Exec(ctx, inlineProofSQL)
+			require.NoError(b, err)
+			_, err = stateDb.Exec(ctx, "INSERT INTO state.proof_inline (batch_num, batch_num_final, proof, input_prover) VALUES (1, 1, $1, $1)", blob)
+			require.NoError(b, err)
+
+			var lsn string
+			require.NoError(b, stateDb.QueryRow(ctx, "SELECT pg_current_wal_insert_lsn()::TEXT").Scan(&lsn))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := stateDb.Exec(ctx, "UPDATE state.proof_inline SET generating = NOT generating WHERE batch_num = 1 AND batch_num_final = 1")
+				require.NoError(b, err)
+			}
+			b.ReportMetric(float64(walBytesSince(ctx, b, lsn))/float64(b.N), "WAL-bytes/op")
+
+			b.StopTimer()
+			_, err = stateDb.Exec(ctx, "DROP TABLE state.proof_inline")
+			require.NoError(b, err)
+		})
+	}
+}
+
+// BenchmarkProofDataMigration times the up migration moving the proof blobs
+// to state.proof_data, on a copy of the state.proof table it migrates filled
+// with the number of proofs of each run, their proof and input prover being
+// 1MB each.
+func BenchmarkProofDataMigration(b *testing.B) {
+	ctx := context.Background()
+	migration, err := os.ReadFile("../db/migrations/state/0006.sql")
+	require.NoError(b, err)
+	up := strings.Split(strings.SplitN(string(migration), "-- +migrate Up", 2)[1], "-- +migrate Down")[0]
+	up = strings.ReplaceAll(up, "state.", "proof_migration.")
+
+	for _, proofs := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("%d proofs", proofs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				_, err := stateDb.Exec(ctx, `DROP SCHEMA IF EXISTS proof_migration CASCADE;
+CREATE SCHEMA proof_migration;
+CREATE TABLE proof_migration.proof
+(
+    batch_num  BIGINT NOT NULL,
+    batch_num_final BIGINT NOT NULL,
+    proof VARCHAR,
+    proof_id VARCHAR,
+    input_prover VARCHAR,
+    prover VARCHAR,
+    generating BOOLEAN DEFAULT FALSE,
+    PRIMARY KEY (batch_num, batch_num_final)
+)`)
+				require.NoError(b, err)
+				_, err = stateDb.Exec(ctx, `INSERT INTO proof_migration.proof (batch_num, batch_num_final, proof, input_prover)
+SELECT n, n, repeat(md5(n::TEXT), 32768), repeat(md5(n::TEXT), 32768) FROM generate_series(1, $1) n`, proofs)
+				require.NoError(b, err)
+				b.StartTimer()
+
+				_, err = stateDb.Exec(ctx, up)
+				require.NoError(b, err)
+			}
+			b.StopTimer()
+			_, err := stateDb.Exec(ctx, "DROP SCHEMA proof_migration CASCADE")
+			require.NoError(b, err)
+		})
+	}
+}
+
+func TestProofVerification(t *testing.T) {
+	initOrResetDB()
+
+	ctx := context.Background()
+	dbTx, err := testState.BeginStateTransaction(ctx)
+	require.NoError(t, err)
+
+	txHash := common.HexToHash("0x1")
+	_, err = testState.GetProofVerification(ctx, txHash, dbTx)
+	require.ErrorIs(t, err, state.ErrNotFound)
+
+	verification := &state.ProofVerification{TxHash: txHash, BatchNumber: 1, BatchNumberFinal: 5, ProofID: "proof"}
+	require.NoError(t, testState.AddProofVerification(ctx, verification, dbTx))
+	stored, err := testState.GetProofVerification(ctx, txHash, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, verification, stored)
+
+	// the verification sent again in the same tx replaces the stored one
+	verification.ProofID = "other proof"
+	require.NoError(t, testState.AddProofVerification(ctx, verification, dbTx))
+	stored, err = testState.GetProofVerification(ctx, txHash, dbTx)
+	require.NoError(t, err)
+	assert.Equal(t, "other proof", stored.ProofID)
+
+	require.NoError(t, dbTx.Commit(ctx))
+}
@@ -1,5 +1,22 @@
 package state
 
+import "github.com/ethereum/go-ethereum/common"
+
+// ProofPreference is the preference among the proofs ready to verify, all of
+// them starting right after the last verified batch and ending at the end of
+// a sequence, so only their final batch number differs.
+type ProofPreference string
+
+const (
+	// ProofPreferenceLargest prefers the proof with the largest final batch
+	// number, verifying as many batches as possible at once. It's the
+	// default
+	ProofPreferenceLargest ProofPreference = "largest"
+	// ProofPreferenceSmallest prefers the proof with the smallest final
+	// batch number, verifying the batches as soon as possible
+	ProofPreferenceSmallest ProofPreference = "smallest"
+)
+
 // Proof struct
 type Proof struct {
 	BatchNumber      uint64
@@ -9,4 +26,18 @@ type Proof struct {
 	ProofID          *string
 	Prover           *string
 	Generating       bool
+	// Commitment is the hash chain over the state roots of the batches
+	// covered by the proof, nil if it wasn't computed
+	Commitment *string
+	// Depth is the number of aggregation rounds folded into the proof, 0
+	// for a batch proof
+	Depth uint64
+}
+
+// ProofVerification is a final proof sent to L1 to verify a range of batches.
+type ProofVerification struct {
+	TxHash           common.Hash
+	BatchNumber      uint64
+	BatchNumberFinal uint64
+	ProofID          string
 }
@@ -1412,19 +1412,38 @@ func DetermineProcessedTransactions(responses []*ProcessTransactionResponse) (
 	return processedTxResponses, processedTxsHashes, unprocessedTxResponses, unprocessedTxsHashes
 }
 
-// WaitSequencingTxToBeSynced waits for a sequencing transaction to be synced into the state
-func (s *State) WaitSequencingTxToBeSynced(parentCtx context.Context, tx *types.Transaction, timeout time.Duration) error {
+// SyncProgress is the progress of the synchronizer observed while waiting for
+// a tx to be synced into the state.
+type SyncProgress struct {
+	// LastSyncedBatchNumber is the last batch synced, the last virtual batch
+	// when waiting for a sequencing tx and the last verified batch when
+	// waiting for a verified batch
+	LastSyncedBatchNumber uint64
+	// Elapsed is the time waited
+	Elapsed time.Duration
+}
+
+// WaitSequencingTxToBeSynced waits for a sequencing transaction to be synced
+// into the state. The progress of the synchronizer is returned along with the
+// error if the tx is not synced within the timeout.
+func (s *State) WaitSequencingTxToBeSynced(parentCtx context.Context, tx *types.Transaction, timeout time.Duration) (SyncProgress, error) {
 	ctx, cancel := context.WithTimeout(parentCtx, timeout)
 	defer cancel()
 
+	var progress SyncProgress
+	start := time.Now()
 	for {
 		virtualized, err := s.IsSequencingTXSynced(ctx, tx.Hash(), nil)
+		if lastBatchNum, err := s.GetLastVirtualBatchNum(ctx, nil); err == nil {
+			progress.LastSyncedBatchNumber = lastBatchNum
+		}
+		progress.Elapsed = time.Since(start)
 		if err != nil && err != ErrNotFound {
 			log.Errorf("error waiting sequencing tx %s to be synced: %w", tx.Hash().String(), err)
-			return err
+			return progress, err
 		} else if ctx.Err() != nil {
 			log.Errorf("error waiting sequencing tx %s to be synced: %w", tx.Hash().String(), err)
-			return ctx.Err()
+			return progress, ctx.Err()
 		} else if virtualized {
 			break
 		}
@@ -1433,22 +1452,30 @@ func (s *State) WaitSequencingTxToBeSynced(parentCtx context.Context, tx *types.
 	}
 
 	log.Debug("Sequencing txh successfully synced: ", tx.Hash().String())
-	return nil
+	return progress, nil
 }
 
-// WaitVerifiedBatchToBeSynced waits for a sequenced batch to be synced into the state
-func (s *State) WaitVerifiedBatchToBeSynced(parentCtx context.Context, batchNumber uint64, timeout time.Duration) error {
+// WaitVerifiedBatchToBeSynced waits for a sequenced batch to be synced into
+// the state. The progress of the synchronizer is returned along with the error
+// if the batch is not synced within the timeout.
+func (s *State) WaitVerifiedBatchToBeSynced(parentCtx context.Context, batchNumber uint64, timeout time.Duration) (SyncProgress, error) {
 	ctx, cancel := context.WithTimeout(parentCtx, timeout)
 	defer cancel()
 
+	var progress SyncProgress
+	start := time.Now()
 	for {
 		batch, err := s.GetVerifiedBatch(ctx, batchNumber, nil)
+		if lastBatch, err := s.GetLastVerifiedBatch(ctx, nil); err == nil {
+			progress.LastSyncedBatchNumber = lastBatch.BatchNumber
+		}
+		progress.Elapsed = time.Since(start)
 		if err != nil && err != ErrNotFound {
 			log.Errorf("error waiting verified batch %s to be synced: %w", batchNumber, err)
-			return err
+			return progress, err
 		} else if ctx.Err() != nil {
 			log.Errorf("error waiting verified batch %s to be synced: %w", batchNumber, err)
-			return ctx.Err()
+			return progress, ctx.Err()
 		} else if batch != nil {
 			break
 		}
@@ -1457,7 +1484,7 @@ func (s *State) WaitVerifiedBatchToBeSynced(parentCtx context.Context, batchNumb
 	}
 
 	log.Debug("Verified batch successfully synced: ", batchNumber)
-	return nil
+	return progress, nil
 }
 
 func (s *State) monitorNewL2Blocks() {
@@ -79,7 +79,10 @@ func TestMain(m *testing.M) {
 
 	mtDBServerConfig := merkletree.Config{URI: fmt.Sprintf("%s:50061", zkProverURI)}
 	var mtDBCancel context.CancelFunc
-	mtDBServiceClient, mtDBClientConn, mtDBCancel = merkletree.NewMTDBServiceClient(ctx, mtDBServerConfig)
+	mtDBServiceClient, mtDBClientConn, mtDBCancel, err = merkletree.NewMTDBServiceClient(ctx, mtDBServerConfig)
+	if err != nil {
+		panic(err)
+	}
 	s = mtDBClientConn.GetState()
 	log.Infof("stateDbClientConn state: %s", s.String())
 	defer func() {
@@ -2505,7 +2508,7 @@ func TestWaitSequencingTxToBeSyncedAndWaitVerifiedBatchToBeSynced(t *testing.T)
 		Data:     common.Hex2Bytes("0x00"),
 	})
 
-	err = testState.WaitSequencingTxToBeSynced(ctx, tx, 1*time.Second)
+	_, err = testState.WaitSequencingTxToBeSynced(ctx, tx, 1*time.Second)
 	require.Error(t, err)
 
 	processingContext := state.ProcessingContext{
@@ -2544,12 +2547,15 @@ func TestWaitSequencingTxToBeSyncedAndWaitVerifiedBatchToBeSynced(t *testing.T)
 	require.NoError(t, err)
 	require.NoError(t, dbTx.Commit(ctx))
 
-	err = testState.WaitSequencingTxToBeSynced(ctx, tx, 5*time.Second)
+	progress, err := testState.WaitSequencingTxToBeSynced(ctx, tx, 5*time.Second)
 	require.NoError(t, err)
+	assert.Equal(t, uint64(1), progress.LastSyncedBatchNumber)
 
 	// VerifiedBatch
-	err = testState.WaitVerifiedBatchToBeSynced(ctx, 1, 1*time.Second)
-	require.Error(t, err)
+	progress, err = testState.WaitVerifiedBatchToBeSynced(ctx, 1, 1*time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, uint64(0), progress.LastSyncedBatchNumber)
+	assert.GreaterOrEqual(t, progress.Elapsed, time.Second)
 
 	verifiedBatch := state.VerifiedBatch{
 		BlockNumber: 0,
@@ -2561,8 +2567,9 @@ func TestWaitSequencingTxToBeSyncedAndWaitVerifiedBatchToBeSynced(t *testing.T)
 	err = testState.AddVerifiedBatch(ctx, &verifiedBatch, nil)
 	require.NoError(t, err)
 
-	err = testState.WaitVerifiedBatchToBeSynced(ctx, 1, 5*time.Second)
+	progress, err = testState.WaitVerifiedBatchToBeSynced(ctx, 1, 5*time.Second)
 	require.NoError(t, err)
+	assert.Equal(t, uint64(1), progress.LastSyncedBatchNumber)
 }
 
 func TestStoreDebugInfo(t *testing.T) {
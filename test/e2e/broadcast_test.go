@@ -89,7 +89,10 @@ func initState() (*state.State, error) {
 	executorUri := testutils.GetEnv(constants.ENV_ZKPROVER_URI, "127.0.0.1:50071")
 	merkleTreeUri := testutils.GetEnv(constants.ENV_MERKLETREE_URI, "127.0.0.1:50061")
 	executorClient, _, _ := executor.NewExecutorClient(ctx, executor.Config{URI: executorUri})
-	mtDBClient, _, _ := merkletree.NewMTDBServiceClient(ctx, merkletree.Config{URI: merkleTreeUri})
+	mtDBClient, _, _, err := merkletree.NewMTDBServiceClient(ctx, merkletree.Config{URI: merkleTreeUri})
+	if err != nil {
+		return nil, err
+	}
 	stateTree := merkletree.NewStateTree(mtDBClient)
 	return state.NewState(state.Config{}, stateDb, executorClient, stateTree), nil
 }
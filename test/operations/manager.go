@@ -327,7 +327,10 @@ func initState(maxCumulativeGasUsed uint64) (*state.State, error) {
 	ctx := context.Background()
 	stateDb := state.NewPostgresStorage(sqlDB)
 	executorClient, _, _ := executor.NewExecutorClient(ctx, executorConfig)
-	stateDBClient, _, _ := merkletree.NewMTDBServiceClient(ctx, merkleTreeConfig)
+	stateDBClient, _, _, err := merkletree.NewMTDBServiceClient(ctx, merkleTreeConfig)
+	if err != nil {
+		return nil, err
+	}
 	stateTree := merkletree.NewStateTree(stateDBClient)
 
 	stateCfg := state.Config{